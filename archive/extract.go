@@ -2,10 +2,12 @@ package archive
 
 import (
 	"archive/tar"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/pkg/errors"
@@ -42,6 +44,17 @@ func unsetUmaskIfNeeded() {
 
 // Extract reads all entries from TarReader and extracts them to the filesystem.
 func Extract(tr TarReader) error {
+	return extract(tr, "")
+}
+
+// ExtractToOverlay reads all entries from TarReader and extracts them beneath upperDir,
+// treating the paths of the entries on the filesystem as a read-only lower directory.
+// Regular files that already exist in the lower directory with the same mode and contents are not written.
+func ExtractToOverlay(tr TarReader, upperDir string) error {
+	return extract(tr, upperDir)
+}
+
+func extract(tr TarReader, upperDir string) error {
 	setUmaskIfNeeded()
 	defer unsetUmaskIfNeeded()
 
@@ -63,6 +76,11 @@ func Extract(tr TarReader) error {
 			return errors.Wrap(err, "error extracting from archive")
 		}
 
+		lowerPath := hdr.Name
+		if upperDir != "" {
+			hdr.Name = overlayPath(upperDir, lowerPath)
+		}
+
 		switch hdr.Typeflag {
 		case tar.TypeDir:
 			if _, err := os.Stat(hdr.Name); os.IsNotExist(err) {
@@ -85,6 +103,12 @@ func Extract(tr TarReader) error {
 				}
 			}
 
+			if upperDir != "" {
+				if err := writeOverlayFile(tr, lowerPath, hdr.Name, hdr.FileInfo().Mode(), hdr.Size, buf); err != nil {
+					return errors.Wrapf(err, "failed to write file %q", hdr.Name)
+				}
+				continue
+			}
 			if err := writeFile(tr, hdr.Name, hdr.FileInfo().Mode(), buf); err != nil {
 				return errors.Wrapf(err, "failed to write file %q", hdr.Name)
 			}
@@ -118,3 +142,48 @@ func writeFile(in io.Reader, path string, mode os.FileMode, buf []byte) (err err
 	_, err = io.CopyBuffer(fh, in, buf)
 	return err
 }
+
+func overlayPath(upperDir, path string) string {
+	return filepath.Join(upperDir, strings.TrimPrefix(path, filepath.VolumeName(path)))
+}
+
+// writeOverlayFile writes the contents of in to upperPath, unless the file at lowerPath has the same mode and contents.
+func writeOverlayFile(in io.Reader, lowerPath, upperPath string, mode os.FileMode, size int64, buf []byte) error {
+	lower, err := os.Open(lowerPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return writeFile(in, upperPath, mode, buf)
+		}
+		return err
+	}
+	defer lower.Close()
+
+	fi, err := lower.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() || fi.Mode().Perm() != mode.Perm() || fi.Size() != size {
+		return writeFile(in, upperPath, mode, buf)
+	}
+
+	inBuf, lowerBuf := buf[:len(buf)/2], buf[len(buf)/2:]
+	var offset int64
+	for {
+		n, err := io.ReadFull(in, inBuf)
+		if err == io.EOF {
+			return nil // identical
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		if _, err := io.ReadFull(lower, lowerBuf[:n]); err != nil || !bytes.Equal(inBuf[:n], lowerBuf[:n]) {
+			// the contents differ: write the identical prefix from the lower file, followed by the remaining contents of in
+			if _, err := lower.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			pending := append([]byte(nil), inBuf[:n]...)
+			return writeFile(io.MultiReader(io.LimitReader(lower, offset), bytes.NewReader(pending), in), upperPath, mode, buf)
+		}
+		offset += int64(n)
+	}
+}
@@ -2,9 +2,11 @@ package archive_test
 
 import (
 	"archive/tar"
+	"bytes"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/sclevine/spec"
@@ -118,6 +120,70 @@ func testExtract(t *testing.T, when spec.G, it spec.S) {
 			}
 		})
 	})
+
+	when("#ExtractToOverlay", func() {
+		var lowerDir, upperDir string
+
+		it.Before(func() {
+			var err error
+			lowerDir, err = os.MkdirTemp("", "archive-extract-lower")
+			h.AssertNil(t, err)
+			upperDir, err = os.MkdirTemp("", "archive-extract-upper")
+			h.AssertNil(t, err)
+
+			h.Mkdir(t, filepath.Join(lowerDir, "root"))
+			h.AssertNil(t, os.WriteFile(filepath.Join(lowerDir, "root", "unchanged"), []byte("some-contents"), 0644))
+			h.AssertNil(t, os.WriteFile(filepath.Join(lowerDir, "root", "changed"), []byte("some-contents"), 0644))
+		})
+
+		it.After(func() {
+			h.AssertNil(t, os.RemoveAll(lowerDir))
+			h.AssertNil(t, os.RemoveAll(upperDir))
+		})
+
+		it("writes new and changed files to the upper directory and skips identical files", func() {
+			otr := newOverlayTarReader(t, lowerDir, map[string]string{
+				"unchanged": "some-contents",
+				"changed":   "some-contentz",
+				"new":       "some-new-contents",
+			})
+
+			h.AssertNil(t, archive.ExtractToOverlay(otr, upperDir))
+
+			upperRoot := filepath.Join(upperDir, strings.TrimPrefix(lowerDir, filepath.VolumeName(lowerDir)), "root")
+			h.AssertPathDoesNotExist(t, filepath.Join(upperRoot, "unchanged"))
+			h.AssertEq(t, string(h.MustReadFile(t, filepath.Join(upperRoot, "changed"))), "some-contentz")
+			h.AssertEq(t, string(h.MustReadFile(t, filepath.Join(upperRoot, "new"))), "some-new-contents")
+
+			t.Log("does not modify the lower directory")
+			h.AssertEq(t, string(h.MustReadFile(t, filepath.Join(lowerDir, "root", "changed"))), "some-contents")
+			h.AssertPathDoesNotExist(t, filepath.Join(lowerDir, "root", "new"))
+		})
+	})
+}
+
+func newOverlayTarReader(t *testing.T, lowerDir string, files map[string]string) *archive.NormalizingTarReader {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, name := range []string{"unchanged", "changed", "new"} {
+		contents, ok := files[name]
+		if !ok {
+			continue
+		}
+		h.AssertNil(t, tw.WriteHeader(&tar.Header{
+			Name:     filepath.ToSlash(filepath.Join("root", name)),
+			Typeflag: tar.TypeReg,
+			Mode:     int64(0644),
+			Size:     int64(len(contents)),
+		}))
+		_, err := tw.Write([]byte(contents))
+		h.AssertNil(t, err)
+	}
+	h.AssertNil(t, tw.Close())
+	tr := archive.NewNormalizingTarReader(tar.NewReader(buf))
+	tr.PrependDir(lowerDir)
+	return tr
 }
 
 func newFakeTarReader(t *testing.T) (*archive.NormalizingTarReader, string) {
@@ -23,7 +23,8 @@ type TarWriter interface {
 // Other modifications can be enabled by invoking options on the NormalizingTarWriter
 type NormalizingTarWriter struct {
 	TarWriter
-	headerOpts []HeaderOpt
+	headerOpts      []HeaderOpt
+	originalModTime bool
 }
 
 type HeaderOpt func(header *tar.Header) *tar.Header
@@ -52,18 +53,28 @@ func (tw *NormalizingTarWriter) WithModTime(modTime time.Time) {
 	})
 }
 
+// WithOriginalModTime keeps the ModTime of any subsequently written *tar.Header, truncated to the second,
+// overriding WithModTime. Archives written with this option are not reproducible.
+func (tw *NormalizingTarWriter) WithOriginalModTime() {
+	tw.originalModTime = true
+}
+
 // NewNormalizingTarWriter creates a NormalizingTarWriter that wraps the provided TarWriter
 func NewNormalizingTarWriter(tw TarWriter) *NormalizingTarWriter {
-	return &NormalizingTarWriter{tw, []HeaderOpt{}}
+	return &NormalizingTarWriter{TarWriter: tw, headerOpts: []HeaderOpt{}}
 }
 
 // WriteHeader writes the header to the wrapped TarWriter after applying standard and configured modifications
 // Modification options will be apply in the order the options were invoked.
 // Standard modification (ModTime, Uname, and Gname) are applied last.
 func (tw *NormalizingTarWriter) WriteHeader(hdr *tar.Header) error {
+	modTime := hdr.ModTime
 	for _, opt := range tw.headerOpts {
 		hdr = opt(hdr)
 	}
+	if tw.originalModTime {
+		hdr.ModTime = modTime.Truncate(time.Second)
+	}
 	hdr.Name = filepath.ToSlash(strings.TrimPrefix(hdr.Name, filepath.VolumeName(hdr.Name)))
 	hdr.Uname = ""
 	hdr.Gname = ""
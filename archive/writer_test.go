@@ -84,6 +84,20 @@ func testNormalizingTarWriter(t *testing.T, when spec.G, it spec.S) {
 				}
 			})
 		})
+
+		when("#WithOriginalModTime", func() {
+			it("keeps the mod time truncated to the second", func() {
+				ntw.WithModTime(archive.NormalizedModTime)
+				ntw.WithOriginalModTime()
+				modTime := time.Date(2023, time.March, 4, 5, 6, 7, 8, time.UTC)
+				h.AssertNil(t, ntw.WriteHeader(&tar.Header{
+					ModTime: modTime,
+				}))
+				if !ftw.getLastHeader().ModTime.Equal(time.Date(2023, time.March, 4, 5, 6, 7, 0, time.UTC)) {
+					t.Fatalf("expected the original mod time, got '%s'", ftw.getLastHeader().ModTime)
+				}
+			})
+		})
 	})
 }
 
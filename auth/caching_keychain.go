@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultCredentialTTL is how long credentials resolved by a CachingKeychain are used before they are resolved again.
+// It is shorter than the lifetime of the tokens returned by common credential helpers (e.g., 12 hours for Amazon ECR).
+const DefaultCredentialTTL = 10 * time.Minute
+
+// CachingKeychain is an implementation of authn.Keychain that caches the credentials resolved by the wrapped keychain
+// for each registry, so that long-running processes do not invoke credential helpers for every registry operation,
+// while still picking up refreshed credentials once the cached ones expire.
+// Concurrent resolutions of the credentials for a registry result in a single resolution by the wrapped keychain.
+type CachingKeychain struct {
+	Keychain authn.Keychain
+	TTL      time.Duration
+
+	group   singleflight.Group
+	mu      sync.Mutex
+	entries map[string]cachedCredentials
+	realms  map[string]map[string]bool // the registries that challenged to authorize with each token server
+}
+
+type cachedCredentials struct {
+	config    *authn.AuthConfig // nil for anonymous access
+	expiresAt time.Time
+}
+
+// NewCachingKeychain returns a CachingKeychain that wraps the provided keychain,
+// caching resolved credentials for the provided TTL (or DefaultCredentialTTL if not positive).
+func NewCachingKeychain(keychain authn.Keychain, ttl time.Duration) *CachingKeychain {
+	if ttl <= 0 {
+		ttl = DefaultCredentialTTL
+	}
+	return &CachingKeychain{
+		Keychain: keychain,
+		TTL:      ttl,
+		entries:  map[string]cachedCredentials{},
+		realms:   map[string]map[string]bool{},
+	}
+}
+
+func (k *CachingKeychain) Resolve(resource authn.Resource) (authn.Authenticator, error) {
+	config, err := k.credentials(resource)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return authn.Anonymous, nil
+	}
+	return &cachingAuth{keychain: k, resource: resource}, nil
+}
+
+// Invalidate removes the cached credentials for the provided registry, if any,
+// so that they are resolved again the next time they are needed.
+func (k *CachingKeychain) Invalidate(registry string) {
+	k.mu.Lock()
+	delete(k.entries, registry)
+	k.mu.Unlock()
+}
+
+// Transport wraps the provided transport so that the cached credentials for a registry are invalidated
+// when the registry rejects them, i.e., when it responds with 401 Unauthorized to a request that was authorized.
+// Registries that authorize with bearer tokens challenge to fetch a token from a token server (the realm, e.g., auth.docker.io),
+// which is what rejects the credentials; a rejection by a token server invalidates the credentials of the registries it serves.
+func (k *CachingKeychain) Transport(base http.RoundTripper) http.RoundTripper {
+	return &invalidatingTransport{base: base, keychain: k}
+}
+
+// credentials returns the cached credentials for the registry of the provided resource,
+// resolving them from the wrapped keychain if they are not cached or have expired.
+func (k *CachingKeychain) credentials(resource authn.Resource) (*authn.AuthConfig, error) {
+	registry := resource.RegistryStr()
+	k.mu.Lock()
+	entry, ok := k.entries[registry]
+	k.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.config, nil
+	}
+
+	config, err, _ := k.group.Do(registry, func() (interface{}, error) {
+		authenticator, err := k.Keychain.Resolve(resource)
+		if err != nil {
+			return nil, err
+		}
+		var config *authn.AuthConfig
+		if authenticator != authn.Anonymous {
+			if config, err = authenticator.Authorization(); err != nil {
+				return nil, err
+			}
+		}
+		k.mu.Lock()
+		k.entries[registry] = cachedCredentials{config: config, expiresAt: time.Now().Add(k.TTL)}
+		k.mu.Unlock()
+		return config, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return config.(*authn.AuthConfig), nil
+}
+
+// cachingAuth is an authn.Authenticator that returns the cached credentials for a resource when authorization is needed,
+// so that credentials that expired after the resource was resolved are refreshed.
+type cachingAuth struct {
+	keychain *CachingKeychain
+	resource authn.Resource
+}
+
+func (a *cachingAuth) Authorization() (*authn.AuthConfig, error) {
+	config, err := a.keychain.credentials(a.resource)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return &authn.AuthConfig{}, nil
+	}
+	return config, nil
+}
+
+type invalidatingTransport struct {
+	base     http.RoundTripper
+	keychain *CachingKeychain
+}
+
+func (t *invalidatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	if realm := bearerRealmHost(resp.Header.Get("WWW-Authenticate")); realm != "" && realm != req.URL.Host {
+		t.keychain.addRealm(realm, req.URL.Host)
+	}
+	// an unauthorized response to an unauthorized request is a challenge to authorize, rather than a rejection of the credentials
+	if req.Header.Get("Authorization") != "" {
+		for _, registry := range t.keychain.registriesFor(req.URL.Host) {
+			t.keychain.Invalidate(registry)
+		}
+	}
+	return resp, nil
+}
+
+func (k *CachingKeychain) addRealm(realm, registry string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.realms[realm] == nil {
+		k.realms[realm] = map[string]bool{}
+	}
+	k.realms[realm][registry] = true
+}
+
+// registriesFor returns the registries whose credentials are rejected by an unauthorized response from the provided host,
+// i.e., the host itself and, if it is a token server, the registries it serves.
+func (k *CachingKeychain) registriesFor(host string) []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	registries := []string{host}
+	for registry := range k.realms[host] {
+		registries = append(registries, registry)
+	}
+	return registries
+}
+
+var bearerRealmRegexp = regexp.MustCompile(`(?i)^\s*bearer\s.*\brealm="([^"]+)"`)
+
+// bearerRealmHost returns the host of the token server in the provided bearer challenge, if any.
+func bearerRealmHost(challenge string) string {
+	matches := bearerRealmRegexp.FindStringSubmatch(challenge)
+	if matches == nil {
+		return ""
+	}
+	realm, err := url.Parse(matches[1])
+	if err != nil {
+		return ""
+	}
+	return realm.Host
+}
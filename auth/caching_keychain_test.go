@@ -0,0 +1,210 @@
+package auth_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/auth"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestCachingKeychain(t *testing.T) {
+	spec.Run(t, "CachingKeychain", testCachingKeychain, spec.Report(report.Terminal{}))
+}
+
+func testCachingKeychain(t *testing.T, when spec.G, it spec.S) {
+	var (
+		wrapped  *countingKeychain
+		keychain *auth.CachingKeychain
+		registry name.Registry
+	)
+
+	it.Before(func() {
+		wrapped = &countingKeychain{config: &authn.AuthConfig{Username: "some-username", Password: "some-password"}}
+		keychain = auth.NewCachingKeychain(wrapped, time.Hour)
+		var err error
+		registry, err = name.NewRegistry("some-registry.io", name.WeakValidation)
+		h.AssertNil(t, err)
+	})
+
+	resolve := func() *authn.AuthConfig {
+		authenticator, err := keychain.Resolve(registry)
+		h.AssertNil(t, err)
+		authConfig, err := authenticator.Authorization()
+		h.AssertNil(t, err)
+		return authConfig
+	}
+
+	when("#Resolve", func() {
+		it("resolves credentials from the wrapped keychain once", func() {
+			h.AssertEq(t, resolve(), &authn.AuthConfig{Username: "some-username", Password: "some-password"})
+			h.AssertEq(t, resolve(), &authn.AuthConfig{Username: "some-username", Password: "some-password"})
+			h.AssertEq(t, wrapped.count(), 1)
+		})
+
+		it("resolves credentials concurrently once", func() {
+			wrapped.delay = 50 * time.Millisecond
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, _ = keychain.Resolve(registry)
+				}()
+			}
+			wg.Wait()
+			h.AssertEq(t, wrapped.count(), 1)
+		})
+
+		when("the credentials have expired", func() {
+			it("resolves them again", func() {
+				keychain = auth.NewCachingKeychain(wrapped, time.Millisecond)
+				resolve()
+				time.Sleep(10 * time.Millisecond)
+				wrapped.setConfig(&authn.AuthConfig{RegistryToken: "some-refreshed-token"})
+
+				h.AssertEq(t, resolve(), &authn.AuthConfig{RegistryToken: "some-refreshed-token"})
+				h.AssertEq(t, wrapped.count(), 2)
+			})
+		})
+
+		when("the wrapped keychain resolves to anonymous", func() {
+			it("returns anonymous", func() {
+				wrapped.config = nil
+
+				authenticator, err := keychain.Resolve(registry)
+				h.AssertNil(t, err)
+				h.AssertEq(t, authenticator, authn.Anonymous)
+			})
+		})
+	})
+
+	when("#Invalidate", func() {
+		it("resolves the credentials of the registry again", func() {
+			resolve()
+			keychain.Invalidate("some-registry.io")
+			resolve()
+			h.AssertEq(t, wrapped.count(), 2)
+		})
+	})
+
+	when("#Transport", func() {
+		var server *httptest.Server
+
+		it.Before(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+			}))
+			var err error
+			registry, err = name.NewRegistry(server.Listener.Addr().String(), name.WeakValidation)
+			h.AssertNil(t, err)
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		roundTrip := func(authorization string) {
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/v2/", nil)
+			h.AssertNil(t, err)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			resp, err := keychain.Transport(http.DefaultTransport).RoundTrip(req)
+			h.AssertNil(t, err)
+			resp.Body.Close()
+		}
+
+		when("an authorized request is rejected", func() {
+			it("invalidates the credentials of the registry", func() {
+				resolve()
+				roundTrip("Basic some-credentials")
+				resolve()
+				h.AssertEq(t, wrapped.count(), 2)
+			})
+		})
+
+		when("an unauthorized request is challenged", func() {
+			it("keeps the credentials of the registry", func() {
+				resolve()
+				roundTrip("")
+				resolve()
+				h.AssertEq(t, wrapped.count(), 1)
+			})
+		})
+
+		when("the registry authorizes with bearer tokens", func() {
+			var tokenServer *httptest.Server
+
+			it.Before(func() {
+				tokenServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusUnauthorized)
+				}))
+				server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="some-service"`, tokenServer.URL))
+					w.WriteHeader(http.StatusUnauthorized)
+				})
+			})
+
+			it.After(func() {
+				tokenServer.Close()
+			})
+
+			when("the token server rejects the credentials", func() {
+				it("invalidates the credentials of the registry", func() {
+					authenticator, err := keychain.Resolve(registry)
+					h.AssertNil(t, err)
+
+					_, err = transport.NewWithContext(context.Background(), registry, authenticator, keychain.Transport(http.DefaultTransport), []string{registry.Scope(transport.PullScope)})
+					h.AssertNotNil(t, err)
+
+					resolve()
+					h.AssertEq(t, wrapped.count(), 2)
+				})
+			})
+		})
+	})
+}
+
+// countingKeychain resolves the provided credentials for every registry, counting the resolutions.
+type countingKeychain struct {
+	delay time.Duration
+
+	mu          sync.Mutex
+	config      *authn.AuthConfig
+	resolutions int
+}
+
+func (k *countingKeychain) Resolve(authn.Resource) (authn.Authenticator, error) {
+	time.Sleep(k.delay)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.resolutions++
+	if k.config == nil {
+		return authn.Anonymous, nil
+	}
+	return authn.FromConfig(*k.config), nil
+}
+
+func (k *countingKeychain) setConfig(config *authn.AuthConfig) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.config = config
+}
+
+func (k *countingKeychain) count() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.resolutions
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+)
+
+// EnvCredHelpersDir is a directory that is searched for `docker-credential-<helper>` binaries
+// before the directories in PATH.
+const EnvCredHelpersDir = "CNB_CRED_HELPERS_DIR"
+
+const credHelperPrefix = "docker-credential-"
+
+// dockerConfig holds the fields of the docker config.json file that provide credentials.
+type dockerConfig struct {
+	Auths       map[string]authn.AuthConfig `json:"auths"`
+	CredHelpers map[string]string           `json:"credHelpers"`
+	CredsStore  string                      `json:"credsStore"`
+}
+
+// helperFor returns the name of the credential helper used for the provided registry, if any,
+// following the precedence of DockerConfigKeychain.
+func (c dockerConfig) helperFor(registry string) string {
+	if helper, ok := c.CredHelpers[configKeyFor(registry)]; ok {
+		return helper
+	}
+	if _, ok := c.authFor(registry); ok {
+		return ""
+	}
+	return c.CredsStore
+}
+
+// readDockerConfig reads the docker config.json file in the directory provided by DOCKER_CONFIG (or `~/.docker`),
+// falling back to the podman auth file `$XDG_RUNTIME_DIR/containers/auth.json` if the docker config does not exist.
+// Like authn.DefaultKeychain, a file that cannot be parsed is logged and ignored.
+func readDockerConfig() (dockerConfig, error) {
+	var paths []string
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".docker")
+		}
+	}
+	if dir != "" {
+		paths = append(paths, filepath.Join(dir, "config.json"))
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		paths = append(paths, filepath.Join(runtimeDir, "containers", "auth.json"))
+	}
+	for _, path := range paths {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return dockerConfig{}, err
+		}
+		var config dockerConfig
+		if err := json.Unmarshal(contents, &config); err != nil {
+			logs.Warn.Printf("failed to parse %s: %v", path, err)
+			return dockerConfig{}, nil
+		}
+		return config, nil
+	}
+	return dockerConfig{}, nil
+}
+
+// credHelperPath returns the absolute path of the `docker-credential-<helper>` binary,
+// looking in the directory provided by CNB_CRED_HELPERS_DIR (if any) and then in PATH.
+// Helpers are executed by path so that PATH is left unchanged for the rest of the process, e.g., for buildpacks.
+func credHelperPath(helper string) (string, error) {
+	binary := credHelperPrefix + helper
+	if dir := os.Getenv(EnvCredHelpersDir); dir != "" {
+		if path, err := exec.LookPath(filepath.Join(dir, binary)); err == nil {
+			return filepath.Abs(path)
+		}
+	}
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return "", errors.Errorf("credential helper '%s' was not found in PATH or %s", binary, EnvCredHelpersDir)
+	}
+	return filepath.Abs(path)
+}
+
+// imagesWithoutCredentials returns the provided images whose registries have no credentials in the provided keychain.
+func imagesWithoutCredentials(keychain authn.Keychain, images ...string) []string {
+	var filtered []string
+	for _, image := range images {
+		ref, err := name.ParseReference(image, name.WeakValidation)
+		if err != nil {
+			filtered = append(filtered, image)
+			continue
+		}
+		if authenticator, err := keychain.Resolve(ref.Context()); err == nil && authenticator != authn.Anonymous {
+			continue
+		}
+		filtered = append(filtered, image)
+	}
+	return filtered
+}
+
+// checkCredHelpers returns an error naming the credential helper and registry
+// if a credential helper configured in the docker config.json file for any of the provided images cannot be found
+// or fails to resolve credentials.
+// Without this check, such failures are indistinguishable from the registry not requiring credentials.
+func checkCredHelpers(keychain *DockerConfigKeychain, images ...string) error {
+	for _, image := range images {
+		ref, err := name.ParseReference(image, name.WeakValidation)
+		if err != nil {
+			continue
+		}
+		registry := ref.Context().RegistryStr()
+		helper := keychain.config.helperFor(registry)
+		if helper == "" {
+			continue
+		}
+		binary := credHelperPrefix + helper
+		if _, err := credHelperPath(helper); err != nil {
+			return errors.Errorf("credential helper '%s' for registry '%s' was not found in PATH or %s", binary, registry, EnvCredHelpersDir)
+		}
+		if _, err := keychain.Resolve(ref.Context()); err != nil {
+			return errors.Wrapf(err, "credential helper '%s' failed for registry '%s'", binary, registry)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"sort"
+
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/docker/docker/registry"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+)
+
+// tokenUsername is the username returned by credential helpers for identity tokens.
+const tokenUsername = "<token>"
+
+// DockerConfigKeychain is an implementation of authn.Keychain that resolves credentials from the docker config.json file
+// in the directory provided by DOCKER_CONFIG (or `~/.docker`), e.g., a `.dockerconfigjson` mounted from a Kubernetes secret.
+// For each registry, it uses the first of the following that applies:
+// the credential helper for the registry in `credHelpers`;
+// the credentials for the registry in `auths`, preferring the entry whose key is the registry hostname
+// over entries whose keys are URLs for the registry (e.g., `https://some-registry.io/v1/`);
+// the credential helper in `credsStore`.
+// Unlike authn.DefaultKeychain, credentials in `auths` are used even if `credsStore` is set,
+// and the entry that is used does not depend on the order of the entries in the file.
+type DockerConfigKeychain struct {
+	config dockerConfig
+}
+
+// NewDockerConfigKeychain returns a DockerConfigKeychain for the docker config.json file (or the podman auth file), if it exists.
+func NewDockerConfigKeychain() (*DockerConfigKeychain, error) {
+	config, err := readDockerConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading docker config")
+	}
+	return &DockerConfigKeychain{config: config}, nil
+}
+
+func (k *DockerConfigKeychain) Resolve(resource authn.Resource) (authn.Authenticator, error) {
+	serverURL := configKeyFor(resource.RegistryStr())
+	if helper, ok := k.config.CredHelpers[serverURL]; ok {
+		return resolveWithHelper(helper, serverURL)
+	}
+	if authConfig, ok := k.config.authFor(resource.RegistryStr()); ok {
+		return authn.FromConfig(authConfig), nil
+	}
+	if k.config.CredsStore != "" {
+		return resolveWithHelper(k.config.CredsStore, serverURL)
+	}
+	return authn.Anonymous, nil
+}
+
+// configKeyFor returns the key of the provided registry in the docker config.json file,
+// which is `https://index.docker.io/v1/` for Docker Hub, as written by `docker login`.
+func configKeyFor(registry string) string {
+	if registry == name.DefaultRegistry {
+		return authn.DefaultAuthKey
+	}
+	return registry
+}
+
+// authFor returns the non-empty credentials in `auths` for the provided registry, if any.
+// An entry whose key is the registry (or the Docker Hub key) is preferred; otherwise, the first entry in sorted order
+// whose key is a URL for the registry is used.
+func (c dockerConfig) authFor(registryStr string) (authn.AuthConfig, bool) {
+	for _, key := range []string{registryStr, configKeyFor(registryStr)} {
+		if authConfig, ok := c.Auths[key]; ok && authConfig != (authn.AuthConfig{}) {
+			return authConfig, true
+		}
+	}
+	var keys []string
+	for key := range c.Auths {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if authConfig := c.Auths[key]; registry.ConvertToHostname(key) == registryStr && authConfig != (authn.AuthConfig{}) {
+			return authConfig, true
+		}
+	}
+	return authn.AuthConfig{}, false
+}
+
+// resolveWithHelper returns the credentials for the provided registry from the credential helper with the provided name.
+// If the helper does not have credentials for the registry, it returns authn.Anonymous.
+func resolveWithHelper(helper, serverURL string) (authn.Authenticator, error) {
+	path, err := credHelperPath(helper)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := client.Get(client.NewShellProgramFunc(path), serverURL)
+	if err != nil {
+		if credentials.IsErrCredentialsNotFound(err) {
+			return authn.Anonymous, nil
+		}
+		return nil, errors.Wrapf(err, "getting credentials from credential helper '%s'", credHelperPrefix+helper)
+	}
+	if creds.Username == tokenUsername {
+		return authn.FromConfig(authn.AuthConfig{IdentityToken: creds.Secret}), nil
+	}
+	return authn.FromConfig(authn.AuthConfig{Username: creds.Username, Password: creds.Secret}), nil
+}
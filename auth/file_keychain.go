@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/docker/docker/registry"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/pkg/errors"
+)
+
+// envRegistryAuthFile is the path to a file that maps registry hostnames to `<username>:<password>` credentials;
+// it is read by DefaultKeychain, and is documented as platform.EnvRegistryAuthFile.
+const envRegistryAuthFile = "CNB_REGISTRY_AUTH_FILE"
+
+// NewFileKeychain returns an authn.Keychain that uses the provided file as a source of credentials.
+// The file should be a JSON object (if it has a `.json` extension) or a TOML table
+// that maps OCI registry hostnames to credentials of the form `<username>:<password>`, e.g.:
+//
+//	"some-registry.io" = "some-user:some-token"
+func NewFileKeychain(path string) (authn.Keychain, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading auth file")
+	}
+	rawCredentials := map[string]string{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(contents, &rawCredentials)
+	} else {
+		_, err = toml.Decode(string(contents), &rawCredentials)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse auth file '%s'", path)
+	}
+
+	authConfigs := map[string]*authn.AuthConfig{}
+	for reg, credentials := range rawCredentials {
+		username, password, ok := strings.Cut(credentials, ":")
+		if !ok || username == "" {
+			// don't print the credentials in the error message
+			return nil, errors.Errorf("failed to parse credentials for registry '%s' in auth file '%s': expected '<username>:<password>'", reg, path)
+		}
+		authConfigs[registry.ConvertToHostname(reg)] = &authn.AuthConfig{
+			Username: username,
+			Password: password,
+		}
+	}
+	return &ResolvedKeychain{AuthConfigs: authConfigs}, nil
+}
@@ -0,0 +1,100 @@
+package auth_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/auth"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestFileKeychain(t *testing.T) {
+	spec.Run(t, "FileKeychain", testFileKeychain, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testFileKeychain(t *testing.T, when spec.G, it spec.S) {
+	var tmpDir string
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "file-keychain")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		h.AssertNil(t, os.RemoveAll(tmpDir))
+	})
+
+	resolve := func(keychain authn.Keychain, reg string) *authn.AuthConfig {
+		registry, err := name.NewRegistry(reg, name.WeakValidation)
+		h.AssertNil(t, err)
+		authenticator, err := keychain.Resolve(registry)
+		h.AssertNil(t, err)
+		authConfig, err := authenticator.Authorization()
+		h.AssertNil(t, err)
+		return authConfig
+	}
+
+	when("#NewFileKeychain", func() {
+		when("the file is TOML", func() {
+			it("returns a keychain with credentials from the file", func() {
+				path := filepath.Join(tmpDir, "auth.toml")
+				h.AssertNil(t, os.WriteFile(path, []byte(`"https://some-registry.io/v1/" = "some-user:some-token"`), 0600))
+
+				keychain, err := auth.NewFileKeychain(path)
+				h.AssertNil(t, err)
+
+				h.AssertEq(t, resolve(keychain, "some-registry.io"), &authn.AuthConfig{Username: "some-user", Password: "some-token"})
+				h.AssertEq(t, resolve(keychain, "other-registry.io"), &authn.AuthConfig{})
+			})
+		})
+
+		when("the file is JSON", func() {
+			it("returns a keychain with credentials from the file", func() {
+				path := filepath.Join(tmpDir, "auth.json")
+				h.AssertNil(t, os.WriteFile(path, []byte(`{"some-registry.io": "some-user:some:token"}`), 0600))
+
+				keychain, err := auth.NewFileKeychain(path)
+				h.AssertNil(t, err)
+
+				h.AssertEq(t, resolve(keychain, "some-registry.io"), &authn.AuthConfig{Username: "some-user", Password: "some:token"})
+			})
+		})
+
+		when("the credentials are invalid", func() {
+			it("returns an error without the credentials", func() {
+				path := filepath.Join(tmpDir, "auth.json")
+				h.AssertNil(t, os.WriteFile(path, []byte(`{"some-registry.io": "some-token"}`), 0600))
+
+				_, err := auth.NewFileKeychain(path)
+				h.AssertError(t, err, "failed to parse credentials for registry 'some-registry.io'")
+				h.AssertStringDoesNotContain(t, err.Error(), "some-token")
+			})
+		})
+
+		when("the file does not exist", func() {
+			it("returns an error", func() {
+				_, err := auth.NewFileKeychain(filepath.Join(tmpDir, "missing.toml"))
+				h.AssertError(t, err, "reading auth file")
+			})
+		})
+	})
+
+	when("#DefaultKeychainWithAuthFile", func() {
+		it("uses credentials from the file", func() {
+			path := filepath.Join(tmpDir, "auth.toml")
+			h.AssertNil(t, os.WriteFile(path, []byte(`"some-registry.io" = "some-user:some-token"`), 0600))
+
+			keychain, err := auth.DefaultKeychainWithAuthFile(path, "some-registry.io/some-image")
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, resolve(keychain, "some-registry.io"), &authn.AuthConfig{Username: "some-user", Password: "some-token"})
+		})
+	})
+}
@@ -26,17 +26,43 @@ var (
 // DefaultKeychain returns a keychain containing authentication configuration for the given images
 // from the following sources, if they exist, in order of precedence:
 // the provided environment variable
-// the docker config.json file
+// the docker config.json file (see DockerConfigKeychain)
+// the file provided by CNB_REGISTRY_AUTH_FILE
 // credential helpers for Amazon and Azure
+// Credential helpers configured in the docker config.json file are looked up in the directory provided by CNB_CRED_HELPERS_DIR (if any)
+// and then in PATH; an error is returned if a helper needed for the given images is missing or fails,
+// unless the environment variable provides credentials for the image's registry.
 func DefaultKeychain(images ...string) (authn.Keychain, error) {
+	return DefaultKeychainWithAuthFile(os.Getenv(envRegistryAuthFile), images...)
+}
+
+// DefaultKeychainWithAuthFile is like DefaultKeychain, but reads credentials from the provided auth file (if not empty)
+// instead of the file provided by CNB_REGISTRY_AUTH_FILE.
+func DefaultKeychainWithAuthFile(authFile string, images ...string) (authn.Keychain, error) {
 	envKeychain, err := NewEnvKeychain(EnvRegistryAuth)
 	if err != nil {
 		return nil, err
 	}
+	fileKeychain := authn.Keychain(&ResolvedKeychain{})
+	if authFile != "" {
+		if fileKeychain, err = NewFileKeychain(authFile); err != nil {
+			return nil, err
+		}
+	}
+	dockerConfigKeychain, err := NewDockerConfigKeychain()
+	if err != nil {
+		return nil, err
+	}
+	// credentials provided by the environment take precedence, so credential helpers are not needed for their registries
+	dockerConfigImages := imagesWithoutCredentials(envKeychain, images...)
+	if err := checkCredHelpers(dockerConfigKeychain, dockerConfigImages...); err != nil {
+		return nil, err
+	}
 
 	return authn.NewMultiKeychain(
 		envKeychain,
-		NewResolvedKeychain(authn.DefaultKeychain, images...),
+		NewResolvedKeychain(dockerConfigKeychain, dockerConfigImages...),
+		fileKeychain,
 		NewResolvedKeychain(amazonKeychain, images...),
 		NewResolvedKeychain(azureKeychain, images...),
 	), nil
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/authn"
@@ -371,6 +373,224 @@ func testEnvKeychain(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#DefaultKeychain", func() {
+		var (
+			tmpDir       string
+			originalPath = os.Getenv("PATH")
+		)
+
+		it.Before(func() {
+			if runtime.GOOS == "windows" {
+				t.Skip("credential helper fixtures are shell scripts")
+			}
+			var err error
+			tmpDir, err = os.MkdirTemp("", "cred-helpers")
+			h.AssertNil(t, err)
+			h.AssertNil(t, os.Setenv("DOCKER_CONFIG", tmpDir))
+			h.AssertNil(t, os.WriteFile(
+				filepath.Join(tmpDir, "config.json"),
+				[]byte(`{"credHelpers": {"some-registry.com": "some-helper"}}`),
+				0600,
+			))
+		})
+
+		it.After(func() {
+			h.AssertNil(t, os.Unsetenv("DOCKER_CONFIG"))
+			h.AssertNil(t, os.Unsetenv(auth.EnvCredHelpersDir))
+			h.AssertNil(t, os.Setenv("PATH", originalPath))
+			h.AssertNil(t, os.RemoveAll(tmpDir))
+		})
+
+		when("the credential helper is in CNB_CRED_HELPERS_DIR", func() {
+			it.Before(func() {
+				helpersDir := filepath.Join(tmpDir, "helpers")
+				h.AssertNil(t, os.MkdirAll(helpersDir, 0755))
+				h.AssertNil(t, os.WriteFile(
+					filepath.Join(helpersDir, "docker-credential-some-helper"),
+					[]byte("#!/bin/sh\necho '{\"Username\": \"some-user\", \"Secret\": \"some-secret\"}'\n"),
+					0755, // #nosec G306
+				))
+				h.AssertNil(t, os.Setenv(auth.EnvCredHelpersDir, helpersDir))
+			})
+
+			it("resolves credentials using the helper", func() {
+				keychain, err := auth.DefaultKeychain("some-registry.com/some-image")
+				h.AssertNil(t, err)
+
+				registry, err := name.NewRegistry("some-registry.com", name.WeakValidation)
+				h.AssertNil(t, err)
+				authenticator, err := keychain.Resolve(registry)
+				h.AssertNil(t, err)
+				authConfig, err := authenticator.Authorization()
+				h.AssertNil(t, err)
+				h.AssertEq(t, authConfig, &authn.AuthConfig{Username: "some-user", Password: "some-secret"})
+			})
+
+			it("does not modify PATH", func() {
+				_, err := auth.DefaultKeychain("some-registry.com/some-image")
+				h.AssertNil(t, err)
+				h.AssertEq(t, os.Getenv("PATH"), originalPath)
+			})
+		})
+
+		when("the credential helper cannot be found", func() {
+			it("returns an error naming the helper and registry", func() {
+				_, err := auth.DefaultKeychain("some-registry.com/some-image")
+				h.AssertError(t, err, "credential helper 'docker-credential-some-helper' for registry 'some-registry.com' was not found")
+			})
+
+			it("ignores images on other registries", func() {
+				_, err := auth.DefaultKeychain("other-registry.com/some-image")
+				h.AssertNil(t, err)
+			})
+
+			when("CNB_REGISTRY_AUTH provides credentials for the registry", func() {
+				it.Before(func() {
+					h.AssertNil(t, os.Setenv(auth.EnvRegistryAuth, `{"some-registry.com": "Basic ZW52LXVzZXI6ZW52LXNlY3JldA=="}`))
+				})
+
+				it.After(func() {
+					h.AssertNil(t, os.Unsetenv(auth.EnvRegistryAuth))
+				})
+
+				it("does not require the helper", func() {
+					keychain, err := auth.DefaultKeychain("some-registry.com/some-image")
+					h.AssertNil(t, err)
+
+					registry, err := name.NewRegistry("some-registry.com", name.WeakValidation)
+					h.AssertNil(t, err)
+					authenticator, err := keychain.Resolve(registry)
+					h.AssertNil(t, err)
+					authConfig, err := authenticator.Authorization()
+					h.AssertNil(t, err)
+					h.AssertEq(t, authConfig.Auth, "ZW52LXVzZXI6ZW52LXNlY3JldA==")
+				})
+			})
+		})
+
+		when("the docker config cannot be parsed", func() {
+			it.Before(func() {
+				h.AssertNil(t, os.WriteFile(filepath.Join(tmpDir, "config.json"), []byte(`{"auths": `), 0600))
+			})
+
+			it("ignores the docker config", func() {
+				keychain, err := auth.DefaultKeychain("some-registry.com/some-image")
+				h.AssertNil(t, err)
+
+				registry, err := name.NewRegistry("some-registry.com", name.WeakValidation)
+				h.AssertNil(t, err)
+				authenticator, err := keychain.Resolve(registry)
+				h.AssertNil(t, err)
+				h.AssertEq(t, authenticator, authn.Anonymous)
+			})
+		})
+
+		when("the docker config does not exist", func() {
+			var runtimeDir string
+
+			it.Before(func() {
+				h.AssertNil(t, os.Remove(filepath.Join(tmpDir, "config.json")))
+				runtimeDir = filepath.Join(tmpDir, "runtime")
+				h.AssertNil(t, os.MkdirAll(filepath.Join(runtimeDir, "containers"), 0755))
+				h.AssertNil(t, os.WriteFile(
+					filepath.Join(runtimeDir, "containers", "auth.json"),
+					[]byte(`{"auths": {"some-registry.com": {"username": "podman-user", "password": "podman-secret"}}}`),
+					0600,
+				))
+				h.AssertNil(t, os.Setenv("XDG_RUNTIME_DIR", runtimeDir))
+			})
+
+			it.After(func() {
+				h.AssertNil(t, os.Unsetenv("XDG_RUNTIME_DIR"))
+			})
+
+			it("uses the podman auth file", func() {
+				keychain, err := auth.DefaultKeychain("some-registry.com/some-image")
+				h.AssertNil(t, err)
+
+				registry, err := name.NewRegistry("some-registry.com", name.WeakValidation)
+				h.AssertNil(t, err)
+				authenticator, err := keychain.Resolve(registry)
+				h.AssertNil(t, err)
+				authConfig, err := authenticator.Authorization()
+				h.AssertNil(t, err)
+				h.AssertEq(t, authConfig.Username, "podman-user")
+				h.AssertEq(t, authConfig.Password, "podman-secret")
+			})
+		})
+
+		when("the docker config has auths, credential helpers, and a credential store", func() {
+			resolve := func(keychain authn.Keychain, registryName string) *authn.AuthConfig {
+				registry, err := name.NewRegistry(registryName, name.WeakValidation)
+				h.AssertNil(t, err)
+				authenticator, err := keychain.Resolve(registry)
+				h.AssertNil(t, err)
+				authConfig, err := authenticator.Authorization()
+				h.AssertNil(t, err)
+				return &authn.AuthConfig{Username: authConfig.Username, Password: authConfig.Password}
+			}
+
+			it.Before(func() {
+				helpersDir := filepath.Join(tmpDir, "helpers")
+				h.AssertNil(t, os.MkdirAll(helpersDir, 0755))
+				h.AssertNil(t, os.WriteFile(
+					filepath.Join(helpersDir, "docker-credential-some-helper"),
+					[]byte("#!/bin/sh\necho '{\"Username\": \"helper-user\", \"Secret\": \"helper-secret\"}'\n"),
+					0755, // #nosec G306
+				))
+				// the store returns the server URL it was asked for as the secret
+				h.AssertNil(t, os.WriteFile(
+					filepath.Join(helpersDir, "docker-credential-some-store"),
+					[]byte("#!/bin/sh\nread url\necho \"{\\\"Username\\\": \\\"store-user\\\", \\\"Secret\\\": \\\"$url\\\"}\"\n"),
+					0755, // #nosec G306
+				))
+				h.AssertNil(t, os.Setenv(auth.EnvCredHelpersDir, helpersDir))
+				h.AssertNil(t, os.WriteFile(
+					filepath.Join(tmpDir, "config.json"),
+					[]byte(`{
+  "auths": {
+    "auth-registry.com": {"auth": "YXV0aC11c2VyOmF1dGgtc2VjcmV0"},
+    "https://url-registry.com/v1/": {"username": "url-user", "password": "url-secret"},
+    "https://exact-registry.com/v1/": {"username": "url-user", "password": "url-secret"},
+    "exact-registry.com": {"username": "exact-user", "password": "exact-secret"},
+    "stored-registry.com": {}
+  },
+  "credHelpers": {"helper-registry.com": "some-helper"},
+  "credsStore": "some-store"
+}`),
+					0600,
+				))
+			})
+
+			it("uses the credential helper for the registry", func() {
+				keychain, err := auth.DefaultKeychain("helper-registry.com/some-image")
+				h.AssertNil(t, err)
+				h.AssertEq(t, resolve(keychain, "helper-registry.com"), &authn.AuthConfig{Username: "helper-user", Password: "helper-secret"})
+			})
+
+			it("uses the auths for the registry rather than the credential store", func() {
+				keychain, err := auth.DefaultKeychain("auth-registry.com/some-image", "url-registry.com/some-image")
+				h.AssertNil(t, err)
+				h.AssertEq(t, resolve(keychain, "auth-registry.com"), &authn.AuthConfig{Username: "auth-user", Password: "auth-secret"})
+				h.AssertEq(t, resolve(keychain, "url-registry.com"), &authn.AuthConfig{Username: "url-user", Password: "url-secret"})
+			})
+
+			it("prefers the auths entry whose key is the registry hostname", func() {
+				keychain, err := auth.DefaultKeychain("exact-registry.com/some-image")
+				h.AssertNil(t, err)
+				h.AssertEq(t, resolve(keychain, "exact-registry.com"), &authn.AuthConfig{Username: "exact-user", Password: "exact-secret"})
+			})
+
+			it("uses the credential store for other registries, including those with empty auths", func() {
+				keychain, err := auth.DefaultKeychain("stored-registry.com/some-image", "other-registry.com/some-image", "some-org/some-image")
+				h.AssertNil(t, err)
+				h.AssertEq(t, resolve(keychain, "stored-registry.com"), &authn.AuthConfig{Username: "store-user", Password: "stored-registry.com"})
+				h.AssertEq(t, resolve(keychain, "other-registry.com"), &authn.AuthConfig{Username: "store-user", Password: "other-registry.com"})
+				h.AssertEq(t, resolve(keychain, "index.docker.io"), &authn.AuthConfig{Username: "store-user", Password: authn.DefaultAuthKey})
+			})
+		})
+	})
+
 	when("#BuildEnvVar", func() {
 		var keychain authn.Keychain
 
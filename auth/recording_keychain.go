@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// RecordingKeychain is an implementation of authn.Keychain that records the registry of every resource it resolves
+// before delegating to the wrapped keychain.
+// Credentials are resolved once for each registry operation (e.g., reading a manifest or pushing an image),
+// so the recorded counts reflect registry operations rather than individual HTTP requests.
+type RecordingKeychain struct {
+	Keychain authn.Keychain
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewRecordingKeychain returns a RecordingKeychain that wraps the provided keychain.
+func NewRecordingKeychain(keychain authn.Keychain) *RecordingKeychain {
+	return &RecordingKeychain{
+		Keychain: keychain,
+		counts:   map[string]int{},
+	}
+}
+
+func (k *RecordingKeychain) Resolve(resource authn.Resource) (authn.Authenticator, error) {
+	k.mu.Lock()
+	k.counts[resource.RegistryStr()]++
+	k.mu.Unlock()
+	return k.Keychain.Resolve(resource)
+}
+
+// RegistryCount is the number of times credentials were resolved for a registry.
+type RegistryCount struct {
+	Registry string
+	Count    int
+}
+
+// Registries returns the recorded registries and their counts, sorted by registry.
+func (k *RecordingKeychain) Registries() []RegistryCount {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var ret []RegistryCount
+	for registry, count := range k.counts {
+		ret = append(ret, RegistryCount{Registry: registry, Count: count})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Registry < ret[j].Registry
+	})
+	return ret
+}
@@ -0,0 +1,63 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/auth"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestRecordingKeychain(t *testing.T) {
+	spec.Run(t, "RecordingKeychain", testRecordingKeychain, spec.Report(report.Terminal{}))
+}
+
+func testRecordingKeychain(t *testing.T, when spec.G, it spec.S) {
+	var keychain *auth.RecordingKeychain
+
+	it.Before(func() {
+		keychain = auth.NewRecordingKeychain(&auth.ResolvedKeychain{AuthConfigs: map[string]*authn.AuthConfig{
+			"some-registry.io": {Username: "some-username", Password: "some-password"},
+		}})
+	})
+
+	when("#Resolve", func() {
+		it("delegates to the wrapped keychain", func() {
+			registry, err := name.NewRegistry("some-registry.io", name.WeakValidation)
+			h.AssertNil(t, err)
+
+			authenticator, err := keychain.Resolve(registry)
+			h.AssertNil(t, err)
+
+			authConfig, err := authenticator.Authorization()
+			h.AssertNil(t, err)
+			h.AssertEq(t, authConfig, &authn.AuthConfig{Username: "some-username", Password: "some-password"})
+		})
+	})
+
+	when("#Registries", func() {
+		it("returns each resolved registry with a count, sorted by registry", func() {
+			for _, ref := range []string{"some-registry.io/some-repo", "other-registry.io/other-repo", "some-registry.io/other-repo"} {
+				repo, err := name.NewRepository(ref)
+				h.AssertNil(t, err)
+				_, err = keychain.Resolve(repo)
+				h.AssertNil(t, err)
+			}
+
+			h.AssertEq(t, keychain.Registries(), []auth.RegistryCount{
+				{Registry: "other-registry.io", Count: 1},
+				{Registry: "some-registry.io", Count: 2},
+			})
+		})
+
+		when("no registries were contacted", func() {
+			it("returns nothing", func() {
+				h.AssertEq(t, len(keychain.Registries()), 0)
+			})
+		})
+	})
+}
@@ -9,16 +9,17 @@ import (
 	"github.com/BurntSushi/toml"
 
 	"github.com/buildpacks/lifecycle/internal/encoding"
+	"github.com/buildpacks/lifecycle/launch"
 )
 
 type BpDescriptor struct {
-	WithAPI     string           `toml:"api"`
-	Buildpack   BpInfo           `toml:"buildpack"`
-	Order       Order            `toml:"order"`
-	WithRootDir string           `toml:"-"`
-	Targets     []TargetMetadata `toml:"targets"`
-	Stacks      []StackMetadata  `tome:"stacks"` // just for backwards compat so we can check if it's the bionic stack, which we translate to a target
-
+	WithAPI     string                 `toml:"api"`
+	Buildpack   BpInfo                 `toml:"buildpack"`
+	Order       Order                  `toml:"order"`
+	WithRootDir string                 `toml:"-"`
+	Targets     []TargetMetadata       `toml:"targets"`
+	Stacks      []StackMetadata        `tome:"stacks"` // just for backwards compat so we can check if it's the bionic stack, which we translate to a target
+	Metadata    map[string]interface{} `toml:"metadata"`
 }
 
 type StackMetadata struct {
@@ -133,6 +134,29 @@ func (bg Group) HasExtensions() bool {
 	return len(bg.GroupExtensions) > 0
 }
 
+// Contains returns true if the group has a buildpack with the provided ID, which may be escaped (see Lookup).
+func (bg Group) Contains(id string) bool {
+	_, ok := bg.Lookup(id)
+	return ok
+}
+
+// Lookup returns the buildpack in the group with the provided ID.
+// The ID may be escaped as for the buildpack's layers directory (e.g., `some_buildpack` for `some/buildpack`);
+// a buildpack whose ID matches exactly is preferred over one whose escaped ID matches.
+func (bg Group) Lookup(id string) (GroupElement, bool) {
+	for _, bp := range bg.Group {
+		if bp.ID == id {
+			return bp, true
+		}
+	}
+	for _, bp := range bg.Group {
+		if launch.EscapeID(bp.ID) == id {
+			return bp, true
+		}
+	}
+	return GroupElement{}, false
+}
+
 // A GroupElement represents a buildpack referenced in a buildpack.toml's [[order.group]] OR
 // a buildpack or extension in order.toml OR a buildpack or extension in group.toml.
 type GroupElement struct {
@@ -176,4 +176,48 @@ func testBpDescriptor(t *testing.T, when spec.G, it spec.S) {
 			h.AssertEq(t, descriptor.Targets[1].OS, "linux")
 		})
 	})
+
+	when("Group", func() {
+		group := buildpack.Group{
+			Group: []buildpack.GroupElement{
+				{ID: "some/buildpack", Version: "v1"},
+				{ID: "some_buildpack", Version: "v2"},
+				{ID: "other/buildpack", Version: "v3"},
+			},
+			GroupExtensions: []buildpack.GroupElement{{ID: "some-extension", Extension: true}},
+		}
+
+		when("#Lookup", func() {
+			it("returns the buildpack with the provided ID", func() {
+				bp, ok := group.Lookup("some/buildpack")
+				h.AssertEq(t, ok, true)
+				h.AssertEq(t, bp.Version, "v1")
+			})
+
+			it("returns the buildpack with the provided escaped ID", func() {
+				bp, ok := group.Lookup("other_buildpack")
+				h.AssertEq(t, ok, true)
+				h.AssertEq(t, bp.Version, "v3")
+			})
+
+			it("prefers the buildpack whose ID matches exactly", func() {
+				bp, ok := group.Lookup("some_buildpack")
+				h.AssertEq(t, ok, true)
+				h.AssertEq(t, bp.Version, "v2")
+			})
+
+			it("does not return extensions", func() {
+				_, ok := group.Lookup("some-extension")
+				h.AssertEq(t, ok, false)
+			})
+		})
+
+		when("#Contains", func() {
+			it("returns whether the group has the buildpack", func() {
+				h.AssertEq(t, group.Contains("other/buildpack"), true)
+				h.AssertEq(t, group.Contains("other_buildpack"), true)
+				h.AssertEq(t, group.Contains("missing/buildpack"), false)
+			})
+		})
+	})
 }
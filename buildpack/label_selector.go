@@ -0,0 +1,35 @@
+package buildpack
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LabelSelector selects buildpacks whose buildpack.toml `[metadata]` table has a top-level key with the provided value,
+// e.g., `experimental=false` selects buildpacks with `experimental = false` in their metadata.
+// Values are compared using their string representation.
+type LabelSelector struct {
+	Key   string
+	Value string
+}
+
+// ParseLabelSelector parses a label selector of the form `key=value`.
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	key, value, ok := strings.Cut(selector, "=")
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	if !ok || key == "" {
+		return LabelSelector{}, fmt.Errorf("invalid label selector %q: must be of the form key=value", selector)
+	}
+	return LabelSelector{Key: key, Value: value}, nil
+}
+
+// Matches returns true if the provided buildpack carries the selected label.
+// Buildpacks without the label do not match.
+func (s LabelSelector) Matches(descriptor *BpDescriptor) bool {
+	value, ok := descriptor.Metadata[s.Key]
+	return ok && fmt.Sprint(value) == s.Value
+}
+
+func (s LabelSelector) String() string {
+	return s.Key + "=" + s.Value
+}
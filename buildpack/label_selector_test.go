@@ -0,0 +1,49 @@
+package buildpack_test
+
+import (
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/buildpack"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestLabelSelector(t *testing.T) {
+	spec.Run(t, "LabelSelector", testLabelSelector, spec.Report(report.Terminal{}))
+}
+
+func testLabelSelector(t *testing.T, when spec.G, it spec.S) {
+	when(".ParseLabelSelector", func() {
+		it("parses the key and value", func() {
+			selector, err := buildpack.ParseLabelSelector("experimental = false")
+			h.AssertNil(t, err)
+			h.AssertEq(t, selector, buildpack.LabelSelector{Key: "experimental", Value: "false"})
+		})
+
+		when("the selector is not of the form key=value", func() {
+			it("errors", func() {
+				_, err := buildpack.ParseLabelSelector("experimental")
+				h.AssertError(t, err, `invalid label selector "experimental": must be of the form key=value`)
+			})
+		})
+	})
+
+	when("#Matches", func() {
+		selector := buildpack.LabelSelector{Key: "experimental", Value: "false"}
+
+		it("matches buildpacks with the label", func() {
+			h.AssertEq(t, selector.Matches(&buildpack.BpDescriptor{Metadata: map[string]interface{}{"experimental": false}}), true)
+			h.AssertEq(t, selector.Matches(&buildpack.BpDescriptor{Metadata: map[string]interface{}{"experimental": "false"}}), true)
+		})
+
+		it("does not match buildpacks with a different value", func() {
+			h.AssertEq(t, selector.Matches(&buildpack.BpDescriptor{Metadata: map[string]interface{}{"experimental": true}}), false)
+		})
+
+		it("does not match buildpacks without the label", func() {
+			h.AssertEq(t, selector.Matches(&buildpack.BpDescriptor{}), false)
+		})
+	})
+}
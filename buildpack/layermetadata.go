@@ -1,12 +1,15 @@
 package buildpack
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/BurntSushi/toml"
 
+	"github.com/buildpacks/lifecycle/internal/encoding"
 	"github.com/buildpacks/lifecycle/log"
 )
 
@@ -18,17 +21,15 @@ type LayerMetadataFile struct {
 }
 
 func EncodeLayerMetadataFile(lmf LayerMetadataFile, path, buildpackAPI string) error {
-	fh, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer fh.Close()
-
 	encoders := supportedEncoderDecoders()
 
 	for _, encoder := range encoders {
 		if encoder.IsSupported(buildpackAPI) {
-			return encoder.Encode(fh, lmf)
+			buf := new(bytes.Buffer)
+			if err := encoder.Encode(buf, lmf); err != nil {
+				return err
+			}
+			return encoding.WriteFileAtomic(path, buf.Bytes())
 		}
 	}
 	return errors.New("couldn't find an encoder")
@@ -59,7 +60,7 @@ func DecodeLayerMetadataFile(path string, buildpackAPI string, logger log.Logger
 
 type encoderDecoder interface {
 	IsSupported(buildpackAPI string) bool
-	Encode(file *os.File, lmf LayerMetadataFile) error
+	Encode(w io.Writer, lmf LayerMetadataFile) error
 	Decode(path string) (LayerMetadataFile, string, error)
 }
 
@@ -75,13 +76,13 @@ func (d *defaultEncoderDecoder) IsSupported(buildpackAPI string) bool {
 	return true
 }
 
-func (d *defaultEncoderDecoder) Encode(file *os.File, lmf LayerMetadataFile) error {
+func (d *defaultEncoderDecoder) Encode(w io.Writer, lmf LayerMetadataFile) error {
 	// omit the types table - all the flags are set to false
 	type dataTomlFile struct {
 		Data interface{} `toml:"metadata"`
 	}
 	dtf := dataTomlFile{Data: lmf.Data}
-	return toml.NewEncoder(file).Encode(dtf)
+	return toml.NewEncoder(w).Encode(dtf)
 }
 
 func (d *defaultEncoderDecoder) Decode(path string) (LayerMetadataFile, string, error) {
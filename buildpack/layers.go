@@ -22,8 +22,35 @@ type LayersDir struct {
 	Store     *StoreTOML
 }
 
+// LayersDirLayout determines where the layers of each buildpack are found within the layers directory.
+// It allows platforms with conventions other than the standard `<layers>/<escaped buildpack ID>` layout to reuse
+// the lifecycle's layer handling.
+type LayersDirLayout interface {
+	BuildpackDir(layersDir string, bp GroupElement) string
+}
+
+// DefaultLayersDirLayout is the standard layout, in which the layers of each buildpack are found in `<layers>/<escaped buildpack ID>`.
+type DefaultLayersDirLayout struct{}
+
+func (DefaultLayersDirLayout) BuildpackDir(layersDir string, bp GroupElement) string {
+	return filepath.Join(layersDir, launch.EscapeID(bp.ID))
+}
+
+// LayoutOrDefault returns the provided layout, or the default layout if it is nil.
+func LayoutOrDefault(layout LayersDirLayout) LayersDirLayout {
+	if layout == nil {
+		return DefaultLayersDirLayout{}
+	}
+	return layout
+}
+
 func ReadLayersDir(layersDir string, bp GroupElement, logger log.Logger) (LayersDir, error) {
-	path := filepath.Join(layersDir, launch.EscapeID(bp.ID))
+	return ReadLayersDirWithLayout(layersDir, bp, DefaultLayersDirLayout{}, logger)
+}
+
+// ReadLayersDirWithLayout reads the layers of the provided buildpack from the directory determined by the provided layout.
+func ReadLayersDirWithLayout(layersDir string, bp GroupElement, layout LayersDirLayout, logger log.Logger) (LayersDir, error) {
+	path := LayoutOrDefault(layout).BuildpackDir(layersDir, bp)
 	logger.Debugf("Reading buildpack directory: %s", path)
 	bpDir := LayersDir{
 		name:      bp.ID,
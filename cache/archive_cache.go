@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"encoding/json"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/lifecycle/log"
+	"github.com/buildpacks/lifecycle/platform"
+)
+
+var errArchiveReadOnly = errors.New("cache archive cannot be modified")
+
+// ArchiveCache is a read-only cache backed by a cache image that was exported to a tarball in `docker save` format,
+// e.g., for transfer to an air-gapped environment. Layers and metadata are read from the tarball on disk.
+// The tarball must contain a single image.
+type ArchiveCache struct {
+	path   string
+	image  v1.Image
+	logger log.Logger
+}
+
+// NewArchiveCache creates a new ArchiveCache from the tarball at the provided path.
+func NewArchiveCache(path string, logger log.Logger) (*ArchiveCache, error) {
+	image, err := tarball.ImageFromPath(path, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading cache archive %q", path)
+	}
+	return &ArchiveCache{path: path, image: image, logger: logger}, nil
+}
+
+func (c *ArchiveCache) Exists() bool {
+	return true
+}
+
+func (c *ArchiveCache) Name() string {
+	return c.path
+}
+
+func (c *ArchiveCache) SetMetadata(_ platform.CacheMetadata) error {
+	return errArchiveReadOnly
+}
+
+func (c *ArchiveCache) RetrieveMetadata() (platform.CacheMetadata, error) {
+	configFile, err := c.image.ConfigFile()
+	if err != nil {
+		return platform.CacheMetadata{}, errors.Wrapf(err, "reading config of cache archive %q", c.path)
+	}
+	var meta platform.CacheMetadata
+	label, ok := configFile.Config.Labels[MetadataLabel]
+	if !ok {
+		return meta, nil
+	}
+	if err = json.Unmarshal([]byte(label), &meta); err != nil {
+		c.logger.Infof("Ignoring metadata of cache archive %q because it was corrupt", c.path)
+		return platform.CacheMetadata{}, nil
+	}
+	return meta, nil
+}
+
+func (c *ArchiveCache) AddLayerFile(_ string, _ string) error {
+	return errArchiveReadOnly
+}
+
+func (c *ArchiveCache) ReuseLayer(_ string) error {
+	return errArchiveReadOnly
+}
+
+func (c *ArchiveCache) RetrieveLayer(diffID string) (io.ReadCloser, error) {
+	hash, err := v1.NewHash(diffID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing layer SHA '%s'", diffID)
+	}
+	layer, err := c.image.LayerByDiffID(hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "layer with SHA '%s' not found in cache archive %q", diffID, c.path)
+	}
+	return layer.Uncompressed()
+}
+
+// VerifyIntegrity returns an error if the layer with the provided diffID is missing from the archive
+// or its uncompressed contents do not match the diffID.
+func (c *ArchiveCache) VerifyIntegrity(diffID string) error {
+	rc, err := c.RetrieveLayer(diffID)
+	if err != nil {
+		return err
+	}
+	return verifyDiffID(rc, diffID)
+}
+
+func (c *ArchiveCache) Commit() error {
+	return errArchiveReadOnly
+}
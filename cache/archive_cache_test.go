@@ -0,0 +1,146 @@
+package cache_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/cache"
+	"github.com/buildpacks/lifecycle/cmd"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestArchiveCache(t *testing.T) {
+	spec.Run(t, "ArchiveCache", testArchiveCache, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testArchiveCache(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir      string
+		archivePath string
+		layerSHA    string
+		subject     *cache.ArchiveCache
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "lifecycle.cache.archive_cache")
+		h.AssertNil(t, err)
+
+		archivePath, layerSHA = writeCacheArchive(t, tmpDir, `{"buildpacks": [{"key": "bp.id", "version": "1.2.3"}]}`)
+		subject, err = cache.NewArchiveCache(archivePath, cmd.DefaultLogger)
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		h.AssertNil(t, os.RemoveAll(tmpDir))
+	})
+
+	when("#NewArchiveCache", func() {
+		when("the archive does not exist", func() {
+			it("errors", func() {
+				_, err := cache.NewArchiveCache(filepath.Join(tmpDir, "does-not-exist.tar"), cmd.DefaultLogger)
+				h.AssertNotNil(t, err)
+			})
+		})
+	})
+
+	when("#RetrieveMetadata", func() {
+		it("returns the metadata", func() {
+			meta, err := subject.RetrieveMetadata()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(meta.Buildpacks), 1)
+			h.AssertEq(t, meta.Buildpacks[0].ID, "bp.id")
+			h.AssertEq(t, meta.Buildpacks[0].Version, "1.2.3")
+		})
+
+		when("the metadata is invalid", func() {
+			it("returns empty metadata", func() {
+				archivePath, _ = writeCacheArchive(t, tmpDir, "garbage")
+				subject, err := cache.NewArchiveCache(archivePath, cmd.DefaultLogger)
+				h.AssertNil(t, err)
+
+				meta, err := subject.RetrieveMetadata()
+				h.AssertNil(t, err)
+				h.AssertEq(t, len(meta.Buildpacks), 0)
+			})
+		})
+	})
+
+	when("#RetrieveLayer", func() {
+		it("returns the uncompressed layer", func() {
+			rc, err := subject.RetrieveLayer(layerSHA)
+			h.AssertNil(t, err)
+			defer rc.Close()
+
+			tr := tar.NewReader(rc)
+			hdr, err := tr.Next()
+			h.AssertNil(t, err)
+			h.AssertEq(t, hdr.Name, "some-file")
+			contents, err := io.ReadAll(tr)
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(contents), "some-content")
+		})
+
+		when("the layer does not exist", func() {
+			it("errors", func() {
+				_, err := subject.RetrieveLayer("sha256:" + string(bytes.Repeat([]byte("0"), 64)))
+				h.AssertError(t, err, "not found in cache archive")
+			})
+		})
+	})
+
+	when("#VerifyIntegrity", func() {
+		it("succeeds for a layer in the archive", func() {
+			h.AssertNil(t, subject.VerifyIntegrity(layerSHA))
+		})
+	})
+
+	when("modifying the cache", func() {
+		it("errors", func() {
+			h.AssertError(t, subject.AddLayerFile("some-path", layerSHA), "cache archive cannot be modified")
+			h.AssertError(t, subject.ReuseLayer(layerSHA), "cache archive cannot be modified")
+			h.AssertError(t, subject.Commit(), "cache archive cannot be modified")
+		})
+	})
+}
+
+// writeCacheArchive writes a cache image with a single layer and the provided metadata label to a tarball in `docker save` format,
+// returning the path of the tarball and the diffID of the layer.
+func writeCacheArchive(t *testing.T, dir, metadata string) (string, string) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: "some-file", Mode: 0644, Size: int64(len("some-content"))}))
+	_, err := tw.Write([]byte("some-content"))
+	h.AssertNil(t, err)
+	h.AssertNil(t, tw.Close())
+	layerPath := filepath.Join(dir, "some-layer.tar")
+	h.AssertNil(t, os.WriteFile(layerPath, buf.Bytes(), 0600))
+
+	layer, err := tarball.LayerFromFile(layerPath)
+	h.AssertNil(t, err)
+	image, err := mutate.AppendLayers(empty.Image, layer)
+	h.AssertNil(t, err)
+	image, err = mutate.Config(image, v1.Config{Labels: map[string]string{cache.MetadataLabel: metadata}})
+	h.AssertNil(t, err)
+
+	ref, err := name.ParseReference("some-registry.io/cache:latest")
+	h.AssertNil(t, err)
+	archivePath := filepath.Join(dir, "cache.tar")
+	h.AssertNil(t, tarball.WriteToFile(archivePath, ref, image))
+
+	diffID, err := layer.DiffID()
+	h.AssertNil(t, err)
+	return archivePath, diffID.String()
+}
@@ -0,0 +1,241 @@
+package cache
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/lifecycle/internal/encoding"
+)
+
+// Cache layers may be stored as a list of content-defined chunks rather than as a single tar file,
+// so that chunks that are unchanged between builds are stored once and reused across commits.
+// Chunk boundaries are found using a gear rolling hash, so that an edit to a layer only affects the chunks around the edit.
+const (
+	minChunkSize = 64 << 10
+	maxChunkSize = 1 << 20
+	// a boundary is found when the top chunkBits bits of the rolling hash are zero,
+	// giving an average chunk size of roughly minChunkSize + 2^chunkBits bytes
+	chunkBits = 18
+
+	chunksDirName       = "chunks"
+	chunkManifestSuffix = ".chunks"
+)
+
+var gearTable = func() [256]uint64 {
+	// splitmix64, seeded with a fixed value so that chunk boundaries are stable across processes
+	var (
+		table [256]uint64
+		state uint64 = 0x9e3779b97f4a7c15
+	)
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// splitChunks reads the provided reader to completion, calling fn with each content-defined chunk.
+// The chunk passed to fn is only valid until fn returns.
+func splitChunks(r io.Reader, fn func(chunk []byte) error) error {
+	var (
+		br  = bufio.NewReader(r)
+		buf = make([]byte, maxChunkSize)
+		eof bool
+	)
+	for !eof {
+		var (
+			n    int
+			hash uint64
+		)
+		for n < maxChunkSize {
+			b, err := br.ReadByte()
+			if err == io.EOF {
+				eof = true
+				break
+			}
+			if err != nil {
+				return err
+			}
+			buf[n] = b
+			n++
+			hash = (hash << 1) + gearTable[b]
+			if n >= minChunkSize && hash>>(64-chunkBits) == 0 {
+				break
+			}
+		}
+		if n == 0 {
+			break
+		}
+		if err := fn(buf[:n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeChunks splits the file at the provided path into chunks, writing any chunks not already present to chunksDir,
+// and returns the digests of the chunks in order.
+func writeChunks(path, chunksDir string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := os.MkdirAll(chunksDir, 0777); err != nil {
+		return nil, errors.Wrapf(err, "creating chunks directory '%s'", chunksDir)
+	}
+
+	var digests []string
+	err = splitChunks(f, func(chunk []byte) error {
+		digest := fmt.Sprintf("sha256:%x", sha256.Sum256(chunk))
+		digests = append(digests, digest)
+		chunkPath := chunkPath(chunksDir, digest)
+		if _, err := os.Stat(chunkPath); err == nil {
+			// don't waste time rewriting an identical chunk
+			return nil
+		}
+		return encoding.WriteFileAtomic(chunkPath, chunk)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "chunking '%s'", path)
+	}
+	return digests, nil
+}
+
+// reassembleChunks writes the layer made of the chunks with the provided digests to the file at the provided path,
+// unless the file already exists.
+func reassembleChunks(path, chunksDir string, digests []string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, newChunkReader(chunksDir, digests)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func chunkPath(chunksDir, digest string) string {
+	return filepath.Join(chunksDir, strings.TrimPrefix(digest, "sha256:"))
+}
+
+func chunkManifestPath(basePath, diffID string) string {
+	return strings.TrimSuffix(diffIDPath(basePath, diffID), ".tar") + chunkManifestSuffix
+}
+
+func writeChunkManifest(path string, digests []string) error {
+	return encoding.WriteFileAtomic(path, []byte(strings.Join(digests, "\n")+"\n"))
+}
+
+func readChunkManifest(path string) ([]string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(contents)), nil
+}
+
+// chunkReader reassembles a layer from its chunks, opening each chunk as it is needed.
+type chunkReader struct {
+	paths   []string
+	current *os.File
+}
+
+func newChunkReader(chunksDir string, digests []string) *chunkReader {
+	var paths []string
+	for _, digest := range digests {
+		paths = append(paths, chunkPath(chunksDir, digest))
+	}
+	return &chunkReader{paths: paths}
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.paths) == 0 {
+				return 0, io.EOF
+			}
+			f, err := os.Open(r.paths[0])
+			if err != nil {
+				return 0, errors.Wrap(err, "opening cache chunk")
+			}
+			r.current, r.paths = f, r.paths[1:]
+		}
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
+
+// pruneChunks removes chunks that are not referenced by any chunk manifest in manifestsDirs.
+func pruneChunks(chunksDir string, manifestsDirs ...string) error {
+	referenced := make(map[string]bool)
+	for _, manifestsDir := range manifestsDirs {
+		manifests, err := filepath.Glob(filepath.Join(manifestsDir, "*"+chunkManifestSuffix))
+		if err != nil {
+			return err
+		}
+		for _, manifest := range manifests {
+			digests, err := readChunkManifest(manifest)
+			if err != nil {
+				return err
+			}
+			for _, digest := range digests {
+				referenced[filepath.Base(chunkPath(chunksDir, digest))] = true
+			}
+		}
+	}
+	fis, err := os.ReadDir(chunksDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, fi := range fis {
+		if referenced[fi.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(chunksDir, fi.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
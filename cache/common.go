@@ -1,7 +1,25 @@
 package cache
 
 import (
-	"errors"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
 )
 
 var errCacheCommitted = errors.New("cache cannot be modified after commit")
+
+// verifyDiffID reads the provided uncompressed layer to completion and returns an error
+// if its digest does not match the provided diffID.
+func verifyDiffID(rc io.ReadCloser, diffID string) error {
+	defer rc.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, rc); err != nil {
+		return errors.Wrapf(err, "reading layer with SHA '%s'", diffID)
+	}
+	if actual := fmt.Sprintf("sha256:%x", hasher.Sum(nil)); actual != diffID {
+		return fmt.Errorf("layer with SHA '%s' has digest '%s'", diffID, actual)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// Compression is the compression of the layer files stored in a cache directory, or of the layer blobs of a cache image.
+type Compression string
+
+const (
+	// CompressionNone stores layer files as uncompressed tars; this is the default.
+	CompressionNone Compression = "none"
+	// CompressionGzip stores layer files as gzip-compressed tars.
+	CompressionGzip Compression = "gzip"
+	// CompressionZstd stores layer files as zstd-compressed tars, which are smaller than uncompressed tars
+	// and faster to decompress than gzip-compressed tars.
+	CompressionZstd Compression = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ParseCompression returns the compression with the provided name.
+func ParseCompression(value string) (Compression, error) {
+	switch compression := Compression(value); compression {
+	case CompressionNone, CompressionGzip, CompressionZstd:
+		return compression, nil
+	default:
+		return "", fmt.Errorf("invalid cache compression %q: must be one of %q, %q, or %q", value, CompressionNone, CompressionGzip, CompressionZstd)
+	}
+}
+
+// writeCompressed writes the contents of the provided reader to the file at the provided path, compressed with the provided compression.
+func writeCompressed(path string, r io.Reader, compression Compression) error {
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var w io.WriteCloser
+	switch compression {
+	case CompressionGzip:
+		if w, err = gzip.NewWriterLevel(fh, gzip.BestSpeed); err != nil {
+			return err
+		}
+	case CompressionZstd:
+		if w, err = zstd.NewWriter(fh); err != nil {
+			return err
+		}
+	default:
+		if _, err = io.Copy(fh, r); err != nil {
+			return err
+		}
+		return fh.Close()
+	}
+	if _, err = io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return fh.Close()
+}
+
+// decompressingReader returns a reader of the decompressed contents of the provided reader.
+// The compression is detected from the leading bytes of the contents, so that layer files written with any compression can be read;
+// uncompressed contents are read as is.
+func decompressingReader(rc io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(rc)
+	header, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		rc.Close()
+		return nil, errors.Wrap(err, "detecting layer compression")
+	}
+	switch {
+	case bytes.HasPrefix(header, zstdMagic):
+		return decompressorFor(CompressionZstd, br, rc)
+	case bytes.HasPrefix(header, gzipMagic):
+		return decompressorFor(CompressionGzip, br, rc)
+	}
+	return &decompressedReader{Reader: br, close: rc.Close}, nil
+}
+
+// decompressorFor returns a reader of the contents of the provided reader decompressed with the provided compression,
+// which closes the provided closer when closed.
+func decompressorFor(compression Compression, r io.Reader, rc io.Closer) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			rc.Close()
+			return nil, errors.Wrap(err, "decompressing layer")
+		}
+		return &decompressedReader{Reader: zr, close: func() error {
+			zr.Close()
+			return rc.Close()
+		}}, nil
+	case CompressionGzip:
+		gzr, err := gzip.NewReader(r)
+		if err != nil {
+			rc.Close()
+			return nil, errors.Wrap(err, "decompressing layer")
+		}
+		return &decompressedReader{Reader: gzr, close: func() error {
+			gzr.Close()
+			return rc.Close()
+		}}, nil
+	}
+	return &decompressedReader{Reader: r, close: rc.Close}, nil
+}
+
+// decompressedReader reads decompressed contents, closing the decompressor and the underlying reader when closed.
+type decompressedReader struct {
+	io.Reader
+	close func() error
+}
+
+func (r *decompressedReader) Close() error {
+	return r.close()
+}
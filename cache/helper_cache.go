@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/lifecycle/platform"
+)
+
+// HelperPrefix is the prefix of the names of cache helpers: the cache helper for caches whose location has the scheme `s3`
+// (e.g., `s3://some-bucket/some-prefix`) is the executable `lifecycle-cache-s3`.
+const HelperPrefix = "lifecycle-cache-"
+
+// helperExitLayerNotFound is the exit status of a cache helper asked for a layer that is not in the committed state.
+const helperExitLayerNotFound = 2
+
+// HelperCache is a cache whose contents are stored by a separate executable, the cache helper,
+// so that caches other than the built-in cache image and cache directory can be provided without rebuilding the lifecycle.
+// The helper is invoked as `<helper> <command> <location> [args]`, where location is the location of the cache without its scheme,
+// and must exit with a non-zero status (writing the reason to stderr) if the command fails. The commands are:
+//   - `exists <location>` writes `true` to stdout if the cache has a committed state, or `false` otherwise.
+//   - `get-metadata <location>` writes the committed metadata as JSON to stdout; it writes nothing if there is none.
+//   - `has-layer <location> <diffID>` writes `true` to stdout if the committed state has the layer, or `false` otherwise.
+//   - `get-layer <location> <diffID>` writes the uncompressed tar of the committed layer to stdout;
+//     it exits with status 2 if the committed state does not have the layer.
+//   - `commit <location>` reads a JSON object from stdin with the `metadata` to commit, the `layers` to add
+//     (each with its `diffID` and the `path` of its uncompressed tar), and the diffIDs of the committed layers to `reuse`,
+//     and replaces the committed state with them. Layers that are neither added nor reused must not be in the committed state after it.
+//
+// Read commands may be invoked concurrently. The staged state is held by the lifecycle until Commit,
+// so that the helper does not need to keep state between invocations.
+type HelperCache struct {
+	helper   string
+	location string
+
+	mu        sync.Mutex
+	committed bool
+	staged    helperCommit
+}
+
+// helperCommit is the staged state that is provided to the cache helper on commit.
+type helperCommit struct {
+	Metadata platform.CacheMetadata `json:"metadata"`
+	Layers   []helperLayer          `json:"layers"`
+	Reuse    []string               `json:"reuse"`
+}
+
+type helperLayer struct {
+	DiffID string `json:"diffID"`
+	Path   string `json:"path"`
+}
+
+// NewHelperCache returns a HelperCache for the cache at the provided location, stored by the cache helper at the provided path.
+func NewHelperCache(helper, location string) *HelperCache {
+	return &HelperCache{
+		helper:   helper,
+		location: location,
+		staged:   helperCommit{Layers: []helperLayer{}, Reuse: []string{}},
+	}
+}
+
+func (c *HelperCache) Exists() bool {
+	out, err := c.run(nil, "exists")
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+func (c *HelperCache) Name() string {
+	return c.location
+}
+
+func (c *HelperCache) SetMetadata(metadata platform.CacheMetadata) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.committed {
+		return errCacheCommitted
+	}
+	c.staged.Metadata = metadata
+	return nil
+}
+
+func (c *HelperCache) RetrieveMetadata() (platform.CacheMetadata, error) {
+	out, err := c.run(nil, "get-metadata")
+	if err != nil {
+		return platform.CacheMetadata{}, err
+	}
+	var metadata platform.CacheMetadata
+	if len(bytes.TrimSpace(out)) == 0 {
+		return metadata, nil
+	}
+	if err = json.Unmarshal(out, &metadata); err != nil {
+		return platform.CacheMetadata{}, nil
+	}
+	return metadata, nil
+}
+
+func (c *HelperCache) AddLayerFile(tarPath string, diffID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.committed {
+		return errCacheCommitted
+	}
+	c.staged.Layers = append(c.staged.Layers, helperLayer{DiffID: diffID, Path: tarPath})
+	return nil
+}
+
+func (c *HelperCache) ReuseLayer(diffID string) error {
+	out, err := c.run(nil, "has-layer", diffID)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(out)) != "true" {
+		return errors.Errorf("layer with SHA '%s' not found", diffID)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.committed {
+		return errCacheCommitted
+	}
+	c.staged.Reuse = append(c.staged.Reuse, diffID)
+	return nil
+}
+
+func (c *HelperCache) RetrieveLayer(diffID string) (io.ReadCloser, error) {
+	cmd := exec.Command(c.helper, "get-layer", c.location, diffID) // #nosec G204
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, errors.Wrapf(err, "running cache helper '%s'", c.helper)
+	}
+	r := &helperLayerReader{r: bufio.NewReader(stdout), stdout: stdout, cmd: cmd, stderr: stderr, diffID: diffID}
+	// a helper that does not have the layer exits without output, which is reported here rather than on the first read
+	if _, err = r.r.Peek(1); err == io.EOF {
+		if r.err = r.wait(); r.err != io.EOF {
+			return nil, r.err
+		}
+	}
+	return r, nil
+}
+
+// VerifyIntegrity returns an error if the layer with the provided diffID is missing
+// or its contents do not match the diffID.
+func (c *HelperCache) VerifyIntegrity(diffID string) error {
+	rc, err := c.RetrieveLayer(diffID)
+	if err != nil {
+		return err
+	}
+	return verifyDiffID(rc, diffID)
+}
+
+func (c *HelperCache) Commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.committed {
+		return errCacheCommitted
+	}
+	c.committed = true
+	data, err := json.Marshal(c.staged)
+	if err != nil {
+		return errors.Wrap(err, "serializing staged cache")
+	}
+	_, err = c.run(bytes.NewReader(data), "commit")
+	return err
+}
+
+// run invokes the cache helper with the provided command and arguments, returning its stdout.
+func (c *HelperCache) run(stdin io.Reader, command string, args ...string) ([]byte, error) {
+	cmd := exec.Command(c.helper, append([]string{command, c.location}, args...)...) // #nosec G204
+	cmd.Stdin = stdin
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, helperError(c.helper, command, err, stderr)
+	}
+	return out, nil
+}
+
+func helperError(helper, command string, err error, stderr *bytes.Buffer) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		err = errors.Errorf("%s: %s", err, msg)
+	}
+	return errors.Wrapf(err, "running cache helper '%s' command '%s'", helper, command)
+}
+
+// helperLayerReader reads a layer from the stdout of a cache helper, returning the failure of the helper, if any,
+// instead of the end of the stream, so that a layer is never truncated silently.
+type helperLayerReader struct {
+	r      *bufio.Reader
+	stdout io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+	diffID string
+	err    error
+	done   bool
+}
+
+func (r *helperLayerReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, r.err
+	}
+	n, err := r.r.Read(p)
+	if err == io.EOF {
+		r.err = r.wait()
+		return n, r.err
+	}
+	return n, err
+}
+
+// wait waits for the helper to exit, returning io.EOF if it succeeded.
+func (r *helperLayerReader) wait() error {
+	r.done = true
+	err := r.cmd.Wait()
+	if err == nil {
+		return io.EOF
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == helperExitLayerNotFound {
+		return errors.Wrapf(os.ErrNotExist, "layer with SHA '%s' not found", r.diffID)
+	}
+	return helperError(r.cmd.Path, "get-layer", err, r.stderr)
+}
+
+func (r *helperLayerReader) Close() error {
+	if r.done {
+		return nil
+	}
+	r.done = true
+	_ = r.stdout.Close()
+	if r.cmd.Process != nil {
+		_ = r.cmd.Process.Kill()
+	}
+	_ = r.cmd.Wait()
+	return nil
+}
@@ -0,0 +1,131 @@
+package cache_test
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/buildpack"
+	"github.com/buildpacks/lifecycle/cache"
+	"github.com/buildpacks/lifecycle/platform"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+// fakeCacheHelper stores the committed state of the cache in the location directory,
+// and records the input of the last commit.
+const fakeCacheHelper = `#!/bin/sh
+cmd=$1
+loc=$2
+case $cmd in
+  exists) if [ -f "$loc/metadata.json" ]; then echo true; else echo false; fi ;;
+  get-metadata) cat "$loc/metadata.json" 2>/dev/null || true ;;
+  has-layer) if [ -f "$loc/$3.tar" ]; then echo true; else echo false; fi ;;
+  get-layer) [ -f "$loc/$3.tar" ] || exit 2; cat "$loc/$3.tar" ;;
+  commit) cat > "$loc/commit.json" ;;
+  *) echo "unknown command $cmd" >&2; exit 1 ;;
+esac
+`
+
+func TestHelperCache(t *testing.T) {
+	spec.Run(t, "HelperCache", testHelperCache, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testHelperCache(t *testing.T, when spec.G, it spec.S) {
+	var (
+		location   string
+		subject    *cache.HelperCache
+		layerSHA   string
+		layerBytes = []byte("some-layer-contents")
+	)
+
+	it.Before(func() {
+		if runtime.GOOS == "windows" {
+			t.Skip("the cache helper fixture is a shell script")
+		}
+		tmpDir := t.TempDir()
+		helper := filepath.Join(tmpDir, cache.HelperPrefix+"fake")
+		h.AssertNil(t, os.WriteFile(helper, []byte(fakeCacheHelper), 0755)) // #nosec G306
+		location = filepath.Join(tmpDir, "location")
+		h.AssertNil(t, os.MkdirAll(location, 0755))
+		layerSHA = fmt.Sprintf("sha256:%x", sha256.Sum256(layerBytes))
+
+		subject = cache.NewHelperCache(helper, location)
+	})
+
+	when("the cache has no committed state", func() {
+		it("does not exist and has empty metadata", func() {
+			h.AssertEq(t, subject.Exists(), false)
+			metadata, err := subject.RetrieveMetadata()
+			h.AssertNil(t, err)
+			h.AssertEq(t, metadata, platform.CacheMetadata{})
+		})
+	})
+
+	when("the cache has a committed state", func() {
+		it.Before(func() {
+			h.AssertNil(t, os.WriteFile(filepath.Join(location, "metadata.json"), []byte(`{"buildpacks": [{"key": "some-buildpack"}]}`), 0600))
+			h.AssertNil(t, os.WriteFile(filepath.Join(location, layerSHA+".tar"), layerBytes, 0600))
+		})
+
+		it("retrieves the metadata", func() {
+			h.AssertEq(t, subject.Exists(), true)
+			metadata, err := subject.RetrieveMetadata()
+			h.AssertNil(t, err)
+			h.AssertEq(t, metadata.Buildpacks, []buildpack.LayersMetadata{{ID: "some-buildpack"}})
+		})
+
+		it("retrieves and verifies layers", func() {
+			rc, err := subject.RetrieveLayer(layerSHA)
+			h.AssertNil(t, err)
+			contents, err := io.ReadAll(rc)
+			h.AssertNil(t, err)
+			h.AssertNil(t, rc.Close())
+			h.AssertEq(t, contents, layerBytes)
+
+			h.AssertNil(t, subject.VerifyIntegrity(layerSHA))
+		})
+
+		when("the layer is not in the committed state", func() {
+			it("returns an error matching os.ErrNotExist", func() {
+				_, err := subject.RetrieveLayer("sha256:some-missing-sha")
+				h.AssertNotNil(t, err)
+				h.AssertEq(t, errors.Is(err, os.ErrNotExist), true)
+
+				h.AssertError(t, subject.ReuseLayer("sha256:some-missing-sha"), "layer with SHA 'sha256:some-missing-sha' not found")
+			})
+		})
+
+		it("commits the staged state", func() {
+			h.AssertNil(t, subject.SetMetadata(platform.CacheMetadata{Buildpacks: []buildpack.LayersMetadata{{ID: "other-buildpack"}}}))
+			h.AssertNil(t, subject.AddLayerFile("/some/layer.tar", "sha256:some-new-sha"))
+			h.AssertNil(t, subject.ReuseLayer(layerSHA))
+			h.AssertNil(t, subject.Commit())
+
+			var committed struct {
+				Metadata platform.CacheMetadata `json:"metadata"`
+				Layers   []struct {
+					DiffID string `json:"diffID"`
+					Path   string `json:"path"`
+				} `json:"layers"`
+				Reuse []string `json:"reuse"`
+			}
+			h.AssertNil(t, json.Unmarshal(h.MustReadFile(t, filepath.Join(location, "commit.json")), &committed))
+			h.AssertEq(t, committed.Metadata.Buildpacks[0].ID, "other-buildpack")
+			h.AssertEq(t, len(committed.Layers), 1)
+			h.AssertEq(t, committed.Layers[0].DiffID, "sha256:some-new-sha")
+			h.AssertEq(t, committed.Layers[0].Path, "/some/layer.tar")
+			h.AssertEq(t, committed.Reuse, []string{layerSHA})
+
+			h.AssertError(t, subject.AddLayerFile("/some/layer.tar", "sha256:some-new-sha"), "cache cannot be modified after commit")
+		})
+	})
+}
@@ -4,11 +4,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"os"
 	"runtime"
 
 	"github.com/buildpacks/imgutil"
 	"github.com/buildpacks/imgutil/remote"
 	"github.com/google/go-containerregistry/pkg/authn"
+	ggcrname "github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/pkg/errors"
 
 	"github.com/buildpacks/lifecycle/image"
@@ -24,6 +31,7 @@ type ImageCache struct {
 	newImage     imgutil.Image
 	logger       log.Logger
 	imageDeleter ImageDeleter
+	compression  Compression
 }
 
 // NewImageCache creates a new ImageCache instance
@@ -36,8 +44,10 @@ func NewImageCache(origImage imgutil.Image, newImage imgutil.Image, logger log.L
 	}
 }
 
-// NewImageCacheFromName creates a new ImageCache from the name that has been provided
-func NewImageCacheFromName(name string, keychain authn.Keychain, logger log.Logger, imageDeleter ImageDeleter) (*ImageCache, error) {
+// NewImageCacheFromName creates a new ImageCache from the name that has been provided.
+// The provided options are applied to the cache image to be saved, e.g., to select its media types;
+// the existing cache image is read regardless of its media types.
+func NewImageCacheFromName(name string, keychain authn.Keychain, logger log.Logger, imageDeleter ImageDeleter, newImageOpts ...remote.ImageOption) (*ImageCache, error) {
 	origImage, err := remote.NewImage(
 		name,
 		keychain,
@@ -47,12 +57,21 @@ func NewImageCacheFromName(name string, keychain authn.Keychain, logger log.Logg
 	if err != nil {
 		return nil, fmt.Errorf("accessing cache image %q: %v", name, err)
 	}
+	if !origImage.Found() {
+		// a cache image that does not exist yet is expected on the first build,
+		// but a cache image that cannot be accessed would silently invalidate the cache
+		if err = checkImageNotFound(name, keychain); err != nil {
+			return nil, fmt.Errorf("accessing cache image %q: %v", name, err)
+		}
+	}
 	emptyImage, err := remote.NewImage(
 		name,
 		keychain,
-		remote.WithPreviousImage(name),
-		remote.WithDefaultPlatform(imgutil.Platform{OS: runtime.GOOS}),
-		remote.AddEmptyLayerOnSave(),
+		append([]remote.ImageOption{
+			remote.WithPreviousImage(name),
+			remote.WithDefaultPlatform(imgutil.Platform{OS: runtime.GOOS}),
+			remote.AddEmptyLayerOnSave(),
+		}, newImageOpts...)...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("creating new cache image %q: %v", name, err)
@@ -61,6 +80,21 @@ func NewImageCacheFromName(name string, keychain authn.Keychain, logger log.Logg
 	return NewImageCache(origImage, emptyImage, logger, imageDeleter), nil
 }
 
+// checkImageNotFound returns an error if the image with the provided name is missing for any reason
+// other than not existing, e.g., because the registry denied access to it.
+func checkImageNotFound(name string, keychain authn.Keychain) error {
+	ref, err := ggcrname.ParseReference(name, ggcrname.WeakValidation)
+	if err != nil {
+		return err
+	}
+	_, err = ggcrremote.Head(ref, ggcrremote.WithAuthFromKeychain(keychain))
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) && (transportErr.StatusCode == http.StatusUnauthorized || transportErr.StatusCode == http.StatusForbidden) {
+		return err
+	}
+	return nil
+}
+
 func (c *ImageCache) Exists() bool {
 	return c.origImage.Found()
 }
@@ -81,6 +115,10 @@ func (c *ImageCache) SetMetadata(metadata platform.CacheMetadata) error {
 }
 
 func (c *ImageCache) RetrieveMetadata() (platform.CacheMetadata, error) {
+	if !c.origImage.Found() {
+		c.logger.Infof("No cache image %q found, starting fresh", c.origImage.Name())
+		return platform.CacheMetadata{}, nil
+	}
 	if c.origImage.Found() && !c.origImage.Valid() {
 		c.logger.Infof("Ignoring cache image %q because it was corrupt", c.origImage.Name())
 		return platform.CacheMetadata{}, nil
@@ -92,11 +130,31 @@ func (c *ImageCache) RetrieveMetadata() (platform.CacheMetadata, error) {
 	return meta, nil
 }
 
+// SetCompression configures the compression of the layer blobs of the cache image; by default, layer blobs are gzip-compressed.
+// Layer blobs are always compressed, so CompressionNone results in gzip-compressed blobs as well.
+// Layer blobs are read regardless of their compression, so that changing the compression does not invalidate the cache.
+func (c *ImageCache) SetCompression(compression Compression) {
+	c.compression = compression
+}
+
 func (c *ImageCache) AddLayerFile(tarPath string, diffID string) error {
 	if c.committed {
 		return errCacheCommitted
 	}
-	return c.newImage.AddLayerWithDiffID(tarPath, diffID)
+	if c.compression != CompressionZstd {
+		return c.newImage.AddLayerWithDiffID(tarPath, diffID)
+	}
+	// the compressed tar is uploaded as is, and must remain until the cache is committed like the provided tar
+	fh, err := os.Open(tarPath)
+	if err != nil {
+		return errors.Wrapf(err, "opening layer '%s'", diffID)
+	}
+	defer fh.Close()
+	compressedPath := tarPath + ".zst"
+	if err = writeCompressed(compressedPath, fh, c.compression); err != nil {
+		return errors.Wrapf(err, "compressing layer '%s'", diffID)
+	}
+	return c.newImage.AddLayerWithDiffID(compressedPath, diffID)
 }
 
 func (c *ImageCache) ReuseLayer(diffID string) error {
@@ -106,8 +164,58 @@ func (c *ImageCache) ReuseLayer(diffID string) error {
 	return c.newImage.ReuseLayer(diffID)
 }
 
+// RetrieveLayer returns a reader of the uncompressed contents of the layer with the provided diffID.
+// Layer blobs with a zstd media type are decompressed with zstd; as every added layer is given the media type requested for the cache image,
+// the compression of other layer blobs is detected from their leading bytes.
 func (c *ImageCache) RetrieveLayer(diffID string) (io.ReadCloser, error) {
-	return c.origImage.GetLayer(diffID)
+	layer := c.origLayer(diffID)
+	if layer == nil {
+		rc, err := c.origImage.GetLayer(diffID)
+		if err != nil {
+			return nil, err
+		}
+		return decompressingReader(rc)
+	}
+	mediaType, err := layer.MediaType()
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting media type of layer '%s'", diffID)
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting layer '%s'", diffID)
+	}
+	if mediaType == types.OCILayerZStd {
+		return decompressorFor(CompressionZstd, rc, rc)
+	}
+	return decompressingReader(rc)
+}
+
+// origLayer returns the layer with the provided diffID from the underlying image of the original image,
+// or nil if the original image does not have an underlying image or the layer.
+func (c *ImageCache) origLayer(diffID string) v1.Layer {
+	underlying := c.origImage.UnderlyingImage()
+	if underlying == nil {
+		return nil
+	}
+	hash, err := v1.NewHash(diffID)
+	if err != nil {
+		return nil
+	}
+	layer, err := underlying.LayerByDiffID(hash)
+	if err != nil {
+		return nil
+	}
+	return layer
+}
+
+// VerifyIntegrity returns an error if the layer with the provided diffID is missing from the original image
+// or its uncompressed contents do not match the diffID.
+func (c *ImageCache) VerifyIntegrity(diffID string) error {
+	rc, err := c.RetrieveLayer(diffID)
+	if err != nil {
+		return err
+	}
+	return verifyDiffID(rc, diffID)
 }
 
 func (c *ImageCache) Commit() error {
@@ -1,16 +1,33 @@
 package cache_test
 
 import (
+	"archive/tar"
 	"fmt"
 	"io"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/golang/mock/gomock"
-
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/compression"
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/buildpacks/imgutil"
 	"github.com/buildpacks/imgutil/fakes"
 	"github.com/buildpacks/imgutil/local"
+	"github.com/buildpacks/imgutil/remote"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 
@@ -151,6 +168,36 @@ func testImageCache(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#VerifyIntegrity", func() {
+		when("layer matches its SHA", func() {
+			it.Before(func() {
+				h.AssertNil(t, fakeOriginalImage.AddLayer(testLayerTarPath))
+			})
+
+			it("succeeds", func() {
+				h.AssertNil(t, subject.VerifyIntegrity(testLayerSHA))
+			})
+		})
+
+		when("layer does not match its SHA", func() {
+			it.Before(func() {
+				h.AssertNil(t, fakeOriginalImage.AddLayerWithDiffID(testLayerTarPath, "sha256:"+strings.Repeat("0", 64)))
+			})
+
+			it("returns an error", func() {
+				err := subject.VerifyIntegrity("sha256:" + strings.Repeat("0", 64))
+				h.AssertError(t, err, "has digest '"+testLayerSHA+"'")
+			})
+		})
+
+		when("layer does not exist", func() {
+			it("returns an error", func() {
+				err := subject.VerifyIntegrity("some_nonexistent_sha")
+				h.AssertError(t, err, "failed to get layer with sha 'some_nonexistent_sha'")
+			})
+		})
+	})
+
 	when("#Commit", func() {
 		when("with #SetMetadata", func() {
 			var newMetadata platform.CacheMetadata
@@ -298,4 +345,153 @@ func testImageCache(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 	})
+
+	when("#NewImageCacheFromName", func() {
+		var (
+			registry  *httptest.Server
+			cacheName string
+			layerPath string
+			diffID    string
+		)
+
+		it.Before(func() {
+			registry = httptest.NewServer(ggcrregistry.New(ggcrregistry.Logger(stdlog.New(io.Discard, "", 0))))
+			cacheName = strings.TrimPrefix(registry.URL, "http://") + "/some-cache"
+
+			layerPath = filepath.Join(tmpDir, "layer.tar")
+			f, err := os.Create(layerPath)
+			h.AssertNil(t, err)
+			tw := tar.NewWriter(f)
+			h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: "some-file", Mode: 0644, Size: int64(len("some-content"))}))
+			_, err = tw.Write([]byte("some-content"))
+			h.AssertNil(t, err)
+			h.AssertNil(t, tw.Close())
+			h.AssertNil(t, f.Close())
+			diffID = "sha256:" + h.ComputeSHA256ForFile(t, layerPath)
+		})
+
+		it.After(func() {
+			registry.Close()
+		})
+
+		saveCache := func(opts ...remote.ImageOption) {
+			imageCache, err := cache.NewImageCacheFromName(cacheName, authn.DefaultKeychain, testLogger, cache.NewImageDeleter(cache.NewImageComparer(), testLogger, false), opts...)
+			h.AssertNil(t, err)
+			h.AssertNil(t, imageCache.AddLayerFile(layerPath, diffID))
+			h.AssertNil(t, imageCache.Commit())
+		}
+
+		assertRestorable := func() {
+			imageCache, err := cache.NewImageCacheFromName(cacheName, authn.DefaultKeychain, testLogger, cache.NewImageDeleter(cache.NewImageComparer(), testLogger, false))
+			h.AssertNil(t, err)
+			rc, err := imageCache.RetrieveLayer(diffID)
+			h.AssertNil(t, err)
+			defer rc.Close()
+			contents, err := io.ReadAll(rc)
+			h.AssertNil(t, err)
+			h.AssertEq(t, contents, h.MustReadFile(t, layerPath))
+		}
+
+		manifestMediaType := func() types.MediaType {
+			ref, err := name.ParseReference(cacheName)
+			h.AssertNil(t, err)
+			desc, err := ggcrremote.Get(ref)
+			h.AssertNil(t, err)
+			return desc.MediaType
+		}
+
+		it("saves the cache image with Docker media types by default", func() {
+			saveCache()
+			h.AssertEq(t, manifestMediaType(), types.DockerManifestSchema2)
+			assertRestorable()
+		})
+
+		when("the cache image does not exist", func() {
+			it("returns empty metadata", func() {
+				imageCache, err := cache.NewImageCacheFromName(cacheName, authn.DefaultKeychain, testLogger, cache.NewImageDeleter(cache.NewImageComparer(), testLogger, false))
+				h.AssertNil(t, err)
+				h.AssertEq(t, imageCache.Exists(), false)
+
+				meta, err := imageCache.RetrieveMetadata()
+				h.AssertNil(t, err)
+				h.AssertEq(t, meta, platform.CacheMetadata{})
+			})
+		})
+
+		when("the registry denies access to the cache image", func() {
+			it.Before(func() {
+				registry.Close()
+				registry = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path == "/v2/" {
+						w.WriteHeader(http.StatusOK)
+						return
+					}
+					w.WriteHeader(http.StatusUnauthorized)
+				}))
+				cacheName = strings.TrimPrefix(registry.URL, "http://") + "/some-cache"
+			})
+
+			it("returns an error", func() {
+				_, err := cache.NewImageCacheFromName(cacheName, authn.DefaultKeychain, testLogger, cache.NewImageDeleter(cache.NewImageComparer(), testLogger, false))
+				h.AssertError(t, err, fmt.Sprintf("accessing cache image %q", cacheName))
+				h.AssertError(t, err, "401 Unauthorized")
+			})
+		})
+
+		when("OCI media types are requested", func() {
+			it("saves the cache image with OCI media types", func() {
+				saveCache(remote.WithMediaTypes(imgutil.OCITypes))
+				h.AssertEq(t, manifestMediaType(), types.OCIManifestSchema1)
+				assertRestorable()
+			})
+		})
+
+		when("zstd compression is selected", func() {
+			it("saves zstd-compressed layer blobs", func() {
+				imageCache, err := cache.NewImageCacheFromName(cacheName, authn.DefaultKeychain, testLogger, cache.NewImageDeleter(cache.NewImageComparer(), testLogger, false))
+				h.AssertNil(t, err)
+				imageCache.SetCompression(cache.CompressionZstd)
+				h.AssertNil(t, imageCache.AddLayerFile(layerPath, diffID))
+				h.AssertNil(t, imageCache.Commit())
+
+				ref, err := name.ParseReference(cacheName)
+				h.AssertNil(t, err)
+				img, err := ggcrremote.Image(ref)
+				h.AssertNil(t, err)
+				layers, err := img.Layers()
+				h.AssertNil(t, err)
+				h.AssertEq(t, len(layers), 1)
+				rc, err := layers[0].Compressed()
+				h.AssertNil(t, err)
+				defer rc.Close()
+				magic := make([]byte, 4)
+				_, err = io.ReadFull(rc, magic)
+				h.AssertNil(t, err)
+				h.AssertEq(t, magic, []byte{0x28, 0xb5, 0x2f, 0xfd})
+
+				assertRestorable()
+			})
+		})
+
+		when("a layer blob has a zstd media type", func() {
+			it.Before(func() {
+				layer, err := tarball.LayerFromFile(layerPath, tarball.WithCompression(compression.ZStd), tarball.WithMediaType(types.OCILayerZStd))
+				h.AssertNil(t, err)
+				img, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1), mutate.Addendum{Layer: layer, MediaType: types.OCILayerZStd})
+				h.AssertNil(t, err)
+				configFile, err := img.ConfigFile()
+				h.AssertNil(t, err)
+				configFile.OS = runtime.GOOS
+				img, err = mutate.ConfigFile(img, configFile)
+				h.AssertNil(t, err)
+				ref, err := name.ParseReference(cacheName)
+				h.AssertNil(t, err)
+				h.AssertNil(t, ggcrremote.Write(ref, img))
+			})
+
+			it("restores the layer", func() {
+				assertRestorable()
+			})
+		})
+	})
 }
@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// lockFileName is the name of the file that is locked to serialize commits and pruning of a volume cache
+// shared by concurrent builds.
+const lockFileName = "lock"
+
+// lockDir takes an exclusive lock on the lock file in dir, waiting for a lock held by another process to be released.
+// The lock is held on the open file rather than by the existence of the file, so it is released by the operating system
+// if the process exits without releasing it, and a lock file left behind by such a process never blocks later builds.
+// The returned function releases the lock.
+func lockDir(dir string) (func(), error) {
+	fh, err := os.OpenFile(filepath.Join(dir, lockFileName), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "locking cache '%s'", dir)
+	}
+	if err := lockFile(fh); err != nil {
+		_ = fh.Close()
+		return nil, errors.Wrapf(err, "locking cache '%s'", dir)
+	}
+	return func() {
+		_ = unlockFile(fh)
+		_ = fh.Close()
+	}, nil
+}
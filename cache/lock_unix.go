@@ -0,0 +1,22 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package cache
+
+import (
+	"os"
+	"syscall"
+)
+
+func lockFile(fh *os.File) error {
+	for {
+		err := syscall.Flock(int(fh.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+func unlockFile(fh *os.File) error {
+	return syscall.Flock(int(fh.Fd()), syscall.LOCK_UN)
+}
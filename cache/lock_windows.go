@@ -0,0 +1,15 @@
+package cache
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+func lockFile(fh *os.File) error {
+	return windows.LockFileEx(windows.Handle(fh.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &windows.Overlapped{})
+}
+
+func unlockFile(fh *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(fh.Fd()), 0, 1, 0, &windows.Overlapped{})
+}
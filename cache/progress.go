@@ -0,0 +1,32 @@
+package cache
+
+import "io"
+
+// ProgressFunc is called with the total number of bytes read so far from a layer.
+type ProgressFunc func(bytesRead int64)
+
+// ProgressReader wraps a layer returned by RetrieveLayer, from any cache, and reports progress as it is read.
+type ProgressReader struct {
+	io.ReadCloser
+	interval int64
+	next     int64
+	read     int64
+	fn       ProgressFunc
+}
+
+// NewProgressReader returns a ProgressReader that calls fn each time at least another interval bytes have been read from rc.
+// fn is called synchronously from Read, so it should return quickly; with a large interval,
+// the overhead of reporting progress is negligible compared to the cost of reading the layer.
+func NewProgressReader(rc io.ReadCloser, interval int64, fn ProgressFunc) *ProgressReader {
+	return &ProgressReader{ReadCloser: rc, interval: interval, next: interval, fn: fn}
+}
+
+func (p *ProgressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	p.read += int64(n)
+	if p.interval > 0 && p.read >= p.next {
+		p.fn(p.read)
+		p.next = p.read + p.interval
+	}
+	return n, err
+}
@@ -0,0 +1,53 @@
+package cache_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/cache"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestProgressReader(t *testing.T) {
+	spec.Run(t, "ProgressReader", testProgressReader, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testProgressReader(t *testing.T, when spec.G, it spec.S) {
+	when("#Read", func() {
+		it("reports progress each time another interval bytes have been read", func() {
+			var reported []int64
+			subject := cache.NewProgressReader(io.NopCloser(strings.NewReader(strings.Repeat("a", 10))), 4, func(bytesRead int64) {
+				reported = append(reported, bytesRead)
+			})
+
+			buf := make([]byte, 3)
+			var contents []byte
+			for {
+				n, err := subject.Read(buf)
+				contents = append(contents, buf[:n]...)
+				if err == io.EOF {
+					break
+				}
+				h.AssertNil(t, err)
+			}
+
+			h.AssertEq(t, string(contents), strings.Repeat("a", 10))
+			h.AssertEq(t, reported, []int64{6, 10})
+		})
+
+		when("the interval is zero", func() {
+			it("does not report progress", func() {
+				subject := cache.NewProgressReader(io.NopCloser(strings.NewReader("some-data")), 0, func(int64) {
+					t.Fatalf("unexpected progress")
+				})
+				contents, err := io.ReadAll(subject)
+				h.AssertNil(t, err)
+				h.AssertEq(t, string(contents), "some-data")
+			})
+		})
+	})
+}
@@ -16,10 +16,15 @@ import (
 
 type VolumeCache struct {
 	committed    bool
+	chunked      bool
+	compression  Compression
 	dir          string
 	backupDir    string
 	stagingDir   string
 	committedDir string
+	chunksDir    string
+	// reassembledDir holds chunked layers reassembled into layer files by RetrieveLayerFile
+	reassembledDir string
 }
 
 func NewVolumeCache(dir string) (*VolumeCache, error) {
@@ -28,18 +33,23 @@ func NewVolumeCache(dir string) (*VolumeCache, error) {
 	}
 
 	c := &VolumeCache{
-		dir:          dir,
-		backupDir:    filepath.Join(dir, "committed-backup"),
-		stagingDir:   filepath.Join(dir, "staging"),
-		committedDir: filepath.Join(dir, "committed"),
+		dir:            dir,
+		backupDir:      filepath.Join(dir, "committed-backup"),
+		stagingDir:     filepath.Join(dir, "staging"),
+		committedDir:   filepath.Join(dir, "committed"),
+		chunksDir:      filepath.Join(dir, chunksDirName),
+		reassembledDir: filepath.Join(dir, "reassembled"),
 	}
 
 	if err := c.setupStagingDir(); err != nil {
 		return nil, errors.Wrapf(err, "initializing staging directory '%s'", c.stagingDir)
 	}
+	if err := os.RemoveAll(c.reassembledDir); err != nil {
+		return nil, errors.Wrapf(err, "removing reassembled directory '%s'", c.reassembledDir)
+	}
 
-	if err := os.RemoveAll(c.backupDir); err != nil {
-		return nil, errors.Wrapf(err, "removing backup directory '%s'", c.backupDir)
+	if err := c.recoverInterruptedCommit(); err != nil {
+		return nil, err
 	}
 
 	if err := os.MkdirAll(c.committedDir, 0777); err != nil {
@@ -49,6 +59,25 @@ func NewVolumeCache(dir string) (*VolumeCache, error) {
 	return c, nil
 }
 
+// NewChunkedVolumeCache returns a VolumeCache that stores added layers as content-defined chunks,
+// so that chunks which are unchanged between builds are stored once and reused across commits.
+// Layers are reassembled transparently when retrieved; any VolumeCache can retrieve chunked layers.
+func NewChunkedVolumeCache(dir string) (*VolumeCache, error) {
+	c, err := NewVolumeCache(dir)
+	if err != nil {
+		return nil, err
+	}
+	c.chunked = true
+	return c, nil
+}
+
+// SetCompression configures the compression of layer files added to the cache; by default, layer files are not compressed.
+// Layer files are read regardless of their compression, so that changing the compression does not invalidate the cache.
+// Chunked layers are not compressed.
+func (c *VolumeCache) SetCompression(compression Compression) {
+	c.compression = compression
+}
+
 func (c *VolumeCache) Exists() bool {
 	if _, err := os.Stat(c.committedDir); err != nil {
 		return false
@@ -101,12 +130,36 @@ func (c *VolumeCache) AddLayerFile(tarPath string, diffID string) error {
 		return errCacheCommitted
 	}
 	layerTar := diffIDPath(c.stagingDir, diffID)
-	if _, err := os.Stat(layerTar); err == nil {
-		// don't waste time rewriting an identical layer
-		return nil
+	manifest := chunkManifestPath(c.stagingDir, diffID)
+	for _, path := range []string{layerTar, manifest} {
+		if _, err := os.Stat(path); err == nil {
+			// don't waste time rewriting an identical layer
+			return nil
+		}
 	}
 
-	if err := fsutil.Copy(tarPath, layerTar); err != nil {
+	if c.chunked {
+		digests, err := writeChunks(tarPath, c.chunksDir)
+		if err != nil {
+			return errors.Wrapf(err, "caching layer (%s)", diffID)
+		}
+		if err := writeChunkManifest(manifest, digests); err != nil {
+			return errors.Wrapf(err, "caching layer (%s)", diffID)
+		}
+		return nil
+	}
+	if c.compression == "" || c.compression == CompressionNone {
+		if err := fsutil.Copy(tarPath, layerTar); err != nil {
+			return errors.Wrapf(err, "caching layer (%s)", diffID)
+		}
+		return nil
+	}
+	fh, err := os.Open(tarPath)
+	if err != nil {
+		return errors.Wrapf(err, "caching layer (%s)", diffID)
+	}
+	defer fh.Close()
+	if err := writeCompressed(layerTar, fh, c.compression); err != nil {
 		return errors.Wrapf(err, "caching layer (%s)", diffID)
 	}
 	return nil
@@ -117,13 +170,7 @@ func (c *VolumeCache) AddLayer(rc io.ReadCloser, diffID string) error {
 		return errCacheCommitted
 	}
 
-	fh, err := os.Create(diffIDPath(c.stagingDir, diffID))
-	if err != nil {
-		return errors.Wrapf(err, "create layer file in cache")
-	}
-	defer fh.Close()
-
-	if _, err := io.Copy(fh, rc); err != nil {
+	if err := writeCompressed(diffIDPath(c.stagingDir, diffID), rc, c.compression); err != nil {
 		return errors.Wrap(err, "copying layer to tar file")
 	}
 	return nil
@@ -133,6 +180,12 @@ func (c *VolumeCache) ReuseLayer(diffID string) error {
 	if c.committed {
 		return errCacheCommitted
 	}
+	if _, err := os.Stat(chunkManifestPath(c.committedDir, diffID)); err == nil {
+		if err := os.Link(chunkManifestPath(c.committedDir, diffID), chunkManifestPath(c.stagingDir, diffID)); err != nil && !os.IsExist(err) {
+			return errors.Wrapf(err, "reusing layer (%s)", diffID)
+		}
+		return nil
+	}
 	if err := os.Link(diffIDPath(c.committedDir, diffID), diffIDPath(c.stagingDir, diffID)); err != nil && !os.IsExist(err) {
 		return errors.Wrapf(err, "reusing layer (%s)", diffID)
 	}
@@ -140,6 +193,9 @@ func (c *VolumeCache) ReuseLayer(diffID string) error {
 }
 
 func (c *VolumeCache) RetrieveLayer(diffID string) (io.ReadCloser, error) {
+	if digests, err := readChunkManifest(chunkManifestPath(c.committedDir, diffID)); err == nil {
+		return newChunkReader(c.chunksDir, digests), nil
+	}
 	path, err := c.RetrieveLayerFile(diffID)
 	if err != nil {
 		return nil, err
@@ -148,20 +204,41 @@ func (c *VolumeCache) RetrieveLayer(diffID string) (io.ReadCloser, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "opening layer with SHA '%s'", diffID)
 	}
-	return file, nil
+	return decompressingReader(file)
+}
+
+// VerifyIntegrity returns an error if the layer with the provided diffID is missing or its contents do not match the diffID,
+// for example because the layer file was truncated.
+func (c *VolumeCache) VerifyIntegrity(diffID string) error {
+	rc, err := c.RetrieveLayer(diffID)
+	if err != nil {
+		return err
+	}
+	return verifyDiffID(rc, diffID)
 }
 
+// HasLayer returns true if the committed cache has the layer with the provided diffID, either as a layer file or as chunks.
 func (c *VolumeCache) HasLayer(diffID string) (bool, error) {
-	if _, err := os.Stat(diffIDPath(c.committedDir, diffID)); err != nil {
-		if os.IsNotExist(err) {
-			return false, nil
+	for _, path := range []string{chunkManifestPath(c.committedDir, diffID), diffIDPath(c.committedDir, diffID)} {
+		if _, err := os.Stat(path); err == nil {
+			return true, nil
+		} else if !os.IsNotExist(err) {
+			return false, errors.Wrapf(err, "retrieving layer with SHA '%s'", diffID)
 		}
-		return false, errors.Wrapf(err, "retrieving layer with SHA '%s'", diffID)
 	}
-	return true, nil
+	return false, nil
 }
 
+// RetrieveLayerFile returns the path of the stored layer file, which may be compressed (see SetCompression).
+// A chunked layer is reassembled into a layer file, which remains until the cache directory is next opened.
 func (c *VolumeCache) RetrieveLayerFile(diffID string) (string, error) {
+	if digests, err := readChunkManifest(chunkManifestPath(c.committedDir, diffID)); err == nil {
+		path := diffIDPath(c.reassembledDir, diffID)
+		if err := reassembleChunks(path, c.chunksDir, digests); err != nil {
+			return "", errors.Wrapf(err, "reassembling layer with SHA '%s'", diffID)
+		}
+		return path, nil
+	}
 	path := diffIDPath(c.committedDir, diffID)
 	if _, err := os.Stat(path); err != nil {
 		if os.IsNotExist(err) {
@@ -177,6 +254,11 @@ func (c *VolumeCache) Commit() error {
 		return errCacheCommitted
 	}
 	c.committed = true
+	unlock, err := lockDir(c.dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	if err := fsutil.RenameWithWindowsFallback(c.committedDir, c.backupDir); err != nil {
 		return errors.Wrap(err, "backing up cache")
 	}
@@ -189,6 +271,73 @@ func (c *VolumeCache) Commit() error {
 		return errors.Wrap(err1, "committing cache")
 	}
 
+	// pruning is best-effort: leftover chunks take up space, but don't affect the committed cache
+	_ = pruneChunks(c.chunksDir, c.committedDir, c.stagingDir)
+	return nil
+}
+
+// Prune removes layers from the committed cache that are neither in keepSHAs nor referenced by the committed cache metadata,
+// along with chunks that are no longer referenced by any layer.
+// Pruning and committing are serialized with a lock file in the cache directory,
+// so that builds sharing the directory do not prune a cache while it is being committed.
+func (c *VolumeCache) Prune(keepSHAs []string) error {
+	unlock, err := lockDir(c.dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	meta, err := c.RetrieveMetadata()
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool)
+	for _, sha := range append(keepSHAs, meta.LayerSHAs()...) {
+		keep[filepath.Base(diffIDPath(c.committedDir, sha))] = true
+		keep[filepath.Base(chunkManifestPath(c.committedDir, sha))] = true
+	}
+	fis, err := os.ReadDir(c.committedDir)
+	if err != nil {
+		return errors.Wrapf(err, "reading committed directory '%s'", c.committedDir)
+	}
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() || keep[name] || !(strings.HasSuffix(name, ".tar") || strings.HasSuffix(name, chunkManifestSuffix)) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.committedDir, name)); err != nil {
+			return errors.Wrapf(err, "pruning '%s'", name)
+		}
+	}
+	return pruneChunks(c.chunksDir, c.committedDir, c.stagingDir)
+}
+
+// recoverInterruptedCommit restores the committed directory from the backup directory if a commit was interrupted (e.g., by a crash)
+// after the committed directory was backed up but before the staging directory replaced it, so that the committed state is not lost;
+// otherwise it removes any leftover backup directory. Staged state is never committed by recovery.
+func (c *VolumeCache) recoverInterruptedCommit() error {
+	if _, err := os.Stat(c.backupDir); os.IsNotExist(err) {
+		return nil
+	}
+	// a commit in progress renames the committed directory, so hold the lock to see a consistent state;
+	// the lock of an interrupted commit was released when its process exited
+	unlock, err := lockDir(c.dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err := os.Stat(c.backupDir); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Stat(c.committedDir); os.IsNotExist(err) {
+		if err := fsutil.RenameWithWindowsFallback(c.backupDir, c.committedDir); err != nil {
+			return errors.Wrapf(err, "restoring committed directory from backup directory '%s'", c.backupDir)
+		}
+		return nil
+	}
+	if err := os.RemoveAll(c.backupDir); err != nil {
+		return errors.Wrapf(err, "removing backup directory '%s'", c.backupDir)
+	}
 	return nil
 }
 
@@ -1,8 +1,13 @@
 package cache_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"testing"
@@ -12,6 +17,7 @@ import (
 
 	"github.com/buildpacks/lifecycle/buildpack"
 	"github.com/buildpacks/lifecycle/cache"
+	"github.com/buildpacks/lifecycle/layers"
 	"github.com/buildpacks/lifecycle/platform"
 	h "github.com/buildpacks/lifecycle/testhelpers"
 )
@@ -117,6 +123,57 @@ func testVolumeCache(t *testing.T, when spec.G, it spec.S) {
 					t.Fatal("expect NewVolumeCache to clear the staging dir")
 				}
 			})
+
+			when("committed dir also exists", func() {
+				it("clears the backup dir and keeps the committed dir", func() {
+					h.AssertNil(t, os.MkdirAll(committedDir, 0777))
+					h.AssertNil(t, os.WriteFile(filepath.Join(committedDir, "io.buildpacks.lifecycle.cache.metadata"), []byte(`{"buildpacks": [{"key": "committed.bp.id"}]}`), 0600))
+
+					subject, err := cache.NewVolumeCache(volumeDir)
+					h.AssertNil(t, err)
+
+					h.AssertPathDoesNotExist(t, backupDir)
+					meta, err := subject.RetrieveMetadata()
+					h.AssertNil(t, err)
+					h.AssertEq(t, meta.Buildpacks[0].ID, "committed.bp.id")
+				})
+			})
+		})
+
+		when("a commit was interrupted after the committed dir was backed up", func() {
+			it.Before(func() {
+				h.AssertNil(t, os.MkdirAll(backupDir, 0777))
+				h.AssertNil(t, os.WriteFile(filepath.Join(backupDir, "io.buildpacks.lifecycle.cache.metadata"), []byte(`{"buildpacks": [{"key": "committed.bp.id"}]}`), 0600))
+				h.AssertNil(t, os.MkdirAll(stagingDir, 0777))
+				h.AssertNil(t, os.WriteFile(filepath.Join(stagingDir, "io.buildpacks.lifecycle.cache.metadata"), []byte(`{"buildpacks": [{"key": "staged.bp.id"}]}`), 0600))
+			})
+
+			it("restores the committed dir from the backup dir and discards the staged state", func() {
+				subject, err := cache.NewVolumeCache(volumeDir)
+				h.AssertNil(t, err)
+
+				h.AssertPathDoesNotExist(t, backupDir)
+				meta, err := subject.RetrieveMetadata()
+				h.AssertNil(t, err)
+				h.AssertEq(t, meta.Buildpacks[0].ID, "committed.bp.id")
+				h.AssertPathDoesNotExist(t, filepath.Join(stagingDir, "io.buildpacks.lifecycle.cache.metadata"))
+			})
+
+			when("the lock file was left behind by the interrupted commit", func() {
+				it.Before(func() {
+					h.AssertNil(t, os.WriteFile(filepath.Join(volumeDir, "lock"), nil, 0600))
+				})
+
+				it("restores the committed dir from the backup dir", func() {
+					subject, err := cache.NewVolumeCache(volumeDir)
+					h.AssertNil(t, err)
+
+					h.AssertPathDoesNotExist(t, backupDir)
+					meta, err := subject.RetrieveMetadata()
+					h.AssertNil(t, err)
+					h.AssertEq(t, meta.Buildpacks[0].ID, "committed.bp.id")
+				})
+			})
 		})
 	})
 
@@ -211,6 +268,44 @@ func testVolumeCache(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("#VerifyIntegrity", func() {
+			var layerSHA string
+
+			it.Before(func() {
+				layerTarPath := filepath.Join(tmpDir, "some-layer.tar")
+				h.AssertNil(t, os.WriteFile(layerTarPath, []byte("dummy data"), 0600))
+				layerSHA = "sha256:" + h.ComputeSHA256ForFile(t, layerTarPath)
+				h.AssertNil(t, subject.AddLayerFile(layerTarPath, layerSHA))
+				h.AssertNil(t, subject.Commit())
+			})
+
+			when("layer matches its SHA", func() {
+				it("succeeds", func() {
+					h.AssertNil(t, subject.VerifyIntegrity(layerSHA))
+				})
+			})
+
+			when("layer does not match its SHA", func() {
+				it.Before(func() {
+					layerPath, err := subject.RetrieveLayerFile(layerSHA)
+					h.AssertNil(t, err)
+					h.AssertNil(t, os.Truncate(layerPath, 5))
+				})
+
+				it("returns an error", func() {
+					err := subject.VerifyIntegrity(layerSHA)
+					h.AssertError(t, err, fmt.Sprintf("layer with SHA '%s' has digest", layerSHA))
+				})
+			})
+
+			when("layer does not exist", func() {
+				it("returns an error", func() {
+					err := subject.VerifyIntegrity("some_nonexistent_sha")
+					h.AssertError(t, err, "layer with SHA 'some_nonexistent_sha' not found")
+				})
+			})
+		})
+
 		when("#RetrieveLayerFile", func() {
 			when("layer exists", func() {
 				it.Before(func() {
@@ -520,4 +615,311 @@ func testVolumeCache(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 	})
+
+	when("chunked", func() {
+		var (
+			layerData    []byte
+			layerTarPath string
+			layerSHA     string
+			chunksDir    string
+		)
+
+		it.Before(func() {
+			var err error
+			subject, err = cache.NewChunkedVolumeCache(volumeDir)
+			h.AssertNil(t, err)
+
+			chunksDir = filepath.Join(volumeDir, "chunks")
+			layerData = make([]byte, 4<<20)
+			rand.New(rand.NewSource(1)).Read(layerData)
+			layerTarPath = filepath.Join(tmpDir, "some-layer.tar")
+			h.AssertNil(t, os.WriteFile(layerTarPath, layerData, 0600))
+			layerSHA = "sha256:" + h.ComputeSHA256ForFile(t, layerTarPath)
+
+			h.AssertNil(t, subject.AddLayerFile(layerTarPath, layerSHA))
+			h.AssertNil(t, subject.Commit())
+		})
+
+		it("stores the layer as chunks", func() {
+			h.AssertPathDoesNotExist(t, filepath.Join(committedDir, layerSHA+".tar"))
+			chunks, err := os.ReadDir(chunksDir)
+			h.AssertNil(t, err)
+			if len(chunks) < 2 {
+				t.Fatalf("expected layer to be stored as multiple chunks, found %d", len(chunks))
+			}
+		})
+
+		it("reassembles the layer when retrieved", func() {
+			rc, err := subject.RetrieveLayer(layerSHA)
+			h.AssertNil(t, err)
+			defer rc.Close()
+
+			bytes, err := io.ReadAll(rc)
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(bytes), len(layerData))
+			h.AssertNil(t, subject.VerifyIntegrity(layerSHA))
+		})
+
+		it("has the layer and reassembles it into a layer file", func() {
+			found, err := subject.HasLayer(layerSHA)
+			h.AssertNil(t, err)
+			h.AssertEq(t, found, true)
+
+			layerPath, err := subject.RetrieveLayerFile(layerSHA)
+			h.AssertNil(t, err)
+			h.AssertEq(t, h.MustReadFile(t, layerPath), layerData)
+		})
+
+		when("a layer changes slightly in the next build", func() {
+			var (
+				chunksBefore  int
+				nextLayerSHA  string
+				nextLayerData []byte
+			)
+
+			it.Before(func() {
+				chunks, err := os.ReadDir(chunksDir)
+				h.AssertNil(t, err)
+				chunksBefore = len(chunks)
+
+				nextLayerData = append([]byte("some-prefix"), layerData...)
+				h.AssertNil(t, os.WriteFile(layerTarPath, nextLayerData, 0600))
+				nextLayerSHA = "sha256:" + h.ComputeSHA256ForFile(t, layerTarPath)
+
+				subject, err = cache.NewChunkedVolumeCache(volumeDir)
+				h.AssertNil(t, err)
+				h.AssertNil(t, subject.AddLayerFile(layerTarPath, nextLayerSHA))
+				h.AssertNil(t, subject.Commit())
+			})
+
+			it("reuses unchanged chunks and removes unreferenced chunks", func() {
+				chunks, err := os.ReadDir(chunksDir)
+				h.AssertNil(t, err)
+				if len(chunks) > chunksBefore+1 {
+					t.Fatalf("expected at most %d chunks, found %d", chunksBefore+1, len(chunks))
+				}
+
+				rc, err := subject.RetrieveLayer(nextLayerSHA)
+				h.AssertNil(t, err)
+				defer rc.Close()
+				bytes, err := io.ReadAll(rc)
+				h.AssertNil(t, err)
+				h.AssertEq(t, len(bytes), len(nextLayerData))
+				h.AssertNil(t, subject.VerifyIntegrity(nextLayerSHA))
+
+				_, err = subject.RetrieveLayer(layerSHA)
+				h.AssertError(t, err, "not found")
+			})
+		})
+
+		when("a layer is reused", func() {
+			it("retrieves the layer after the next commit", func() {
+				var err error
+				subject, err = cache.NewChunkedVolumeCache(volumeDir)
+				h.AssertNil(t, err)
+				h.AssertNil(t, subject.ReuseLayer(layerSHA))
+				h.AssertNil(t, subject.Commit())
+
+				h.AssertNil(t, subject.VerifyIntegrity(layerSHA))
+			})
+		})
+	})
+
+	when("compressed", func() {
+		var (
+			layerData    []byte
+			layerTarPath string
+			layerSHA     string
+		)
+
+		it.Before(func() {
+			layerData = bytes.Repeat([]byte("some-layer-data"), 64<<10)
+			layerTarPath = filepath.Join(tmpDir, "some-layer.tar")
+			h.AssertNil(t, os.WriteFile(layerTarPath, layerData, 0600))
+			layerSHA = "sha256:" + h.ComputeSHA256ForFile(t, layerTarPath)
+		})
+
+		for _, compression := range []cache.Compression{cache.CompressionGzip, cache.CompressionZstd} {
+			compression := compression
+			when(string(compression), func() {
+				it.Before(func() {
+					var err error
+					subject, err = cache.NewVolumeCache(volumeDir)
+					h.AssertNil(t, err)
+					subject.SetCompression(compression)
+					h.AssertNil(t, subject.AddLayerFile(layerTarPath, layerSHA))
+					h.AssertNil(t, subject.Commit())
+				})
+
+				it("stores the layer compressed", func() {
+					layerPath, err := subject.RetrieveLayerFile(layerSHA)
+					h.AssertNil(t, err)
+					fi, err := os.Stat(layerPath)
+					h.AssertNil(t, err)
+					if fi.Size() >= int64(len(layerData)) {
+						t.Fatalf("expected layer to be compressed, found %d bytes", fi.Size())
+					}
+				})
+
+				it("decompresses the layer when retrieved", func() {
+					rc, err := subject.RetrieveLayer(layerSHA)
+					h.AssertNil(t, err)
+					defer rc.Close()
+
+					contents, err := io.ReadAll(rc)
+					h.AssertNil(t, err)
+					h.AssertEq(t, len(contents), len(layerData))
+					h.AssertNil(t, subject.VerifyIntegrity(layerSHA))
+				})
+
+				it("retrieves layers stored by a cache without compression", func() {
+					otherLayerPath := filepath.Join(tmpDir, "other-layer.tar")
+					h.AssertNil(t, os.WriteFile(otherLayerPath, []byte("other-layer-data"), 0600))
+					otherLayerSHA := "sha256:" + h.ComputeSHA256ForFile(t, otherLayerPath)
+
+					uncompressed, err := cache.NewVolumeCache(volumeDir)
+					h.AssertNil(t, err)
+					h.AssertNil(t, uncompressed.ReuseLayer(layerSHA))
+					h.AssertNil(t, uncompressed.AddLayerFile(otherLayerPath, otherLayerSHA))
+					h.AssertNil(t, uncompressed.Commit())
+
+					h.AssertNil(t, uncompressed.VerifyIntegrity(layerSHA))
+					h.AssertNil(t, uncompressed.VerifyIntegrity(otherLayerSHA))
+				})
+			})
+		}
+	})
+
+	when(".ParseCompression", func() {
+		it("errors for invalid compressions", func() {
+			_, err := cache.ParseCompression("brotli")
+			h.AssertError(t, err, `invalid cache compression "brotli"`)
+		})
+	})
+
+	when("#Prune", func() {
+		it.Before(func() {
+			var err error
+			subject, err = cache.NewVolumeCache(volumeDir)
+			h.AssertNil(t, err)
+
+			for _, sha := range []string{"sha256:kept", "sha256:referenced", "sha256:stale"} {
+				h.AssertNil(t, os.WriteFile(filepath.Join(committedDir, sha+".tar"), []byte(sha), 0600))
+			}
+			h.AssertNil(t, os.WriteFile(
+				filepath.Join(committedDir, cache.MetadataLabel),
+				[]byte(`{"buildpacks": [{"key": "some-buildpack", "layers": {"some-layer": {"sha": "sha256:referenced", "cache": true}}}]}`),
+				0600,
+			))
+		})
+
+		it("removes layers that are neither kept nor referenced by the cache metadata", func() {
+			h.AssertNil(t, subject.Prune([]string{"sha256:kept"}))
+
+			h.AssertPathExists(t, filepath.Join(committedDir, "sha256:kept.tar"))
+			h.AssertPathExists(t, filepath.Join(committedDir, "sha256:referenced.tar"))
+			h.AssertPathExists(t, filepath.Join(committedDir, cache.MetadataLabel))
+			h.AssertPathDoesNotExist(t, filepath.Join(committedDir, "sha256:stale.tar"))
+		})
+
+		when("a lock file was left behind by a process that exited", func() {
+			it("does not wait for the lock", func() {
+				h.AssertNil(t, os.WriteFile(filepath.Join(volumeDir, "lock"), nil, 0600))
+
+				h.AssertNil(t, subject.Prune(nil))
+				h.AssertPathDoesNotExist(t, filepath.Join(committedDir, "sha256:stale.tar"))
+			})
+		})
+	})
+}
+
+func BenchmarkVolumeCacheRetrieveLayer(b *testing.B) {
+	for _, compression := range []cache.Compression{cache.CompressionNone, cache.CompressionGzip, cache.CompressionZstd} {
+		b.Run(string(compression), func(b *testing.B) {
+			benchmarkRetrieveLayer(b, compression)
+		})
+	}
+}
+
+// benchmarkRetrieveLayer measures the throughput of restoring a layer stored with the provided compression,
+// i.e., retrieving the layer from the cache and extracting it.
+func benchmarkRetrieveLayer(b *testing.B, compression cache.Compression) {
+	tmpDir := b.TempDir()
+	volumeDir := filepath.Join(tmpDir, "volume")
+	if err := os.MkdirAll(volumeDir, os.ModePerm); err != nil {
+		b.Fatal(err)
+	}
+	layerTarPath := filepath.Join(tmpDir, "some-layer.tar")
+	diffID, size := writeBenchmarkLayer(b, layerTarPath)
+
+	subject, err := cache.NewVolumeCache(volumeDir)
+	if err != nil {
+		b.Fatal(err)
+	}
+	subject.SetCompression(compression)
+	if err = subject.AddLayerFile(layerTarPath, diffID); err != nil {
+		b.Fatal(err)
+	}
+	if err = subject.Commit(); err != nil {
+		b.Fatal(err)
+	}
+
+	dest := filepath.Join(tmpDir, "dest")
+	b.SetBytes(size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rc, err := subject.RetrieveLayer(diffID)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err = layers.Extract(rc, dest); err != nil {
+			b.Fatal(err)
+		}
+		rc.Close()
+		b.StopTimer()
+		if err = os.RemoveAll(dest); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+	}
+}
+
+// writeBenchmarkLayer writes a layer tar of compressible files to the provided path, returning its diffID and size.
+func writeBenchmarkLayer(b *testing.B, path string) (string, int64) {
+	f, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	tw := tar.NewWriter(io.MultiWriter(f, hasher))
+	// random words from a small vocabulary compress roughly like source code and text files
+	var (
+		rng      = rand.New(rand.NewSource(1))
+		words    = make([]string, 256)
+		contents []byte
+	)
+	for i := range words {
+		words[i] = fmt.Sprintf("%x", rng.Int63())[:2+rng.Intn(8)]
+	}
+	for len(contents) < 16<<20 {
+		contents = append(contents, words[rng.Intn(len(words))]...)
+		contents = append(contents, " \n"[rng.Intn(2)])
+	}
+	for i := 0; i < 8; i++ {
+		if err = tw.WriteHeader(&tar.Header{Name: fmt.Sprintf("some-file-%d", i), Mode: 0644, Size: int64(len(contents))}); err != nil {
+			b.Fatal(err)
+		}
+		if _, err = tw.Write(contents); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err = tw.Close(); err != nil {
+		b.Fatal(err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		b.Fatal(err)
+	}
+	return "sha256:" + hex.EncodeToString(hasher.Sum(nil)), fi.Size()
 }
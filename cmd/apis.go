@@ -65,9 +65,49 @@ func VerifyBuildpackAPI(kind, name, requestedVersion string, logger log.Logger)
 	return buildpackAPIError(kind, name, requestedVersion)
 }
 
+// ModuleAPI is the Buildpack API requested by a buildpack or extension.
+type ModuleAPI struct {
+	Kind string
+	Name string
+	API  string
+}
+
+// VerifyBuildpackAPIs verifies the Buildpack API requested by each of the provided modules like VerifyBuildpackAPI,
+// but returns an error listing every module that requests an incompatible API and the APIs supported by the lifecycle,
+// so that it is clear which modules need to be upgraded.
+// If warnOnly is true, unsupported APIs are logged as warnings instead;
+// APIs that cannot be parsed are an error regardless, as the lifecycle cannot run such modules.
+func VerifyBuildpackAPIs(modules []ModuleAPI, warnOnly bool, logger log.Logger) error {
+	var problems, invalid []string
+	for _, module := range modules {
+		if _, err := api.NewVersion(module.API); err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s '%s' requests invalid API '%s'", module.Kind, module.Name, module.API))
+			continue
+		}
+		if err := VerifyBuildpackAPI(module.Kind, module.Name, module.API, logger); err != nil {
+			problems = append(problems, fmt.Sprintf("%s '%s' requests API '%s'", module.Kind, module.Name, module.API))
+		}
+	}
+	if len(problems) == 0 && len(invalid) == 0 {
+		return nil
+	}
+	if warnOnly && len(invalid) == 0 {
+		for _, problem := range problems {
+			logger.Warnf("%s, which is incompatible with the lifecycle (supported APIs: %s)", problem, api.Buildpack.Supported)
+		}
+		logger.Warn("Continuing with incompatible Buildpack APIs; the build may fail or behave unexpectedly")
+		return nil
+	}
+	return FailErrCode(
+		fmt.Errorf("%s; supported APIs: %s", strings.Join(append(invalid, problems...), "; "), api.Buildpack.Supported),
+		CodeForIncompatibleBuildpackAPI,
+		"verify buildpack APIs",
+	)
+}
+
 func buildpackAPIError(moduleKind string, name string, requested string) error {
 	return FailErrCode(
-		fmt.Errorf("buildpack API version '%s' is incompatible with the lifecycle", requested),
+		fmt.Errorf("buildpack API version '%s' is incompatible with the lifecycle (supported APIs: %s)", requested, api.Buildpack.Supported),
 		CodeForIncompatibleBuildpackAPI,
 		fmt.Sprintf("set API for %s '%s'", moduleKind, name),
 	)
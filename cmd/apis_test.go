@@ -171,4 +171,65 @@ func testVerifyAPIs(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 	})
+
+	when("VerifyBuildpackAPIs", func() {
+		var modules []cmd.ModuleAPI
+
+		it.Before(func() {
+			var err error
+			api.Buildpack, err = api.NewAPIs([]string{"1.2", "2.1"}, []string{"1"})
+			h.AssertNil(t, err)
+			modules = []cmd.ModuleAPI{
+				{Kind: buildpack.KindBuildpack, Name: "good-buildpack@1.0", API: "2.1"},
+				{Kind: buildpack.KindBuildpack, Name: "bad-buildpack@1.0", API: "3.0"},
+			}
+		})
+
+		when("a buildpack requests an unsupported API", func() {
+			it("errors with the offending buildpack and the supported APIs", func() {
+				err := cmd.VerifyBuildpackAPIs(modules, false, logger)
+				failErr, ok := err.(*cmd.ErrorFail)
+				if !ok {
+					t.Fatalf("expected an error of type cmd.ErrorFail")
+				}
+				h.AssertEq(t, failErr.Code, 12)
+				h.AssertError(t, err, `Buildpack 'bad-buildpack@1.0' requests API '3.0'; supported APIs: ["1.2", "2.1"]`)
+				h.AssertStringDoesNotContain(t, err.Error(), "good-buildpack")
+			})
+
+			when("warnOnly is true", func() {
+				it("warns", func() {
+					err := cmd.VerifyBuildpackAPIs(modules, true, logger)
+					h.AssertNil(t, err)
+					h.AssertEq(t, len(logHandler.Entries), 2)
+					h.AssertEq(t, logHandler.Entries[0].Level, log.WarnLevel)
+					h.AssertEq(t, logHandler.Entries[0].Message, `Buildpack 'bad-buildpack@1.0' requests API '3.0', which is incompatible with the lifecycle (supported APIs: ["1.2", "2.1"])`)
+				})
+			})
+		})
+
+		when("a buildpack requests an API that cannot be parsed", func() {
+			it.Before(func() {
+				modules = append(modules, cmd.ModuleAPI{Kind: buildpack.KindBuildpack, Name: "invalid-buildpack@1.0", API: "not-a-version"})
+			})
+
+			when("warnOnly is true", func() {
+				it("errors with the offending buildpack", func() {
+					err := cmd.VerifyBuildpackAPIs(modules, true, logger)
+					failErr, ok := err.(*cmd.ErrorFail)
+					if !ok {
+						t.Fatalf("expected an error of type cmd.ErrorFail")
+					}
+					h.AssertEq(t, failErr.Code, 12)
+					h.AssertStringContains(t, err.Error(), `Buildpack 'invalid-buildpack@1.0' requests invalid API 'not-a-version'`)
+				})
+			})
+		})
+
+		when("all buildpacks request supported APIs", func() {
+			it("succeeds", func() {
+				h.AssertNil(t, cmd.VerifyBuildpackAPIs(modules[:1], false, logger))
+			})
+		})
+	})
 }
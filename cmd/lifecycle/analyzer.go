@@ -1,7 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/docker/docker/client"
 	"github.com/google/go-containerregistry/pkg/authn"
@@ -21,6 +25,9 @@ type analyzeCmd struct {
 
 	docker   client.CommonAPIClient // construct if necessary before dropping privileges
 	keychain authn.Keychain         // construct if necessary before dropping privileges
+	egress   *auth.RecordingKeychain
+
+	validateOrder bool // true if an order path was provided, in which case the order is validated before analyzing
 }
 
 // DefineFlags defines the flags that are considered valid and reads their values (if provided).
@@ -42,18 +49,53 @@ func (a *analyzeCmd) DefineFlags() {
 		cli.FlagSkipLayers(&a.SkipLayers)
 		fallthrough
 	default:
+		cli.FlagAllowMutableRunImage(&a.AllowMutableRunImage)
+		cli.FlagAllowPreviousDrift(&a.AllowPreviousDrift)
 		cli.FlagAnalyzedPath(&a.AnalyzedPath)
+		cli.FlagAnalyzeReportPath(&a.AnalyzeReportPath)
+		cli.FlagBuildImage(&a.BuildImageRef)
+		cli.FlagBuildpackLabelSelector(&a.BuildpackLabelSelector)
+		cli.FlagBuildpacksDir(&a.BuildpacksDir)
+		cli.FlagCacheFallback(&a.CacheFallback)
 		cli.FlagCacheImage(&a.CacheImageRef)
+		cli.FlagCacheNamespace(&a.CacheNamespace)
+		cli.FlagClockSkewThreshold(&a.ClockSkewThreshold)
+		cli.FlagConfigDumpPath(&a.ConfigDumpPath)
+		cli.FlagEgressReportPath(&a.EgressReportPath)
+		cli.FlagForceAnalyze(&a.ForceAnalyze)
+		cli.FlagForceRebuild(&a.ForceRebuild)
 		cli.FlagGID(&a.GID)
 		cli.FlagLayersDir(&a.LayersDir)
-		cli.FlagPreviousImage(&a.PreviousImageRef)
+		cli.FlagLogHTTP(&a.LogHTTP)
+		cli.FlagNoNetwork(&a.NoNetwork)
+		cli.FlagNoRunImage(&a.NoRunImage)
+		cli.FlagOrderPath(&a.OrderPath)
+		cli.FlagPinRunImage(&a.PinRunImage)
+		cli.FlagPreviousImageCandidates(&a.PreviousImageRef)
+		cli.FlagPreviousImageDigest(&a.PreviousImageDigest)
+		cli.FlagPullPolicy(&a.PullPolicy)
+		cli.FlagReadOnlyPaths(&a.ReadOnlyPaths)
+		cli.FlagRegistryAuthFile(&a.RegistryAuthFile)
+		cli.FlagRegistryCACert(&a.RegistryCACert)
+		cli.FlagRegistryMirrors(&a.RegistryMirrors)
+		cli.FlagRequiredMixins(&a.RequiredMixins)
 		cli.FlagRunImage(&a.RunImageRef)
+		cli.FlagRunImageLineage(&a.RunImageLineage)
+		cli.FlagSkipPrevious(&a.SkipPrevious)
 		cli.FlagTags(&a.AdditionalTags)
+		cli.FlagTargetArch(&a.TargetArch)
+		cli.FlagTargetOS(&a.TargetOS)
 		cli.FlagUID(&a.UID)
+		cli.FlagUseBuildImageMixins(&a.UseBuildImageMixins)
 		cli.FlagUseDaemon(&a.UseDaemon)
 	}
 }
 
+// WritesToStdout returns true if analyzed.toml is written to stdout.
+func (a *analyzeCmd) WritesToStdout() bool {
+	return a.AnalyzedPath == files.StdoutPath
+}
+
 // Args validates arguments and flags, and fills in default values.
 func (a *analyzeCmd) Args(nargs int, args []string) error {
 	if nargs != 1 {
@@ -61,6 +103,7 @@ func (a *analyzeCmd) Args(nargs int, args []string) error {
 		return cmd.FailErrCode(err, cmd.CodeForInvalidArgs, "parse arguments")
 	}
 	a.LifecycleInputs.OutputImageRef = args[0]
+	a.validateOrder = a.OrderPath != "" && a.OrderPath != filepath.Join(platform.PlaceholderLayers, platform.DefaultOrderFile)
 	if err := platform.ResolveInputs(platform.Analyze, a.LifecycleInputs, cmd.DefaultLogger); err != nil {
 		return cmd.FailErrCode(err, cmd.CodeForInvalidArgs, "resolve inputs")
 	}
@@ -74,18 +117,22 @@ func (a *analyzeCmd) Args(nargs int, args []string) error {
 
 // Privileges validates the needed privileges.
 func (a *analyzeCmd) Privileges() error {
-	var err error
-	a.keychain, err = auth.DefaultKeychain(a.RegistryImages()...)
+	if err := configureRegistryTransport(a.RegistryCACert, a.NoProxy, a.LogHTTP); err != nil {
+		return err
+	}
+	keychain, err := auth.DefaultKeychainWithAuthFile(a.RegistryAuthFile, a.RegistryImages()...)
 	if err != nil {
 		return cmd.FailErr(err, "resolve keychain")
 	}
+	a.keychain = cacheCredentials(keychain)
+	a.keychain, a.egress = recordEgress(a.keychain, a.EgressReportPath)
 	if a.UseDaemon {
 		a.docker, err = priv.DockerClient()
 		if err != nil {
 			return cmd.FailErr(err, "initialize docker client")
 		}
 	}
-	if err = priv.EnsureOwner(a.UID, a.GID, a.LayersDir, a.CacheDir, a.LaunchCacheDir); err != nil {
+	if err = priv.EnsureOwnerTolerating(a.UID, a.GID, a.ReadOnlyPaths, cmd.DefaultLogger, a.LayersDir, a.CacheDir, a.LaunchCacheDir); err != nil {
 		return cmd.FailErr(err, "chown volumes")
 	}
 	if err = priv.RunAs(a.UID, a.GID); err != nil {
@@ -96,21 +143,82 @@ func (a *analyzeCmd) Privileges() error {
 
 // Exec executes the command.
 func (a *analyzeCmd) Exec() error {
-	factory := phase.NewConnectedFactory(
-		a.PlatformAPI,
-		&cmd.BuildpackAPIVerifier{},
-		NewCacheHandler(a.keychain),
-		files.Handler,
-		image.NewHandler(a.docker, a.keychain, a.LayoutDir, a.UseLayout, a.InsecureRegistries),
-		image.NewRegistryHandler(a.keychain, a.InsecureRegistries),
+	startedAt := time.Now()
+	if a.ConfigDumpPath != "" {
+		writeConfigDump(platform.NewConfigDump("analyze", a.LifecycleInputs, os.Environ()), a.ConfigDumpPath)
+	}
+	defer writeEgressReport(a.egress, a.EgressReportPath)
+	if a.validateOrder {
+		if err := phase.NewHermeticFactory(
+			a.PlatformAPI,
+			&cmd.BuildpackAPIVerifier{},
+			files.Handler,
+			platform.NewDirStore(a.BuildpacksDir, a.ExtensionsDir),
+		).ValidateOrder(a.OrderPath, a.BuildpackLabelSelector, cmd.DefaultLogger); err != nil {
+			return cmd.FailErrCode(err, cmd.CodeForInvalidArgs, "validate order")
+		}
+	}
+	var (
+		imageHandler    image.Handler         = applyPullPolicy(image.NewHandler(a.docker, a.keychain, a.LayoutDir, a.UseLayout, a.InsecureRegistries), a.docker, a.keychain, a.PullPolicy)
+		registryHandler image.RegistryHandler = image.NewRegistryHandler(a.keychain, a.InsecureRegistries)
 	)
-	analyzer, err := factory.NewAnalyzer(a.Inputs(), cmd.DefaultLogger)
-	if err != nil {
-		return unwrapErrorFailWithMessage(err, "initialize analyzer")
+	if a.NoNetwork {
+		imageHandler, registryHandler = image.NewOfflineHandler(imageHandler), image.OfflineRegistryHandler{}
 	}
-	analyzedMD, err := analyzer.Analyze()
+	analyzedMD, err := phase.Analyze(phase.AnalyzeOptions{
+		Inputs:          a.Inputs(),
+		ImageHandler:    image.NewMemoizingHandler(imageHandler),
+		RegistryHandler: registryHandler,
+		Logger:          cmd.DefaultLogger,
+	})
 	if err != nil {
+		switch {
+		case errors.Is(err, phase.ErrAnalyzerInit):
+			return unwrapErrorFailWithMessage(err, "initialize analyzer")
+		case errors.Is(err, phase.ErrPreviousImageDrift):
+			return cmd.FailErrCode(err, cmd.CodeForInvalidArgs, "analyze")
+		}
 		return cmd.FailErrCode(err, a.CodeFor(platform.AnalyzeError), "analyze")
 	}
-	return files.Handler.WriteAnalyzed(a.AnalyzedPath, &analyzedMD, cmd.DefaultLogger)
+	if err = a.ensureAnalyzedNotNewer(startedAt); err != nil {
+		return err
+	}
+	if err = files.Handler.WriteAnalyzed(a.AnalyzedPath, &analyzedMD, cmd.DefaultLogger); err != nil {
+		return err
+	}
+	if a.AnalyzeReportPath != "" {
+		writeAnalyzeReport(platform.NewAnalyzeReport(a.LifecycleInputs, analyzedMD, cmd.DefaultLogger), a.AnalyzeReportPath)
+	}
+	return nil
+}
+
+// ensureAnalyzedNotNewer returns an error if analyzed.toml was written after the provided time at which the analyzer started,
+// e.g., by a retried run of the analyzer for the same layers directory, as overwriting it would replace its metadata with staler metadata.
+// Differences within the clock skew threshold are tolerated, as the file may have been written on a node with a different clock.
+// If -force is provided, it warns and allows the file to be overwritten.
+func (a *analyzeCmd) ensureAnalyzedNotNewer(startedAt time.Time) error {
+	if a.AnalyzedPath == files.StdoutPath {
+		return nil
+	}
+	writtenAt, ok, err := files.AnalyzedWrittenAt(a.AnalyzedPath, a.ClockSkewThreshold)
+	if err != nil {
+		return cmd.FailErr(err, "check existing analyzed metadata")
+	}
+	if !ok || !writtenAt.After(startedAt.Add(a.ClockSkewThreshold)) {
+		return nil
+	}
+	if a.ForceAnalyze {
+		cmd.DefaultLogger.Warnf("Overwriting analyzed metadata at %q written at %s, after the analyzer started at %s", a.AnalyzedPath, writtenAt.UTC().Format(time.RFC3339), startedAt.UTC().Format(time.RFC3339))
+		return nil
+	}
+	cmd.DefaultLogger.Infof("Found analyzed metadata at %q written at %s, after the analyzer started at %s", a.AnalyzedPath, writtenAt.UTC().Format(time.RFC3339), startedAt.UTC().Format(time.RFC3339))
+	return cmd.FailErr(fmt.Errorf("analyzed metadata at %q is newer than this run; provide -force to overwrite it", a.AnalyzedPath), "write analyzed metadata")
+}
+
+// writeAnalyzeReport writes the provided report to the analyze report path.
+// Failures are logged rather than returned, as the report is informational.
+func writeAnalyzeReport(report files.AnalyzeReport, analyzeReportPath string) {
+	if err := files.Handler.WriteAnalyzeReport(analyzeReportPath, &report); err != nil {
+		cmd.DefaultLogger.Warnf("Failed to write analyze report: %s", err)
+	}
 }
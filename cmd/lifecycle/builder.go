@@ -33,6 +33,7 @@ func (b *buildCmd) DefineFlags() {
 		cli.FlagLayersDir(&b.LayersDir)
 		cli.FlagPlanPath(&b.PlanPath)
 		cli.FlagPlatformDir(&b.PlatformDir)
+		cli.FlagWarnUnsupportedAPI(&b.WarnUnsupportedAPI)
 	}
 }
 
@@ -60,7 +61,7 @@ func (b *buildCmd) Exec() error {
 	if err != nil {
 		return err
 	}
-	if err = verifyBuildpackApis(group); err != nil {
+	if err = verifyBuildpackApis(group, b.WarnUnsupportedAPI); err != nil {
 		return err
 	}
 	amd, err := files.Handler.ReadAnalyzed(b.AnalyzedPath, cmd.DefaultLogger)
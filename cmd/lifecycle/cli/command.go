@@ -24,15 +24,23 @@ type Command interface {
 	Exec() error
 }
 
+// stdoutWriter is implemented by commands that may write their output to stdout;
+// when they do, logs are written to stderr so that they do not corrupt the output.
+type stdoutWriter interface {
+	WritesToStdout() bool
+}
+
 func Run(c Command, withPhaseName string, asSubcommand bool) {
 	var (
 		printVersion bool
+		buildID      string
 		logLevel     string
 		noColor      bool
 	)
 
 	log.SetOutput(io.Discard)
 	FlagVersion(&printVersion)
+	FlagBuildID(&buildID)
 	FlagLogLevel(&logLevel)
 	FlagNoColor(&noColor)
 	c.DefineFlags()
@@ -48,6 +56,10 @@ func Run(c Command, withPhaseName string, asSubcommand bool) {
 		}
 	}
 	cmd.DisableColor(noColor)
+	cmd.DefaultLogger.SetBuildID(buildID)
+	if w, ok := c.(stdoutWriter); ok && w.WritesToStdout() {
+		cmd.DefaultLogger.SetWriter(cmd.Stderr)
+	}
 
 	if printVersion {
 		cmd.ExitWithVersion()
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/buildpacks/lifecycle/internal/str"
@@ -20,38 +21,193 @@ func FlagUseLayout(useLayout *bool) {
 	flagSet.BoolVar(useLayout, "layout", *useLayout, "export to OCI layout format on disk")
 }
 
+func FlagAllowPreviousDrift(allowPreviousDrift *bool) {
+	flagSet.BoolVar(allowPreviousDrift, "allow-previous-drift", *allowPreviousDrift, "warn rather than fail when the previous image does not resolve to the expected digest")
+}
+
+func FlagAllowMutableRunImage(allowMutableRunImage *bool) {
+	flagSet.BoolVar(allowMutableRunImage, "allow-mutable-run-image", *allowMutableRunImage, "do not warn when the run image is provided with the latest tag or no tag")
+}
+
 func FlagAnalyzedPath(analyzedPath *string) {
 	flagSet.StringVar(analyzedPath, "analyzed", *analyzedPath, "path to analyzed.toml")
 }
 
+func FlagAnalyzeReportPath(analyzeReportPath *string) {
+	flagSet.StringVar(analyzeReportPath, "report", *analyzeReportPath, "path to write a report of the analyzer's decisions")
+}
+
 func FlagAppDir(appDir *string) {
 	flagSet.StringVar(appDir, "app", *appDir, "path to app directory")
 }
 
+func FlagAtomicRestore(atomicRestore *bool) {
+	flagSet.BoolVar(atomicRestore, "atomic-restore", *atomicRestore, "extract each cache layer to a staging directory and rename it into place")
+}
+
+func FlagBestEffortRestore(bestEffortRestore *bool) {
+	flagSet.BoolVar(bestEffortRestore, "best-effort", *bestEffortRestore, "remove cache layers that fail to restore instead of failing")
+}
+
 func FlagBuildConfigDir(buildConfigDir *string) {
 	flagSet.StringVar(buildConfigDir, "build-config", *buildConfigDir, "path to build config directory")
 }
 
+func FlagBuildID(buildID *string) {
+	flagSet.StringVar(buildID, "build-id", os.Getenv(platform.EnvBuildID), "ID to prefix every logged line with")
+}
+
 func FlagBuildImage(buildImage *string) {
 	flagSet.StringVar(buildImage, "build-image", *buildImage, "build image tag name")
 }
 
+func FlagBuildpackLabelSelector(buildpackLabelSelector *string) {
+	flagSet.StringVar(buildpackLabelSelector, "buildpack-label-selector", *buildpackLabelSelector, "only consider buildpacks in the order whose metadata has the provided label, in the form key=value")
+}
+
 func FlagBuildpacksDir(buildpacksDir *string) {
 	flagSet.StringVar(buildpacksDir, "buildpacks", *buildpacksDir, "path to buildpacks directory")
 }
 
+func FlagClockSkewThreshold(clockSkewThreshold *time.Duration) {
+	flagSet.DurationVar(clockSkewThreshold, "clock-skew-threshold", *clockSkewThreshold, "maximum time difference between nodes tolerated as clock skew")
+}
+
+func FlagCacheArchive(cacheArchivePath *string) {
+	flagSet.StringVar(cacheArchivePath, "cache-archive", *cacheArchivePath, "path to a cache image exported to a tarball, to restore from after any other caches")
+}
+
+// FlagCacheChunking parses `cache-chunking` flag
+func FlagCacheChunking(cacheChunking *bool) {
+	flagSet.BoolVar(cacheChunking, "cache-chunking", *cacheChunking, "store layers added to the cache directory as content-defined chunks")
+}
+
+// FlagCacheCompression parses `cache-compression` flag
+func FlagCacheCompression(cacheCompression *string) {
+	flagSet.StringVar(cacheCompression, "cache-compression", *cacheCompression, "compression of layers added to the cache (none, gzip, or zstd)")
+}
+
+// FlagCacheImageOCI parses `cache-image-oci` flag
+func FlagCacheImageOCI(cacheImageOCI *bool) {
+	flagSet.BoolVar(cacheImageOCI, "cache-image-oci", *cacheImageOCI, "save the cache image with OCI media types instead of Docker media types")
+}
+
 func FlagCacheDir(cacheDir *string) {
 	flagSet.StringVar(cacheDir, "cache-dir", *cacheDir, "path to cache directory")
 }
 
+func FlagCacheFallback(cacheFallback *bool) {
+	flagSet.BoolVar(cacheFallback, "cache-fallback", *cacheFallback, "warn rather than fail when the cache image cannot be accessed, falling back to the cache directory or an empty cache")
+}
+
 func FlagCacheImage(cacheImage *string) {
 	flagSet.StringVar(cacheImage, "cache-image", *cacheImage, "cache image tag name")
 }
 
+func FlagCacheNamespace(cacheNamespace *string) {
+	flagSet.StringVar(cacheNamespace, "cache-namespace", *cacheNamespace, "namespace partitioning the cache, e.g., the name of the branch being built")
+}
+
+func FlagCacheNamespaceFallbacks(cacheNamespaceFallbacks *str.Slice) {
+	flagSet.Var(cacheNamespaceFallbacks, "cache-namespace-fallback", "cache namespace to restore from when layers are not cached in the cache namespace; may be repeated")
+}
+
+// FlagCacheSources parses the restorer's `cache-dir` and `cache-image` flags, which may each be provided multiple times.
+// Caches are appended to sources in the order they are provided;
+// the first occurrence of each flag also sets cacheDir or cacheImage, respectively.
+func FlagCacheSources(cacheDir, cacheImage *string, sources *[]platform.CacheSource) {
+	flagSet.Var(&cacheSourceValue{first: cacheDir, sources: sources, isDir: true}, "cache-dir", "path to cache directory; may be repeated, with caches tried in the order provided")
+	flagSet.Var(&cacheSourceValue{first: cacheImage, sources: sources}, "cache-image", "cache image tag name; may be repeated, with caches tried in the order provided")
+}
+
+type cacheSourceValue struct {
+	first   *string
+	sources *[]platform.CacheSource
+	isDir   bool
+	set     bool
+}
+
+func (v *cacheSourceValue) String() string {
+	if v.first == nil {
+		return ""
+	}
+	return *v.first
+}
+
+func (v *cacheSourceValue) Set(value string) error {
+	if !v.set {
+		*v.first = value
+		v.set = true
+	}
+	if v.isDir {
+		*v.sources = append(*v.sources, platform.CacheSource{Dir: value})
+	} else {
+		*v.sources = append(*v.sources, platform.CacheSource{ImageRef: value})
+	}
+	return nil
+}
+
+// FlagExportDestinations parses the exporter's `destination` flag, which may be provided multiple times.
+// Each value is a comma-separated list of image references, the first of which is the destination image
+// and the rest of which are additional tags for it. Destinations provided by flags replace any provided by the environment.
+func FlagExportDestinations(destinations *[]platform.ExportDestination) {
+	flagSet.Var(&exportDestinationsValue{destinations: destinations}, "destination",
+		"additional destination for the image, as a comma-separated list of an image reference followed by additional tags; may be repeated")
+}
+
+type exportDestinationsValue struct {
+	destinations *[]platform.ExportDestination
+	set          bool
+}
+
+func (v *exportDestinationsValue) String() string {
+	if v.destinations == nil {
+		return ""
+	}
+	var values []string
+	for _, destination := range *v.destinations {
+		values = append(values, strings.Join(destination.Images(), ","))
+	}
+	return strings.Join(values, ";")
+}
+
+func (v *exportDestinationsValue) Set(value string) error {
+	destination, err := platform.ParseExportDestination(value)
+	if err != nil {
+		return err
+	}
+	if !v.set {
+		*v.destinations = nil
+		v.set = true
+	}
+	*v.destinations = append(*v.destinations, destination)
+	return nil
+}
+
 func FlagExtendKind(extendKind *string) {
 	flagSet.StringVar(extendKind, "kind", *extendKind, "kind of image to extend")
 }
 
+func FlagConfigDumpPath(configDumpPath *string) {
+	flagSet.StringVar(configDumpPath, "config-dump", *configDumpPath, "path to write the effective configuration of the phase")
+}
+
+func FlagCreationTime(creationTime *string) {
+	flagSet.StringVar(creationTime, "creation-time", *creationTime, "created time of the image, as seconds since the Unix epoch, an RFC3339 timestamp, or now")
+}
+
+func FlagDedupRestore(dedupRestore *bool) {
+	flagSet.BoolVar(dedupRestore, "dedup-restore", *dedupRestore, "retrieve cache layers with the same sha only once, hard-linking the data for other layers")
+}
+
+func FlagDryRun(dryRun *bool) {
+	flagSet.BoolVar(dryRun, "dry-run", *dryRun, "log what would be done with each cache layer without modifying the layers directory")
+}
+
+func FlagEgressReportPath(egressReportPath *string) {
+	flagSet.StringVar(egressReportPath, "egress-report", *egressReportPath, "path to write a report of the registries contacted")
+}
+
 func FlagExtendedDir(extendedDir *string) {
 	flagSet.StringVar(extendedDir, "extended", *extendedDir, "path to output directory for image layers created from applying generated Dockerfiles")
 }
@@ -76,6 +232,10 @@ func FlagKanikoCacheTTL(kanikoCacheTTL *time.Duration) {
 	flagSet.DurationVar(kanikoCacheTTL, "kaniko-cache-ttl", *kanikoCacheTTL, "kaniko cache time-to-live")
 }
 
+func FlagLayerRestoreTimeout(layerRestoreTimeout *time.Duration) {
+	flagSet.DurationVar(layerRestoreTimeout, "layer-restore-timeout", *layerRestoreTimeout, "maximum time to spend restoring a single cache layer, or 0 for no limit")
+}
+
 func FlagLaunchCacheDir(launchCacheDir *string) {
 	flagSet.StringVar(launchCacheDir, "launch-cache", *launchCacheDir, "path to launch cache directory")
 }
@@ -92,18 +252,42 @@ func FlagLayersDir(layersDir *string) {
 	flagSet.StringVar(layersDir, "layers", *layersDir, "path to layers directory")
 }
 
+func FlagLogHTTP(logHTTP *bool) {
+	flagSet.BoolVar(logHTTP, "log-http", *logHTTP, "log each request to registries at debug level, with credentials redacted")
+}
+
 func FlagLogLevel(logLevel *string) {
 	flagSet.StringVar(logLevel, "log-level", platform.DefaultLogLevel, "logging level")
 }
 
+func FlagMaxLayerSize(maxLayerSize *string) {
+	flagSet.StringVar(maxLayerSize, "max-layer-size", *maxLayerSize, "maximum size in bytes of the uncompressed data of a single cache layer to restore, or 0 for no limit")
+}
+
+func FlagMetadataOnly(metadataOnly *bool) {
+	flagSet.BoolVar(metadataOnly, "metadata-only", *metadataOnly, "restore only layer metadata from the cache, so that layers are re-created")
+}
+
 func FlagNoColor(noColor *bool) {
 	flagSet.BoolVar(noColor, "no-color", boolEnv(platform.EnvNoColor), "disable color output")
 }
 
+func FlagNoNetwork(noNetwork *bool) {
+	flagSet.BoolVar(noNetwork, "no-network", *noNetwork, "fail instead of reading images from a registry")
+}
+
+func FlagNoRunImage(noRunImage *bool) {
+	flagSet.BoolVar(noRunImage, "no-run-image", *noRunImage, "export the app image on an empty base instead of a run image")
+}
+
 func FlagOrderPath(orderPath *string) {
 	flagSet.StringVar(orderPath, "order", *orderPath, "path to order.toml")
 }
 
+func FlagOverlayUpperDir(overlayUpperDir *string) {
+	flagSet.StringVar(overlayUpperDir, "overlay-upper", *overlayUpperDir, "path to writable overlay upper directory for restored cache layers")
+}
+
 func FlagPlanPath(planPath *string) {
 	flagSet.StringVar(planPath, "plan", *planPath, "path to plan.toml")
 }
@@ -117,10 +301,39 @@ func FlagParallelExport(parallelExport *bool) {
 	flagSet.BoolVar(parallelExport, "parallel", *parallelExport, "export app image and cache image in parallel")
 }
 
+// FlagAsyncCacheCommit parses `async-cache-commit` flag
+func FlagAsyncCacheCommit(asyncCacheCommit *bool) {
+	flagSet.BoolVar(asyncCacheCommit, "async-cache-commit", *asyncCacheCommit, "commit the cache in the background while the app image is exported")
+}
+
+// FlagStrictCacheCommit parses `strict-cache-commit` flag
+func FlagStrictCacheCommit(strictCacheCommit *bool) {
+	flagSet.BoolVar(strictCacheCommit, "strict-cache-commit", *strictCacheCommit, "fail if the cache cannot be exported")
+}
+
+func FlagPinRunImage(pinRunImage *bool) {
+	flagSet.BoolVar(pinRunImage, "pin-run-image", *pinRunImage, "record in analyzed metadata that the run image should be pulled by digest rather than by the provided tag")
+}
+
 func FlagPreviousImage(previousImage *string) {
 	flagSet.StringVar(previousImage, "previous-image", *previousImage, "reference to previous image")
 }
 
+func FlagPreviousImageCandidates(previousImage *string) {
+	flagSet.StringVar(previousImage, "previous-image", *previousImage, "reference to previous image, or a comma-separated list of references to try in order")
+}
+
+func FlagPreviousImageDigest(previousImageDigest *string) {
+	flagSet.StringVar(previousImageDigest, "previous-image-digest", *previousImageDigest, "digest the previous image is expected to resolve to")
+}
+
+// FlagPreserveModTimes parses the exporter's `preserve-mtimes` flag, which accepts a `<buildpack-id>:<layer-name>` glob pattern
+// and may be provided multiple times.
+func FlagPreserveModTimes(preserveModTimes *str.Slice) {
+	flagSet.Var(preserveModTimes, "preserve-mtimes", "glob pattern matching <buildpack-id>:<layer-name> of layers whose file modification times should be kept; "+
+		"matching layers are not reproducible, as their digests change whenever the modification times do")
+}
+
 func FlagProcessType(processType *string) {
 	flagSet.StringVar(processType, "process-type", *processType, "default process type")
 }
@@ -129,50 +342,144 @@ func FlagProjectMetadataPath(projectMetadataPath *string) {
 	flagSet.StringVar(projectMetadataPath, "project-metadata", *projectMetadataPath, "path to project-metadata.toml")
 }
 
+func FlagPruneCache(pruneCache *bool) {
+	flagSet.BoolVar(pruneCache, "prune-cache", *pruneCache, "remove layers no longer referenced by the cache metadata from the cache directory")
+}
+
+func FlagPullPolicy(pullPolicy *string) {
+	flagSet.StringVar(pullPolicy, "pull-policy", *pullPolicy, "whether to pull images into the daemon before using them: always, if-not-present, or never")
+}
+
 func FlagReportPath(reportPath *string) {
 	flagSet.StringVar(reportPath, "report", *reportPath, "path to report.toml")
 }
 
+// FlagReadOnlyPaths parses the `read-only-path` flag, which may be provided multiple times.
+func FlagReadOnlyPaths(readOnlyPaths *str.Slice) {
+	flagSet.Var(readOnlyPaths, "read-only-path", "read-only path whose ownership may not be changed to the build user")
+}
+
+func FlagRegistryAuthFile(registryAuthFile *string) {
+	flagSet.StringVar(registryAuthFile, "registry-auth-file", *registryAuthFile, "path to a file mapping registries to <username>:<password> credentials")
+}
+
+func FlagRegistryCACert(registryCACert *string) {
+	flagSet.StringVar(registryCACert, "registry-ca-cert", *registryCACert, "path to a PEM-encoded bundle of CA certificates to trust when making requests to registries")
+}
+
+// FlagRegistryMirrors parses the `registry-mirror` flag, which may be provided multiple times.
+func FlagRegistryMirrors(registryMirrors *str.Slice) {
+	flagSet.Var(registryMirrors, "registry-mirror", "registry mirror of the form <from>=<to>, used to rewrite the references of the run image and the build image")
+}
+
+func FlagRequiredMixins(requiredMixins *str.Slice) {
+	flagSet.Var(requiredMixins, "required-mixin", "mixin required by the build, which the run image must provide unless it is specific to the build stage; may be repeated")
+}
+
+// FlagRestoreBuildpacks parses the `restore-buildpacks` flag, which may be repeated.
+func FlagRestoreBuildpacks(restoreBuildpacks *str.Slice) {
+	flagSet.Var(restoreBuildpacks, "restore-buildpacks", "ID of a buildpack whose layers should be restored; may be repeated")
+}
+
+func FlagRestoreReportPath(restoreReportPath *string) {
+	flagSet.StringVar(restoreReportPath, "restore-report", *restoreReportPath, "path to write a report of the restored cache layers")
+}
+
 func FlagRunImage(runImage *string) {
 	flagSet.StringVar(runImage, "run-image", *runImage, "reference to run image")
 }
 
+func FlagRunImageLineage(runImageLineage *string) {
+	flagSet.StringVar(runImageLineage, "run-image-lineage", *runImageLineage, "whether to warn or fail when the previous image was built on a different run image: warn or fail")
+}
+
 func FlagRunPath(runPath *string) {
 	flagSet.StringVar(runPath, "run", *runPath, "path to run.toml")
 }
 
+func FlagSBOMOnly(sbomOnly *bool) {
+	flagSet.BoolVar(sbomOnly, "sbom-only", *sbomOnly, "restore only SBOM data from the cache")
+}
+
+func FlagSBOMOutputDir(sbomOutputDir *string) {
+	flagSet.StringVar(sbomOutputDir, "sbom-output", *sbomOutputDir, "path to directory to write SBOM files to, grouped by buildpack")
+}
+
 func FlagSkipLayers(skipLayers *bool) {
 	flagSet.BoolVar(skipLayers, "skip-layers", *skipLayers, "do not provide layer metadata to buildpacks")
 }
 
+func FlagSkipPrevious(skipPrevious *bool) {
+	flagSet.BoolVar(skipPrevious, "skip-previous", *skipPrevious, "ignore the previous image even if it exists")
+}
+
 func FlagSkipRestore(skipRestore *bool) {
 	flagSet.BoolVar(skipRestore, "skip-restore", *skipRestore, "do not restore layers or layer metadata")
 }
 
+// FlagSkipRestorePatterns parses the restorer's `skip-restore` flag, which unlike the creator's flag of the same name
+// accepts a `<buildpack-id>:<layer-name>` glob pattern and may be provided multiple times.
+func FlagSkipRestorePatterns(skipRestorePatterns *str.Slice) {
+	flagSet.Var(skipRestorePatterns, "skip-restore", "glob pattern matching <buildpack-id>:<layer-name> of cache layers whose data should not be restored")
+}
+
+func FlagSkipSBOM(skipSBOM *bool) {
+	flagSet.BoolVar(skipSBOM, "skip-sbom", *skipSBOM, "do not restore SBOM data")
+}
+
+func FlagSlowLayerThreshold(slowLayerThreshold *time.Duration) {
+	flagSet.DurationVar(slowLayerThreshold, "slow-layer-threshold", *slowLayerThreshold, "time after which restoring a single cache layer is reported as slow, or 0 to never report")
+}
+
 func FlagStackPath(stackPath *string) {
 	flagSet.StringVar(stackPath, "stack", *stackPath, "path to stack.toml")
 }
 
 func FlagTags(tags *str.Slice) {
-	flagSet.Var(tags, "tag", "additional tags")
+	flagSet.Var(tags, "tag", "additional tags; may contain templates such as {{.Date}}, {{.Unix}}, or {{env \"SOME_VAR\"}}")
+}
+
+func FlagTargetArch(targetArch *string) {
+	flagSet.StringVar(targetArch, "target-arch", *targetArch, "architecture the run image must be for, e.g., amd64")
+}
+
+func FlagTargetOS(targetOS *string) {
+	flagSet.StringVar(targetOS, "target-os", *targetOS, "operating system the run image must be for, e.g., linux")
 }
 
 func FlagUID(uid *int) {
 	flagSet.IntVar(uid, "uid", *uid, "UID of user in the stack's build and run images")
 }
 
+func FlagUseBuildImageMixins(useBuildImageMixins *bool) {
+	flagSet.BoolVar(useBuildImageMixins, "use-build-image-mixins", *useBuildImageMixins, "read the mixins required by the build from the build image, if -required-mixin is not provided")
+}
+
 func FlagUseDaemon(useDaemon *bool) {
 	flagSet.BoolVar(useDaemon, "daemon", *useDaemon, "export to docker daemon")
 }
 
+// FlagWarnUnsupportedAPI parses `warn-unsupported-api` flag
+func FlagWarnUnsupportedAPI(warnUnsupportedAPI *bool) {
+	flagSet.BoolVar(warnUnsupportedAPI, "warn-unsupported-api", *warnUnsupportedAPI, "warn instead of failing when buildpacks request incompatible Buildpack APIs; intended for experimental setups only")
+}
+
 func FlagVersion(showVersion *bool) {
 	flagSet.BoolVar(showVersion, "version", false, "show version")
 }
 
+func FlagForceAnalyze(force *bool) {
+	flagSet.BoolVar(force, "force", *force, "overwrite analyzed metadata even if it was written after the analyzer started, e.g., by another run of the analyzer")
+}
+
 func FlagForceRebase(force *bool) {
 	flagSet.BoolVar(force, "force", *force, "execute rebase even if operation is unsafe")
 }
 
+func FlagForceRebuild(forceRebuild *bool) {
+	flagSet.BoolVar(forceRebuild, "force-rebuild", *forceRebuild, "omit the launch layer metadata of the previous image so that buildpacks rebuild every launch layer, while still restoring cached layers")
+}
+
 // FlagInsecureRegistries sets insecure-registry parameter as available
 func FlagInsecureRegistries(insecureRegistries *str.Slice) {
 	flagSet.Var(insecureRegistries, "insecure-registry", "insecure registries")
@@ -43,26 +43,58 @@ func (c *createCmd) DefineFlags() {
 		cli.FlagBuildConfigDir(&c.BuildConfigDir)
 		cli.FlagLauncherSBOMDir(&c.LauncherSBOMDir)
 	}
+	if c.PlatformAPI.AtLeast("0.9") {
+		cli.FlagCreationTime(&c.CreationTime)
+	}
+	if c.PlatformAPI.AtLeast("0.8") {
+		cli.FlagSBOMOutputDir(&c.SBOMOutputDir)
+	}
+	cli.FlagAllowMutableRunImage(&c.AllowMutableRunImage)
+	cli.FlagAllowPreviousDrift(&c.AllowPreviousDrift)
 	cli.FlagAppDir(&c.AppDir)
+	cli.FlagAsyncCacheCommit(&c.AsyncCacheCommit)
+	cli.FlagBuildImage(&c.BuildImageRef)
+	cli.FlagBuildpackLabelSelector(&c.BuildpackLabelSelector)
 	cli.FlagBuildpacksDir(&c.BuildpacksDir)
+	cli.FlagCacheChunking(&c.CacheChunking)
+	cli.FlagCacheCompression(&c.CacheCompression)
+	cli.FlagCacheImageOCI(&c.CacheImageOCI)
 	cli.FlagCacheDir(&c.CacheDir)
 	cli.FlagCacheImage(&c.CacheImageRef)
+	cli.FlagCacheNamespace(&c.CacheNamespace)
+	cli.FlagExportDestinations(&c.ExportDestinations)
+	cli.FlagForceRebuild(&c.ForceRebuild)
 	cli.FlagGID(&c.GID)
 	cli.FlagLaunchCacheDir(&c.LaunchCacheDir)
 	cli.FlagLauncherPath(&c.LauncherPath)
 	cli.FlagLayersDir(&c.LayersDir)
+	cli.FlagLogHTTP(&c.LogHTTP)
 	cli.FlagOrderPath(&c.OrderPath)
 	cli.FlagParallelExport(&c.ParallelExport)
+	cli.FlagPreserveModTimes(&c.PreserveModTimes)
 	cli.FlagPlatformDir(&c.PlatformDir)
-	cli.FlagPreviousImage(&c.PreviousImageRef)
+	cli.FlagPinRunImage(&c.PinRunImage)
+	cli.FlagPreviousImageCandidates(&c.PreviousImageRef)
+	cli.FlagPreviousImageDigest(&c.PreviousImageDigest)
 	cli.FlagProcessType(&c.DefaultProcessType)
 	cli.FlagProjectMetadataPath(&c.ProjectMetadataPath)
+	cli.FlagPruneCache(&c.PruneCache)
+	cli.FlagPullPolicy(&c.PullPolicy)
+	cli.FlagReadOnlyPaths(&c.ReadOnlyPaths)
+	cli.FlagRegistryCACert(&c.RegistryCACert)
+	cli.FlagRegistryMirrors(&c.RegistryMirrors)
+	cli.FlagRequiredMixins(&c.RequiredMixins)
 	cli.FlagReportPath(&c.ReportPath)
 	cli.FlagRunImage(&c.RunImageRef)
+	cli.FlagRunImageLineage(&c.RunImageLineage)
 	cli.FlagSkipRestore(&c.SkipLayers)
 	cli.FlagStackPath(&c.StackPath)
+	cli.FlagStrictCacheCommit(&c.StrictCacheCommit)
 	cli.FlagTags(&c.AdditionalTags)
+	cli.FlagTargetArch(&c.TargetArch)
+	cli.FlagTargetOS(&c.TargetOS)
 	cli.FlagUID(&c.UID)
+	cli.FlagUseBuildImageMixins(&c.UseBuildImageMixins)
 	cli.FlagUseDaemon(&c.UseDaemon)
 }
 
@@ -84,6 +116,9 @@ func (c *createCmd) Args(nargs int, args []string) error {
 }
 
 func (c *createCmd) Privileges() error {
+	if err := configureRegistryTransport(c.RegistryCACert, c.NoProxy, c.LogHTTP); err != nil {
+		return err
+	}
 	var err error
 	c.keychain, err = auth.DefaultKeychain(c.RegistryImages()...)
 	if err != nil {
@@ -95,7 +130,7 @@ func (c *createCmd) Privileges() error {
 			return cmd.FailErr(err, "initialize docker client")
 		}
 	}
-	if err = priv.EnsureOwner(c.UID, c.GID, c.CacheDir, c.LaunchCacheDir, c.LayersDir); err != nil {
+	if err = priv.EnsureOwnerTolerating(c.UID, c.GID, c.ReadOnlyPaths, cmd.DefaultLogger, c.CacheDir, c.LaunchCacheDir, c.LayersDir); err != nil {
 		return cmd.FailErr(err, "chown volumes")
 	}
 	if err = priv.RunAs(c.UID, c.GID); err != nil {
@@ -108,10 +143,13 @@ func (c *createCmd) Privileges() error {
 }
 
 func (c *createCmd) Exec() error {
-	cacheStore, err := initCache(c.CacheImageRef, c.CacheDir, c.keychain, c.PlatformAPI.LessThan("0.13"))
+	cacheStore, err := initCache(c.CacheImageRef, c.CacheDir, c.keychain, c.PlatformAPI.LessThan("0.13"), c.CacheChunking, c.CacheImageOCI)
 	if err != nil {
 		return err
 	}
+	if err = configureCacheCompression(cacheStore, c.CacheCompression); err != nil {
+		return err
+	}
 	dirStore := platform.NewDirStore(c.BuildpacksDir, c.ExtensionsDir)
 	if err != nil {
 		return err
@@ -129,7 +167,7 @@ func (c *createCmd) Exec() error {
 		&cmd.BuildpackAPIVerifier{},
 		NewCacheHandler(c.keychain),
 		files.NewHandler(),
-		image.NewHandler(c.docker, c.keychain, c.LayoutDir, c.UseLayout, c.InsecureRegistries),
+		image.NewMemoizingHandler(applyPullPolicy(image.NewHandler(c.docker, c.keychain, c.LayoutDir, c.UseLayout, c.InsecureRegistries), c.docker, c.keychain, c.PullPolicy)),
 		image.NewRegistryHandler(c.keychain, c.InsecureRegistries),
 	)
 	analyzer, err := analyzerFactory.NewAnalyzer(c.Inputs(), cmd.DefaultLogger)
@@ -138,6 +176,9 @@ func (c *createCmd) Exec() error {
 	}
 	analyzedMD, err = analyzer.Analyze()
 	if err != nil {
+		if errors.Is(err, phase.ErrPreviousImageDrift) {
+			return cmd.FailErrCode(err, cmd.CodeForInvalidArgs, "analyze")
+		}
 		return err
 	}
 	if err := files.Handler.WriteAnalyzed(c.AnalyzedPath, &analyzedMD, cmd.DefaultLogger); err != nil {
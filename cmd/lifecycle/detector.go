@@ -30,6 +30,7 @@ func (d *detectCmd) DefineFlags() {
 		cli.FlagGeneratedDir(&d.GeneratedDir)
 	}
 	cli.FlagAppDir(&d.AppDir)
+	cli.FlagBuildpackLabelSelector(&d.BuildpackLabelSelector)
 	cli.FlagBuildpacksDir(&d.BuildpacksDir)
 	cli.FlagGroupPath(&d.GroupPath)
 	cli.FlagLayersDir(&d.LayersDir)
@@ -27,6 +27,7 @@ import (
 	"github.com/buildpacks/lifecycle/cmd"
 	"github.com/buildpacks/lifecycle/cmd/lifecycle/cli"
 	"github.com/buildpacks/lifecycle/image"
+	iname "github.com/buildpacks/lifecycle/internal/name"
 	"github.com/buildpacks/lifecycle/layers"
 	"github.com/buildpacks/lifecycle/phase"
 	"github.com/buildpacks/lifecycle/platform"
@@ -65,22 +66,42 @@ func (e *exportCmd) DefineFlags() {
 	if e.PlatformAPI.AtLeast("0.11") {
 		cli.FlagLauncherSBOMDir(&e.LauncherSBOMDir)
 	}
+	if e.PlatformAPI.AtLeast("0.9") {
+		cli.FlagCreationTime(&e.CreationTime)
+	}
+	if e.PlatformAPI.AtLeast("0.8") {
+		cli.FlagSBOMOutputDir(&e.SBOMOutputDir)
+	}
 	cli.FlagAnalyzedPath(&e.AnalyzedPath)
 	cli.FlagAppDir(&e.AppDir)
+	cli.FlagAsyncCacheCommit(&e.AsyncCacheCommit)
+	cli.FlagCacheChunking(&e.CacheChunking)
+	cli.FlagCacheCompression(&e.CacheCompression)
+	cli.FlagCacheImageOCI(&e.CacheImageOCI)
 	cli.FlagCacheDir(&e.CacheDir)
 	cli.FlagCacheImage(&e.CacheImageRef)
+	cli.FlagCacheNamespace(&e.CacheNamespace)
+	cli.FlagExportDestinations(&e.ExportDestinations)
 	cli.FlagGID(&e.GID)
 	cli.FlagGroupPath(&e.GroupPath)
 	cli.FlagLaunchCacheDir(&e.LaunchCacheDir)
 	cli.FlagLauncherPath(&e.LauncherPath)
 	cli.FlagLayersDir(&e.LayersDir)
+	cli.FlagLogHTTP(&e.LogHTTP)
 	cli.FlagParallelExport(&e.ParallelExport)
+	cli.FlagPreserveModTimes(&e.PreserveModTimes)
 	cli.FlagProcessType(&e.DefaultProcessType)
 	cli.FlagProjectMetadataPath(&e.ProjectMetadataPath)
+	cli.FlagPruneCache(&e.PruneCache)
+	cli.FlagReadOnlyPaths(&e.ReadOnlyPaths)
+	cli.FlagRegistryCACert(&e.RegistryCACert)
+	cli.FlagRegistryMirrors(&e.RegistryMirrors)
 	cli.FlagReportPath(&e.ReportPath)
 	cli.FlagRunImage(&e.RunImageRef) // FIXME: this flag isn't valid on Platform 0.7 and later
+	cli.FlagStrictCacheCommit(&e.StrictCacheCommit)
 	cli.FlagUID(&e.UID)
 	cli.FlagUseDaemon(&e.UseDaemon)
+	cli.FlagWarnUnsupportedAPI(&e.WarnUnsupportedAPI)
 
 	cli.DeprecatedFlagRunImage(&e.DeprecatedRunImageRef) // FIXME: this flag isn't valid on Platform 0.7 and later
 }
@@ -101,6 +122,9 @@ func (e *exportCmd) Args(nargs int, args []string) error {
 	if err != nil {
 		return err
 	}
+	if err := e.persistedData.analyzedMD.Validate(); err != nil {
+		cmd.DefaultLogger.Warnf("Analyzed metadata at %q may not be usable: %s", e.AnalyzedPath, err)
+	}
 	if e.UseLayout {
 		if err := platform.GuardExperimental(platform.LayoutFormat, cmd.DefaultLogger); err != nil {
 			return err
@@ -110,6 +134,9 @@ func (e *exportCmd) Args(nargs int, args []string) error {
 }
 
 func (e *exportCmd) Privileges() error {
+	if err := configureRegistryTransport(e.RegistryCACert, e.NoProxy, e.LogHTTP); err != nil {
+		return err
+	}
 	var err error
 	e.keychain, err = auth.DefaultKeychain(e.registryImages()...)
 	if err != nil {
@@ -122,7 +149,7 @@ func (e *exportCmd) Privileges() error {
 			return cmd.FailErr(err, "initialize docker client")
 		}
 	}
-	if err = priv.EnsureOwner(e.UID, e.GID, e.CacheDir, e.LaunchCacheDir); err != nil {
+	if err = priv.EnsureOwnerTolerating(e.UID, e.GID, e.ReadOnlyPaths, cmd.DefaultLogger, e.CacheDir, e.LaunchCacheDir); err != nil {
 		return cmd.FailErr(err, "chown volumes")
 	}
 	if err = priv.RunAs(e.UID, e.GID); err != nil {
@@ -136,13 +163,16 @@ func (e *exportCmd) Exec() error {
 	if err != nil {
 		return err
 	}
-	if err = verifyBuildpackApis(group); err != nil {
+	if err = verifyBuildpackApis(group, e.WarnUnsupportedAPI); err != nil {
 		return err
 	}
-	cacheStore, err := initCache(e.CacheImageRef, e.CacheDir, e.keychain, e.PlatformAPI.LessThan("0.13"))
+	cacheStore, err := initCache(e.CacheImageRef, e.CacheDir, e.keychain, e.PlatformAPI.LessThan("0.13"), e.CacheChunking, e.CacheImageOCI)
 	if err != nil {
 		return err
 	}
+	if err = configureCacheCompression(cacheStore, e.CacheCompression); err != nil {
+		return err
+	}
 	if e.hasExtendedLayers() {
 		if err := platform.GuardExperimental(platform.FeatureDockerfiles, cmd.DefaultLogger); err != nil {
 			return err
@@ -182,14 +212,16 @@ func (e *exportCmd) export(group buildpack.Group, cacheStore phase.Cache, analyz
 	exporter := &phase.Exporter{
 		Buildpacks: group.Group,
 		LayerFactory: &layers.Factory{
-			ArtifactsDir: artifactsDir,
-			UID:          e.UID,
-			GID:          e.GID,
-			Logger:       cmd.DefaultLogger,
-			Ctx:          ctx,
+			ArtifactsDir:     artifactsDir,
+			UID:              e.UID,
+			GID:              e.GID,
+			Logger:           cmd.DefaultLogger,
+			Ctx:              ctx,
+			PreserveModTimes: e.PreserveModTimes,
 		},
 		Logger:      cmd.DefaultLogger,
 		PlatformAPI: e.PlatformAPI,
+		PruneCache:  e.PruneCache,
 	}
 
 	var (
@@ -213,6 +245,13 @@ func (e *exportCmd) export(group buildpack.Group, cacheStore phase.Cache, analyz
 		return err
 	}
 
+	var waitForCache func() error
+	if cacheStore != nil && e.AsyncCacheCommit {
+		// the commit may reference layer tarballs in the artifacts directory, so it must finish before they are removed
+		waitForCache = exporter.CacheAsync(e.LayersDir, cacheStore)
+		defer func() { _ = waitForCache() }()
+	}
+
 	g.Go(func() error {
 		report, err := exporter.Export(phase.ExportOptions{
 			AdditionalNames:    e.AdditionalTags,
@@ -225,8 +264,18 @@ func (e *exportCmd) export(group buildpack.Group, cacheStore phase.Cache, analyz
 			Project:            projectMD,
 			RunImageRef:        runImageID,
 			RunImageForExport:  runImageForExport,
+			NoRunImage:         analyzedMD.HasNoRunImage(),
+			SBOMOutputDir:      e.SBOMOutputDir,
+			Destinations:       e.ExportDestinations,
 			WorkingImage:       appImage,
 		})
+		var destinationsErr *phase.DestinationsError
+		if errors.As(err, &destinationsErr) {
+			// the image was saved, so report the outcome for each destination before failing
+			if reportErr := files.Handler.WriteReport(e.ReportPath, &report); reportErr != nil {
+				cmd.DefaultLogger.Warnf("Failed to write export report: %s", reportErr)
+			}
+		}
 		if err != nil {
 			return cmd.FailErrCode(err, e.CodeFor(platform.ExportError), "export")
 		}
@@ -243,9 +292,9 @@ func (e *exportCmd) export(group buildpack.Group, cacheStore phase.Cache, analyz
 	}
 
 	g.Go(func() error {
-		if cacheStore != nil {
+		if cacheStore != nil && waitForCache == nil {
 			if cacheErr := exporter.Cache(e.LayersDir, cacheStore); cacheErr != nil {
-				cmd.DefaultLogger.Warnf("Failed to export cache: %v\n", cacheErr)
+				return e.handleCacheErr(cacheErr)
 			}
 		}
 		return nil
@@ -255,12 +304,33 @@ func (e *exportCmd) export(group buildpack.Group, cacheStore phase.Cache, analyz
 		return err
 	}
 
+	if waitForCache != nil {
+		if cacheErr := waitForCache(); cacheErr != nil {
+			return e.handleCacheErr(cacheErr)
+		}
+	}
+
+	return nil
+}
+
+func (e *exportCmd) handleCacheErr(err error) error {
+	if e.StrictCacheCommit {
+		return cmd.FailErrCode(err, e.CodeFor(platform.ExportError), "export cache")
+	}
+	cmd.DefaultLogger.Warnf("Failed to export cache: %v\n", err)
 	return nil
 }
 
 func (e *exportCmd) initDaemonAppImage(analyzedMD files.Analyzed) (imgutil.Image, string, error) {
-	var opts = []local.ImageOption{
-		local.FromBaseImage(e.RunImageRef),
+	var opts []local.ImageOption
+	if analyzedMD.HasNoRunImage() {
+		targetPlatform, err := noRunImagePlatform(analyzedMD)
+		if err != nil {
+			return nil, "", cmd.FailErr(err, "get app image platform")
+		}
+		opts = append(opts, local.WithDefaultPlatform(targetPlatform))
+	} else {
+		opts = append(opts, local.FromBaseImage(e.RunImageRef))
 	}
 	if e.supportsRunImageExtension() {
 		extendedConfig, err := e.getExtendedConfig(analyzedMD.RunImage)
@@ -295,9 +365,13 @@ func (e *exportCmd) initDaemonAppImage(analyzedMD files.Analyzed) (imgutil.Image
 		return nil, "", cmd.FailErr(err, " image")
 	}
 
-	runImageID, err := appImage.Identifier()
-	if err != nil {
-		return nil, "", cmd.FailErr(err, "get run image ID")
+	var runImageID string
+	if !analyzedMD.HasNoRunImage() {
+		identifier, err := appImage.Identifier()
+		if err != nil {
+			return nil, "", cmd.FailErr(err, "get run image ID")
+		}
+		runImageID = identifier.String()
 	}
 
 	if e.LaunchCacheDir != "" {
@@ -307,7 +381,7 @@ func (e *exportCmd) initDaemonAppImage(analyzedMD files.Analyzed) (imgutil.Image
 		}
 		appImage = cache.NewCachingImage(appImage, volumeCache)
 	}
-	return appImage, runImageID.String(), nil
+	return appImage, runImageID, nil
 }
 
 func toContainerConfig(v1C *v1.Config) *container.Config {
@@ -362,8 +436,15 @@ func toNATPortSet(v1Ps map[string]struct{}) nat.PortSet {
 }
 
 func (e *exportCmd) initRemoteAppImage(analyzedMD files.Analyzed) (imgutil.Image, string, error) {
-	var opts = []remote.ImageOption{
-		remote.FromBaseImage(e.RunImageRef),
+	var opts []remote.ImageOption
+	if analyzedMD.HasNoRunImage() {
+		targetPlatform, err := noRunImagePlatform(analyzedMD)
+		if err != nil {
+			return nil, "", cmd.FailErr(err, "get app image platform")
+		}
+		opts = append(opts, remote.WithDefaultPlatform(targetPlatform))
+	} else {
+		opts = append(opts, remote.FromBaseImage(e.RunImageRef))
 	}
 
 	if e.supportsRunImageExtension() {
@@ -400,6 +481,9 @@ func (e *exportCmd) initRemoteAppImage(analyzedMD files.Analyzed) (imgutil.Image
 	if err != nil {
 		return nil, "", cmd.FailErr(err, "create new app image")
 	}
+	if analyzedMD.HasNoRunImage() {
+		return appImage, "", nil
+	}
 
 	runImage, err := remote.NewImage(e.RunImageRef, e.keychain, remote.FromBaseImage(e.RunImageRef))
 	if err != nil {
@@ -409,17 +493,53 @@ func (e *exportCmd) initRemoteAppImage(analyzedMD files.Analyzed) (imgutil.Image
 	if err != nil {
 		return nil, "", cmd.FailErr(err, "get run image reference")
 	}
+	if err = verifyRunImageDigest(analyzedMD.RunImage, runImageID.String()); err != nil {
+		return nil, "", cmd.FailErr(err, "verify run image")
+	}
 	return appImage, runImageID.String(), nil
 }
 
-func (e *exportCmd) initLayoutAppImage(analyzedMD files.Analyzed) (imgutil.Image, string, error) {
-	runImageIdentifier, err := layout.ParseIdentifier(analyzedMD.RunImage.Reference)
-	if err != nil {
-		return nil, "", cmd.FailErr(err, "parsing run image reference")
+// noRunImagePlatform returns the platform of an app image without a run image, which is the target recorded by the analyzer.
+func noRunImagePlatform(analyzedMD files.Analyzed) (imgutil.Platform, error) {
+	target := analyzedMD.RunImage.TargetMetadata
+	if target == nil || target.OS == "" || target.Arch == "" {
+		return imgutil.Platform{}, errors.New("analyzed metadata has no target for the app image without a run image")
+	}
+	if target.OS == "windows" {
+		return imgutil.Platform{}, errors.New("exporting Windows images without a run image is unsupported")
 	}
+	return imgutil.Platform{OS: target.OS, Architecture: target.Arch}, nil
+}
 
-	var opts = []layout.ImageOption{
-		layout.FromBaseImagePath(runImageIdentifier.Path),
+// verifyRunImageDigest ensures that the run image resolved during export matches the digest recorded in analyzed.toml, if any.
+func verifyRunImageDigest(runImage *files.RunImage, runImageRef string) error {
+	if runImage == nil || runImage.Digest == "" {
+		return nil
+	}
+	if digest := iname.DigestMaybe(runImageRef); digest != "" && digest != runImage.Digest {
+		return fmt.Errorf("run image digest %s does not match digest %s recorded in analyzed metadata", digest, runImage.Digest)
+	}
+	return nil
+}
+
+func (e *exportCmd) initLayoutAppImage(analyzedMD files.Analyzed) (imgutil.Image, string, error) {
+	var (
+		runImageIdentifier layout.Identifier
+		opts               []layout.ImageOption
+		err                error
+	)
+	if analyzedMD.HasNoRunImage() {
+		targetPlatform, err := noRunImagePlatform(analyzedMD)
+		if err != nil {
+			return nil, "", cmd.FailErr(err, "get app image platform")
+		}
+		opts = append(opts, layout.WithDefaultPlatform(targetPlatform))
+	} else {
+		runImageIdentifier, err = layout.ParseIdentifier(analyzedMD.RunImage.Reference)
+		if err != nil {
+			return nil, "", cmd.FailErr(err, "parsing run image reference")
+		}
+		opts = append(opts, layout.FromBaseImagePath(runImageIdentifier.Path))
 	}
 
 	if e.supportsHistory() {
@@ -459,6 +579,9 @@ func (e *exportCmd) initLayoutAppImage(analyzedMD files.Analyzed) (imgutil.Image
 	if err = appImage.AnnotateRefName(reference.Identifier()); err != nil {
 		return nil, "", err
 	}
+	if analyzedMD.HasNoRunImage() {
+		return appImage, "", nil
+	}
 
 	runImage, err := layout.NewImage(runImageIdentifier.Path)
 	if err != nil {
@@ -468,6 +591,9 @@ func (e *exportCmd) initLayoutAppImage(analyzedMD files.Analyzed) (imgutil.Image
 	if err != nil {
 		return nil, "", cmd.FailErr(err, "get run image reference")
 	}
+	if err = verifyRunImageDigest(analyzedMD.RunImage, runImageID.String()); err != nil {
+		return nil, "", cmd.FailErr(err, "verify run image")
+	}
 	return appImage, runImageID.String(), nil
 }
 
@@ -492,6 +618,9 @@ func (e *exportCmd) customSourceDateEpoch() time.Time {
 		return time.Time{}
 	}
 
+	if !e.CreatedAt.IsZero() {
+		return e.CreatedAt
+	}
 	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
 		seconds, err := strconv.ParseInt(epoch, 10, 64)
 		if err != nil {
@@ -34,6 +34,7 @@ func (e *extendCmd) DefineFlags() {
 	cli.FlagLayersDir(&e.LayersDir)
 	cli.FlagPlanPath(&e.PlanPath)
 	cli.FlagPlatformDir(&e.PlatformDir)
+	cli.FlagReadOnlyPaths(&e.ReadOnlyPaths)
 	cli.FlagUID(&e.UID)
 }
 
@@ -71,7 +72,7 @@ func (e *extendCmd) Exec() error {
 		if err = extender.Extend(e.ExtendKind, cmd.DefaultLogger); err != nil {
 			return cmd.FailErrCode(err, e.CodeFor(platform.ExtendError), "extend build image")
 		}
-		if err = priv.EnsureOwner(e.UID, e.GID, e.LayersDir); err != nil {
+		if err = priv.EnsureOwnerTolerating(e.UID, e.GID, e.ReadOnlyPaths, cmd.DefaultLogger, e.LayersDir); err != nil {
 			return cmd.FailErr(err, "chown volumes")
 		}
 		if err = priv.RunAs(e.UID, e.GID); err != nil {
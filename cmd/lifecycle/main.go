@@ -1,19 +1,26 @@
 package main
 
 import (
+	"flag"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/docker/docker/client"
 	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/pkg/errors"
 
+	"github.com/buildpacks/lifecycle/auth"
 	"github.com/buildpacks/lifecycle/buildpack"
 	"github.com/buildpacks/lifecycle/cache"
 	"github.com/buildpacks/lifecycle/cmd"
 	"github.com/buildpacks/lifecycle/cmd/lifecycle/cli"
+	"github.com/buildpacks/lifecycle/image"
 	"github.com/buildpacks/lifecycle/phase"
 	"github.com/buildpacks/lifecycle/platform"
+	"github.com/buildpacks/lifecycle/platform/files"
 )
 
 func main() {
@@ -42,10 +49,25 @@ func main() {
 		if os.Args[1] == "-version" {
 			cmd.ExitWithVersion()
 		}
+		if os.Args[1] == "apis" {
+			printSupportedAPIs(os.Args[2:])
+		}
 		subcommand(platformAPIWithExitOnError())
 	}
 }
 
+// printSupportedAPIs prints the Platform and Buildpack APIs supported by the lifecycle, e.g., `lifecycle apis -format json`.
+// It does not require a Platform API or any build inputs.
+func printSupportedAPIs(args []string) {
+	flagSet := flag.NewFlagSet("apis", flag.ExitOnError)
+	format := flagSet.String("format", "toml", "output format, toml or json")
+	_ = flagSet.Parse(args)
+	if flagSet.NArg() > 0 {
+		cmd.Exit(cmd.FailErrCode(errors.New("received unexpected arguments"), cmd.CodeForInvalidArgs, "parse arguments"))
+	}
+	cmd.ExitWithSupportedAPIs(*format)
+}
+
 func platformAPIWithExitOnError() string {
 	platformAPI := cmd.EnvOrDefault(platform.EnvPlatformAPI, platform.DefaultPlatformAPI)
 	if err := cmd.VerifyPlatformAPI(platformAPI, cmd.DefaultLogger); err != nil {
@@ -90,54 +112,160 @@ func NewCacheHandler(keychain authn.Keychain) *DefaultCacheHandler {
 	}
 }
 
-// InitCache is a factory used to create either a NewImageCache or a NewVolumeCache
+// InitCache is a factory used to create the cache for the provided cache image or cache directory,
+// with the cache backend or cache helper for its scheme (see phase.NewCache).
 func (ch *DefaultCacheHandler) InitCache(cacheImageRef string, cacheDir string, deletionEnabled bool) (phase.Cache, error) {
-	var (
-		cacheStore phase.Cache
-		err        error
-	)
-	if cacheImageRef != "" {
-		logger := cmd.DefaultLogger
-		cacheStore, err = cache.NewImageCacheFromName(cacheImageRef, ch.keychain, logger, cache.NewImageDeleter(cache.NewImageComparer(), logger, deletionEnabled))
-		if err != nil {
-			return nil, errors.Wrap(err, "creating image cache")
-		}
-	} else if cacheDir != "" {
-		cacheStore, err = cache.NewVolumeCache(cacheDir)
-		if err != nil {
-			return nil, errors.Wrap(err, "creating volume cache")
-		}
+	location, defaultScheme := cacheLocation(cacheImageRef, cacheDir)
+	if location == "" {
+		return nil, nil
+	}
+	cacheStore, err := phase.NewCache(location, defaultScheme, phase.CacheOptions{
+		Keychain:        ch.keychain,
+		Logger:          cmd.DefaultLogger,
+		DeletionEnabled: deletionEnabled,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating cache")
 	}
 	return cacheStore, nil
 }
 
 // helpers
 
-func initCache(cacheImageTag, cacheDir string, keychain authn.Keychain, deletionEnabled bool) (phase.Cache, error) {
-	var (
-		cacheStore phase.Cache
-		err        error
-	)
-	if cacheImageTag != "" {
-		logger := cmd.DefaultLogger
-		cacheStore, err = cache.NewImageCacheFromName(cacheImageTag, keychain, logger, cache.NewImageDeleter(cache.NewImageComparer(), logger, deletionEnabled))
+// initCache initializes the cache image or cache directory, if provided, with the cache backend or cache helper for its scheme.
+// If chunked is true, layers added to a cache directory are stored as content-defined chunks.
+// If ociMediaTypes is true, the cache image is saved with OCI media types.
+func initCache(cacheImageTag, cacheDir string, keychain authn.Keychain, deletionEnabled, chunked, ociMediaTypes bool) (phase.Cache, error) {
+	location, defaultScheme := cacheLocation(cacheImageTag, cacheDir)
+	if location == "" {
+		return nil, nil
+	}
+	cacheStore, err := phase.NewCache(location, defaultScheme, phase.CacheOptions{
+		Keychain:        keychain,
+		Logger:          cmd.DefaultLogger,
+		DeletionEnabled: deletionEnabled,
+		Chunked:         chunked,
+		OCIMediaTypes:   ociMediaTypes,
+	})
+	if err != nil {
+		return nil, cmd.FailErr(err, "create cache")
+	}
+	return cacheStore, nil
+}
+
+// cacheLocation returns the cache image if provided, or else the cache directory, along with the scheme to use if it has none.
+func cacheLocation(cacheImageRef, cacheDir string) (string, string) {
+	if cacheImageRef != "" {
+		return cacheImageRef, platform.CacheSchemeImage
+	}
+	return cacheDir, platform.CacheSchemeFile
+}
+
+// configureCacheCompression configures the provided cache to compress the layers added to its cache directory or cache image, if any.
+func configureCacheCompression(cacheStore phase.Cache, compression string) error {
+	if compression == "" {
+		return nil
+	}
+	parsed, err := cache.ParseCompression(compression)
+	if err != nil {
+		return cmd.FailErrCode(err, cmd.CodeForInvalidArgs, "parse cache compression")
+	}
+	switch c := cacheStore.(type) {
+	case *cache.VolumeCache:
+		c.SetCompression(parsed)
+	case *cache.ImageCache:
+		c.SetCompression(parsed)
+	}
+	return nil
+}
+
+// applyPullPolicy wraps the provided image handler so that images are pulled into the daemon according to the provided pull policy, if any.
+func applyPullPolicy(h image.Handler, docker client.CommonAPIClient, keychain authn.Keychain, pullPolicy string) image.Handler {
+	if pullPolicy == "" || docker == nil || h.Kind() != image.LocalKind {
+		return h
+	}
+	policy, err := image.ParsePullPolicy(pullPolicy)
+	if err != nil {
+		// validated when resolving inputs
+		return h
+	}
+	return image.NewPullingHandler(h, docker, keychain, policy)
+}
+
+// cacheCredentials wraps the provided keychain so that resolved credentials are cached until they expire
+// or are rejected by a registry, rather than resolved again (e.g., by invoking a credential helper) for every registry operation.
+func cacheCredentials(keychain authn.Keychain) authn.Keychain {
+	cachingKeychain := auth.NewCachingKeychain(keychain, auth.DefaultCredentialTTL)
+	image.SetDefaultRegistryTransport(cachingKeychain.Transport(remote.DefaultTransport))
+	return cachingKeychain
+}
+
+// recordEgress wraps the provided keychain so that contacted registries are recorded, if an egress report was requested.
+func recordEgress(keychain authn.Keychain, egressReportPath string) (authn.Keychain, *auth.RecordingKeychain) {
+	if egressReportPath == "" {
+		return keychain, nil
+	}
+	recorder := auth.NewRecordingKeychain(keychain)
+	return recorder, recorder
+}
+
+// configureRegistryTransport configures registry requests to trust the CA certificates at the provided path, if any,
+// and to bypass the proxy for hosts matching the provided no-proxy rules, if any, in addition to those in NO_PROXY.
+// If logHTTP is true, requests to registries are additionally logged at debug level.
+func configureRegistryTransport(caCertPath, noProxy string, logHTTP bool) error {
+	var transport *http.Transport
+	if caCertPath != "" {
+		var err error
+		transport, err = image.NewRegistryTransport(caCertPath)
 		if err != nil {
-			return nil, cmd.FailErr(err, "create image cache")
+			return cmd.FailErr(err, "configure registry transport")
 		}
-	} else if cacheDir != "" {
-		cacheStore, err = cache.NewVolumeCache(cacheDir)
-		if err != nil {
-			return nil, cmd.FailErr(err, "create volume cache")
+	}
+	if noProxy != "" {
+		if transport == nil {
+			transport = image.NewDefaultRegistryTransport()
 		}
+		transport.Proxy = image.ProxyFunc(noProxy)
+	}
+	if transport != nil {
+		image.SetDefaultRegistryTransport(transport)
+	}
+	if logHTTP {
+		image.SetDefaultRegistryTransport(image.NewLoggingTransport(remote.DefaultTransport, cmd.DefaultLogger))
+	}
+	return nil
+}
+
+// writeEgressReport writes the registries recorded by the provided keychain to the egress report path.
+// Failures are logged rather than returned, so that they do not mask the result of the phase.
+func writeEgressReport(recorder *auth.RecordingKeychain, egressReportPath string) {
+	if recorder == nil {
+		return
+	}
+	report := files.EgressReport{}
+	for _, registry := range recorder.Registries() {
+		report.Registries = append(report.Registries, files.RegistryEgress{Host: registry.Registry, Operations: registry.Count})
+	}
+	if err := files.Handler.WriteEgressReport(egressReportPath, &report); err != nil {
+		cmd.DefaultLogger.Warnf("Failed to write egress report: %s", err)
+	}
+}
+
+// writeConfigDump writes the provided config dump to the config dump path.
+// Failures are logged rather than returned, so that they do not prevent the phase from running.
+func writeConfigDump(dump files.ConfigDump, configDumpPath string) {
+	if err := files.Handler.WriteConfigDump(configDumpPath, &dump); err != nil {
+		cmd.DefaultLogger.Warnf("Failed to write config dump: %s", err)
 	}
-	return cacheStore, nil
 }
 
-func verifyBuildpackApis(group buildpack.Group) error {
+// verifyBuildpackApis returns an error listing every buildpack in the group that requests an incompatible Buildpack API,
+// or logs warnings instead if warnOnly is true.
+func verifyBuildpackApis(group buildpack.Group, warnOnly bool) error {
+	var modules []cmd.ModuleAPI
 	for _, bp := range group.Group {
-		if err := cmd.VerifyBuildpackAPI(buildpack.KindBuildpack, bp.String(), bp.API, cmd.DefaultLogger); err != nil { // FIXME: when exporter is extensions-aware, this function call should be modified to provide the right module kind
-			return err
-		}
+		// FIXME: when exporter is extensions-aware, this should provide the right module kind
+		modules = append(modules, cmd.ModuleAPI{Kind: buildpack.KindBuildpack, Name: bp.String(), API: bp.API})
 	}
-	return nil
+	return cmd.VerifyBuildpackAPIs(modules, warnOnly, cmd.DefaultLogger)
 }
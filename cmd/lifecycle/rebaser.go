@@ -33,6 +33,9 @@ type rebaseCmd struct {
 // DefineFlags defines the flags that are considered valid and reads their values (if provided).
 func (r *rebaseCmd) DefineFlags() {
 	cli.FlagGID(&r.GID)
+	cli.FlagLogHTTP(&r.LogHTTP)
+	cli.FlagRegistryCACert(&r.RegistryCACert)
+	cli.FlagRegistryMirrors(&r.RegistryMirrors)
 	cli.FlagReportPath(&r.ReportPath)
 	cli.FlagRunImage(&r.RunImageRef)
 	cli.FlagUID(&r.UID)
@@ -78,6 +81,9 @@ func (r *rebaseCmd) Args(nargs int, args []string) error {
 }
 
 func (r *rebaseCmd) Privileges() error {
+	if err := configureRegistryTransport(r.RegistryCACert, r.NoProxy, r.LogHTTP); err != nil {
+		return err
+	}
 	var err error
 	r.keychain, err = auth.DefaultKeychain(r.RegistryImages()...)
 	if err != nil {
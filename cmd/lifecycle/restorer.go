@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/buildpacks/imgutil"
 	"github.com/buildpacks/imgutil/layout"
@@ -15,11 +19,13 @@ import (
 
 	"github.com/buildpacks/lifecycle/auth"
 	"github.com/buildpacks/lifecycle/buildpack"
+	"github.com/buildpacks/lifecycle/cache"
 	"github.com/buildpacks/lifecycle/cmd"
 	"github.com/buildpacks/lifecycle/cmd/lifecycle/cli"
 	"github.com/buildpacks/lifecycle/image"
 	"github.com/buildpacks/lifecycle/internal/encoding"
 	"github.com/buildpacks/lifecycle/internal/layer"
+	iname "github.com/buildpacks/lifecycle/internal/name"
 	"github.com/buildpacks/lifecycle/phase"
 	"github.com/buildpacks/lifecycle/platform"
 	"github.com/buildpacks/lifecycle/platform/files"
@@ -33,6 +39,7 @@ type restoreCmd struct {
 
 	docker   client.CommonAPIClient // construct if necessary before dropping privileges
 	keychain authn.Keychain         // construct if necessary before dropping privileges
+	egress   *auth.RecordingKeychain
 }
 
 // DefineFlags defines the flags that are considered valid and reads their values (if provided).
@@ -53,13 +60,38 @@ func (r *restoreCmd) DefineFlags() {
 	}
 
 	cli.FlagAnalyzedPath(&r.AnalyzedPath)
-	cli.FlagCacheDir(&r.CacheDir)
-	cli.FlagCacheImage(&r.CacheImageRef)
+	cli.FlagAtomicRestore(&r.AtomicRestore)
+	cli.FlagBestEffortRestore(&r.BestEffortRestore)
+	cli.FlagCacheArchive(&r.CacheArchivePath)
+	cli.FlagCacheFallback(&r.CacheFallback)
+	cli.FlagCacheNamespace(&r.CacheNamespace)
+	cli.FlagCacheNamespaceFallbacks(&r.CacheNamespaceFallbacks)
+	cli.FlagCacheSources(&r.CacheDir, &r.CacheImageRef, &r.CacheSources)
+	cli.FlagClockSkewThreshold(&r.ClockSkewThreshold)
+	cli.FlagDedupRestore(&r.DedupRestore)
+	cli.FlagDryRun(&r.RestoreDryRun)
+	cli.FlagLayerRestoreTimeout(&r.LayerRestoreTimeout)
+	cli.FlagEgressReportPath(&r.EgressReportPath)
 	cli.FlagGID(&r.GID)
 	cli.FlagGroupPath(&r.GroupPath)
 	cli.FlagLayersDir(&r.LayersDir)
+	cli.FlagLogHTTP(&r.LogHTTP)
+	cli.FlagMaxLayerSize(&r.MaxLayerSize)
+	cli.FlagMetadataOnly(&r.MetadataOnly)
+	cli.FlagOverlayUpperDir(&r.OverlayUpperDir)
+	cli.FlagPruneCache(&r.PruneCache)
+	cli.FlagReadOnlyPaths(&r.ReadOnlyPaths)
+	cli.FlagRegistryCACert(&r.RegistryCACert)
+	cli.FlagRegistryMirrors(&r.RegistryMirrors)
+	cli.FlagRestoreBuildpacks(&r.RestoreBuildpacks)
+	cli.FlagRestoreReportPath(&r.RestoreReportPath)
+	cli.FlagSBOMOnly(&r.SBOMOnly)
 	cli.FlagSkipLayers(&r.SkipLayers)
+	cli.FlagSkipRestorePatterns(&r.SkipRestorePatterns)
+	cli.FlagSkipSBOM(&r.SkipSBOM)
+	cli.FlagSlowLayerThreshold(&r.SlowLayerThreshold)
 	cli.FlagUID(&r.UID)
+	cli.FlagWarnUnsupportedAPI(&r.WarnUnsupportedAPI)
 }
 
 // Args validates arguments and flags, and fills in default values.
@@ -74,11 +106,15 @@ func (r *restoreCmd) Args(nargs int, _ []string) error {
 }
 
 func (r *restoreCmd) Privileges() error {
+	if err := configureRegistryTransport(r.RegistryCACert, r.NoProxy, r.LogHTTP); err != nil {
+		return err
+	}
 	var err error
 	r.keychain, err = auth.DefaultKeychain(r.RegistryImages()...)
 	if err != nil {
 		return cmd.FailErr(err, "resolve keychain")
 	}
+	r.keychain, r.egress = recordEgress(r.keychain, r.EgressReportPath)
 	if r.UseDaemon {
 		var err error
 		r.docker, err = priv.DockerClient()
@@ -86,8 +122,14 @@ func (r *restoreCmd) Privileges() error {
 			return cmd.FailErr(err, "initialize docker client")
 		}
 	}
-	if err = priv.EnsureOwner(r.UID, r.GID, r.LayersDir, r.CacheDir, r.KanikoDir); err != nil {
-		return cmd.FailErr(err, "chown volumes")
+	volumes := []string{r.LayersDir, r.CacheDir, r.KanikoDir}
+	for _, source := range r.CacheSources {
+		if source.Dir != "" && source.Dir != r.CacheDir {
+			volumes = append(volumes, source.Dir)
+		}
+	}
+	if err = priv.EnsureOwnerTolerating(r.UID, r.GID, r.ReadOnlyPaths, cmd.DefaultLogger, volumes...); err != nil {
+		return cmd.FailErrCode(err, r.CodeFor(platform.RestoreChownError), "chown volumes")
 	}
 	if err = priv.RunAs(r.UID, r.GID); err != nil {
 		return cmd.FailErr(err, fmt.Sprintf("exec as user %d:%d", r.UID, r.GID))
@@ -96,69 +138,155 @@ func (r *restoreCmd) Privileges() error {
 }
 
 func (r *restoreCmd) Exec() error {
+	defer writeEgressReport(r.egress, r.EgressReportPath)
 	group, err := files.Handler.ReadGroup(r.GroupPath)
 	if err != nil {
 		return err
 	}
-	if err = verifyBuildpackApis(group); err != nil {
+	if err = verifyBuildpackApis(group, r.WarnUnsupportedAPI); err != nil {
 		return err
 	}
 
 	var analyzedMD files.Analyzed
 	if analyzedMD, err = files.Handler.ReadAnalyzed(r.AnalyzedPath, cmd.DefaultLogger); err == nil {
-		if r.supportsBuildImageExtension() && r.BuildImageRef != "" {
-			cmd.DefaultLogger.Debugf("Pulling builder image metadata for %s...", r.BuildImageRef)
-			remoteBuildImage, err := r.pullSparse(r.BuildImageRef)
-			if err != nil {
-				return cmd.FailErr(err, fmt.Sprintf("pull builder image %s", r.BuildImageRef))
-			}
-			digestRef, err := remoteBuildImage.Identifier()
-			if err != nil {
-				return cmd.FailErr(err, "get digest reference for builder image")
-			}
-			analyzedMD.BuildImage = &files.ImageIdentifier{Reference: digestRef.String()}
-			cmd.DefaultLogger.Debugf("Adding build image info to analyzed metadata: ")
-			cmd.DefaultLogger.Debugf(encoding.ToJSONMaybe(analyzedMD.BuildImage))
+		if skew, ok := analyzedMD.ClockSkew(time.Now()); ok && skew > r.ClockSkewThreshold {
+			cmd.DefaultLogger.Warnf("Clock skew of %s detected between the analyzer and the restorer; cache comparisons may be unreliable", skew.Round(time.Second))
 		}
-		var (
-			runImage imgutil.Image
-		)
-		runImageName := analyzedMD.RunImageImage() // FIXME: if we have a digest reference available in `Reference` (e.g., in the non-daemon case) we should use it
-		if r.supportsRunImageExtension() && needsPulling(analyzedMD.RunImage) {
-			cmd.DefaultLogger.Debugf("Pulling run image metadata for %s...", runImageName)
-			runImage, err = r.pullSparse(runImageName)
-			if err != nil {
-				return cmd.FailErr(err, fmt.Sprintf("pull run image %s", runImageName))
-			}
-			// update analyzed metadata, even if we only needed to pull the image metadata, because
-			// the extender needs a digest reference in analyzed.toml,
-			// and daemon images will only have a daemon image ID
-			if err = r.updateAnalyzedMD(&analyzedMD, runImage); err != nil {
-				return cmd.FailErr(err, "update analyzed metadata")
-			}
-		} else if r.needsUpdating(analyzedMD.RunImage, group) {
-			cmd.DefaultLogger.Debugf("Updating run image info in analyzed metadata...")
-			h := image.NewHandler(r.docker, r.keychain, r.LayoutDir, r.UseLayout, r.InsecureRegistries)
-			runImage, err = h.InitImage(runImageName)
-			if err != nil || !runImage.Found() {
-				return cmd.FailErr(err, fmt.Sprintf("get run image %s", runImageName))
-			}
-			if err = r.updateAnalyzedMD(&analyzedMD, runImage); err != nil {
-				return cmd.FailErr(err, "update analyzed metadata")
-			}
-		}
-		if err = files.Handler.WriteAnalyzed(r.AnalyzedPath, &analyzedMD, cmd.DefaultLogger); err != nil {
-			return cmd.FailErr(err, "write analyzed metadata")
+		if r.RestoreDryRun {
+			cmd.DefaultLogger.Info("Dry run: not updating analyzed metadata")
+		} else if err = r.updateAnalyzed(&analyzedMD, group); err != nil {
+			return err
 		}
 	} else {
 		cmd.DefaultLogger.Warnf("Not using analyzed data, usable file not found: %s", err)
 	}
 
-	cacheStore, err := initCache(r.CacheImageRef, r.CacheDir, r.keychain, r.PlatformAPI.LessThan("0.13"))
+	cacheStores, err := r.initCaches()
 	if err != nil {
 		return err
 	}
-	return r.restore(analyzedMD.LayersMetadata, group, cacheStore)
+	return r.restore(analyzedMD.LayersMetadata, group, cacheStores...)
+}
+
+// updateAnalyzed adds build image and run image information needed by the extender to the analyzed metadata,
+// and writes it to the analyzed path. If no information is needed, e.g., because the group has no extensions,
+// the analyzed metadata is not written, so that several restores may use the same analyzed metadata, which may be read-only.
+func (r *restoreCmd) updateAnalyzed(analyzedMD *files.Analyzed, group buildpack.Group) error {
+	if analyzedMD.HasNoRunImage() && group.HasExtensions() {
+		return cmd.FailErrCode(errors.New("image extensions are unsupported without a run image"), cmd.CodeForInvalidArgs, "restore")
+	}
+	var updated bool
+	if r.supportsBuildImageExtension() && r.BuildImageRef != "" {
+		cmd.DefaultLogger.Debugf("Pulling builder image metadata for %s...", r.BuildImageRef)
+		remoteBuildImage, err := r.pullSparse(r.BuildImageRef)
+		if err != nil {
+			return cmd.FailErr(err, fmt.Sprintf("pull builder image %s", r.BuildImageRef))
+		}
+		digestRef, err := remoteBuildImage.Identifier()
+		if err != nil {
+			return cmd.FailErr(err, "get digest reference for builder image")
+		}
+		analyzedMD.BuildImage = &files.ImageIdentifier{Reference: digestRef.String()}
+		cmd.DefaultLogger.Debugf("Adding build image info to analyzed metadata: ")
+		cmd.DefaultLogger.Debugf(encoding.ToJSONMaybe(analyzedMD.BuildImage))
+		updated = true
+	}
+	var (
+		runImage imgutil.Image
+		err      error
+	)
+	runImageName := analyzedMD.RunImageImage() // FIXME: if we have a digest reference available in `Reference` (e.g., in the non-daemon case) we should use it
+	if analyzedMD.RunImage != nil && analyzedMD.RunImage.Pinned {
+		runImageName = analyzedMD.RunImage.Reference
+	}
+	if r.supportsRunImageExtension() && needsPulling(analyzedMD.RunImage) {
+		cmd.DefaultLogger.Debugf("Pulling run image metadata for %s...", runImageName)
+		runImage, err = r.pullSparse(runImageName)
+		if err != nil {
+			return cmd.FailErr(err, fmt.Sprintf("pull run image %s", runImageName))
+		}
+		// update analyzed metadata, even if we only needed to pull the image metadata, because
+		// the extender needs a digest reference in analyzed.toml,
+		// and daemon images will only have a daemon image ID
+		if err = r.updateAnalyzedMD(analyzedMD, runImage); err != nil {
+			return cmd.FailErr(err, "update analyzed metadata")
+		}
+		updated = true
+	} else if r.needsUpdating(analyzedMD.RunImage, group) {
+		cmd.DefaultLogger.Debugf("Updating run image info in analyzed metadata...")
+		h := image.NewHandler(r.docker, r.keychain, r.LayoutDir, r.UseLayout, r.InsecureRegistries)
+		runImage, err = h.InitImage(runImageName)
+		if err != nil || !runImage.Found() {
+			return cmd.FailErr(err, fmt.Sprintf("get run image %s", runImageName))
+		}
+		if err = r.updateAnalyzedMD(analyzedMD, runImage); err != nil {
+			return cmd.FailErr(err, "update analyzed metadata")
+		}
+		updated = true
+	}
+	if !updated {
+		cmd.DefaultLogger.Debugf("Not writing analyzed metadata to %q, no image information was added", r.AnalyzedPath)
+		return nil
+	}
+	if err = files.Handler.WriteAnalyzed(r.AnalyzedPath, analyzedMD, cmd.DefaultLogger); err != nil {
+		return cmd.FailErr(err, "write analyzed metadata")
+	}
+	return nil
+}
+
+// initCaches initializes the caches to restore from, in precedence order.
+// If a cache archive was provided, it is used after any other configured caches.
+func (r *restoreCmd) initCaches() ([]phase.Cache, error) {
+	cacheStores, err := r.initConfiguredCaches()
+	if err != nil {
+		return nil, err
+	}
+	if r.CacheArchivePath != "" {
+		archiveCache, err := cache.NewArchiveCache(r.CacheArchivePath, cmd.DefaultLogger)
+		if err != nil {
+			return nil, cmd.FailErr(err, "create archive cache")
+		}
+		cacheStores = append(cacheStores, archiveCache)
+	}
+	return cacheStores, nil
+}
+
+// initConfiguredCaches initializes the configured cache images and directories, in precedence order.
+// If cache flags were repeated, every provided cache is used; otherwise, the single configured cache (if any) is used.
+func (r *restoreCmd) initConfiguredCaches() ([]phase.Cache, error) {
+	deletionEnabled := r.PlatformAPI.LessThan("0.13")
+	if len(r.CacheSources) <= 1 {
+		cacheStore, err := r.initCache(r.CacheImageRef, r.CacheDir, deletionEnabled)
+		if err != nil {
+			return nil, err
+		}
+		return []phase.Cache{cacheStore}, nil
+	}
+	var cacheStores []phase.Cache
+	for _, source := range r.CacheSources {
+		cacheStore, err := r.initCache(source.ImageRef, source.Dir, deletionEnabled)
+		if err != nil {
+			return nil, err
+		}
+		cacheStores = append(cacheStores, cacheStore)
+	}
+	return cacheStores, nil
+}
+
+// initCache initializes the provided cache image or cache directory.
+// If cache fallback is enabled and the cache image cannot be accessed, it warns and falls back to the cache directory,
+// or to no cache if a cache directory was not provided.
+func (r *restoreCmd) initCache(cacheImageRef, cacheDir string, deletionEnabled bool) (phase.Cache, error) {
+	cacheStore, err := initCache(cacheImageRef, cacheDir, r.keychain, deletionEnabled, false, false)
+	if err == nil || !r.CacheFallback || cacheImageRef == "" {
+		return cacheStore, err
+	}
+	if cacheDir != "" {
+		cmd.DefaultLogger.Warnf("Falling back to cache directory %q: %s", cacheDir, err)
+	} else {
+		cmd.DefaultLogger.Warnf("Falling back to an empty cache: %s", err)
+	}
+	return initCache("", cacheDir, r.keychain, deletionEnabled, false, false)
 }
 
 func (r *restoreCmd) updateAnalyzedMD(analyzedMD *files.Analyzed, runImage imgutil.Image) error {
@@ -176,10 +304,16 @@ func (r *restoreCmd) updateAnalyzedMD(analyzedMD *files.Analyzed, runImage imgut
 			return cmd.FailErr(err, "read target data from run image")
 		}
 	}
+	runImageMD, err := platform.GetRunImageMetadata(runImage)
+	if err != nil {
+		return cmd.FailErr(err, "read metadata from run image")
+	}
 	cmd.DefaultLogger.Debugf("Run image info in analyzed metadata was: ")
 	cmd.DefaultLogger.Debugf(encoding.ToJSONMaybe(analyzedMD.RunImage))
 	analyzedMD.RunImage.Reference = digestRef.String()
+	analyzedMD.RunImage.Digest = iname.DigestMaybe(digestRef.String())
 	analyzedMD.RunImage.TargetMetadata = targetData
+	analyzedMD.RunImage.Metadata = runImageMD
 	cmd.DefaultLogger.Debugf("Run image info in analyzed metadata is: ")
 	cmd.DefaultLogger.Debugf(encoding.ToJSONMaybe(analyzedMD.RunImage))
 	return nil
@@ -271,22 +405,91 @@ func (r *restoreCmd) pullSparse(imageRef string) (imgutil.Image, error) {
 	return remoteImage, nil
 }
 
-func (r *restoreCmd) restore(layerMetadata files.LayersMetadata, group buildpack.Group, cacheStore phase.Cache) error {
+func (r *restoreCmd) restore(layerMetadata files.LayersMetadata, group buildpack.Group, cacheStores ...phase.Cache) error {
 	restorer := &phase.Restorer{
-		LayersDir:             r.LayersDir,
-		Buildpacks:            group.Group,
-		Logger:                cmd.DefaultLogger,
-		PlatformAPI:           r.PlatformAPI,
-		LayerMetadataRestorer: layer.NewDefaultMetadataRestorer(r.LayersDir, r.SkipLayers, cmd.DefaultLogger),
-		LayersMetadata:        layerMetadata,
+		LayersDir:                   r.LayersDir,
+		Buildpacks:                  group.Group,
+		Logger:                      cmd.DefaultLogger,
+		PlatformAPI:                 r.PlatformAPI,
+		LayerMetadataRestorer:       r.metadataRestorer(),
+		LayersMetadata:              layerMetadata,
+		OverlayUpperDir:             r.OverlayUpperDir,
+		AtomicRestore:               r.AtomicRestore,
+		BestEffort:                  r.BestEffortRestore,
+		DedupRestore:                r.DedupRestore,
+		DryRun:                      r.RestoreDryRun,
+		LayerRestoreTimeout:         r.LayerRestoreTimeout,
+		MaxLayerSize:                r.MaxLayerSizeBytes,
+		SlowLayerThreshold:          r.SlowLayerThreshold,
+		MetadataOnly:                r.MetadataOnly,
+		ProgressInterval:            phase.DefaultProgressInterval,
+		PruneCache:                  r.PruneCache,
+		SBOMOnly:                    r.SBOMOnly,
+		SkipSBOM:                    r.SkipSBOM,
+		SkipRestorePatterns:         r.SkipRestorePatterns,
+		RestoreBuildpacks:           r.RestoreBuildpacks,
+		FindBuildpacksWithoutLayers: r.RestoreReportPath != "",
 		SBOMRestorer: layer.NewSBOMRestorer(layer.SBOMRestorerOpts{
 			LayersDir: r.LayersDir,
 			Logger:    cmd.DefaultLogger,
 			Nop:       r.SkipLayers,
 		}, r.PlatformAPI),
 	}
-	if err := restorer.Restore(cacheStore); err != nil {
-		return cmd.FailErrCode(err, r.CodeFor(platform.RestoreError), "restore")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	summary, err := restorer.RestoreContext(ctx, cacheStores...)
+	if err != nil {
+		return cmd.FailErrCode(err, r.CodeFor(restoreErrorType(err)), "restore")
+	}
+	if r.RestoreReportPath != "" && !r.RestoreDryRun {
+		writeRestoreReport(summary, r.RestoreReportPath)
 	}
 	return nil
 }
+
+func (r *restoreCmd) metadataRestorer() *layer.DefaultMetadataRestorer {
+	metadataRestorer := layer.NewDefaultMetadataRestorer(r.LayersDir, r.SkipLayers, cmd.DefaultLogger)
+	metadataRestorer.DryRun = r.RestoreDryRun
+	return metadataRestorer
+}
+
+// writeRestoreReport writes the provided summary to the restore report path.
+// Failures are logged rather than returned, as the report is informational.
+func writeRestoreReport(summary phase.RestoreSummary, restoreReportPath string) {
+	report := files.RestoreReport{
+		Restored:                summary.Restored,
+		Skipped:                 summary.Skipped,
+		RemovedNotInCache:       summary.RemovedNotInCache,
+		RemovedWrongSHA:         summary.RemovedWrongSHA,
+		RemovedCorrupt:          summary.RemovedCorrupt,
+		RemovedTimedOut:         summary.RemovedTimedOut,
+		RemovedFailed:           summary.RemovedFailed,
+		BytesRestored:           summary.BytesRestored,
+		BuildpacksWithoutLayers: summary.BuildpacksWithoutLayers,
+	}
+	for _, bp := range summary.Buildpacks {
+		report.Buildpacks = append(report.Buildpacks, files.RestoreBuildpackReport{
+			ID:                bp.ID,
+			Restored:          bp.Restored,
+			RemovedNotInCache: bp.RemovedNotInCache,
+			RemovedWrongSHA:   bp.RemovedWrongSHA,
+		})
+	}
+	if err := files.Handler.WriteRestoreReport(restoreReportPath, &report); err != nil {
+		cmd.DefaultLogger.Warnf("Failed to write restore report: %s", err)
+	}
+}
+
+// restoreErrorType returns the type of the provided restore error, so that platforms can distinguish failures by exit code.
+func restoreErrorType(err error) platform.LifecycleExitError {
+	switch {
+	case errors.Is(err, phase.ErrCacheUnavailable):
+		return platform.RestoreCacheUnavailableError
+	case errors.Is(err, phase.ErrLayerCorrupt):
+		return platform.RestoreLayerCorruptError
+	case errors.Is(err, phase.ErrLayerTooLarge):
+		return platform.RestoreLayerTooLargeError
+	default:
+		return platform.RestoreError
+	}
+}
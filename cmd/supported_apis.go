@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/buildpacks/lifecycle/api"
+)
+
+// SupportedAPIs are the Platform and Buildpack APIs supported by the lifecycle, in the layout of the `[apis]` table of lifecycle.toml.
+type SupportedAPIs struct {
+	Buildpack APIVersions `toml:"buildpack" json:"buildpack"`
+	Platform  APIVersions `toml:"platform" json:"platform"`
+}
+
+// APIVersions are the supported and deprecated versions of an API.
+type APIVersions struct {
+	Deprecated []string `toml:"deprecated" json:"deprecated"`
+	Supported  []string `toml:"supported" json:"supported"`
+}
+
+// NewSupportedAPIs returns the APIs supported by the lifecycle,
+// i.e., the APIs that VerifyBuildpackAPI and VerifyPlatformAPI accept.
+func NewSupportedAPIs() SupportedAPIs {
+	return SupportedAPIs{
+		Buildpack: newAPIVersions(api.Buildpack),
+		Platform:  newAPIVersions(api.Platform),
+	}
+}
+
+func newAPIVersions(apis api.APIs) APIVersions {
+	versions := APIVersions{Deprecated: []string{}, Supported: []string{}}
+	for _, version := range apis.Deprecated {
+		versions.Deprecated = append(versions.Deprecated, version.String())
+	}
+	for _, version := range apis.Supported {
+		versions.Supported = append(versions.Supported, version.String())
+	}
+	return versions
+}
+
+// WriteSupportedAPIs writes the APIs supported by the lifecycle to the provided writer, as "toml" or "json".
+func WriteSupportedAPIs(w io.Writer, format string) error {
+	descriptor := struct {
+		APIs SupportedAPIs `toml:"apis" json:"apis"`
+	}{APIs: NewSupportedAPIs()}
+	switch format {
+	case "toml":
+		return toml.NewEncoder(w).Encode(descriptor)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(descriptor)
+	default:
+		return fmt.Errorf("invalid format %q: must be one of %q or %q", format, "toml", "json")
+	}
+}
+
+// ExitWithSupportedAPIs prints the APIs supported by the lifecycle in the provided format and exits with exit code 0.
+func ExitWithSupportedAPIs(format string) {
+	if err := WriteSupportedAPIs(os.Stdout, format); err != nil {
+		Exit(FailErrCode(err, CodeForInvalidArgs, "print supported APIs"))
+	}
+	os.Exit(0)
+}
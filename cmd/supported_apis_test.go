@@ -0,0 +1,52 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/api"
+	"github.com/buildpacks/lifecycle/cmd"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestSupportedAPIs(t *testing.T) {
+	spec.Run(t, "SupportedAPIs", testSupportedAPIs, spec.Report(report.Terminal{}))
+}
+
+func testSupportedAPIs(t *testing.T, when spec.G, it spec.S) {
+	var descriptor struct {
+		APIs cmd.SupportedAPIs `toml:"apis" json:"apis"`
+	}
+
+	when("WriteSupportedAPIs", func() {
+		it("writes the supported APIs as toml", func() {
+			buf := &bytes.Buffer{}
+			h.AssertNil(t, cmd.WriteSupportedAPIs(buf, "toml"))
+
+			_, err := toml.Decode(buf.String(), &descriptor)
+			h.AssertNil(t, err)
+			h.AssertEq(t, descriptor.APIs, cmd.NewSupportedAPIs())
+			h.AssertEq(t, descriptor.APIs.Platform.Supported[len(descriptor.APIs.Platform.Supported)-1], api.Platform.Latest().String())
+			h.AssertEq(t, descriptor.APIs.Buildpack.Supported[len(descriptor.APIs.Buildpack.Supported)-1], api.Buildpack.Latest().String())
+		})
+
+		it("writes the supported APIs as json", func() {
+			buf := &bytes.Buffer{}
+			h.AssertNil(t, cmd.WriteSupportedAPIs(buf, "json"))
+
+			h.AssertNil(t, json.Unmarshal(buf.Bytes(), &descriptor))
+			h.AssertEq(t, descriptor.APIs, cmd.NewSupportedAPIs())
+		})
+
+		when("the format is invalid", func() {
+			it("errors", func() {
+				h.AssertError(t, cmd.WriteSupportedAPIs(&bytes.Buffer{}, "xml"), `invalid format "xml"`)
+			})
+		})
+	})
+}
@@ -0,0 +1,102 @@
+package image
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/buildpacks/lifecycle/log"
+)
+
+// loggedQueryParams are the query parameters whose values are logged; the values of other parameters are redacted,
+// as they may contain credentials, e.g., the signatures of pre-signed blob URLs that registries redirect to.
+var loggedQueryParams = map[string]bool{"scope": true, "service": true}
+
+// loggedResponseHeaders are the response headers that help debug registry authentication and redirects.
+var loggedResponseHeaders = []string{"Location", "Www-Authenticate", "Docker-Distribution-Api-Version"}
+
+// redactedHeaders are the request headers whose values are redacted, except for the authentication scheme.
+var redactedHeaders = map[string]bool{"Authorization": true, "Proxy-Authorization": true, "Cookie": true}
+
+// LoggingTransport wraps a transport so that the method, URL, status, and duration of each request are logged at debug level,
+// e.g., to debug registry authentication or redirect issues. Credentials in headers and query parameters are redacted.
+type LoggingTransport struct {
+	base   http.RoundTripper
+	logger log.Logger
+}
+
+// NewLoggingTransport returns a LoggingTransport wrapping the provided transport.
+func NewLoggingTransport(base http.RoundTripper, logger log.Logger) *LoggingTransport {
+	return &LoggingTransport{base: base, logger: logger}
+}
+
+func (t *LoggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		t.logger.Debugf("HTTP %s %s failed after %s: %s", req.Method, redactURL(req.URL), elapsed, err)
+		return resp, err
+	}
+	t.logger.Debugf("HTTP %s %s: %s (%s)", req.Method, redactURL(req.URL), resp.Status, elapsed)
+	for _, name := range sortedHeaderNames(req.Header) {
+		t.logger.Debugf("  > %s: %s", name, redactHeader(name, req.Header.Values(name)))
+	}
+	for _, name := range loggedResponseHeaders {
+		for _, value := range resp.Header.Values(name) {
+			if name == "Location" {
+				value = redactLocation(value)
+			}
+			t.logger.Debugf("  < %s: %s", name, value)
+		}
+	}
+	return resp, nil
+}
+
+// redactURL returns the provided URL without user information, and with the values of query parameters redacted unless they are logged.
+func redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	query := redacted.Query()
+	for key := range query {
+		if !loggedQueryParams[key] {
+			query.Set(key, "REDACTED")
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
+
+func redactLocation(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "REDACTED"
+	}
+	return redactURL(u)
+}
+
+func redactHeader(name string, values []string) string {
+	if !redactedHeaders[name] {
+		return strings.Join(values, ", ")
+	}
+	var redacted []string
+	for _, value := range values {
+		if scheme, _, ok := strings.Cut(value, " "); ok {
+			redacted = append(redacted, scheme+" REDACTED")
+		} else {
+			redacted = append(redacted, "REDACTED")
+		}
+	}
+	return strings.Join(redacted, ", ")
+}
+
+func sortedHeaderNames(header http.Header) []string {
+	var names []string
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,96 @@
+package image
+
+import (
+	"sync"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/google/go-containerregistry/pkg/name"
+	"golang.org/x/sync/singleflight"
+)
+
+// MemoizingHandler wraps a Handler so that repeated calls to InitImage with the same reference return the same image.
+// This avoids redundant round-trips when several parts of a phase need the same image (e.g., the run image)
+// within a single process.
+// Images are keyed by the provided reference and, once initialized, by their resolved digest, so that a digest reference
+// for an image that was initialized by tag (e.g., the run image pinned in analyzed.toml) returns the same image.
+// A tag reference is never resolved to a previously initialized image, as the tag may have moved since.
+// Concurrent calls for the same reference result in a single call to the wrapped Handler,
+// while calls for different references are not serialized, e.g., so that the previous image and the run image are read concurrently.
+type MemoizingHandler struct {
+	Handler
+	group  singleflight.Group
+	mu     sync.Mutex
+	images map[string]imgutil.Image
+}
+
+// NewMemoizingHandler returns a MemoizingHandler wrapping the provided Handler.
+func NewMemoizingHandler(h Handler) *MemoizingHandler {
+	return &MemoizingHandler{
+		Handler: h,
+		images:  make(map[string]imgutil.Image),
+	}
+}
+
+func (h *MemoizingHandler) InitImage(imageRef string) (imgutil.Image, error) {
+	if imageRef == "" {
+		return nil, nil
+	}
+	if img, ok := h.cached(imageRef); ok {
+		return img, nil
+	}
+	v, err, _ := h.group.Do(imageRef, func() (interface{}, error) {
+		if img, ok := h.cached(imageRef); ok {
+			return img, nil
+		}
+		img, err := h.Handler.InitImage(imageRef)
+		if err != nil || img == nil {
+			// don't memoize failures, so that a later call may retry
+			return img, err
+		}
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.images[imageRef] = img
+		if key := resolvedDigestKey(img); key != "" {
+			h.images[key] = img
+		}
+		return img, nil
+	})
+	img, _ := v.(imgutil.Image)
+	return img, err
+}
+
+func (h *MemoizingHandler) cached(imageRef string) (imgutil.Image, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if img, ok := h.images[imageRef]; ok {
+		return img, true
+	}
+	if key := digestKey(imageRef); key != "" {
+		img, ok := h.images[key]
+		return img, ok
+	}
+	return nil, false
+}
+
+// resolvedDigestKey returns the digest key of the provided image if it was found and resolved to a digest reference,
+// e.g., an image in a registry, or an empty string otherwise, e.g., an image in a daemon, which is identified by its image ID.
+func resolvedDigestKey(img imgutil.Image) string {
+	if !img.Found() {
+		return ""
+	}
+	identifier, err := img.Identifier()
+	if err != nil || identifier == nil {
+		return ""
+	}
+	return digestKey(identifier.String())
+}
+
+// digestKey returns the provided reference normalized as `<repository>@<digest>`, if it is a digest reference,
+// so that references to the same digest in the same repository match; images in different repositories are not shared.
+func digestKey(imageRef string) string {
+	digest, err := name.NewDigest(imageRef, name.WeakValidation)
+	if err != nil {
+		return ""
+	}
+	return digest.Context().Name() + "@" + digest.DigestStr()
+}
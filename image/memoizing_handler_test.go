@@ -0,0 +1,170 @@
+package image_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/fakes"
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/buildpacks/lifecycle/image"
+	"github.com/buildpacks/lifecycle/phase/testmock"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestMemoizingHandler(t *testing.T) {
+	spec.Run(t, "MemoizingHandler", testMemoizingHandler, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testMemoizingHandler(t *testing.T, when spec.G, it spec.S) {
+	someDigest := "sha256:" + strings.Repeat("a", 64)
+
+	var (
+		mockController *gomock.Controller
+		mockHandler    *testmock.MockHandler
+		subject        *image.MemoizingHandler
+	)
+
+	it.Before(func() {
+		mockController = gomock.NewController(t)
+		mockHandler = testmock.NewMockHandler(mockController)
+		subject = image.NewMemoizingHandler(mockHandler)
+	})
+
+	it.After(func() {
+		mockController.Finish()
+	})
+
+	when("#InitImage", func() {
+		it("initializes each reference once", func() {
+			someImage := fakes.NewImage("some-image", "", nil)
+			otherImage := fakes.NewImage("other-image", "", nil)
+			mockHandler.EXPECT().InitImage("some-image").Return(someImage, nil).Times(1)
+			mockHandler.EXPECT().InitImage("other-image").Return(otherImage, nil).Times(1)
+
+			for i := 0; i < 2; i++ {
+				img, err := subject.InitImage("some-image")
+				h.AssertNil(t, err)
+				h.AssertEq(t, img == someImage, true)
+			}
+			img, err := subject.InitImage("other-image")
+			h.AssertNil(t, err)
+			h.AssertEq(t, img == otherImage, true)
+		})
+
+		it("initializes different references concurrently", func() {
+			someStarted, otherStarted := make(chan struct{}), make(chan struct{})
+			mockHandler.EXPECT().InitImage("some-image").DoAndReturn(func(string) (imgutil.Image, error) {
+				close(someStarted)
+				<-otherStarted // blocks unless the other reference is initialized concurrently
+				return fakes.NewImage("some-image", "", nil), nil
+			})
+			mockHandler.EXPECT().InitImage("other-image").DoAndReturn(func(string) (imgutil.Image, error) {
+				close(otherStarted)
+				<-someStarted
+				return fakes.NewImage("other-image", "", nil), nil
+			})
+
+			var g errgroup.Group
+			for _, ref := range []string{"some-image", "other-image"} {
+				ref := ref
+				g.Go(func() error {
+					_, err := subject.InitImage(ref)
+					return err
+				})
+			}
+			h.AssertNil(t, g.Wait())
+		})
+
+		it("initializes a reference once when called concurrently", func() {
+			someImage := fakes.NewImage("some-image", "", nil)
+			release := make(chan struct{})
+			mockHandler.EXPECT().InitImage("some-image").DoAndReturn(func(string) (imgutil.Image, error) {
+				<-release
+				return someImage, nil
+			}).Times(1)
+
+			var g errgroup.Group
+			for i := 0; i < 5; i++ {
+				g.Go(func() error {
+					img, err := subject.InitImage("some-image")
+					if err == nil && img != someImage {
+						return errors.New("unexpected image")
+					}
+					return err
+				})
+			}
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+			h.AssertNil(t, g.Wait())
+		})
+
+		when("an image was initialized by tag", func() {
+			var someImage *fakes.Image
+
+			it.Before(func() {
+				identifier, err := name.NewDigest("some-registry.io/some-repo@"+someDigest, name.WeakValidation)
+				h.AssertNil(t, err)
+				someImage = fakes.NewImage("some-registry.io/some-repo:some-tag", "", identifier)
+				mockHandler.EXPECT().InitImage("some-registry.io/some-repo:some-tag").Return(someImage, nil).Times(1)
+				_, err = subject.InitImage("some-registry.io/some-repo:some-tag")
+				h.AssertNil(t, err)
+			})
+
+			it("returns the image for a reference to its resolved digest", func() {
+				img, err := subject.InitImage("some-registry.io/some-repo@" + someDigest)
+				h.AssertNil(t, err)
+				h.AssertEq(t, img == someImage, true)
+			})
+
+			it("initializes the image for a reference to the digest in another repository", func() {
+				otherImage := fakes.NewImage("some-registry.io/other-repo@"+someDigest, "", nil)
+				mockHandler.EXPECT().InitImage("some-registry.io/other-repo@"+someDigest).Return(otherImage, nil)
+
+				img, err := subject.InitImage("some-registry.io/other-repo@" + someDigest)
+				h.AssertNil(t, err)
+				h.AssertEq(t, img == otherImage, true)
+			})
+
+			it("initializes the image for a different reference", func() {
+				otherImage := fakes.NewImage("some-registry.io/some-repo:other-tag", "", nil)
+				mockHandler.EXPECT().InitImage("some-registry.io/some-repo:other-tag").Return(otherImage, nil)
+
+				img, err := subject.InitImage("some-registry.io/some-repo:other-tag")
+				h.AssertNil(t, err)
+				h.AssertEq(t, img == otherImage, true)
+			})
+		})
+
+		when("no image reference is provided", func() {
+			it("returns a nil image", func() {
+				img, err := subject.InitImage("")
+				h.AssertNil(t, err)
+				h.AssertNil(t, img)
+			})
+		})
+
+		when("initializing the image fails", func() {
+			it("retries on the next call", func() {
+				someImage := fakes.NewImage("some-image", "", nil)
+				gomock.InOrder(
+					mockHandler.EXPECT().InitImage("some-image").Return(nil, errors.New("some-error")),
+					mockHandler.EXPECT().InitImage("some-image").Return(someImage, nil),
+				)
+
+				_, err := subject.InitImage("some-image")
+				h.AssertError(t, err, "some-error")
+				img, err := subject.InitImage("some-image")
+				h.AssertNil(t, err)
+				h.AssertEq(t, img == someImage, true)
+			})
+		})
+	})
+}
@@ -0,0 +1,53 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// ErrNetworkDisabled is returned when an image would be read from a registry while network access is disabled.
+var ErrNetworkDisabled = errors.New("network access is disabled")
+
+// OfflineHandler wraps a Handler so that initializing a remote image fails immediately,
+// e.g., for offline builds in which all images are expected to be in a daemon or a layout directory,
+// so that a misconfiguration is reported instead of a fetch hanging on a blocked network.
+type OfflineHandler struct {
+	Handler
+}
+
+// NewOfflineHandler returns an OfflineHandler wrapping the provided Handler.
+func NewOfflineHandler(h Handler) *OfflineHandler {
+	return &OfflineHandler{Handler: h}
+}
+
+func (h *OfflineHandler) InitImage(imageRef string) (imgutil.Image, error) {
+	if imageRef != "" && h.Handler.Kind() == RemoteKind {
+		return nil, fmt.Errorf("reading image %q from a registry: %w", imageRef, ErrNetworkDisabled)
+	}
+	return h.Handler.InitImage(imageRef)
+}
+
+// OfflineRegistryHandler is a RegistryHandler for which checking access to any image fails immediately,
+// as checking access requires contacting the registry.
+type OfflineRegistryHandler struct{}
+
+// EnsureReadAccess returns ErrNetworkDisabled if any of the provided image references is not empty.
+func (OfflineRegistryHandler) EnsureReadAccess(imageRefs ...string) error {
+	return offlineAccessError("read", imageRefs)
+}
+
+// EnsureWriteAccess returns ErrNetworkDisabled if any of the provided image references is not empty.
+func (OfflineRegistryHandler) EnsureWriteAccess(imageRefs ...string) error {
+	return offlineAccessError("write", imageRefs)
+}
+
+func offlineAccessError(access string, imageRefs []string) error {
+	for _, imageRef := range imageRefs {
+		if imageRef != "" {
+			return fmt.Errorf("ensuring registry %s access to %s: %w", access, imageRef, ErrNetworkDisabled)
+		}
+	}
+	return nil
+}
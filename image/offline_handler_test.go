@@ -0,0 +1,79 @@
+package image_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/buildpacks/imgutil/fakes"
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/image"
+	"github.com/buildpacks/lifecycle/phase/testmock"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestOfflineHandler(t *testing.T) {
+	spec.Run(t, "OfflineHandler", testOfflineHandler, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testOfflineHandler(t *testing.T, when spec.G, it spec.S) {
+	var (
+		mockController *gomock.Controller
+		mockHandler    *testmock.MockHandler
+		subject        *image.OfflineHandler
+	)
+
+	it.Before(func() {
+		mockController = gomock.NewController(t)
+		mockHandler = testmock.NewMockHandler(mockController)
+		subject = image.NewOfflineHandler(mockHandler)
+	})
+
+	it.After(func() {
+		mockController.Finish()
+	})
+
+	when("#InitImage", func() {
+		when("images are read from a registry", func() {
+			it("errors without initializing the image", func() {
+				mockHandler.EXPECT().Kind().Return(image.RemoteKind).AnyTimes()
+
+				_, err := subject.InitImage("some-image")
+				h.AssertError(t, err, `reading image "some-image" from a registry: network access is disabled`)
+				h.AssertEq(t, errors.Is(err, image.ErrNetworkDisabled), true)
+			})
+		})
+
+		when("images are read from the daemon", func() {
+			it("initializes the image", func() {
+				someImage := fakes.NewImage("some-image", "", nil)
+				mockHandler.EXPECT().Kind().Return(image.LocalKind).AnyTimes()
+				mockHandler.EXPECT().InitImage("some-image").Return(someImage, nil)
+
+				img, err := subject.InitImage("some-image")
+				h.AssertNil(t, err)
+				if img != someImage {
+					t.Fatalf("expected the image initialized by the wrapped handler")
+				}
+			})
+		})
+	})
+
+	when("OfflineRegistryHandler", func() {
+		it("errors when checking access to an image", func() {
+			err := image.OfflineRegistryHandler{}.EnsureReadAccess("", "some-image")
+			h.AssertError(t, err, "ensuring registry read access to some-image: network access is disabled")
+			h.AssertEq(t, errors.Is(err, image.ErrNetworkDisabled), true)
+
+			err = image.OfflineRegistryHandler{}.EnsureWriteAccess("some-image")
+			h.AssertError(t, err, "ensuring registry write access to some-image: network access is disabled")
+		})
+
+		it("does not error when no images are provided", func() {
+			h.AssertNil(t, image.OfflineRegistryHandler{}.EnsureReadAccess(""))
+			h.AssertNil(t, image.OfflineRegistryHandler{}.EnsureWriteAccess())
+		})
+	})
+}
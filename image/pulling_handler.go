@@ -0,0 +1,125 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// PullPolicy determines whether images are pulled from the registry into the daemon before they are used.
+type PullPolicy string
+
+const (
+	// PullAlways pulls the image before it is used, so that the daemon has the latest image from the registry.
+	PullAlways PullPolicy = "always"
+	// PullIfNotPresent pulls the image only if it is not already in the daemon.
+	PullIfNotPresent PullPolicy = "if-not-present"
+	// PullNever uses only images already in the daemon.
+	PullNever PullPolicy = "never"
+)
+
+// ParsePullPolicy returns the pull policy with the provided name.
+func ParsePullPolicy(value string) (PullPolicy, error) {
+	switch policy := PullPolicy(value); policy {
+	case PullAlways, PullIfNotPresent, PullNever:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid pull policy %q: must be one of %q, %q, or %q", value, PullAlways, PullIfNotPresent, PullNever)
+	}
+}
+
+// PullingHandler wraps a LocalHandler so that images are pulled from the registry into the daemon according to the pull policy.
+// An image that does not exist in the registry is not pulled, and is returned as not found, as the previous image may not exist yet.
+type PullingHandler struct {
+	Handler
+	docker   client.CommonAPIClient
+	keychain authn.Keychain
+	policy   PullPolicy
+}
+
+// NewPullingHandler returns a PullingHandler wrapping the provided Handler.
+func NewPullingHandler(h Handler, docker client.CommonAPIClient, keychain authn.Keychain, policy PullPolicy) *PullingHandler {
+	return &PullingHandler{
+		Handler:  h,
+		docker:   docker,
+		keychain: keychain,
+		policy:   policy,
+	}
+}
+
+func (h *PullingHandler) InitImage(imageRef string) (imgutil.Image, error) {
+	if imageRef == "" {
+		return nil, nil
+	}
+	switch h.policy {
+	case PullAlways:
+		if err := h.pull(imageRef); err != nil {
+			return nil, err
+		}
+	case PullIfNotPresent:
+		img, err := h.Handler.InitImage(imageRef)
+		if err != nil || img == nil || img.Found() {
+			return img, err
+		}
+		if err = h.pull(imageRef); err != nil {
+			return nil, err
+		}
+	}
+	return h.Handler.InitImage(imageRef)
+}
+
+func (h *PullingHandler) pull(imageRef string) error {
+	opts := types.ImagePullOptions{}
+	if h.keychain != nil {
+		registryAuth, err := encodedRegistryAuth(h.keychain, imageRef)
+		if err != nil {
+			return err
+		}
+		opts.RegistryAuth = registryAuth
+	}
+	rc, err := h.docker.ImagePull(context.Background(), imageRef, opts)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("pulling image %q: %w", imageRef, err)
+	}
+	defer rc.Close()
+	if err = jsonmessage.DisplayJSONMessagesStream(rc, io.Discard, 0, false, nil); err != nil {
+		return fmt.Errorf("pulling image %q: %w", imageRef, err)
+	}
+	return nil
+}
+
+// encodedRegistryAuth returns the credentials for the registry of the provided image reference,
+// encoded as expected by the daemon.
+func encodedRegistryAuth(keychain authn.Keychain, imageRef string) (string, error) {
+	ref, err := name.ParseReference(imageRef, name.WeakValidation)
+	if err != nil {
+		return "", err
+	}
+	authenticator, err := keychain.Resolve(ref.Context().Registry)
+	if err != nil {
+		return "", err
+	}
+	authConfig, err := authenticator.Authorization()
+	if err != nil {
+		return "", err
+	}
+	return registry.EncodeAuthConfig(registry.AuthConfig{
+		Username:      authConfig.Username,
+		Password:      authConfig.Password,
+		Auth:          authConfig.Auth,
+		IdentityToken: authConfig.IdentityToken,
+		RegistryToken: authConfig.RegistryToken,
+	})
+}
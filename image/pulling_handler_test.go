@@ -0,0 +1,152 @@
+package image_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/buildpacks/imgutil/fakes"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/pkg/errors"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/image"
+	"github.com/buildpacks/lifecycle/phase/testmock"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestPullingHandler(t *testing.T) {
+	spec.Run(t, "PullingHandler", testPullingHandler, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testPullingHandler(t *testing.T, when spec.G, it spec.S) {
+	var (
+		mockController *gomock.Controller
+		mockHandler    *testmock.MockHandler
+		docker         *pullRecordingClient
+		foundImage     *fakes.Image
+		missingImage   *fakes.Image
+	)
+
+	it.Before(func() {
+		mockController = gomock.NewController(t)
+		mockHandler = testmock.NewMockHandler(mockController)
+		docker = &pullRecordingClient{}
+		foundImage = fakes.NewImage("some-image", "", nil)
+		missingImage = fakes.NewImage("some-image", "", nil)
+		missingImage.Delete()
+	})
+
+	it.After(func() {
+		mockController.Finish()
+	})
+
+	when(".ParsePullPolicy", func() {
+		it("parses valid policies", func() {
+			for _, value := range []string{"always", "if-not-present", "never"} {
+				policy, err := image.ParsePullPolicy(value)
+				h.AssertNil(t, err)
+				h.AssertEq(t, string(policy), value)
+			}
+		})
+
+		it("errors for invalid policies", func() {
+			_, err := image.ParsePullPolicy("sometimes")
+			h.AssertError(t, err, `invalid pull policy "sometimes"`)
+		})
+	})
+
+	when("#InitImage", func() {
+		when("the pull policy is always", func() {
+			it("pulls the image before initializing it", func() {
+				subject := image.NewPullingHandler(mockHandler, docker, authn.DefaultKeychain, image.PullAlways)
+				mockHandler.EXPECT().InitImage("some-image").Return(foundImage, nil)
+
+				img, err := subject.InitImage("some-image")
+				h.AssertNil(t, err)
+				h.AssertEq(t, img.Found(), true)
+				h.AssertEq(t, docker.pulled, []string{"some-image"})
+			})
+
+			when("the image does not exist in the registry", func() {
+				it("returns the image as not found", func() {
+					docker.pullErr = errdefs.NotFound(errors.New("manifest unknown"))
+					subject := image.NewPullingHandler(mockHandler, docker, nil, image.PullAlways)
+					mockHandler.EXPECT().InitImage("some-image").Return(missingImage, nil)
+
+					img, err := subject.InitImage("some-image")
+					h.AssertNil(t, err)
+					h.AssertEq(t, img.Found(), false)
+				})
+			})
+
+			when("pulling fails", func() {
+				it("errors", func() {
+					docker.pullErr = errors.New("some-error")
+					subject := image.NewPullingHandler(mockHandler, docker, nil, image.PullAlways)
+
+					_, err := subject.InitImage("some-image")
+					h.AssertError(t, err, `pulling image "some-image": some-error`)
+				})
+			})
+		})
+
+		when("the pull policy is if-not-present", func() {
+			it("does not pull an image that is in the daemon", func() {
+				subject := image.NewPullingHandler(mockHandler, docker, nil, image.PullIfNotPresent)
+				mockHandler.EXPECT().InitImage("some-image").Return(foundImage, nil)
+
+				img, err := subject.InitImage("some-image")
+				h.AssertNil(t, err)
+				h.AssertEq(t, img.Found(), true)
+				h.AssertEq(t, len(docker.pulled), 0)
+			})
+
+			it("pulls an image that is not in the daemon", func() {
+				subject := image.NewPullingHandler(mockHandler, docker, nil, image.PullIfNotPresent)
+				gomock.InOrder(
+					mockHandler.EXPECT().InitImage("some-image").Return(missingImage, nil),
+					mockHandler.EXPECT().InitImage("some-image").Return(foundImage, nil),
+				)
+
+				img, err := subject.InitImage("some-image")
+				h.AssertNil(t, err)
+				h.AssertEq(t, img.Found(), true)
+				h.AssertEq(t, docker.pulled, []string{"some-image"})
+			})
+		})
+
+		when("the pull policy is never", func() {
+			it("does not pull the image", func() {
+				subject := image.NewPullingHandler(mockHandler, docker, nil, image.PullNever)
+				mockHandler.EXPECT().InitImage("some-image").Return(missingImage, nil)
+
+				img, err := subject.InitImage("some-image")
+				h.AssertNil(t, err)
+				h.AssertEq(t, img.Found(), false)
+				h.AssertEq(t, len(docker.pulled), 0)
+			})
+		})
+	})
+}
+
+// pullRecordingClient is a docker client that records the images it is asked to pull.
+type pullRecordingClient struct {
+	client.CommonAPIClient
+	pulled  []string
+	pullErr error
+}
+
+func (c *pullRecordingClient) ImagePull(_ context.Context, ref string, _ types.ImagePullOptions) (io.ReadCloser, error) {
+	if c.pullErr != nil {
+		return nil, c.pullErr
+	}
+	c.pulled = append(c.pulled, ref)
+	return io.NopCloser(strings.NewReader(`{"status":"Pull complete"}`)), nil
+}
@@ -0,0 +1,73 @@
+package image
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// NewRegistryTransport returns a transport for registry requests, based on the go-containerregistry default transport,
+// that additionally trusts the CA certificates in the PEM-encoded bundle at caCertPath.
+// Proxies are configured from the environment, as with the default transport.
+func NewRegistryTransport(caCertPath string) (*http.Transport, error) {
+	transport := NewDefaultRegistryTransport()
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	contents, err := os.ReadFile(caCertPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading registry CA certificate")
+	}
+	if !pool.AppendCertsFromPEM(contents) {
+		return nil, errors.Errorf("no PEM-encoded certificates found in %q", caCertPath)
+	}
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	return transport, nil
+}
+
+// ProxyFunc returns a function for the Proxy field of an http.Transport that, like http.ProxyFromEnvironment,
+// uses the proxy configured by the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables,
+// but that additionally bypasses the proxy for requests matching the provided no-proxy rules,
+// a comma-separated list in the format of NO_PROXY, e.g., `mirror.internal,.corp.example.com,10.0.0.0/8`.
+// The environment is read when ProxyFunc is called.
+func ProxyFunc(noProxy string) func(*http.Request) (*url.URL, error) {
+	config := httpproxy.FromEnvironment()
+	if noProxy != "" {
+		if config.NoProxy != "" {
+			noProxy = config.NoProxy + "," + noProxy
+		}
+		config.NoProxy = noProxy
+	}
+	proxyFunc := config.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
+// NewDefaultRegistryTransport returns a copy of the go-containerregistry default transport, which may be configured further.
+func NewDefaultRegistryTransport() *http.Transport {
+	base, ok := remote.DefaultTransport.(*http.Transport)
+	if !ok {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	return base.Clone()
+}
+
+// SetDefaultRegistryTransport makes the provided transport the default for registry requests,
+// including those made through imgutil, which does not accept a transport when constructing images.
+// Requests to insecure registries continue to use a transport that skips TLS verification.
+func SetDefaultRegistryTransport(transport http.RoundTripper) {
+	http.DefaultTransport = transport
+	remote.DefaultTransport = transport
+}
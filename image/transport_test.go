@@ -0,0 +1,185 @@
+package image_test
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/image"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestTransport(t *testing.T) {
+	spec.Run(t, "Transport", testTransport, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testTransport(t *testing.T, when spec.G, it spec.S) {
+	when("#NewRegistryTransport", func() {
+		var (
+			server *httptest.Server
+			tmpDir string
+		)
+
+		it.Before(func() {
+			server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			var err error
+			tmpDir, err = os.MkdirTemp("", "lifecycle.image.transport")
+			h.AssertNil(t, err)
+		})
+
+		it.After(func() {
+			server.Close()
+			h.AssertNil(t, os.RemoveAll(tmpDir))
+		})
+
+		it("trusts the provided CA certificates", func() {
+			caCertPath := filepath.Join(tmpDir, "ca.pem")
+			contents := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+			h.AssertNil(t, os.WriteFile(caCertPath, contents, 0600))
+
+			transport, err := image.NewRegistryTransport(caCertPath)
+			h.AssertNil(t, err)
+
+			resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+			h.AssertNil(t, err)
+			h.AssertNil(t, resp.Body.Close())
+			h.AssertEq(t, resp.StatusCode, http.StatusOK)
+		})
+
+		when("the file does not contain certificates", func() {
+			it("errors", func() {
+				caCertPath := filepath.Join(tmpDir, "ca.pem")
+				h.AssertNil(t, os.WriteFile(caCertPath, []byte("some-garbage"), 0600))
+
+				_, err := image.NewRegistryTransport(caCertPath)
+				h.AssertError(t, err, "no PEM-encoded certificates found")
+			})
+		})
+
+		when("the file does not exist", func() {
+			it("errors", func() {
+				_, err := image.NewRegistryTransport(filepath.Join(tmpDir, "missing.pem"))
+				h.AssertError(t, err, "reading registry CA certificate")
+			})
+		})
+	})
+
+	when("#NewLoggingTransport", func() {
+		var (
+			server     *httptest.Server
+			logHandler *memory.Handler
+			transport  *image.LoggingTransport
+		)
+
+		it.Before(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Www-Authenticate", `Bearer realm="https://auth.example.com/token",service="some-registry"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			}))
+			logHandler = memory.New()
+			transport = image.NewLoggingTransport(http.DefaultTransport, &log.Logger{Handler: logHandler, Level: log.DebugLevel})
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		it("logs each request with credentials redacted", func() {
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/v2/some-repo/blobs/sha256:abc?X-Amz-Signature=some-signature&scope=repository:some-repo:pull", nil)
+			h.AssertNil(t, err)
+			req.Header.Set("Authorization", "Bearer some-token")
+
+			resp, err := (&http.Client{Transport: transport}).Do(req)
+			h.AssertNil(t, err)
+			h.AssertNil(t, resp.Body.Close())
+
+			var messages []string
+			for _, entry := range logHandler.Entries {
+				messages = append(messages, entry.Message)
+			}
+			logs := strings.Join(messages, "\n")
+			h.AssertStringContains(t, logs, "HTTP GET "+server.URL+"/v2/some-repo/blobs/sha256:abc?X-Amz-Signature=REDACTED&scope=repository%3Asome-repo%3Apull: 401 Unauthorized")
+			h.AssertStringContains(t, logs, "> Authorization: Bearer REDACTED")
+			h.AssertStringContains(t, logs, `< Www-Authenticate: Bearer realm="https://auth.example.com/token",service="some-registry"`)
+			h.AssertStringDoesNotContain(t, logs, "some-token")
+			h.AssertStringDoesNotContain(t, logs, "some-signature")
+		})
+	})
+}
+
+func TestProxyFunc(t *testing.T) {
+	spec.Run(t, "ProxyFunc", testProxyFunc, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testProxyFunc(t *testing.T, when spec.G, it spec.S) {
+	var (
+		proxy        *httptest.Server
+		proxiedHosts []string
+	)
+
+	it.Before(func() {
+		proxiedHosts = nil
+		proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxiedHosts = append(proxiedHosts, r.Host)
+			w.WriteHeader(http.StatusOK)
+		}))
+		for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy", "REQUEST_METHOD"} {
+			t.Setenv(name, "")
+		}
+		t.Setenv("HTTP_PROXY", proxy.URL)
+		t.Setenv("HTTPS_PROXY", proxy.URL)
+	})
+
+	it.After(func() {
+		proxy.Close()
+	})
+
+	proxyFor := func(proxyFunc func(*http.Request) (*url.URL, error), rawURL string) *url.URL {
+		req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+		h.AssertNil(t, err)
+		proxyURL, err := proxyFunc(req)
+		h.AssertNil(t, err)
+		return proxyURL
+	}
+
+	it("uses the proxy from the environment", func() {
+		transport := image.NewDefaultRegistryTransport()
+		transport.Proxy = image.ProxyFunc("")
+
+		resp, err := (&http.Client{Transport: transport}).Get("http://some-registry.io/v2/")
+		h.AssertNil(t, err)
+		h.AssertNil(t, resp.Body.Close())
+
+		h.AssertEq(t, proxiedHosts, []string{"some-registry.io"})
+	})
+
+	it("bypasses the proxy for hosts in NO_PROXY", func() {
+		t.Setenv("NO_PROXY", "mirror.internal")
+		proxyFunc := image.ProxyFunc("")
+
+		h.AssertNil(t, proxyFor(proxyFunc, "https://mirror.internal/v2/"))
+		h.AssertEq(t, proxyFor(proxyFunc, "https://some-registry.io/v2/").String(), proxy.URL)
+	})
+
+	it("bypasses the proxy for hosts in the additional no-proxy rules", func() {
+		t.Setenv("NO_PROXY", "mirror.internal")
+		proxyFunc := image.ProxyFunc("other-mirror.internal,.corp.example.com")
+
+		h.AssertNil(t, proxyFor(proxyFunc, "https://mirror.internal/v2/"))
+		h.AssertNil(t, proxyFor(proxyFunc, "https://other-mirror.internal/v2/"))
+		h.AssertNil(t, proxyFor(proxyFunc, "https://registry.corp.example.com/v2/"))
+		h.AssertEq(t, proxyFor(proxyFunc, "https://some-registry.io/v2/").String(), proxy.URL)
+	})
+}
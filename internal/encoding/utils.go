@@ -3,9 +3,12 @@ package encoding
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"syscall"
 
 	"github.com/BurntSushi/toml"
 )
@@ -48,14 +51,57 @@ func MarshalTOML(v interface{}) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// WriteTOML writes the provided data to the provided path as TOML.
+// The output is deterministic, so that files written from equal data may be content-addressed:
+// map keys are written in sorted order, and arrays in the order provided,
+// as array order is significant in lifecycle files (e.g., the order of buildpacks in a group).
+// Nothing is written if the data cannot be encoded, and the file is replaced atomically (see WriteFileAtomic).
 func WriteTOML(path string, data interface{}) error {
+	b, err := MarshalTOML(data)
+	if err != nil {
+		return err
+	}
 	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
 		return err
 	}
-	f, err := os.Create(path)
+	return WriteFileAtomic(path, b)
+}
+
+// WriteFileAtomic writes the provided contents to a temporary file in the directory of the provided path
+// and renames it into place, so that readers see either the previous contents or the complete new contents,
+// even if the process is killed while writing.
+// If the file cannot be replaced by renaming because it is a mount point (e.g., a file bind-mounted into the container),
+// or the temporary file is on a different device, the file is written in place instead, which is not atomic.
+func WriteFileAtomic(path string, b []byte) error {
+	tmpPath := fmt.Sprintf("%s.tmp-%d-%d", path, os.Getpid(), rand.Int63()) // #nosec G404 -- only needs to be unique
+	if err := writeFileSynced(tmpPath, b); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		if errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EXDEV) {
+			return os.WriteFile(path, b, 0666) // #nosec G306 -- matches the permissions of os.Create
+		}
+		return err
+	}
+	return nil
+}
+
+// writeFileSynced writes the provided contents to a new file at the provided path, and flushes the file to disk
+// so that the contents are complete before the file is renamed.
+func writeFileSynced(path string, b []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666) // #nosec G302 G304 -- matches the permissions of os.Create
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	return toml.NewEncoder(f).Encode(data)
+	if _, err = f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
@@ -77,5 +77,50 @@ func testEncoding(t *testing.T, when spec.G, it spec.S) {
 				t.Fatalf("Unexpected TOML:\n%s\n", s)
 			}
 		})
+
+		it("should write maps with sorted keys", func() {
+			data := map[string]interface{}{
+				"b-key": "b-val",
+				"c-key": map[string]string{"z": "1", "y": "2"},
+				"a-key": "a-val",
+			}
+			path := filepath.Join(tmpDir, "data.toml")
+			for i := 0; i < 10; i++ {
+				if err := encoding.WriteTOML(path, data); err != nil {
+					t.Fatal(err)
+				}
+				b := h.Rdfile(t, path)
+				if s := cmp.Diff(b,
+					`a-key = "a-val"`+"\n"+
+						`b-key = "b-val"`+"\n"+
+						"\n"+
+						"[c-key]\n"+
+						`  y = "2"`+"\n"+
+						`  z = "1"`+"\n",
+				); s != "" {
+					t.Fatalf("Unexpected TOML:\n%s\n", s)
+				}
+			}
+		})
+
+		it("should not write the file if the data cannot be encoded", func() {
+			path := filepath.Join(tmpDir, "data.toml")
+			if err := encoding.WriteTOML(path, []interface{}{nil}); err == nil {
+				t.Fatal("expected an error")
+			}
+			h.AssertPathDoesNotExist(t, path)
+		})
+
+		it("should replace an existing file without leaving temporary files", func() {
+			path := filepath.Join(tmpDir, "data.toml")
+			h.Mkfile(t, "some-previous-contents-that-are-longer-than-the-new-contents", path)
+			if err := encoding.WriteTOML(path, map[string]string{"key": "val"}); err != nil {
+				t.Fatal(err)
+			}
+			h.AssertEq(t, h.Rdfile(t, path), `key = "val"`+"\n")
+			fis, err := os.ReadDir(tmpDir)
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(fis), 1)
+		})
 	})
 }
@@ -53,6 +53,42 @@ func copyDir(src, dst string) error {
 	return nil
 }
 
+// Link recreates the provided file or directory at dst, hard-linking regular files
+// and falling back to copying them when they cannot be linked (e.g., across filesystems).
+func Link(src, dst string) error {
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case fi.Mode().IsDir():
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+		children, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if err := Link(filepath.Join(src, child.Name()), filepath.Join(dst, child.Name())); err != nil {
+				return err
+			}
+		}
+	case fi.Mode().IsRegular():
+		if err := os.Link(src, dst); err != nil {
+			return copyFile(src, dst)
+		}
+	case fi.Mode()&os.ModeSymlink != 0:
+		if err := copySymlink(src, dst); err != nil {
+			return err
+		}
+	default:
+		// ignore edge cases (unix socket, named pipe, etc.)
+	}
+	return nil
+}
+
 // FilesWithExtensions returns a list of all files in directory that end in any of the extensions provided.
 // top level only - does not recursively visit directories.
 func FilesWithExtensions(dir string, extensions []string) ([]string, error) {
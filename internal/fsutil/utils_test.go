@@ -70,6 +70,23 @@ func testIO(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#Link", func() {
+		it("recreates source at destination with linked files", func() {
+			src := filepath.Join("testdata", "some_dir")
+			dst := filepath.Join(tmpDir, "dest_dir")
+
+			h.AssertNil(t, fsutil.Link(src, dst))
+
+			contents := h.MustReadFile(t, filepath.Join(dst, "some_file"))
+			h.AssertEq(t, string(contents), "some-content\n")
+			contents = h.MustReadFile(t, filepath.Join(dst, "other_dir", "other_file"))
+			h.AssertEq(t, string(contents), "other-content\n")
+			target, err := os.Readlink(filepath.Join(dst, "some_link"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, target, "some_file")
+		})
+	})
+
 	when("#RenameWithWindowsFallback", func() {
 		when("directory does not exist", func() {
 			it("returns not exist error", func() {
@@ -3,12 +3,12 @@ package layer
 import (
 	"fmt"
 	"path/filepath"
+	"sort"
 
 	"github.com/pkg/errors"
 
 	"github.com/buildpacks/lifecycle/buildpack"
 	"github.com/buildpacks/lifecycle/internal/encoding"
-	"github.com/buildpacks/lifecycle/launch"
 	"github.com/buildpacks/lifecycle/log"
 	"github.com/buildpacks/lifecycle/platform"
 	"github.com/buildpacks/lifecycle/platform/files"
@@ -36,6 +36,10 @@ type DefaultMetadataRestorer struct {
 	LayersDir  string
 	SkipLayers bool
 	Logger     log.Logger
+	// Layout determines where the layers of each buildpack are found; if nil, the default layout is used.
+	Layout buildpack.LayersDirLayout
+	// DryRun, if true, causes layer shas to be recorded without writing any metadata to the layers directory.
+	DryRun bool
 }
 
 func (r *DefaultMetadataRestorer) Restore(buildpacks []buildpack.GroupElement, appMeta files.LayersMetadata, cacheMeta platform.CacheMetadata, layerSHAStore SHAStore) error {
@@ -51,9 +55,12 @@ func (r *DefaultMetadataRestorer) Restore(buildpacks []buildpack.GroupElement, a
 }
 
 func (r *DefaultMetadataRestorer) restoreStoreTOML(appMeta files.LayersMetadata, buildpacks []buildpack.GroupElement) error {
+	if r.DryRun {
+		return nil
+	}
 	for _, bp := range buildpacks {
 		if store := appMeta.LayersMetadataFor(bp.ID).Store; store != nil {
-			if err := encoding.WriteTOML(filepath.Join(r.LayersDir, launch.EscapeID(bp.ID), "store.toml"), store); err != nil {
+			if err := encoding.WriteTOML(filepath.Join(buildpack.LayoutOrDefault(r.Layout).BuildpackDir(r.LayersDir, bp), "store.toml"), store); err != nil {
 				return err
 			}
 		}
@@ -68,7 +75,7 @@ func (r *DefaultMetadataRestorer) restoreLayerMetadata(layerSHAStore SHAStore, a
 	}
 
 	for _, bp := range buildpacks {
-		buildpackDir, err := buildpack.ReadLayersDir(r.LayersDir, bp, r.Logger)
+		buildpackDir, err := buildpack.ReadLayersDirWithLayout(r.LayersDir, bp, r.Layout, r.Logger)
 		if err != nil {
 			return errors.Wrap(err, "reading buildpack layer directory")
 		}
@@ -77,7 +84,9 @@ func (r *DefaultMetadataRestorer) restoreLayerMetadata(layerSHAStore SHAStore, a
 		// The restorer step will restore the layer data for cache=true layers if possible or delete the layer.
 		appLayers := appMeta.LayersMetadataFor(bp.ID).Layers
 		cachedLayers := cacheMeta.MetadataForBuildpack(bp.ID).Layers
-		for layerName, layer := range appLayers {
+		// Layers are visited in order of name, so that logs are the same from build to build.
+		for _, layerName := range sortedLayerNames(appLayers) {
+			layer := appLayers[layerName]
 			identifier := fmt.Sprintf("%s:%s", bp.ID, layerName)
 			if !layer.Launch {
 				r.Logger.Debugf("Not restoring metadata for %q, marked as launch=false", identifier)
@@ -107,7 +116,8 @@ func (r *DefaultMetadataRestorer) restoreLayerMetadata(layerSHAStore SHAStore, a
 
 		// Restore metadata for cache=true layers.
 		// The restorer step will restore the layer data if possible or delete the layer.
-		for layerName, layer := range cachedLayers {
+		for _, layerName := range sortedLayerNames(cachedLayers) {
+			layer := cachedLayers[layerName]
 			identifier := fmt.Sprintf("%s:%s", bp.ID, layerName)
 			if !layer.Cache {
 				r.Logger.Debugf("Not restoring %q from cache, marked as cache=false", identifier)
@@ -127,8 +137,20 @@ func (r *DefaultMetadataRestorer) restoreLayerMetadata(layerSHAStore SHAStore, a
 	return nil
 }
 
+func sortedLayerNames(layers map[string]buildpack.LayerMetadata) []string {
+	var names []string
+	for name := range layers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (r *DefaultMetadataRestorer) writeLayerMetadata(layerSHAStore SHAStore, buildpackDir buildpack.LayersDir, layerName string, metadata buildpack.LayerMetadata, buildpackID string) error {
 	layer := buildpackDir.NewLayer(layerName, buildpackDir.Buildpack.API, r.Logger)
+	if r.DryRun {
+		return layerSHAStore.add(buildpackID, metadata.SHA, layer)
+	}
 	r.Logger.Debugf("Writing layer metadata for %q", layer.Identifier())
 	if err := layer.WriteMetadata(metadata.LayerMetadataFile); err != nil {
 		return err
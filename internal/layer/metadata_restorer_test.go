@@ -9,6 +9,7 @@ import (
 
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/discard"
+	"github.com/apex/log/handlers/memory"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 
@@ -143,6 +144,41 @@ func testLayerMetadataRestorer(t *testing.T, when spec.G, it spec.S) {
 				}
 			})
 
+			it("restores layer metadata in order of layer name", func() {
+				var runs [][]string
+				for i := 0; i < 5; i++ {
+					logHandler := memory.New()
+					logger = log.Logger{Handler: logHandler, Level: log.DebugLevel}
+					layerMetadataRestorer = layer.NewDefaultMetadataRestorer(layerDir, skipLayers, &logger)
+
+					h.AssertNil(t, layerMetadataRestorer.Restore(buildpacks, layersMetadata, cacheMetadata, layer.NewSHAStore()))
+
+					var messages []string
+					for _, entry := range logHandler.Entries {
+						if strings.Contains(entry.Message, "metadata.buildpack:") && !strings.HasPrefix(entry.Message, "Writing") {
+							messages = append(messages, entry.Message)
+						}
+					}
+					runs = append(runs, messages)
+				}
+
+				h.AssertEq(t, runs[0], []string{
+					`Restoring metadata for "metadata.buildpack:launch" from app image`,
+					`Not restoring metadata for "metadata.buildpack:launch-build", marked as build=true, cache=false`,
+					`Restoring metadata for "metadata.buildpack:launch-build-cache" from app image`,
+					`Restoring metadata for "metadata.buildpack:launch-cache" from app image`,
+					`Not restoring metadata for "metadata.buildpack:launch-false", marked as launch=false`,
+					`Restoring metadata for "metadata.buildpack:cache" from cache`,
+					`Not restoring "metadata.buildpack:cache-false" from cache, marked as cache=false`,
+					`Not restoring "metadata.buildpack:launch-build-cache" from cache, marked as launch=true`,
+					`Not restoring "metadata.buildpack:launch-cache" from cache, marked as launch=true`,
+					`Not restoring "metadata.buildpack:launch-cache-not-in-app" from cache, marked as launch=true`,
+				})
+				for _, run := range runs[1:] {
+					h.AssertEq(t, run, runs[0])
+				}
+			})
+
 			it("restores layer metadata without the launch, build and cache flags", func() {
 				buildpacks = []buildpack.GroupElement{
 					{ID: "metadata.buildpack", API: api.Buildpack.Latest().String()},
@@ -6,16 +6,13 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
-	"regexp"
-	"runtime"
+	"strings"
 
 	"github.com/buildpacks/imgutil"
 	"github.com/pkg/errors"
 
 	"github.com/buildpacks/lifecycle/api"
 	"github.com/buildpacks/lifecycle/buildpack"
-	"github.com/buildpacks/lifecycle/internal/fsutil"
-	"github.com/buildpacks/lifecycle/launch"
 	"github.com/buildpacks/lifecycle/layers"
 	"github.com/buildpacks/lifecycle/log"
 )
@@ -94,45 +91,61 @@ func (r *DefaultSBOMRestorer) RestoreFromCache(cache Cache, layerDigest string)
 	return layers.Extract(rc, "")
 }
 
+// RestoreToBuildpackLayers copies the cache and launch SBOM files of each layer to `<layers>/<buildpack-id>/<layer-name>.sbom.<format>.json`.
+// When a layer has both a cache and a launch SBOM file of the same format (e.g., because it is a cache=true, launch=true layer),
+// the cache SBOM file is kept, as it describes the layer data that is restored from the cache; the launch SBOM file does not overwrite it.
 func (r *DefaultSBOMRestorer) RestoreToBuildpackLayers(detectedBps []buildpack.GroupElement) error {
 	var (
 		cacheDir  = filepath.Join(r.LayersDir, "sbom", "cache")
 		launchDir = filepath.Join(r.LayersDir, "sbom", "launch")
+		restored  = make(map[string]string) // the type of SBOM restored to each destination path
 	)
 	defer os.RemoveAll(filepath.Join(r.LayersDir, "sbom"))
 
-	if err := filepath.Walk(cacheDir, r.restoreSBOMFunc(detectedBps, "cache")); err != nil {
+	if err := r.walkSBOMDir(cacheDir, detectedBps, restored); err != nil {
 		return err
 	}
 
-	return filepath.Walk(launchDir, r.restoreSBOMFunc(detectedBps, "launch"))
+	return r.walkSBOMDir(launchDir, detectedBps, restored)
 }
 
-func (r *DefaultSBOMRestorer) restoreSBOMFunc(detectedBps []buildpack.GroupElement, bomType string) func(path string, info fs.FileInfo, err error) error {
-	var bomRegex *regexp.Regexp
-
-	if runtime.GOOS == "windows" {
-		bomRegex = regexp.MustCompile(fmt.Sprintf(`%s\\(.+)\\(.+)\\(sbom.+json)`, bomType))
-	} else {
-		bomRegex = regexp.MustCompile(fmt.Sprintf(`%s/(.+)/(.+)/(sbom.+json)`, bomType))
+// walkSBOMDir copies SBOM files found under the provided directory to the matching buildpack layers directories,
+// skipping destination paths that were already restored.
+// A missing directory is not an error; errors reading individual files are logged and skipped,
+// but errors on the directory itself (such as permission errors) and errors writing files are returned.
+func (r *DefaultSBOMRestorer) walkSBOMDir(dir string, detectedBps []buildpack.GroupElement, restored map[string]string) error {
+	if _, err := os.Stat(dir); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "reading SBOM directory %q", dir)
 	}
+	return filepath.Walk(dir, r.restoreSBOMFunc(dir, detectedBps, restored))
+}
 
+func (r *DefaultSBOMRestorer) restoreSBOMFunc(root string, detectedBps []buildpack.GroupElement, restored map[string]string) func(path string, info fs.FileInfo, err error) error {
+	sbomType := filepath.Base(root)
 	return func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			if path == root {
+				return errors.Wrapf(err, "reading SBOM directory %q", root)
+			}
+			r.Logger.Warnf("Skipping SBOM file %q: %s", path, err)
+			return nil
+		}
 		if info == nil || !info.Mode().IsRegular() {
 			return nil
 		}
 
-		matches := bomRegex.FindStringSubmatch(path)
-		if len(matches) != 4 {
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
 			return nil
 		}
-
-		var (
-			bpID      = matches[1]
-			layerName = matches[2]
-			fileName  = matches[3]
-			destDir   = filepath.Join(r.LayersDir, bpID)
-		)
+		bpID, layerName, fileName, ok := parseSBOMPath(relPath)
+		if !ok {
+			return nil
+		}
+		destDir := filepath.Join(r.LayersDir, bpID)
 
 		// don't try to restore sbom files when the bp layers directory doesn't exist
 		// this can happen when there are sbom files for launch but the cache is empty
@@ -140,21 +153,44 @@ func (r *DefaultSBOMRestorer) restoreSBOMFunc(detectedBps []buildpack.GroupEleme
 			return nil
 		}
 
-		if !r.contains(detectedBps, bpID) {
+		if !(buildpack.Group{Group: detectedBps}).Contains(bpID) {
 			return nil
 		}
 
-		return fsutil.Copy(path, filepath.Join(destDir, fmt.Sprintf("%s.%s", layerName, fileName)))
+		destPath := filepath.Join(destDir, fmt.Sprintf("%s.%s", layerName, fileName))
+		if restoredType, ok := restored[destPath]; ok {
+			r.Logger.Debugf("Not restoring %s SBOM file %q, the %s SBOM file was restored to %q", sbomType, path, restoredType, destPath)
+			return nil
+		}
+		// the cached SBOM file may be unreadable, e.g., if the cache is corrupt, in which case it is skipped,
+		// but failing to write it, e.g., because the disk is full, fails the restore
+		b, err := os.ReadFile(path)
+		if err != nil {
+			r.Logger.Warnf("Failed to restore SBOM file %q: %s", path, err)
+			return nil
+		}
+		if err := os.WriteFile(destPath, b, 0666); err != nil { // #nosec G306 -- matches the permissions of os.Create
+			return errors.Wrapf(err, "restoring SBOM file %q", destPath)
+		}
+		restored[destPath] = sbomType
+		return nil
 	}
 }
 
-func (r *DefaultSBOMRestorer) contains(detectedBps []buildpack.GroupElement, id string) bool {
-	for _, bp := range detectedBps {
-		if launch.EscapeID(bp.ID) == id {
-			return true
-		}
+// parseSBOMPath splits the provided path, relative to a `<layers>/sbom/<cache|launch>` directory, into its components.
+// Only layer-level SBOM files, at `<buildpack-id>/<layer-name>/sbom.<format>.json`, are matched.
+// Both forward slashes and backslashes are treated as separators, regardless of the host OS,
+// as SBOM directories may be extracted from tars created on a different OS.
+func parseSBOMPath(relPath string) (bpID, layerName, fileName string, ok bool) {
+	parts := strings.Split(strings.ReplaceAll(filepath.ToSlash(relPath), `\`, "/"), "/")
+	if len(parts) != 3 || !isSBOMFileName(parts[2]) {
+		return "", "", "", false
 	}
-	return false
+	return parts[0], parts[1], parts[2], true
+}
+
+func isSBOMFileName(name string) bool {
+	return strings.HasPrefix(name, "sbom.") && strings.HasSuffix(name, ".json")
 }
 
 type NopSBOMRestorer struct{}
@@ -0,0 +1,52 @@
+package layer
+
+import (
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestSBOMRestorerInternal(t *testing.T) {
+	spec.Run(t, "SBOMRestorerInternal", testSBOMRestorerInternal, spec.Report(report.Terminal{}))
+}
+
+func testSBOMRestorerInternal(t *testing.T, when spec.G, it spec.S) {
+	when("parseSBOMPath", func() {
+		for _, tc := range []struct {
+			name    string
+			relPath string
+		}{
+			{name: "forward slashes", relPath: "com.example_my+bp/some-layer/sbom.cdx.json"},
+			{name: "backslashes", relPath: `com.example_my+bp\some-layer\sbom.cdx.json`},
+			{name: "mixed separators", relPath: `com.example_my+bp/some-layer\sbom.cdx.json`},
+		} {
+			tc := tc
+			when(tc.name, func() {
+				it("returns the buildpack ID, layer name, and file name", func() {
+					bpID, layerName, fileName, ok := parseSBOMPath(tc.relPath)
+					h.AssertEq(t, ok, true)
+					h.AssertEq(t, bpID, "com.example_my+bp")
+					h.AssertEq(t, layerName, "some-layer")
+					h.AssertEq(t, fileName, "sbom.cdx.json")
+				})
+			})
+		}
+
+		when("the path is not a layer-level SBOM file", func() {
+			it("does not match", func() {
+				for _, relPath := range []string{
+					`some-bp/sbom.cdx.json`,
+					`some-bp\some-layer\nested\sbom.cdx.json`,
+					`some-bp/some-layer/some-file.json`,
+					`some-bp/some-layer/sbom.cdx.txt`,
+				} {
+					_, _, _, ok := parseSBOMPath(relPath)
+					h.AssertEq(t, ok, false)
+				}
+			})
+		})
+	})
+}
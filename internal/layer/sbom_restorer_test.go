@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/apex/log"
@@ -245,5 +246,111 @@ func testSBOMRestorer(t *testing.T, when spec.G, it spec.S) {
 				h.AssertNil(t, sbomRestorer.RestoreToBuildpackLayers(detectedBps))
 			})
 		})
+
+		when("the sbom directories don't exist", func() {
+			it.Before(func() {
+				h.AssertNil(t, os.RemoveAll(filepath.Join(layersDir, "sbom")))
+			})
+
+			it("does not error", func() {
+				h.AssertNil(t, sbomRestorer.RestoreToBuildpackLayers(detectedBps))
+			})
+		})
+
+		when("buildpack IDs and layer names contain special characters", func() {
+			it.Before(func() {
+				detectedBps = append(detectedBps, buildpack.GroupElement{ID: "com.example/my+bp", API: api.Buildpack.Latest().String()})
+				h.AssertNil(t, os.MkdirAll(filepath.Join(layersDir, "com.example_my+bp"), 0755))
+				for _, bomType := range []string{"cache", "launch"} {
+					layerDir := filepath.Join(layersDir, "sbom", bomType, "com.example_my+bp", "my.layer+"+bomType)
+					h.Mkdir(t, layerDir)
+					h.AssertNil(t, os.WriteFile(filepath.Join(layerDir, "sbom.cdx.json"), []byte(`{"key": "some-`+bomType+`-bom-content"}`), 0600))
+				}
+			})
+
+			it("copies the SBOM files", func() {
+				h.AssertNil(t, sbomRestorer.RestoreToBuildpackLayers(detectedBps))
+
+				for _, bomType := range []string{"cache", "launch"} {
+					got := h.MustReadFile(t, filepath.Join(layersDir, "com.example_my+bp", "my.layer+"+bomType+".sbom.cdx.json"))
+					h.AssertEq(t, string(got), `{"key": "some-`+bomType+`-bom-content"}`)
+				}
+			})
+		})
+
+		when("a layer has both cache and launch SBOM files", func() {
+			it.Before(func() {
+				for _, bomType := range []string{"cache", "launch"} {
+					layerDir := filepath.Join(layersDir, "sbom", bomType, "buildpack.id", "cache-and-launch")
+					h.Mkdir(t, layerDir)
+					h.AssertNil(t, os.WriteFile(filepath.Join(layerDir, "sbom.cdx.json"), []byte(`{"key": "some-`+bomType+`-bom-content"}`), 0600))
+					h.AssertNil(t, os.WriteFile(filepath.Join(layerDir, "sbom.spdx.json"), []byte(`{"key": "some-`+bomType+`-spdx-content"}`), 0600))
+				}
+				h.AssertNil(t, os.Remove(filepath.Join(layersDir, "sbom", "cache", "buildpack.id", "cache-and-launch", "sbom.spdx.json")))
+			})
+
+			it("keeps the cache SBOM file rather than overwriting it with the launch SBOM file", func() {
+				h.AssertNil(t, sbomRestorer.RestoreToBuildpackLayers(detectedBps))
+
+				got := h.MustReadFile(t, filepath.Join(layersDir, "buildpack.id", "cache-and-launch.sbom.cdx.json"))
+				h.AssertEq(t, string(got), `{"key": "some-cache-bom-content"}`)
+			})
+
+			it("restores the launch SBOM files of formats that are not in the cache", func() {
+				h.AssertNil(t, sbomRestorer.RestoreToBuildpackLayers(detectedBps))
+
+				got := h.MustReadFile(t, filepath.Join(layersDir, "buildpack.id", "cache-and-launch.sbom.spdx.json"))
+				h.AssertEq(t, string(got), `{"key": "some-launch-spdx-content"}`)
+			})
+		})
+
+		when("SBOM files are nested below a layer directory", func() {
+			it.Before(func() {
+				nestedDir := filepath.Join(layersDir, "sbom", "launch", "buildpack.id", "launch-true", "nested")
+				h.Mkdir(t, nestedDir)
+				h.AssertNil(t, os.WriteFile(filepath.Join(nestedDir, "sbom.cdx.json"), []byte(`{}`), 0600))
+			})
+
+			it("does not copy them", func() {
+				h.AssertNil(t, sbomRestorer.RestoreToBuildpackLayers(detectedBps))
+
+				h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "nested.sbom.cdx.json"))
+			})
+		})
+
+		when("an SBOM file cannot be written", func() {
+			it.Before(func() {
+				h.Mkdir(t, filepath.Join(layersDir, "buildpack.id", "launch-true.sbom.cdx.json"))
+			})
+
+			it("errors", func() {
+				err := sbomRestorer.RestoreToBuildpackLayers(detectedBps)
+				h.AssertNotNil(t, err)
+				h.AssertStringContains(t, err.Error(), fmt.Sprintf("restoring SBOM file %q", filepath.Join(layersDir, "buildpack.id", "launch-true.sbom.cdx.json")))
+			})
+		})
+
+		when("the sbom tree contains a broken symlink", func() {
+			it.Before(func() {
+				h.SkipIf(t, runtime.GOOS == "windows", "symlinks require elevated privileges on windows")
+				brokenLayerDir := filepath.Join(layersDir, "sbom", "launch", "buildpack.id", "broken-layer")
+				h.Mkdir(t, brokenLayerDir)
+				h.AssertNil(t, os.Symlink(
+					filepath.Join(layersDir, "does-not-exist.json"),
+					filepath.Join(brokenLayerDir, "sbom.cdx.json"),
+				))
+			})
+
+			it("restores the remaining SBOM files", func() {
+				h.AssertNil(t, sbomRestorer.RestoreToBuildpackLayers(detectedBps))
+
+				got := h.MustReadFile(t, filepath.Join(layersDir, "buildpack.id", "launch-true.sbom.cdx.json"))
+				want := `{"key": "some-launch-bom-content"}`
+				h.AssertEq(t, string(got), want)
+
+				h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "broken-layer.sbom.cdx.json"))
+				h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "sbom"))
+			})
+		})
 	})
 }
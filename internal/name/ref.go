@@ -22,6 +22,19 @@ func ParseMaybe(provided string) string {
 	return provided
 }
 
+// DigestMaybe returns the digest portion (e.g., `sha256:...`) of the provided reference,
+// or an empty string if the reference is not a digest reference (such as a daemon image ID).
+func DigestMaybe(provided string) string {
+	if !hasDigest(provided) {
+		return ""
+	}
+	digest, err := name.NewDigest(provided)
+	if err != nil {
+		return ""
+	}
+	return digest.DigestStr()
+}
+
 func hasDigest(ref string) bool {
 	return strings.Contains(ref, "@sha256:")
 }
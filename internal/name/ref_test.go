@@ -84,4 +84,27 @@ func testRef(t *testing.T, when spec.G, it spec.S) {
 			}
 		})
 	})
+
+	when(".DigestMaybe", func() {
+		when("provided reference has a digest", func() {
+			it("returns the digest", func() {
+				actual := name.DigestMaybe("some.registry/some-repo@sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+				h.AssertEq(t, actual, "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+			})
+		})
+
+		when("provided reference is a daemon image ID", func() {
+			it("returns an empty string", func() {
+				actual := name.DigestMaybe("sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+				h.AssertEq(t, actual, "")
+			})
+		})
+
+		when("provided reference is a tag", func() {
+			it("returns an empty string", func() {
+				actual := name.DigestMaybe("some.registry/some-repo:some-tag")
+				h.AssertEq(t, actual, "")
+			})
+		})
+	})
 }
@@ -10,6 +10,8 @@ import (
 // DirLayer will set the UID and GID of entries describing dir and its children (but not its parents)
 //
 //	to Factory.UID and Factory.GID
+//
+// If the layer ID matches Factory.PreserveModTimes, entries describing dir and its children keep their modification times.
 func (f *Factory) DirLayer(withID string, fromDir string, createdBy string) (layer Layer, err error) {
 	fromDir, err = filepath.Abs(fromDir)
 	if err != nil {
@@ -25,6 +27,9 @@ func (f *Factory) DirLayer(withID string, fromDir string, createdBy string) (lay
 		}
 		tw.WithUID(f.UID)
 		tw.WithGID(f.GID)
+		if f.preserveModTimes(withID) {
+			tw.WithOriginalModTime()
+		}
 		return archive.AddDirToArchive(tw, fromDir)
 	})
 }
@@ -142,9 +142,55 @@ func testDirs(t *testing.T, when spec.G, it spec.S) {
 				fmt.Sprintf("Reusing tarball for layer \"some-layer-id\" with SHA: %s\n", dirLayer.Digest),
 			)
 		})
+
+		when("the layer ID matches PreserveModTimes", func() {
+			it("keeps the mod times of the dir and its children", func() {
+				factory.PreserveModTimes = []string{"some-buildpack:*"}
+				layer, err := factory.DirLayer("some-buildpack:some-layer", dir, "some-created-by")
+				h.AssertNil(t, err)
+
+				fi, err := os.Stat(filepath.Join(dir, "file.txt"))
+				h.AssertNil(t, err)
+				header := findTarEntry(t, layer.TarPath, tarPath(filepath.Join(dir, "file.txt")))
+				if !header.ModTime.Equal(fi.ModTime().Truncate(time.Second)) {
+					t.Fatalf("expected mod time '%s', got '%s'", fi.ModTime().Truncate(time.Second), header.ModTime)
+				}
+			})
+		})
+
+		when("the layer ID does not match PreserveModTimes", func() {
+			it("normalizes the mod times", func() {
+				factory.PreserveModTimes = []string{"other-buildpack:*"}
+				layer, err := factory.DirLayer("some-buildpack:some-layer", dir, "some-created-by")
+				h.AssertNil(t, err)
+
+				header := findTarEntry(t, layer.TarPath, tarPath(filepath.Join(dir, "file.txt")))
+				if !header.ModTime.Equal(time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)) {
+					t.Fatalf("expected normalized mod time, got '%s'", header.ModTime)
+				}
+			})
+		})
 	})
 }
 
+func findTarEntry(t *testing.T, tarPath string, name string) *tar.Header {
+	t.Helper()
+	lf, err := os.Open(tarPath)
+	h.AssertNil(t, err)
+	defer lf.Close()
+	tr := tar.NewReader(lf)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			t.Fatalf("missing expected archive entry '%s'", name)
+		}
+		h.AssertNil(t, err)
+		if header.Name == name {
+			return header
+		}
+	}
+}
+
 func assertTarEntries(t *testing.T, tarPath string, expectedEntries []*tar.Header) {
 	t.Helper()
 	lf, err := os.Open(tarPath)
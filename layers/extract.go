@@ -11,13 +11,56 @@ import (
 // Extract extracts entries from r to the dest directory
 // Contents of r should be an OCI layer.
 // If dest is an empty string files with be extracted to `/` or `c:\` on unix and windows filesystems respectively.
+// An UnsafeEntryError is returned if r contains an entry that would be written outside dest, e.g., from an untrusted cache.
 func Extract(r io.Reader, dest string) error {
 	tr := tarReader(r, dest)
 	return archive.Extract(tr)
 }
 
+// ExtractToOverlay extracts entries from r like Extract, but writes them beneath upperDir rather than dest,
+// skipping regular files that are already present and identical beneath dest.
+func ExtractToOverlay(r io.Reader, dest, upperDir string) error {
+	tr := tarReader(r, dest)
+	return archive.ExtractToOverlay(tr, upperDir)
+}
+
+// ExtractConcurrent extracts entries from r to the dest directory like Extract, but reads r in a separate goroutine,
+// so that decoding the layer (e.g., gzip decompression performed by r) overlaps with writing its contents to disk.
+// Up to bufferedChunks chunks of the layer are read ahead of extraction; values less than 1 are treated as 1.
+func ExtractConcurrent(r io.Reader, dest string, bufferedChunks int) error {
+	pr := newPipelinedReader(r, bufferedChunks)
+	defer pr.Close()
+	tr := tarReader(pr, dest)
+	return archive.Extract(tr)
+}
+
+// ExtractLayerDir extracts entries from r to the dest directory like Extract, for a layer containing a single layer directory
+// (e.g., `/layers/some-buildpack/some-layer`, as for layers restored from a cache). An UnsafeEntryError is returned
+// if r contains an entry that is not beneath layerDir, other than the directories containing it.
+// The layer directory may be staged beneath dest before it is moved into place,
+// so absolute symlink targets are resolved against the root of the filesystem rather than dest.
+func ExtractLayerDir(r io.Reader, dest, layerDir string) error {
+	tr := layerDirTarReader(r, dest, layerDir)
+	return archive.Extract(tr)
+}
+
+// ExtractLayerDirToOverlay extracts entries from r like ExtractLayerDir, but writes them beneath upperDir rather than dest,
+// as for ExtractToOverlay.
+func ExtractLayerDirToOverlay(r io.Reader, dest, upperDir, layerDir string) error {
+	tr := layerDirTarReader(r, dest, layerDir)
+	return archive.ExtractToOverlay(tr, upperDir)
+}
+
 func tarReader(r io.Reader, dest string) archive.TarReader {
-	tr := archive.NewNormalizingTarReader(tar.NewReader(r))
+	return normalizingTarReader(newSafeTarReader(tar.NewReader(r), dest, ""), dest)
+}
+
+func layerDirTarReader(r io.Reader, dest, layerDir string) archive.TarReader {
+	return normalizingTarReader(newSafeTarReader(tar.NewReader(r), "", layerDir), dest)
+}
+
+func normalizingTarReader(r archive.TarReader, dest string) archive.TarReader {
+	tr := archive.NewNormalizingTarReader(r)
 	if runtime.GOOS == "windows" {
 		tr.ExcludePaths([]string{"Hives"})
 		tr.Strip(`Files/`)
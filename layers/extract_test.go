@@ -0,0 +1,291 @@
+package layers_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/layers"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestExtract(t *testing.T) {
+	spec.Run(t, "Extract", testExtract, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testExtract(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir string
+		layer  []byte
+	)
+
+	it.Before(func() {
+		h.SkipIf(t, runtime.GOOS == "windows", "windows layers have a different structure")
+		var err error
+		tmpDir, err = os.MkdirTemp("", "lifecycle.layers.extract")
+		h.AssertNil(t, err)
+		layer, err = compressedLayer(map[string]int{
+			"some-dir/some-file":  10,
+			"some-dir/large-file": 1024*1024 + 7,
+			"other-file":          0,
+		})
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		h.AssertNil(t, os.RemoveAll(tmpDir))
+	})
+
+	when("#Extract", func() {
+		when("the layer contains unsafe entries", func() {
+			var extractDir string
+
+			it.Before(func() {
+				extractDir = filepath.Join(tmpDir, "extract")
+				h.AssertNil(t, os.MkdirAll(extractDir, 0755))
+			})
+
+			assertUnsafe := func(headers []*tar.Header, expected string) {
+				t.Helper()
+				err := layers.Extract(bytes.NewReader(craftedLayer(t, headers)), extractDir)
+				h.AssertNotNil(t, err)
+				var unsafeErr *layers.UnsafeEntryError
+				h.AssertEq(t, errors.As(err, &unsafeErr), true)
+				h.AssertStringContains(t, err.Error(), expected)
+				_, err = os.Stat(filepath.Join(tmpDir, "escaped"))
+				h.AssertEq(t, os.IsNotExist(err), true)
+			}
+
+			it("rejects paths escaping the destination directory", func() {
+				assertUnsafe([]*tar.Header{
+					{Name: "some-dir/", Typeflag: tar.TypeDir, Mode: 0755},
+					{Name: "some-dir/../../escaped", Typeflag: tar.TypeReg, Mode: 0644},
+				}, `unsafe layer entry "some-dir/../../escaped": path escapes the destination directory`)
+			})
+
+			it("rejects absolute paths escaping the destination directory", func() {
+				assertUnsafe([]*tar.Header{
+					{Name: "/../escaped", Typeflag: tar.TypeReg, Mode: 0644},
+				}, `unsafe layer entry "/../escaped": path escapes the destination directory`)
+			})
+
+			it("rejects symlinks with relative targets escaping the destination directory", func() {
+				assertUnsafe([]*tar.Header{
+					{Name: "some-dir/some-link", Typeflag: tar.TypeSymlink, Linkname: "../../escaped"},
+				}, `unsafe layer entry "some-dir/some-link": symlink target "../../escaped" escapes the destination directory`)
+			})
+
+			it("rejects symlinks with absolute targets escaping the destination directory", func() {
+				assertUnsafe([]*tar.Header{
+					{Name: "some-dir/some-link", Typeflag: tar.TypeSymlink, Linkname: "/../escaped"},
+				}, `unsafe layer entry "some-dir/some-link": symlink target "/../escaped" escapes the destination directory`)
+			})
+
+			it("rejects symlinks with absolute targets outside the destination directory", func() {
+				assertUnsafe([]*tar.Header{
+					{Name: "some-dir/some-link", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+				}, `unsafe layer entry "some-dir/some-link": symlink target "/etc" escapes the destination directory`)
+			})
+
+			it("rejects paths beneath symlinks", func() {
+				assertUnsafe([]*tar.Header{
+					{Name: "some-link", Typeflag: tar.TypeSymlink, Linkname: "."},
+					{Name: "some-link/escaped", Typeflag: tar.TypeReg, Mode: 0644},
+				}, `unsafe layer entry "some-link/escaped": path is beneath symlink "some-link"`)
+			})
+		})
+
+		it("extracts symlinks with targets within the destination directory", func() {
+			extractDir := filepath.Join(tmpDir, "extract")
+			h.AssertNil(t, layers.Extract(bytes.NewReader(craftedLayer(t, []*tar.Header{
+				{Name: "some-dir/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "some-dir/relative-link", Typeflag: tar.TypeSymlink, Linkname: "../other-file"},
+				{Name: "some-dir/absolute-link", Typeflag: tar.TypeSymlink, Linkname: filepath.Join(extractDir, "other-file")},
+				{Name: "./some-dir/./some-file", Typeflag: tar.TypeReg, Mode: 0644},
+			})), extractDir))
+
+			target, err := os.Readlink(filepath.Join(extractDir, "some-dir", "relative-link"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, target, "../other-file")
+			target, err = os.Readlink(filepath.Join(extractDir, "some-dir", "absolute-link"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, target, filepath.Join(extractDir, "other-file"))
+			h.AssertPathExists(t, filepath.Join(extractDir, "some-dir", "some-file"))
+		})
+	})
+
+	when("#ExtractLayerDir", func() {
+		var extractDir string
+
+		it.Before(func() {
+			extractDir = filepath.Join(tmpDir, "extract")
+			h.AssertNil(t, os.MkdirAll(extractDir, 0755))
+		})
+
+		it("extracts the layer directory and the directories containing it", func() {
+			h.AssertNil(t, layers.ExtractLayerDir(bytes.NewReader(craftedLayer(t, []*tar.Header{
+				{Name: "/layers/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "/layers/some-buildpack/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "/layers/some-buildpack/some-layer/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "/layers/some-buildpack/some-layer/some-file", Typeflag: tar.TypeReg, Mode: 0644},
+				{Name: "/layers/some-buildpack/some-layer/some-link", Typeflag: tar.TypeSymlink, Linkname: "/layers/other-buildpack/other-layer/other-file"},
+			})), extractDir, "/layers/some-buildpack/some-layer"))
+
+			h.AssertPathExists(t, filepath.Join(extractDir, "layers", "some-buildpack", "some-layer", "some-file"))
+			target, err := os.Readlink(filepath.Join(extractDir, "layers", "some-buildpack", "some-layer", "some-link"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, target, "/layers/other-buildpack/other-layer/other-file")
+		})
+
+		it("rejects entries outside the layer directory", func() {
+			for _, hdr := range []*tar.Header{
+				{Name: "/cnb/lifecycle/launcher", Typeflag: tar.TypeReg, Mode: 0755},
+				{Name: "/layers/some-buildpack/other-layer/some-file", Typeflag: tar.TypeReg, Mode: 0644},
+				{Name: "/layers/some-buildpack/some-layer-suffix/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "/layers/some-buildpack", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+			} {
+				err := layers.ExtractLayerDir(bytes.NewReader(craftedLayer(t, []*tar.Header{hdr})), extractDir, "/layers/some-buildpack/some-layer")
+				var unsafeErr *layers.UnsafeEntryError
+				h.AssertEq(t, errors.As(err, &unsafeErr), true)
+				h.AssertStringContains(t, err.Error(), `path is outside the layer directory "/layers/some-buildpack/some-layer"`)
+			}
+			h.AssertPathDoesNotExist(t, filepath.Join(extractDir, "cnb"))
+			h.AssertPathDoesNotExist(t, filepath.Join(extractDir, "layers", "some-buildpack", "other-layer"))
+		})
+
+		it("rejects entries written through symlinks of another layer", func() {
+			h.AssertNil(t, layers.ExtractLayerDir(bytes.NewReader(craftedLayer(t, []*tar.Header{
+				{Name: "/layers/some-buildpack/some-layer/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "/layers/some-buildpack/some-layer/some-link", Typeflag: tar.TypeSymlink, Linkname: tmpDir},
+			})), extractDir, "/layers/some-buildpack/some-layer"))
+
+			err := layers.ExtractLayerDir(bytes.NewReader(craftedLayer(t, []*tar.Header{
+				{Name: "/layers/some-buildpack/some-layer/some-link/escaped", Typeflag: tar.TypeReg, Mode: 0644},
+			})), extractDir, "/layers/some-buildpack/other-layer")
+			var unsafeErr *layers.UnsafeEntryError
+			h.AssertEq(t, errors.As(err, &unsafeErr), true)
+			h.AssertPathDoesNotExist(t, filepath.Join(tmpDir, "escaped"))
+		})
+	})
+
+	when("#ExtractConcurrent", func() {
+		it("extracts the same contents as Extract", func() {
+			for _, bufferedChunks := range []int{0, 1, 4} {
+				serialDir := filepath.Join(tmpDir, "serial")
+				concurrentDir := filepath.Join(tmpDir, "concurrent")
+
+				gzr, err := gzip.NewReader(bytes.NewReader(layer))
+				h.AssertNil(t, err)
+				h.AssertNil(t, layers.Extract(gzr, serialDir))
+				gzr, err = gzip.NewReader(bytes.NewReader(layer))
+				h.AssertNil(t, err)
+				h.AssertNil(t, layers.ExtractConcurrent(gzr, concurrentDir, bufferedChunks))
+
+				for _, path := range []string{"some-dir/some-file", "some-dir/large-file", "other-file"} {
+					h.AssertEq(t, h.MustReadFile(t, filepath.Join(concurrentDir, path)), h.MustReadFile(t, filepath.Join(serialDir, path)))
+				}
+				h.AssertNil(t, os.RemoveAll(serialDir))
+				h.AssertNil(t, os.RemoveAll(concurrentDir))
+			}
+		})
+
+		when("the layer is truncated", func() {
+			it("errors", func() {
+				gzr, err := gzip.NewReader(bytes.NewReader(layer[:len(layer)/2]))
+				h.AssertNil(t, err)
+				h.AssertNotNil(t, layers.ExtractConcurrent(gzr, tmpDir, 2))
+			})
+		})
+	})
+}
+
+// compressedLayer returns a gzipped tar containing a file of the provided size at each of the provided paths.
+func compressedLayer(files map[string]int) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec
+	for path, size := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: path, Mode: 0644, Size: int64(size), Typeflag: tar.TypeReg}); err != nil {
+			return nil, err
+		}
+		// random bytes from a small alphabet are compressible, but not trivially so
+		contents := make([]byte, size)
+		for i := range contents {
+			contents[i] = 'a' + byte(rnd.Intn(16))
+		}
+		if _, err := tw.Write(contents); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// craftedLayer returns an uncompressed tar containing the provided headers, with no contents.
+func craftedLayer(t *testing.T, headers []*tar.Header) []byte {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, hdr := range headers {
+		h.AssertNil(t, tw.WriteHeader(hdr))
+	}
+	h.AssertNil(t, tw.Close())
+	return buf.Bytes()
+}
+
+func BenchmarkExtract(b *testing.B) {
+	benchmarkExtract(b, func(r io.Reader, dest string) error {
+		return layers.Extract(r, dest)
+	})
+}
+
+func BenchmarkExtractConcurrent(b *testing.B) {
+	benchmarkExtract(b, func(r io.Reader, dest string) error {
+		return layers.ExtractConcurrent(r, dest, 8)
+	})
+}
+
+func benchmarkExtract(b *testing.B, extract func(r io.Reader, dest string) error) {
+	const size = 64 * 1024 * 1024
+	layer, err := compressedLayer(map[string]int{"large-file": size})
+	if err != nil {
+		b.Fatal(err)
+	}
+	tmpDir, err := os.MkdirTemp("", "lifecycle.layers.extract.bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	b.SetBytes(size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		gzr, err := gzip.NewReader(bytes.NewReader(layer))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err = extract(gzr, filepath.Join(tmpDir, "dest")); err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		if err = os.RemoveAll(filepath.Join(tmpDir, "dest")); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+	}
+}
@@ -6,6 +6,7 @@ package layers
 import (
 	"context"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -34,7 +35,11 @@ type Factory struct {
 	UID, GID     int    // UID and GID are used to normalize layer entries
 	Logger       log.Logger
 	Ctx          context.Context
-	tarHashes    sync.Map // tarHases Stores hashes of layer tarballs for reuse between the export and cache steps.
+	// PreserveModTimes are glob patterns matched against layer IDs (e.g., `<buildpack-id>:<layer-name>`) using the syntax of path.Match.
+	// Entries in matching directory layers keep their original modification times rather than being normalized,
+	// so matching layers are not reproducible.
+	PreserveModTimes []string
+	tarHashes        sync.Map // tarHases Stores hashes of layer tarballs for reuse between the export and cache steps.
 }
 
 type Layer struct {
@@ -107,6 +112,16 @@ func (f *Factory) writeLayer(id, createdBy string, addEntries func(tw *archive.N
 	}
 }
 
+// preserveModTimes returns true if the layer with the provided ID matches any of the PreserveModTimes patterns.
+func (f *Factory) preserveModTimes(id string) bool {
+	for _, pattern := range f.PreserveModTimes {
+		if matched, _ := path.Match(pattern, id); matched {
+			return true
+		}
+	}
+	return false
+}
+
 func escape(id string) string {
 	return strings.ReplaceAll(id, "/", "_")
 }
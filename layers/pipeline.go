@@ -0,0 +1,78 @@
+package layers
+
+import (
+	"io"
+	"sync"
+)
+
+const pipelineChunkSize = 1024 * 1024
+
+type chunk struct {
+	data []byte
+	err  error
+}
+
+// pipelinedReader reads from an underlying reader in a separate goroutine, buffering a bounded number of chunks ahead of the consumer.
+type pipelinedReader struct {
+	chunks  chan chunk
+	done    chan struct{}
+	once    sync.Once
+	current []byte
+	err     error
+}
+
+func newPipelinedReader(r io.Reader, bufferedChunks int) *pipelinedReader {
+	if bufferedChunks < 1 {
+		bufferedChunks = 1
+	}
+	pr := &pipelinedReader{
+		chunks: make(chan chunk, bufferedChunks),
+		done:   make(chan struct{}),
+	}
+	go pr.fill(r)
+	return pr
+}
+
+func (pr *pipelinedReader) fill(r io.Reader) {
+	defer close(pr.chunks)
+	for {
+		buf := make([]byte, pipelineChunkSize)
+		n, err := io.ReadFull(r, buf)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		if n > 0 || err != nil {
+			select {
+			case pr.chunks <- chunk{data: buf[:n], err: err}:
+			case <-pr.done:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (pr *pipelinedReader) Read(p []byte) (int, error) {
+	for len(pr.current) == 0 {
+		if pr.err != nil {
+			return 0, pr.err
+		}
+		c, ok := <-pr.chunks
+		if !ok {
+			pr.err = io.EOF
+			continue
+		}
+		pr.current, pr.err = c.data, c.err
+	}
+	n := copy(p, pr.current)
+	pr.current = pr.current[n:]
+	return n, nil
+}
+
+// Close stops reading from the underlying reader; it does not close the underlying reader.
+func (pr *pipelinedReader) Close() error {
+	pr.once.Do(func() { close(pr.done) })
+	return nil
+}
@@ -0,0 +1,113 @@
+package layers
+
+import (
+	"archive/tar"
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/buildpacks/lifecycle/archive"
+)
+
+// UnsafeEntryError is returned when extracting a layer containing an entry that would be written outside the destination directory,
+// i.e., an entry whose path escapes the root of the layer (e.g., `../some-file`), a symlink whose target escapes the destination directory,
+// or an entry beneath a symlink in the same layer, through which it could be written anywhere the symlink points.
+// When a layer directory is provided (see ExtractLayerDir), it is also returned for an entry that is not beneath the layer directory.
+type UnsafeEntryError struct {
+	Name   string // the path of the entry in the layer
+	Reason string
+}
+
+func (e *UnsafeEntryError) Error() string {
+	return fmt.Sprintf("unsafe layer entry %q: %s", e.Name, e.Reason)
+}
+
+// safeTarReader validates the entries of a layer before they are extracted, returning an UnsafeEntryError for the first unsafe entry.
+// When the layer is extracted to a destination directory, absolute symlink targets must be beneath it, as they are written unchanged
+// and resolved against the root of the filesystem. Otherwise, absolute targets are resolved against the root the layer is extracted to.
+// When a layer directory is provided, every entry must be beneath it, other than the directories containing it,
+// so that a layer cannot write to other layers (e.g., through their symlinks) or anywhere else in the filesystem.
+type safeTarReader struct {
+	archive.TarReader
+	linkDest string          // the directory absolute symlink targets must be beneath, if any
+	layerDir string          // the path of the layer directory relative to the root of the layer, if any
+	symlinks map[string]bool // the paths of the symlinks in the layer, relative to its root
+}
+
+func newSafeTarReader(tr archive.TarReader, linkDest, layerDir string) *safeTarReader {
+	if linkDest != "" {
+		if abs, err := filepath.Abs(linkDest); err == nil {
+			linkDest = abs
+		}
+	}
+	if layerDir != "" {
+		if abs, err := filepath.Abs(layerDir); err == nil {
+			layerDir = abs
+		}
+		layerDir, _ = layerPath(strings.TrimPrefix(layerDir, filepath.VolumeName(layerDir)))
+	}
+	return &safeTarReader{TarReader: tr, linkDest: linkDest, layerDir: layerDir, symlinks: map[string]bool{}}
+}
+
+func (tr *safeTarReader) Next() (*tar.Header, error) {
+	hdr, err := tr.TarReader.Next()
+	if err != nil {
+		return nil, err
+	}
+	rel, ok := layerPath(hdr.Name)
+	if !ok {
+		return nil, &UnsafeEntryError{Name: hdr.Name, Reason: "path escapes the destination directory"}
+	}
+	if tr.layerDir != "" && !tr.inLayerDir(rel, hdr.Typeflag) {
+		return nil, &UnsafeEntryError{Name: hdr.Name, Reason: fmt.Sprintf("path is outside the layer directory %q", "/"+tr.layerDir)}
+	}
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if tr.symlinks[dir] {
+			return nil, &UnsafeEntryError{Name: hdr.Name, Reason: fmt.Sprintf("path is beneath symlink %q", dir)}
+		}
+	}
+	if hdr.Typeflag == tar.TypeSymlink {
+		var ok bool
+		switch {
+		case !path.IsAbs(filepath.ToSlash(hdr.Linkname)) && !filepath.IsAbs(hdr.Linkname):
+			_, ok = layerPath(path.Join(path.Dir(rel), filepath.ToSlash(hdr.Linkname)))
+		case tr.linkDest != "":
+			target := filepath.Clean(hdr.Linkname)
+			ok = target == tr.linkDest || strings.HasPrefix(target, strings.TrimSuffix(tr.linkDest, string(filepath.Separator))+string(filepath.Separator))
+		default:
+			ok = true
+		}
+		if !ok {
+			return nil, &UnsafeEntryError{Name: hdr.Name, Reason: fmt.Sprintf("symlink target %q escapes the destination directory", hdr.Linkname)}
+		}
+		tr.symlinks[rel] = true
+	}
+	return hdr, nil
+}
+
+// inLayerDir returns true if the provided entry path, relative to the root of the layer, is beneath the layer directory,
+// or is a directory containing it.
+func (tr *safeTarReader) inLayerDir(rel string, typeflag byte) bool {
+	if runtime.GOOS == "windows" {
+		// windows layers contain the filesystem beneath `Files/` and registry hives beneath `Hives/`, which are not extracted
+		if rel == "Hives" || strings.HasPrefix(rel, "Hives/") {
+			return true
+		}
+		rel = strings.TrimPrefix(strings.TrimPrefix(rel, "Files"), "/")
+		if rel == "" {
+			rel = "."
+		}
+	}
+	if rel == tr.layerDir || strings.HasPrefix(rel, tr.layerDir+"/") {
+		return true
+	}
+	return typeflag == tar.TypeDir && (rel == "." || strings.HasPrefix(tr.layerDir, rel+"/"))
+}
+
+// layerPath returns the provided entry path cleaned and relative to the root of the layer, and whether it is within the root.
+func layerPath(name string) (string, bool) {
+	rel := path.Clean(strings.TrimLeft(filepath.ToSlash(name), "/"))
+	return rel, rel != ".." && !strings.HasPrefix(rel, "../")
+}
@@ -3,6 +3,7 @@ package log
 import (
 	"fmt"
 	"io"
+	"strings"
 	"sync"
 
 	"github.com/apex/log"
@@ -27,6 +28,35 @@ func NewDefaultLogger(writer io.Writer) *DefaultLogger {
 	}
 }
 
+// SetWriter sets the writer to which log entries are written, e.g., so that logs do not interleave with output written to stdout.
+func (l *DefaultLogger) SetWriter(writer io.Writer) {
+	l.Handler = &handler{writer: writer, prefix: l.prefix()}
+}
+
+// SetBuildID sets an ID that prefixes every line that is logged, e.g., so that interleaved logs from concurrent builds
+// can be attributed to a build. An empty ID removes the prefix.
+func (l *DefaultLogger) SetBuildID(id string) {
+	prefix := ""
+	if id != "" {
+		prefix = fmt.Sprintf("[%s] ", id)
+	}
+	l.Handler = &handler{writer: l.writer(), prefix: prefix}
+}
+
+func (l *DefaultLogger) writer() io.Writer {
+	if h, ok := l.Handler.(*handler); ok {
+		return h.writer
+	}
+	return nil
+}
+
+func (l *DefaultLogger) prefix() string {
+	if h, ok := l.Handler.(*handler); ok {
+		return h.prefix
+	}
+	return ""
+}
+
 func (l *DefaultLogger) HandleLog(entry *log.Entry) error {
 	return l.Handler.HandleLog(entry)
 }
@@ -53,6 +83,7 @@ var _ log.Handler = &handler{}
 type handler struct {
 	mu     sync.Mutex
 	writer io.Writer
+	prefix string
 }
 
 const (
@@ -70,18 +101,25 @@ func (h *handler) HandleLog(entry *log.Entry) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	var err error
+	msg := appendMissingLineFeed(entry.Message)
 	switch entry.Level {
 	case log.WarnLevel:
-		_, err = h.writer.Write([]byte(warnStyle(warnLevelText) + appendMissingLineFeed(entry.Message)))
+		msg = warnStyle(warnLevelText) + msg
 	case log.ErrorLevel:
-		_, err = h.writer.Write([]byte(errorStyle(errorLevelText) + appendMissingLineFeed(entry.Message)))
-	default:
-		_, err = h.writer.Write([]byte(appendMissingLineFeed(entry.Message)))
+		msg = errorStyle(errorLevelText) + msg
 	}
+	_, err := h.writer.Write([]byte(prefixLines(h.prefix, msg)))
 	return err
 }
 
+// prefixLines returns the provided text, which ends with a line feed, with the provided prefix at the start of every line.
+func prefixLines(prefix, text string) string {
+	if prefix == "" {
+		return text
+	}
+	return prefix + strings.ReplaceAll(strings.TrimSuffix(text, "\n"), "\n", "\n"+prefix) + "\n"
+}
+
 func appendMissingLineFeed(msg string) string {
 	buff := []byte(msg)
 	if buff[len(buff)-1] != '\n' {
@@ -0,0 +1,62 @@
+package log_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/heroku/color"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/log"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestDefaultLogger(t *testing.T) {
+	spec.Run(t, "DefaultLogger", testDefaultLogger, spec.Report(report.Terminal{}))
+}
+
+func testDefaultLogger(t *testing.T, when spec.G, it spec.S) {
+	var (
+		buf    *bytes.Buffer
+		logger *log.DefaultLogger
+	)
+
+	it.Before(func() {
+		color.Disable(true)
+		buf = &bytes.Buffer{}
+		logger = log.NewDefaultLogger(buf)
+	})
+
+	it.After(func() {
+		color.Disable(false)
+	})
+
+	when("#SetBuildID", func() {
+		it("prefixes every line with the build ID", func() {
+			logger.SetBuildID("some-build")
+			logger.Info("some-message")
+			logger.Warn("first-line\nsecond-line")
+
+			h.AssertEq(t, buf.String(), "[some-build] some-message\n[some-build] Warning: first-line\n[some-build] second-line\n")
+		})
+
+		it("keeps the prefix when the writer changes", func() {
+			logger.SetBuildID("some-build")
+			other := &bytes.Buffer{}
+			logger.SetWriter(other)
+			logger.Info("some-message")
+
+			h.AssertEq(t, other.String(), "[some-build] some-message\n")
+		})
+
+		when("the build ID is empty", func() {
+			it("does not prefix lines", func() {
+				logger.SetBuildID("")
+				logger.Info("some-message")
+
+				h.AssertEq(t, buf.String(), "some-message\n")
+			})
+		})
+	})
+}
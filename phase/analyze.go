@@ -0,0 +1,52 @@
+package phase
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/lifecycle/image"
+	"github.com/buildpacks/lifecycle/log"
+	"github.com/buildpacks/lifecycle/platform"
+	"github.com/buildpacks/lifecycle/platform/files"
+)
+
+// AnalyzeOptions are the inputs to Analyze.
+type AnalyzeOptions struct {
+	// Inputs are the lifecycle inputs, which must already be resolved (see platform.ResolveInputs),
+	// e.g., the Platform API, the previous image, run image, and cache image references, and the layers directory.
+	Inputs platform.LifecycleInputs
+	// ImageHandler reads the previous image and the run image, e.g., from a registry using a keychain, or from a daemon.
+	ImageHandler image.Handler
+	// RegistryHandler ensures that the images in a registry that are read and written are accessible.
+	RegistryHandler image.RegistryHandler
+	Logger          log.Logger
+}
+
+// ErrAnalyzerInit is matched by errors returned by Analyze when the images cannot be accessed or read,
+// as opposed to errors analyzing them.
+var ErrAnalyzerInit = errors.New("initializing analyzer")
+
+// analyzerInitError marks an error as ErrAnalyzerInit without changing its message.
+type analyzerInitError struct {
+	err error
+}
+
+func (e *analyzerInitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *analyzerInitError) Unwrap() []error {
+	return []error{ErrAnalyzerInit, e.err}
+}
+
+// Analyze reads metadata from the previous image and the run image, as the analyzer does, and returns the analyzed metadata,
+// so that the lifecycle can be embedded in other tools. Unlike the analyzer command, it does not parse flags, drop privileges,
+// or write analyzed.toml; the SBOM of the previous image is still restored to the layers directory unless Inputs.SkipLayers is true.
+func Analyze(opts AnalyzeOptions) (files.Analyzed, error) {
+	// the analyzer does not read config files or buildpacks, or use the cache handler
+	factory := NewConnectedFactory(opts.Inputs.PlatformAPI, nil, nil, nil, opts.ImageHandler, opts.RegistryHandler)
+	analyzer, err := factory.NewAnalyzer(opts.Inputs, opts.Logger)
+	if err != nil {
+		return files.Analyzed{}, &analyzerInitError{err: err}
+	}
+	return analyzer.Analyze()
+}
@@ -0,0 +1,89 @@
+package phase_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/discard"
+	"github.com/buildpacks/imgutil/fakes"
+	"github.com/buildpacks/imgutil/local"
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/api"
+	"github.com/buildpacks/lifecycle/image"
+	"github.com/buildpacks/lifecycle/phase"
+	"github.com/buildpacks/lifecycle/phase/testmock"
+	"github.com/buildpacks/lifecycle/platform"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestAnalyze(t *testing.T) {
+	spec.Run(t, "Analyze", testAnalyze, spec.Report(report.Terminal{}))
+}
+
+func testAnalyze(t *testing.T, when spec.G, it spec.S) {
+	var (
+		mockController      *gomock.Controller
+		fakeImageHandler    *testmock.MockHandler
+		fakeRegistryHandler *testmock.MockRegistryHandler
+		opts                phase.AnalyzeOptions
+	)
+
+	it.Before(func() {
+		mockController = gomock.NewController(t)
+		fakeImageHandler = testmock.NewMockHandler(mockController)
+		fakeRegistryHandler = testmock.NewMockRegistryHandler(mockController)
+		fakeImageHandler.EXPECT().Kind().Return(image.RemoteKind).AnyTimes()
+		fakeRegistryHandler.EXPECT().EnsureReadAccess(gomock.Any()).AnyTimes()
+		fakeRegistryHandler.EXPECT().EnsureWriteAccess(gomock.Any()).AnyTimes()
+		opts = phase.AnalyzeOptions{
+			Inputs: platform.LifecycleInputs{
+				PlatformAPI:      api.Platform.Latest(),
+				LayersDir:        t.TempDir(),
+				OutputImageRef:   "some-output-image-ref",
+				PreviousImageRef: "some-previous-image-ref",
+				RunImageRef:      "some-run-image-ref",
+				SkipLayers:       true,
+			},
+			ImageHandler:    fakeImageHandler,
+			RegistryHandler: fakeRegistryHandler,
+			Logger:          &log.Logger{Handler: &discard.Handler{}},
+		}
+	})
+
+	it.After(func() {
+		mockController.Finish()
+	})
+
+	it("returns the analyzed metadata without writing analyzed.toml", func() {
+		previousImage := fakes.NewImage("some-previous-image-ref", "", nil)
+		h.AssertNil(t, previousImage.Delete())
+		runImage := fakes.NewImage("some-run-image-ref", "", local.IDIdentifier{ImageID: "some-run-image-id"})
+		fakeImageHandler.EXPECT().InitImage("some-previous-image-ref").Return(previousImage, nil)
+		fakeImageHandler.EXPECT().InitImage("some-run-image-ref").Return(runImage, nil)
+
+		analyzedMD, err := phase.Analyze(opts)
+		h.AssertNil(t, err)
+
+		h.AssertEq(t, analyzedMD.PreviousImageRef(), "")
+		h.AssertEq(t, analyzedMD.RunImageRef(), "some-run-image-id")
+		h.AssertEq(t, analyzedMD.RunImageImage(), "some-run-image-ref")
+		h.AssertPathDoesNotExist(t, filepath.Join(opts.Inputs.LayersDir, "analyzed.toml"))
+	})
+
+	when("the images cannot be read", func() {
+		it("returns an error matching ErrAnalyzerInit", func() {
+			fakeImageHandler.EXPECT().InitImage("some-previous-image-ref").Return(nil, errors.New("some-error")).AnyTimes()
+			fakeImageHandler.EXPECT().InitImage("some-run-image-ref").Return(nil, errors.New("some-error")).AnyTimes()
+
+			_, err := phase.Analyze(opts)
+			h.AssertNotNil(t, err)
+			h.AssertEq(t, errors.Is(err, phase.ErrAnalyzerInit), true)
+			h.AssertError(t, err, "some-error")
+		})
+	})
+}
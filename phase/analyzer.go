@@ -1,13 +1,20 @@
 package phase
 
 import (
+	"strings"
+	"time"
+
 	"github.com/buildpacks/imgutil"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/buildpacks/lifecycle/api"
+	"github.com/buildpacks/lifecycle/buildpack"
 	"github.com/buildpacks/lifecycle/image"
 	"github.com/buildpacks/lifecycle/internal/fsutil"
 	"github.com/buildpacks/lifecycle/internal/layer"
+	iname "github.com/buildpacks/lifecycle/internal/name"
 	"github.com/buildpacks/lifecycle/log"
 	"github.com/buildpacks/lifecycle/platform"
 	"github.com/buildpacks/lifecycle/platform/files"
@@ -21,17 +28,73 @@ type Analyzer struct {
 	Logger        log.Logger
 	SBOMRestorer  layer.SBOMRestorer
 	PlatformAPI   *api.Version
+
+	// RequiredMixins if provided are the mixins required by the build, e.g., the mixins of the build image;
+	// the run image must provide all of the mixins that are not specific to the build stage.
+	RequiredMixins []string
+	// TargetOS and TargetArch if provided are the platform the build targets;
+	// the run image must be for the platform, so that picking a run image of the wrong platform fails the build rather than the container.
+	TargetOS   string
+	TargetArch string
+	// PreviousImageDigest if provided is the digest the previous image is expected to resolve to;
+	// if it resolves to a different digest, e.g., because the tag was overwritten by a concurrent push,
+	// Analyze returns ErrPreviousImageDrift unless AllowPreviousDrift is true, in which case it warns.
+	PreviousImageDigest string
+	AllowPreviousDrift  bool
+	// ForceRebuild if true omits the launch layer metadata of the previous image from analyzed.toml (see files.Analyzed).
+	ForceRebuild bool
+	// PinRunImage if true records in analyzed.toml that the run image should be pulled by digest rather than by the provided tag,
+	// so that later phases use the run image that was analyzed even if the tag is moved.
+	PinRunImage bool
+	// AllowMutableRunImage if true suppresses the warning that is logged when the run image is provided with the `latest` tag or no tag.
+	AllowMutableRunImage bool
+	// NoRunImage if true records in analyzed.toml that there is no run image, so that the app image is exported on an empty base;
+	// RunImage must be nil. The target, if provided, is recorded as the target of the app image.
+	NoRunImage bool
+	// RunImageLineage if provided (see platform.RunImageLineageWarn and platform.RunImageLineageFail) validates that the previous image
+	// was built on the run image, i.e., that the run image recorded in its metadata is in the same repository or has the same digest,
+	// so that an accidental swap of the run image is caught before the app image is rebased onto an unrelated base.
+	// If the run image diverges, Analyze warns, or returns ErrRunImageDivergence.
+	RunImageLineage string
+	// UnmirroredRunImageName is the name of the run image provided by the platform, if the run image is pulled from a registry mirror;
+	// the run image lineage is validated against it as well as the name of RunImage, as the previous image records the provided name.
+	UnmirroredRunImageName string
+	// PreviousImageSelected if true indicates the previous image was selected from multiple candidate references,
+	// in which case the name of the previous image, if found, is recorded in analyzed.toml so that it is known which candidate was used.
+	PreviousImageSelected bool
 }
 
+// ErrPreviousImageDrift is returned when the previous image does not resolve to the expected digest.
+var ErrPreviousImageDrift = errors.New("previous image does not resolve to the expected digest")
+
+// ErrRunImageDivergence is returned when the previous image was built on a different run image than the provided run image.
+var ErrRunImageDivergence = errors.New("previous image was built on a different run image")
+
 // NewAnalyzer configures a new Analyzer according to the provided Platform API version.
 func (f *ConnectedFactory) NewAnalyzer(inputs platform.LifecycleInputs, logger log.Logger) (*Analyzer, error) {
 	analyzer := &Analyzer{
-		Logger:       logger,
-		SBOMRestorer: &layer.NopSBOMRestorer{},
-		PlatformAPI:  f.platformAPI,
+		Logger:         logger,
+		SBOMRestorer:   &layer.NopSBOMRestorer{},
+		PlatformAPI:    f.platformAPI,
+		RequiredMixins: inputs.RequiredMixins,
+		TargetOS:       inputs.TargetOS,
+		TargetArch:     inputs.TargetArch,
+
+		PreviousImageDigest: inputs.PreviousImageDigest,
+		AllowPreviousDrift:  inputs.AllowPreviousDrift,
+		ForceRebuild:        inputs.ForceRebuild,
+
+		PinRunImage:          inputs.PinRunImage,
+		AllowMutableRunImage: inputs.AllowMutableRunImage,
+		NoRunImage:           inputs.NoRunImage,
+		RunImageLineage:      inputs.RunImageLineage,
+
+		UnmirroredRunImageName: inputs.UnmirroredRunImageRef,
+
+		PreviousImageSelected: len(inputs.PreviousImageRefs()) > 1,
 	}
 
-	if err := f.ensureRegistryAccess(inputs); err != nil {
+	if err := f.ensureRegistryAccess(inputs, logger); err != nil {
 		return nil, err
 	}
 
@@ -42,11 +105,34 @@ func (f *ConnectedFactory) NewAnalyzer(inputs platform.LifecycleInputs, logger l
 		}
 	}
 
-	var err error
-	if analyzer.PreviousImage, err = f.getPreviousImage(inputs.PreviousImageRef, inputs.LaunchCacheDir); err != nil {
-		return nil, err
+	// The previous image and the run image are independent, so they are read concurrently to halve the latency of slow registries.
+	// Images in the daemon are read one at a time, as docker clients and pulls are not safe for concurrent use.
+	var g errgroup.Group
+	if f.imageHandler.Kind() == image.LocalKind {
+		g.SetLimit(1)
 	}
-	if analyzer.RunImage, err = f.getRunImage(inputs.RunImageRef); err != nil {
+	if inputs.SkipPrevious {
+		logger.Infof("Skipping previous image %q", inputs.PreviousImageRef)
+	} else {
+		g.Go(func() error {
+			var err error
+			analyzer.PreviousImage, err = f.getPreviousImage(inputs.PreviousImageRefs(), inputs.LaunchCacheDir, logger)
+			return err
+		})
+	}
+	g.Go(func() error {
+		var err error
+		analyzer.RunImage, err = f.getRunImage(inputs.RunImageRef, inputs.PullPolicy)
+		return err
+	})
+	if inputs.ReadsBuildImageMixins() {
+		g.Go(func() error {
+			var err error
+			analyzer.RequiredMixins, err = f.getBuildImageMixins(inputs.BuildImageRef)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
 		return nil, err
 	}
 	return analyzer, nil
@@ -56,16 +142,28 @@ func (f *ConnectedFactory) NewAnalyzer(inputs platform.LifecycleInputs, logger l
 func (a *Analyzer) Analyze() (files.Analyzed, error) {
 	defer log.NewMeasurement("Analyzer", a.Logger)()
 	var (
-		err              error
-		appMeta          files.LayersMetadata
-		previousImageRef string
-		runImageRef      string
+		err               error
+		appMeta           files.LayersMetadata
+		previousImageRef  string
+		previousImageName string
+		runImageRef       string
 	)
 	appMeta, previousImageRef, err = a.retrieveAppMetadata()
 	if err != nil {
 		return files.Analyzed{}, err
 	}
 
+	if previousImageRef != "" && a.PreviousImageSelected {
+		previousImageName = a.PreviousImage.Name()
+	}
+	if err = a.verifyPreviousImageDigest(previousImageRef); err != nil {
+		return files.Analyzed{}, err
+	}
+	if a.ForceRebuild {
+		a.Logger.Info("Forcing a rebuild, omitting the launch layer metadata of the previous image")
+		appMeta = withoutLaunchLayers(appMeta)
+	}
+
 	if sha := bomSHA(appMeta); sha != "" {
 		if err = a.SBOMRestorer.RestoreFromPrevious(a.PreviousImage, sha); err != nil {
 			return files.Analyzed{}, errors.Wrap(err, "retrieving launch SBOM layer")
@@ -73,16 +171,33 @@ func (a *Analyzer) Analyze() (files.Analyzed, error) {
 	}
 
 	var (
-		atm          *files.TargetMetadata
-		runImageName string
+		atm            *files.TargetMetadata
+		runImageName   string
+		runImageDigest string
+		runImagePinned bool
+		runImageMD     *files.RunImageMetadata
 	)
 	if a.RunImage != nil {
 		runImageRef, err = a.getImageIdentifier(a.RunImage)
 		if err != nil {
 			return files.Analyzed{}, errors.Wrap(err, "identifying run image")
 		}
+		runImageDigest = iname.DigestMaybe(runImageRef)
+		a.warnIfMutableRunImage(runImageDigest)
+		if err = a.validateRunImageMixins(); err != nil {
+			return files.Analyzed{}, err
+		}
+		if err = a.validateRunImageTarget(); err != nil {
+			return files.Analyzed{}, err
+		}
+		if a.RunImage.Found() {
+			if runImageMD, err = platform.GetRunImageMetadata(a.RunImage); err != nil {
+				return files.Analyzed{}, errors.Wrap(err, "reading run image metadata")
+			}
+		}
 		if a.PlatformAPI.AtLeast("0.12") {
 			runImageName = a.RunImage.Name()
+			runImagePinned = a.pinRunImage(runImageDigest)
 			atm, err = platform.GetTargetMetadata(a.RunImage)
 			if err != nil {
 				return files.Analyzed{}, errors.Wrap(err, "unpacking metadata from image")
@@ -93,19 +208,44 @@ func (a *Analyzer) Analyze() (files.Analyzed, error) {
 		}
 	}
 
+	if err = a.verifyRunImageLineage(appMeta.RunImage, previousImageRef, runImageDigest); err != nil {
+		return files.Analyzed{}, err
+	}
+
+	runImage := &files.RunImage{
+		Reference:      runImageRef, // the image identifier, e.g. "s0m3d1g3st" (the image identifier) when exporting to a daemon, or "some.registry/some-repo@sha256:s0m3d1g3st" when exporting to a registry
+		TargetMetadata: atm,
+		Image:          runImageName,   // the provided tag, e.g., "some.registry/some-repo:some-tag" if supported by the platform
+		Digest:         runImageDigest, // the run image digest, e.g., "sha256:s0m3d1g3st" when exporting to a registry, or empty when exporting to a daemon
+		Pinned:         runImagePinned, // whether later phases should pull the run image by reference rather than by the provided tag
+		Metadata:       runImageMD,     // the run image top layer and labels, so that later phases need not pull the run image to read them
+	}
+	if a.NoRunImage {
+		a.Logger.Info("No run image, the app image will be exported on an empty base")
+		runImage = &files.RunImage{Scratch: true, TargetMetadata: a.scratchTargetMetadata()}
+	}
+
+	analyzedAt := time.Now().UTC()
 	return files.Analyzed{
 		PreviousImage: &files.ImageIdentifier{
-			Reference: previousImageRef,
-		},
-		RunImage: &files.RunImage{
-			Reference:      runImageRef, // the image identifier, e.g. "s0m3d1g3st" (the image identifier) when exporting to a daemon, or "some.registry/some-repo@sha256:s0m3d1g3st" when exporting to a registry
-			TargetMetadata: atm,
-			Image:          runImageName, // the provided tag, e.g., "some.registry/some-repo:some-tag" if supported by the platform
+			Reference: previousImageRef,  // the image identifier of the previous image that was found
+			Image:     previousImageName, // the provided tag of the previous image that was found, if it was selected from multiple candidates
 		},
+		RunImage:       runImage,
 		LayersMetadata: appMeta,
+		AnalyzedAt:     &analyzedAt,
+		ForceRebuild:   a.ForceRebuild,
 	}, nil
 }
 
+// scratchTargetMetadata returns the target of an app image without a run image, which is the provided target, if any.
+func (a *Analyzer) scratchTargetMetadata() *files.TargetMetadata {
+	if a.TargetOS == "" && a.TargetArch == "" {
+		return nil
+	}
+	return &files.TargetMetadata{OS: a.TargetOS, Arch: a.TargetArch}
+}
+
 func (a *Analyzer) getImageIdentifier(image imgutil.Image) (string, error) {
 	if !image.Found() {
 		a.Logger.Infof("Image with name %q not found", image.Name())
@@ -119,6 +259,196 @@ func (a *Analyzer) getImageIdentifier(image imgutil.Image) (string, error) {
 	return identifier.String(), nil
 }
 
+// warnIfMutableRunImage warns when the run image was provided with the `latest` tag or no tag,
+// as the tag may be moved so that builds using it are not reproducible.
+func (a *Analyzer) warnIfMutableRunImage(digest string) {
+	if a.AllowMutableRunImage || !hasMutableTag(a.RunImage.Name()) {
+		return
+	}
+	if digest == "" {
+		a.Logger.Warnf("Run image %q is provided with a mutable tag; provide it by digest for reproducible builds", a.RunImage.Name())
+		return
+	}
+	a.Logger.Warnf("Run image %q is provided with a mutable tag; provide it by digest for reproducible builds, e.g., %q", a.RunImage.Name(), pinnedImageName(a.RunImage.Name(), digest))
+}
+
+// pinRunImage returns true if the run image should be recorded as pinned in analyzed.toml,
+// i.e., if PinRunImage is true and the run image has a registry digest.
+// The provided name is recorded either way, as the exporter matches it against the run images in run.toml.
+func (a *Analyzer) pinRunImage(digest string) bool {
+	if !a.PinRunImage {
+		return false
+	}
+	if digest == "" {
+		a.Logger.Warnf("Not pinning run image %q, it does not have a registry digest", a.RunImage.Name())
+		return false
+	}
+	a.Logger.Infof("Pinning run image %q to %q", a.RunImage.Name(), pinnedImageName(a.RunImage.Name(), digest))
+	return true
+}
+
+// hasMutableTag returns true if the provided image reference has the `latest` tag or no tag, which defaults to `latest`.
+func hasMutableTag(imageRef string) bool {
+	ref, err := name.ParseReference(imageRef, name.WeakValidation)
+	if err != nil {
+		return false
+	}
+	tag, ok := ref.(name.Tag)
+	return ok && tag.TagStr() == name.DefaultTag
+}
+
+// pinnedImageName returns the provided image reference with its tag replaced by the provided digest,
+// e.g., `some.registry/some-repo@sha256:s0m3d1g3st` for `some.registry/some-repo:latest`.
+func pinnedImageName(imageRef, digest string) string {
+	ref, err := name.ParseReference(imageRef, name.WeakValidation)
+	if err != nil {
+		return imageRef
+	}
+	return ref.Context().Name() + "@" + digest
+}
+
+// verifyPreviousImageDigest ensures that the previous image, identified by the provided reference, resolves to the expected digest, if any was provided.
+// The previous image is considered to have drifted if it was not found.
+func (a *Analyzer) verifyPreviousImageDigest(previousImageRef string) error {
+	if a.PreviousImageDigest == "" || a.PreviousImage == nil {
+		return nil
+	}
+	expected, actual := imageDigest(a.PreviousImageDigest), imageDigest(previousImageRef)
+	if actual == expected {
+		a.Logger.Debugf("Previous image %q resolves to the expected digest %q", a.PreviousImage.Name(), expected)
+		return nil
+	}
+	if actual == "" {
+		actual = "<not found>"
+	}
+	if a.AllowPreviousDrift {
+		a.Logger.Warnf("Previous image %q resolves to %q rather than the expected digest %q", a.PreviousImage.Name(), actual, expected)
+		return nil
+	}
+	return errors.Wrapf(ErrPreviousImageDrift, "previous image %q resolves to %q rather than %q", a.PreviousImage.Name(), actual, expected)
+}
+
+// verifyRunImageLineage ensures that the previous image, if found, was built on the run image, if the run image lineage is validated.
+// The previous image was built on the run image if the run image recorded in its metadata (its image, its reference, or one of its mirrors)
+// is in the same repository as the run image (or the run image provided before registry mirrors were applied),
+// e.g., an older version of the run image, or has the same digest as the run image.
+// Previous images exported with Platform API < 0.12 record only the reference of their run image.
+func (a *Analyzer) verifyRunImageLineage(previousRunImage files.RunImageForRebase, previousImageRef, runImageDigest string) error {
+	if a.RunImageLineage == "" || previousImageRef == "" || a.RunImage == nil {
+		return nil
+	}
+	if previousRunImage.Reference == "" && previousRunImage.Image == "" {
+		a.Logger.Debugf("Not validating the run image lineage, previous image %q does not record its run image", a.PreviousImage.Name())
+		return nil
+	}
+	if runImageDigest != "" && iname.DigestMaybe(previousRunImage.Reference) == runImageDigest {
+		a.Logger.Debugf("Previous image %q was built on run image %q", a.PreviousImage.Name(), a.RunImage.Name())
+		return nil
+	}
+	runImageNames := []string{a.RunImage.Name()}
+	if a.UnmirroredRunImageName != "" {
+		runImageNames = append(runImageNames, a.UnmirroredRunImageName)
+	}
+	for _, previous := range append([]string{previousRunImage.Image, previousRunImage.Reference}, previousRunImage.Mirrors...) {
+		for _, runImageName := range runImageNames {
+			if previous != "" && sameRepository(previous, runImageName) {
+				a.Logger.Debugf("Previous image %q was built on run image %q", a.PreviousImage.Name(), previous)
+				return nil
+			}
+		}
+	}
+	recorded := previousRunImage.Image
+	if recorded == "" {
+		recorded = previousRunImage.Reference
+	}
+	if a.RunImageLineage == platform.RunImageLineageWarn {
+		a.Logger.Warnf("Previous image %q was built on run image %q rather than %q", a.PreviousImage.Name(), recorded, a.RunImage.Name())
+		return nil
+	}
+	return errors.Wrapf(ErrRunImageDivergence, "previous image %q was built on run image %q rather than %q", a.PreviousImage.Name(), recorded, a.RunImage.Name())
+}
+
+// sameRepository returns true if the provided image references are in the same repository, regardless of their tags or digests.
+func sameRepository(ref1, ref2 string) bool {
+	parsed1, err := name.ParseReference(ref1, name.WeakValidation)
+	if err != nil {
+		return false
+	}
+	parsed2, err := name.ParseReference(ref2, name.WeakValidation)
+	if err != nil {
+		return false
+	}
+	return parsed1.Context().Name() == parsed2.Context().Name()
+}
+
+// imageDigest returns the digest in the provided image reference, e.g., `sha256:s0m3d1g3st` for `some.registry/some-repo@sha256:s0m3d1g3st`,
+// or the reference itself if it does not contain a digest, e.g., when it is an image ID in a daemon.
+func imageDigest(ref string) string {
+	if digest := iname.DigestMaybe(ref); digest != "" {
+		return digest
+	}
+	return ref
+}
+
+// validateRunImageMixins ensures that the run image provides the required mixins, if any were provided.
+func (a *Analyzer) validateRunImageMixins() error {
+	required := runStageMixins(a.RequiredMixins)
+	if len(required) == 0 || !a.RunImage.Found() {
+		return nil
+	}
+	var runImageMixins []string
+	if err := image.DecodeLabel(a.RunImage, platform.MixinsLabel, &runImageMixins); err != nil {
+		return errors.Wrap(err, "get run image mixins")
+	}
+	if missing := missingMixins(required, runImageMixins); len(missing) > 0 {
+		return errors.Errorf("run image %q is missing required mixin(s): %s", a.RunImage.Name(), strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// validateRunImageTarget ensures that the run image is for the target platform, if one was provided.
+func (a *Analyzer) validateRunImageTarget() error {
+	if (a.TargetOS == "" && a.TargetArch == "") || !a.RunImage.Found() {
+		return nil
+	}
+	runImageOS, err := a.RunImage.OS()
+	if err != nil {
+		return errors.Wrap(err, "get run image os")
+	}
+	runImageArch, err := a.RunImage.Architecture()
+	if err != nil {
+		return errors.Wrap(err, "get run image architecture")
+	}
+	if (a.TargetOS != "" && runImageOS != a.TargetOS) || (a.TargetArch != "" && runImageArch != a.TargetArch) {
+		return errors.Errorf(
+			"run image %q is for platform %s/%s, which does not match the target platform %s/%s",
+			a.RunImage.Name(), runImageOS, runImageArch, orAny(a.TargetOS), orAny(a.TargetArch),
+		)
+	}
+	return nil
+}
+
+// orAny returns the provided value, or `*` if it is empty.
+func orAny(value string) string {
+	if value == "" {
+		return "*"
+	}
+	return value
+}
+
+// withoutLaunchLayers returns the provided metadata without the metadata of buildpack layers and the SBOM layer.
+// The store.toml of each buildpack is retained, as it is not a layer.
+func withoutLaunchLayers(appMeta files.LayersMetadata) files.LayersMetadata {
+	appMeta.BOM = nil
+	var buildpacks []buildpack.LayersMetadata
+	for _, bp := range appMeta.Buildpacks {
+		bp.Layers = nil
+		buildpacks = append(buildpacks, bp)
+	}
+	appMeta.Buildpacks = buildpacks
+	return appMeta
+}
+
 func bomSHA(appMeta files.LayersMetadata) string {
 	if appMeta.BOM == nil {
 		return ""
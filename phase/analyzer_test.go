@@ -2,16 +2,21 @@ package phase_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/discard"
+	"github.com/apex/log/handlers/memory"
 	"github.com/buildpacks/imgutil/fakes"
 	"github.com/buildpacks/imgutil/local"
 	"github.com/golang/mock/gomock"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 
@@ -165,6 +170,252 @@ func testAnalyzerFactory(t *testing.T, when spec.G, it spec.S) {
 				h.AssertEq(t, analyzer.Logger, logger)
 			})
 
+			when("multiple previous image candidates are provided", func() {
+				it("uses the first candidate that is found", func() {
+					missingImage := fakes.NewImage("some-missing-image-ref", "", nil)
+					h.AssertNil(t, missingImage.Delete())
+					previousImage := fakes.NewImage("some-previous-image-ref", "", nil)
+					runImage := fakes.NewImage("some-run-image-ref", "", nil)
+
+					t.Log("ensures registry access")
+					fakeImageHandler.EXPECT().Kind().Return(image.RemoteKind).AnyTimes()
+					fakeRegistryHandler.EXPECT().EnsureReadAccess([]string{"some-missing-image-ref", "some-previous-image-ref", "some-other-image-ref", "some-run-image-ref"})
+					fakeRegistryHandler.EXPECT().EnsureWriteAccess(gomock.Any())
+
+					t.Log("processes previous image candidates in order")
+					fakeImageHandler.EXPECT().InitImage("some-missing-image-ref").Return(missingImage, nil)
+					fakeImageHandler.EXPECT().InitImage("some-previous-image-ref").Return(previousImage, nil)
+
+					t.Log("processes run image")
+					fakeImageHandler.EXPECT().InitImage("some-run-image-ref").Return(runImage, nil)
+
+					analyzer, err := analyzerFactory.NewAnalyzer(platform.LifecycleInputs{
+						CacheImageRef:    "some-cache-image-ref",
+						LayersDir:        "some-layers-dir",
+						OutputImageRef:   "some-output-image-ref",
+						PreviousImageRef: "some-missing-image-ref,some-previous-image-ref,some-other-image-ref",
+						RunImageRef:      "some-run-image-ref",
+					}, logger)
+					h.AssertNil(t, err)
+					h.AssertEq(t, analyzer.PreviousImage.Name(), previousImage.Name())
+					h.AssertEq(t, analyzer.PreviousImageSelected, true)
+				})
+
+				when("no candidate is found", func() {
+					it("uses the last candidate", func() {
+						missingImage := fakes.NewImage("some-missing-image-ref", "", nil)
+						h.AssertNil(t, missingImage.Delete())
+						otherMissingImage := fakes.NewImage("some-other-missing-image-ref", "", nil)
+						h.AssertNil(t, otherMissingImage.Delete())
+
+						fakeImageHandler.EXPECT().Kind().Return(image.RemoteKind).AnyTimes()
+						fakeRegistryHandler.EXPECT().EnsureReadAccess(gomock.Any())
+						fakeRegistryHandler.EXPECT().EnsureWriteAccess(gomock.Any())
+						fakeImageHandler.EXPECT().InitImage("some-missing-image-ref").Return(missingImage, nil)
+						fakeImageHandler.EXPECT().InitImage("some-other-missing-image-ref").Return(otherMissingImage, nil)
+
+						analyzer, err := analyzerFactory.NewAnalyzer(platform.LifecycleInputs{
+							LayersDir:        "some-layers-dir",
+							OutputImageRef:   "some-output-image-ref",
+							PreviousImageRef: "some-missing-image-ref,some-other-missing-image-ref",
+						}, logger)
+						h.AssertNil(t, err)
+						h.AssertEq(t, analyzer.PreviousImage.Name(), otherMissingImage.Name())
+						h.AssertEq(t, analyzer.PreviousImage.Found(), false)
+					})
+				})
+			})
+
+			it("reads the previous image and the run image concurrently", func() {
+				previousImage := fakes.NewImage("some-previous-image-ref", "", nil)
+				runImage := fakes.NewImage("some-run-image-ref", "", nil)
+				previousImageRequested, runImageRequested := make(chan struct{}), make(chan struct{})
+
+				fakeImageHandler.EXPECT().Kind().Return(image.RemoteKind).AnyTimes()
+				fakeRegistryHandler.EXPECT().EnsureReadAccess(gomock.Any())
+				fakeRegistryHandler.EXPECT().EnsureWriteAccess(gomock.Any())
+				fakeImageHandler.EXPECT().InitImage("some-previous-image-ref").DoAndReturn(func(string) (*fakes.Image, error) {
+					close(previousImageRequested)
+					select {
+					case <-runImageRequested:
+						return previousImage, nil
+					case <-time.After(10 * time.Second):
+						return nil, errors.New("run image was not requested while the previous image was being read")
+					}
+				})
+				fakeImageHandler.EXPECT().InitImage("some-run-image-ref").DoAndReturn(func(string) (*fakes.Image, error) {
+					close(runImageRequested)
+					select {
+					case <-previousImageRequested:
+						return runImage, nil
+					case <-time.After(10 * time.Second):
+						return nil, errors.New("previous image was not requested while the run image was being read")
+					}
+				})
+
+				analyzer, err := analyzerFactory.NewAnalyzer(platform.LifecycleInputs{
+					LayersDir:        "some-layers-dir",
+					OutputImageRef:   "some-output-image-ref",
+					PreviousImageRef: "some-previous-image-ref",
+					RunImageRef:      "some-run-image-ref",
+				}, logger)
+				h.AssertNil(t, err)
+				h.AssertEq(t, analyzer.PreviousImage.Name(), previousImage.Name())
+				h.AssertEq(t, analyzer.RunImage.Name(), runImage.Name())
+			})
+
+			when("the image handler is memoizing", func() {
+				it("reads the previous image and the run image concurrently", func() {
+					analyzerFactory = phase.NewConnectedFactory(
+						api.Platform.Latest(),
+						fakeAPIVerifier,
+						fakeCacheHandler,
+						fakeConfigHandler,
+						image.NewMemoizingHandler(fakeImageHandler),
+						fakeRegistryHandler,
+					)
+					previousImage := fakes.NewImage("some-previous-image-ref", "", nil)
+					runImage := fakes.NewImage("some-run-image-ref", "", nil)
+					previousImageRequested, runImageRequested := make(chan struct{}), make(chan struct{})
+
+					fakeImageHandler.EXPECT().Kind().Return(image.RemoteKind).AnyTimes()
+					fakeRegistryHandler.EXPECT().EnsureReadAccess(gomock.Any())
+					fakeRegistryHandler.EXPECT().EnsureWriteAccess(gomock.Any())
+					fakeImageHandler.EXPECT().InitImage("some-previous-image-ref").DoAndReturn(func(string) (*fakes.Image, error) {
+						close(previousImageRequested)
+						select {
+						case <-runImageRequested:
+							return previousImage, nil
+						case <-time.After(10 * time.Second):
+							return nil, errors.New("run image was not requested while the previous image was being read")
+						}
+					})
+					fakeImageHandler.EXPECT().InitImage("some-run-image-ref").DoAndReturn(func(string) (*fakes.Image, error) {
+						close(runImageRequested)
+						select {
+						case <-previousImageRequested:
+							return runImage, nil
+						case <-time.After(10 * time.Second):
+							return nil, errors.New("previous image was not requested while the run image was being read")
+						}
+					})
+
+					analyzer, err := analyzerFactory.NewAnalyzer(platform.LifecycleInputs{
+						LayersDir:        "some-layers-dir",
+						OutputImageRef:   "some-output-image-ref",
+						PreviousImageRef: "some-previous-image-ref",
+						RunImageRef:      "some-run-image-ref",
+					}, logger)
+					h.AssertNil(t, err)
+					h.AssertEq(t, analyzer.PreviousImage.Name(), previousImage.Name())
+					h.AssertEq(t, analyzer.RunImage.Name(), runImage.Name())
+				})
+			})
+
+			when("a build image is provided", func() {
+				it.Before(func() {
+					fakeImageHandler.EXPECT().Kind().Return(image.RemoteKind).AnyTimes()
+					fakeRegistryHandler.EXPECT().EnsureWriteAccess(gomock.Any())
+				})
+
+				it("reads the required mixins from the build image", func() {
+					buildImage := fakes.NewImage("some-build-image-ref", "", nil)
+					h.AssertNil(t, buildImage.SetLabel(platform.MixinsLabel, `["some-mixin", "build:some-build-mixin"]`))
+
+					fakeRegistryHandler.EXPECT().EnsureReadAccess([]string{"some-run-image-ref", "some-build-image-ref"})
+					fakeImageHandler.EXPECT().InitImage("some-run-image-ref").Return(fakes.NewImage("some-run-image-ref", "", nil), nil)
+					fakeImageHandler.EXPECT().InitImage("some-build-image-ref").Return(buildImage, nil)
+
+					analyzer, err := analyzerFactory.NewAnalyzer(platform.LifecycleInputs{
+						BuildImageRef:       "some-build-image-ref",
+						LayersDir:           "some-layers-dir",
+						OutputImageRef:      "some-output-image-ref",
+						RunImageRef:         "some-run-image-ref",
+						SkipPrevious:        true,
+						UseBuildImageMixins: true,
+					}, logger)
+					h.AssertNil(t, err)
+					h.AssertEq(t, analyzer.RequiredMixins, []string{"some-mixin", "build:some-build-mixin"})
+				})
+
+				when("the platform does not ask for the mixins of the build image", func() {
+					it("does not read the build image", func() {
+						fakeRegistryHandler.EXPECT().EnsureReadAccess([]string{"some-run-image-ref"})
+						fakeImageHandler.EXPECT().InitImage("some-run-image-ref").Return(fakes.NewImage("some-run-image-ref", "", nil), nil)
+
+						analyzer, err := analyzerFactory.NewAnalyzer(platform.LifecycleInputs{
+							BuildImageRef:  "some-build-image-ref",
+							LayersDir:      "some-layers-dir",
+							OutputImageRef: "some-output-image-ref",
+							RunImageRef:    "some-run-image-ref",
+							SkipPrevious:   true,
+						}, logger)
+						h.AssertNil(t, err)
+						h.AssertEq(t, len(analyzer.RequiredMixins), 0)
+					})
+				})
+
+				when("required mixins are provided", func() {
+					it("does not read the build image", func() {
+						fakeRegistryHandler.EXPECT().EnsureReadAccess([]string{"some-run-image-ref"})
+						fakeImageHandler.EXPECT().InitImage("some-run-image-ref").Return(fakes.NewImage("some-run-image-ref", "", nil), nil)
+
+						analyzer, err := analyzerFactory.NewAnalyzer(platform.LifecycleInputs{
+							BuildImageRef:       "some-build-image-ref",
+							LayersDir:           "some-layers-dir",
+							OutputImageRef:      "some-output-image-ref",
+							RequiredMixins:      []string{"some-mixin"},
+							RunImageRef:         "some-run-image-ref",
+							SkipPrevious:        true,
+							UseBuildImageMixins: true,
+						}, logger)
+						h.AssertNil(t, err)
+						h.AssertEq(t, analyzer.RequiredMixins, []string{"some-mixin"})
+					})
+				})
+
+				when("the build image is not found", func() {
+					it("errors", func() {
+						buildImage := fakes.NewImage("some-build-image-ref", "", nil)
+						h.AssertNil(t, buildImage.Delete())
+
+						fakeRegistryHandler.EXPECT().EnsureReadAccess(gomock.Any())
+						fakeImageHandler.EXPECT().InitImage("some-run-image-ref").Return(fakes.NewImage("some-run-image-ref", "", nil), nil)
+						fakeImageHandler.EXPECT().InitImage("some-build-image-ref").Return(buildImage, nil)
+
+						_, err := analyzerFactory.NewAnalyzer(platform.LifecycleInputs{
+							BuildImageRef:       "some-build-image-ref",
+							LayersDir:           "some-layers-dir",
+							OutputImageRef:      "some-output-image-ref",
+							RunImageRef:         "some-run-image-ref",
+							SkipPrevious:        true,
+							UseBuildImageMixins: true,
+						}, logger)
+						h.AssertError(t, err, `build image "some-build-image-ref" not found`)
+					})
+				})
+			})
+
+			when("reading the run image fails", func() {
+				it("errors", func() {
+					previousImage := fakes.NewImage("some-previous-image-ref", "", nil)
+
+					fakeImageHandler.EXPECT().Kind().Return(image.RemoteKind).AnyTimes()
+					fakeRegistryHandler.EXPECT().EnsureReadAccess(gomock.Any())
+					fakeRegistryHandler.EXPECT().EnsureWriteAccess(gomock.Any())
+					fakeImageHandler.EXPECT().InitImage("some-previous-image-ref").Return(previousImage, nil)
+					fakeImageHandler.EXPECT().InitImage("some-run-image-ref").Return(nil, errors.New("some-error"))
+
+					_, err := analyzerFactory.NewAnalyzer(platform.LifecycleInputs{
+						LayersDir:        "some-layers-dir",
+						OutputImageRef:   "some-output-image-ref",
+						PreviousImageRef: "some-previous-image-ref",
+						RunImageRef:      "some-run-image-ref",
+					}, logger)
+					h.AssertError(t, err, "getting run image: some-error")
+				})
+			})
+
 			when("daemon case", func() {
 				it("configures the analyzer", func() {
 					previousImage := fakes.NewImage("some-previous-image-ref", "", nil)
@@ -203,6 +454,73 @@ func testAnalyzerFactory(t *testing.T, when spec.G, it spec.S) {
 					h.AssertPathExists(t, filepath.Join(launchCacheDir, "committed"))
 					h.AssertPathExists(t, filepath.Join(launchCacheDir, "staging"))
 				})
+
+				when("the pull policy is never and the run image is not in the daemon", func() {
+					it("errors", func() {
+						runImage := fakes.NewImage("some-run-image-ref", "", nil)
+						runImage.Delete()
+
+						fakeImageHandler.EXPECT().Kind().Return(image.LocalKind).AnyTimes()
+						fakeRegistryHandler.EXPECT().EnsureReadAccess()
+						fakeRegistryHandler.EXPECT().EnsureWriteAccess(gomock.Any())
+						fakeImageHandler.EXPECT().InitImage("some-run-image-ref").Return(runImage, nil)
+
+						_, err := analyzerFactory.NewAnalyzer(platform.LifecycleInputs{
+							LayersDir:      "some-layers-dir",
+							OutputImageRef: "some-output-image-ref",
+							PullPolicy:     "never",
+							RunImageRef:    "some-run-image-ref",
+							SkipPrevious:   true,
+						}, logger)
+						h.AssertError(t, err, `run image "some-run-image-ref" is not in the daemon and the pull policy is "never"`)
+					})
+				})
+			})
+
+			when("cache fallback is enabled and the cache image is not accessible", func() {
+				it("warns", func() {
+					runImage := fakes.NewImage("some-run-image-ref", "", nil)
+					logHandler := memory.New()
+
+					fakeImageHandler.EXPECT().Kind().Return(image.RemoteKind).AnyTimes()
+					fakeRegistryHandler.EXPECT().EnsureReadAccess([]string{"some-run-image-ref"})
+					fakeRegistryHandler.EXPECT().EnsureWriteAccess("some-cache-image-ref").Return(errors.New("some-error"))
+					fakeRegistryHandler.EXPECT().EnsureWriteAccess([]string{"some-output-image-ref"})
+					fakeImageHandler.EXPECT().InitImage("some-run-image-ref").Return(runImage, nil)
+
+					_, err := analyzerFactory.NewAnalyzer(platform.LifecycleInputs{
+						CacheFallback:  true,
+						CacheImageRef:  "some-cache-image-ref",
+						LayersDir:      "some-layers-dir",
+						OutputImageRef: "some-output-image-ref",
+						RunImageRef:    "some-run-image-ref",
+						SkipPrevious:   true,
+					}, &log.Logger{Handler: logHandler})
+					h.AssertNil(t, err)
+					h.AssertLogEntry(t, logHandler, `Ignoring cache image "some-cache-image-ref", validating registry write access failed: some-error`)
+				})
+			})
+
+			when("skip previous", func() {
+				it("does not process the previous image", func() {
+					runImage := fakes.NewImage("some-run-image-ref", "", nil)
+
+					fakeImageHandler.EXPECT().Kind().Return(image.RemoteKind).AnyTimes()
+					fakeRegistryHandler.EXPECT().EnsureReadAccess([]string{"some-run-image-ref"})
+					fakeRegistryHandler.EXPECT().EnsureWriteAccess(gomock.Any())
+					fakeImageHandler.EXPECT().InitImage("some-run-image-ref").Return(runImage, nil)
+
+					analyzer, err := analyzerFactory.NewAnalyzer(platform.LifecycleInputs{
+						LayersDir:        "some-layers-dir",
+						OutputImageRef:   "some-output-image-ref",
+						PreviousImageRef: "some-output-image-ref",
+						RunImageRef:      "some-run-image-ref",
+						SkipPrevious:     true,
+					}, logger)
+					h.AssertNil(t, err)
+					h.AssertNil(t, analyzer.PreviousImage)
+					h.AssertEq(t, analyzer.RunImage.Name(), runImage.Name())
+				})
 			})
 
 			when("skip layers", func() {
@@ -401,9 +719,22 @@ func testAnalyzer(platformAPI string) func(t *testing.T, when spec.G, it spec.S)
 					h.AssertNil(t, err)
 
 					h.AssertEq(t, md.PreviousImageRef(), "s0m3D1g3sT")
+					h.AssertEq(t, md.PreviousImage.Image, "")
 					h.AssertEq(t, md.LayersMetadata, expectedAppMetadata)
 				})
 
+				when("the previous image was selected from multiple candidates", func() {
+					it("records the name of the previous image", func() {
+						analyzer.PreviousImageSelected = true
+
+						md, err := analyzer.Analyze()
+						h.AssertNil(t, err)
+
+						h.AssertEq(t, md.PreviousImageRef(), "s0m3D1g3sT")
+						h.AssertEq(t, md.PreviousImage.Image, "image-repo-name")
+					})
+				})
+
 				when("cache exists", func() {
 					it.Before(func() {
 						metadata := h.MustReadFile(t, filepath.Join("testdata", "analyzer", "cache_metadata.json"))
@@ -419,6 +750,134 @@ func testAnalyzer(platformAPI string) func(t *testing.T, when spec.G, it spec.S)
 						h.AssertEq(t, md.LayersMetadata, expectedAppMetadata)
 					})
 				})
+
+				when("forcing a rebuild", func() {
+					it.Before(func() {
+						analyzer.ForceRebuild = true
+					})
+
+					it("omits the layer metadata but retains the store of each buildpack", func() {
+						md, err := analyzer.Analyze()
+						h.AssertNil(t, err)
+
+						h.AssertEq(t, md.ForceRebuild, true)
+						h.AssertEq(t, md.PreviousImageRef(), "s0m3D1g3sT")
+						h.AssertEq(t, len(md.LayersMetadata.Buildpacks), len(expectedAppMetadata.Buildpacks))
+						for i, bp := range md.LayersMetadata.Buildpacks {
+							h.AssertEq(t, bp.ID, expectedAppMetadata.Buildpacks[i].ID)
+							h.AssertEq(t, len(bp.Layers), 0)
+							h.AssertEq(t, bp.Store, expectedAppMetadata.Buildpacks[i].Store)
+						}
+						h.AssertEq(t, md.LayersMetadata.App, expectedAppMetadata.App)
+					})
+				})
+			})
+
+			when("the run image lineage is validated", func() {
+				var (
+					runImage   *fakes.Image
+					logHandler *memory.Handler
+				)
+				runImageDigest := "sha256:" + strings.Repeat("a", 64)
+
+				it.Before(func() {
+					metadata := fmt.Sprintf(`{"runImage":{"reference":"some-registry.io/some-run-image@%s","image":"some-registry.io/some-run-image:1.0","mirrors":["some-mirror.io/some-run-image:1.0"]}}`, runImageDigest)
+					h.AssertNil(t, previousImage.SetLabel("io.buildpacks.lifecycle.metadata", metadata))
+					logHandler = memory.New()
+					analyzer.Logger = &log.Logger{Handler: logHandler}
+					analyzer.RunImageLineage = platform.RunImageLineageFail
+				})
+
+				newRunImage := func(ref, digest string) *fakes.Image {
+					identifier, err := name.NewDigest(strings.Split(ref, ":")[0]+"@"+digest, name.WeakValidation)
+					h.AssertNil(t, err)
+					return fakes.NewImage(ref, "", identifier)
+				}
+
+				when("the run image is in the same repository", func() {
+					it("succeeds", func() {
+						runImage = newRunImage("some-registry.io/some-run-image:1.1", "sha256:"+strings.Repeat("b", 64))
+						analyzer.RunImage = runImage
+
+						_, err := analyzer.Analyze()
+						h.AssertNil(t, err)
+					})
+
+					it("succeeds for a mirror", func() {
+						runImage = newRunImage("some-mirror.io/some-run-image:1.1", "sha256:"+strings.Repeat("b", 64))
+						analyzer.RunImage = runImage
+
+						_, err := analyzer.Analyze()
+						h.AssertNil(t, err)
+					})
+
+					it("succeeds when the run image is pulled from a registry mirror", func() {
+						runImage = newRunImage("registry-mirror.internal/some-run-image:1.1", "sha256:"+strings.Repeat("b", 64))
+						analyzer.RunImage = runImage
+						analyzer.UnmirroredRunImageName = "some-registry.io/some-run-image:1.1"
+
+						_, err := analyzer.Analyze()
+						h.AssertNil(t, err)
+					})
+
+					when("the previous image records only the reference of its run image", func() {
+						it.Before(func() {
+							metadata := fmt.Sprintf(`{"runImage":{"reference":"some-registry.io/some-run-image@%s"}}`, runImageDigest)
+							h.AssertNil(t, previousImage.SetLabel("io.buildpacks.lifecycle.metadata", metadata))
+						})
+
+						it("succeeds", func() {
+							runImage = newRunImage("some-registry.io/some-run-image:1.1", "sha256:"+strings.Repeat("b", 64))
+							analyzer.RunImage = runImage
+
+							_, err := analyzer.Analyze()
+							h.AssertNil(t, err)
+						})
+					})
+				})
+
+				when("the run image has the same digest", func() {
+					it("succeeds", func() {
+						runImage = newRunImage("other-registry.io/other-run-image:1.0", runImageDigest)
+						analyzer.RunImage = runImage
+
+						_, err := analyzer.Analyze()
+						h.AssertNil(t, err)
+					})
+				})
+
+				when("the run image diverges", func() {
+					it.Before(func() {
+						runImage = newRunImage("other-registry.io/other-run-image:1.0", "sha256:"+strings.Repeat("b", 64))
+						analyzer.RunImage = runImage
+					})
+
+					it("errors", func() {
+						_, err := analyzer.Analyze()
+						h.AssertNotNil(t, err)
+						h.AssertEq(t, errors.Is(err, phase.ErrRunImageDivergence), true)
+						h.AssertStringContains(t, err.Error(), `previous image "image-repo-name" was built on run image "some-registry.io/some-run-image:1.0" rather than "other-registry.io/other-run-image:1.0"`)
+					})
+
+					when("divergence is only warned about", func() {
+						it("warns", func() {
+							analyzer.RunImageLineage = platform.RunImageLineageWarn
+
+							_, err := analyzer.Analyze()
+							h.AssertNil(t, err)
+							h.AssertLogEntry(t, logHandler, `Previous image "image-repo-name" was built on run image "some-registry.io/some-run-image:1.0" rather than "other-registry.io/other-run-image:1.0"`)
+						})
+					})
+
+					when("the run image lineage is not validated", func() {
+						it("succeeds", func() {
+							analyzer.RunImageLineage = ""
+
+							_, err := analyzer.Analyze()
+							h.AssertNil(t, err)
+						})
+					})
+				})
 			})
 
 			when("previous image not found", func() {
@@ -431,10 +890,55 @@ func testAnalyzer(platformAPI string) func(t *testing.T, when spec.G, it spec.S)
 					h.AssertNil(t, err)
 
 					h.AssertEq(t, md.PreviousImageRef(), "")
+					h.AssertEq(t, md.PreviousImage.Image, "")
 					h.AssertEq(t, md.LayersMetadata, files.LayersMetadata{})
 				})
 			})
 
+			when("an expected previous image digest is provided", func() {
+				when("the previous image resolves to the digest", func() {
+					it("does not error", func() {
+						analyzer.PreviousImageDigest = "s0m3D1g3sT"
+						_, err := analyzer.Analyze()
+						h.AssertNil(t, err)
+					})
+				})
+
+				when("the previous image resolves to a different digest", func() {
+					it.Before(func() {
+						analyzer.PreviousImageDigest = "some-other-image@sha256:0f9a6a0b4a2b0e1d3c5e7f9b1d3f5a7c9e1b3d5f7a9c1e3b5d7f9a1c3e5b7d9f"
+					})
+
+					it("errors", func() {
+						_, err := analyzer.Analyze()
+						h.AssertError(t, err, `previous image "image-repo-name" resolves to "s0m3D1g3sT" rather than "sha256:0f9a6a0b4a2b0e1d3c5e7f9b1d3f5a7c9e1b3d5f7a9c1e3b5d7f9a1c3e5b7d9f"`)
+						h.AssertEq(t, errors.Is(err, phase.ErrPreviousImageDrift), true)
+					})
+
+					when("drift is allowed", func() {
+						it("warns", func() {
+							logHandler := memory.New()
+							analyzer.Logger = &log.Logger{Handler: logHandler}
+							analyzer.AllowPreviousDrift = true
+
+							_, err := analyzer.Analyze()
+							h.AssertNil(t, err)
+							h.AssertLogEntry(t, logHandler, `Previous image "image-repo-name" resolves to "s0m3D1g3sT" rather than the expected digest`)
+						})
+					})
+				})
+
+				when("the previous image is not found", func() {
+					it("errors", func() {
+						h.AssertNil(t, previousImage.Delete())
+						analyzer.PreviousImageDigest = "s0m3D1g3sT"
+
+						_, err := analyzer.Analyze()
+						h.AssertError(t, err, `resolves to "<not found>"`)
+					})
+				})
+			})
+
 			when("previous image does not have metadata label", func() {
 				it.Before(func() {
 					h.AssertNil(t, previousImage.SetLabel("io.buildpacks.lifecycle.metadata", ""))
@@ -471,6 +975,53 @@ func testAnalyzer(platformAPI string) func(t *testing.T, when spec.G, it spec.S)
 					_, err := analyzer.Analyze()
 					h.AssertNil(t, err)
 				})
+
+				when("forcing a rebuild", func() {
+					it("does not restore the SBOM layer", func() {
+						analyzer.ForceRebuild = true
+						sbomRestorer.EXPECT().RestoreFromPrevious(gomock.Any(), gomock.Any()).Times(0)
+
+						md, err := analyzer.Analyze()
+						h.AssertNil(t, err)
+						h.AssertNil(t, md.LayersMetadata.BOM)
+					})
+				})
+			})
+
+			it("records the time of analysis", func() {
+				before := time.Now()
+				md, err := analyzer.Analyze()
+				h.AssertNil(t, err)
+
+				h.AssertNotNil(t, md.AnalyzedAt)
+				if md.AnalyzedAt.Before(before.Add(-time.Second)) || md.AnalyzedAt.After(time.Now().Add(time.Second)) {
+					t.Fatalf("expected analyzed-at %s to be the current time", md.AnalyzedAt)
+				}
+			})
+
+			when("no run image", func() {
+				it.Before(func() {
+					analyzer.NoRunImage = true
+				})
+
+				it("records that the app image has no run image", func() {
+					md, err := analyzer.Analyze()
+					h.AssertNil(t, err)
+
+					h.AssertEq(t, md.HasNoRunImage(), true)
+					h.AssertEq(t, md.RunImage.Reference, "")
+					h.AssertNil(t, md.RunImage.TargetMetadata)
+				})
+
+				it("records the provided target", func() {
+					analyzer.TargetOS = "linux"
+					analyzer.TargetArch = "arm64"
+
+					md, err := analyzer.Analyze()
+					h.AssertNil(t, err)
+
+					h.AssertEq(t, md.RunImage.TargetMetadata, &files.TargetMetadata{OS: "linux", Arch: "arm64"})
+				})
 			})
 
 			when("run image is provided", func() {
@@ -484,6 +1035,137 @@ func testAnalyzer(platformAPI string) func(t *testing.T, when spec.G, it spec.S)
 
 					h.AssertEq(t, md.RunImage.Reference, "s0m3D1g3sT")
 				})
+
+				it("records the run image metadata in the analyzed metadata", func() {
+					h.AssertNil(t, previousImage.SetLabel(platform.StackIDLabel, "some-stack-id"))
+
+					md, err := analyzer.Analyze()
+					h.AssertNil(t, err)
+
+					h.AssertNotNil(t, md.RunImage.Metadata)
+					h.AssertEq(t, md.RunImage.Metadata.Labels[platform.StackIDLabel], "some-stack-id")
+				})
+
+				it("omits the run image digest when the run image is a daemon image", func() {
+					md, err := analyzer.Analyze()
+					h.AssertNil(t, err)
+
+					h.AssertEq(t, md.RunImage.Digest, "")
+				})
+
+				when("required mixins are provided", func() {
+					it.Before(func() {
+						analyzer.RequiredMixins = []string{"mixin-1", "run:mixin-2", "build:mixin-3"}
+					})
+
+					it("succeeds when the run image provides the required mixins", func() {
+						h.AssertNil(t, previousImage.SetLabel(platform.MixinsLabel, `["mixin-1", "run:mixin-2"]`))
+
+						_, err := analyzer.Analyze()
+						h.AssertNil(t, err)
+					})
+
+					it("errors with the list of missing mixins", func() {
+						h.AssertNil(t, previousImage.SetLabel(platform.MixinsLabel, `["mixin-0"]`))
+
+						_, err := analyzer.Analyze()
+						h.AssertError(t, err, `run image "image-repo-name" is missing required mixin(s): mixin-1, mixin-2`)
+					})
+				})
+
+				when("a target platform is provided", func() {
+					it.Before(func() {
+						analyzer.TargetOS = "linux"
+						analyzer.TargetArch = "arm64"
+						h.AssertNil(t, previousImage.SetOS("linux"))
+					})
+
+					it("succeeds when the run image is for the target platform", func() {
+						h.AssertNil(t, previousImage.SetArchitecture("arm64"))
+
+						_, err := analyzer.Analyze()
+						h.AssertNil(t, err)
+					})
+
+					it("errors when the run image is for another architecture", func() {
+						h.AssertNil(t, previousImage.SetArchitecture("amd64"))
+
+						_, err := analyzer.Analyze()
+						h.AssertError(t, err, `run image "image-repo-name" is for platform linux/amd64, which does not match the target platform linux/arm64`)
+					})
+
+					it("ignores the part of the target platform that is not provided", func() {
+						analyzer.TargetArch = ""
+						h.AssertNil(t, previousImage.SetArchitecture("amd64"))
+
+						_, err := analyzer.Analyze()
+						h.AssertNil(t, err)
+					})
+				})
+
+				when("run image has a registry digest", func() {
+					it("records the run image digest in the analyzed metadata", func() {
+						digestRef, err := name.NewDigest("some-registry.io/some-run-image@sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+						h.AssertNil(t, err)
+						runImage := fakes.NewImage("some-registry.io/some-run-image:some-tag", "", digestRef)
+						analyzer.RunImage = runImage
+
+						md, err := analyzer.Analyze()
+						h.AssertNil(t, err)
+
+						h.AssertEq(t, md.RunImage.Reference, digestRef.String())
+						h.AssertEq(t, md.RunImage.Digest, "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+					})
+				})
+
+				when("run image is provided with a mutable tag", func() {
+					var (
+						digestRef  name.Digest
+						logHandler *memory.Handler
+					)
+
+					it.Before(func() {
+						var err error
+						digestRef, err = name.NewDigest("some-registry.io/some-run-image@sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+						h.AssertNil(t, err)
+						analyzer.RunImage = fakes.NewImage("some-registry.io/some-run-image", "", digestRef)
+						logHandler = memory.New()
+						analyzer.Logger = &log.Logger{Handler: logHandler}
+					})
+
+					it("warns", func() {
+						_, err := analyzer.Analyze()
+						h.AssertNil(t, err)
+
+						h.AssertLogEntry(t, logHandler, `Run image "some-registry.io/some-run-image" is provided with a mutable tag; provide it by digest for reproducible builds, e.g., "`+digestRef.String()+`"`)
+					})
+
+					when("mutable run images are allowed", func() {
+						it("does not warn", func() {
+							analyzer.AllowMutableRunImage = true
+
+							_, err := analyzer.Analyze()
+							h.AssertNil(t, err)
+
+							h.AssertNoLogEntry(t, logHandler, "mutable tag")
+						})
+					})
+
+					when("the run image is pinned", func() {
+						it("records the run image as pinned and keeps the provided tag", func() {
+							analyzer.PinRunImage = true
+
+							md, err := analyzer.Analyze()
+							h.AssertNil(t, err)
+
+							if api.MustParse(platformAPI).AtLeast("0.12") {
+								h.AssertEq(t, md.RunImage.Image, "some-registry.io/some-run-image")
+								h.AssertEq(t, md.RunImage.Pinned, true)
+							}
+							h.AssertEq(t, md.RunImage.Reference, digestRef.String())
+						})
+					})
+				})
 				it("populates target metadata from the run image", func() {
 					h.AssertNil(t, previousImage.SetLabel("io.buildpacks.base.id", "id software"))
 					h.AssertNil(t, previousImage.SetOS("windows"))
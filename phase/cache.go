@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/pkg/errors"
 
@@ -20,6 +21,45 @@ type LayerDir interface {
 
 func (e *Exporter) Cache(layersDir string, cacheStore Cache) error {
 	defer log.NewMeasurement("Cache", e.Logger)()
+	if err := e.addCacheLayers(layersDir, cacheStore); err != nil {
+		return err
+	}
+	if err := commitCache(cacheStore); err != nil {
+		return err
+	}
+	if e.PruneCache {
+		pruneCache(cacheStore, nil, e.Logger)
+	}
+	return nil
+}
+
+// CacheAsync adds layers to the provided cache like Cache, but commits the cache in the background.
+// The returned function blocks until the commit completes and returns any error encountered while caching;
+// it must be called before the process exits, and before any layer tarballs created by the LayerFactory are removed.
+func (e *Exporter) CacheAsync(layersDir string, cacheStore Cache) func() error {
+	if err := e.addCacheLayers(layersDir, cacheStore); err != nil {
+		return func() error { return err }
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer log.NewMeasurement("Cache commit", e.Logger)()
+		err := commitCache(cacheStore)
+		if err == nil && e.PruneCache {
+			pruneCache(cacheStore, nil, e.Logger)
+		}
+		done <- err
+	}()
+	var (
+		once      sync.Once
+		commitErr error
+	)
+	return func() error {
+		once.Do(func() { commitErr = <-done })
+		return commitErr
+	}
+}
+
+func (e *Exporter) addCacheLayers(layersDir string, cacheStore Cache) error {
 	var err error
 	if !cacheStore.Exists() {
 		e.Logger.Info("Layer cache not found")
@@ -72,13 +112,36 @@ func (e *Exporter) Cache(layersDir string, cacheStore Cache) error {
 	if err := cacheStore.SetMetadata(meta); err != nil {
 		return errors.Wrap(err, "setting cache metadata")
 	}
+	return nil
+}
+
+func commitCache(cacheStore Cache) error {
 	if err := cacheStore.Commit(); err != nil {
 		return errors.Wrap(err, "committing cache")
 	}
-
 	return nil
 }
 
+// PrunableCache is a cache from which layers that are no longer referenced can be removed.
+type PrunableCache interface {
+	// Prune removes layers that are neither in keepSHAs nor referenced by the committed cache metadata.
+	Prune(keepSHAs []string) error
+}
+
+// pruneCache prunes the provided cache, if it supports pruning.
+// Pruning is best-effort: failures are logged rather than returned, as leftover layers take up space but do not affect the cache.
+func pruneCache(cacheStore Cache, keepSHAs []string, logger log.Logger) {
+	prunable, ok := cacheStore.(PrunableCache)
+	if !ok {
+		logger.Debugf("Cache %q does not support pruning", cacheStore.Name())
+		return
+	}
+	defer log.NewMeasurement("Cache prune", logger)()
+	if err := prunable.Prune(keepSHAs); err != nil {
+		logger.Warnf("Failed to prune cache %q: %s", cacheStore.Name(), err)
+	}
+}
+
 type layerDir struct {
 	path       string
 	identifier string
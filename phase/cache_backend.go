@@ -0,0 +1,117 @@
+package phase
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"sync"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/remote"
+	"github.com/google/go-containerregistry/pkg/authn"
+
+	"github.com/buildpacks/lifecycle/cache"
+	"github.com/buildpacks/lifecycle/log"
+	"github.com/buildpacks/lifecycle/platform"
+)
+
+// CacheBackend creates the cache at the provided location, which is the cache image or cache directory provided to the lifecycle
+// without its scheme, e.g., `some-bucket/some-prefix` for `s3://some-bucket/some-prefix`.
+// The returned cache must satisfy the contract documented on Cache.
+type CacheBackend func(location string, opts CacheOptions) (Cache, error)
+
+// CacheOptions configure the cache created by a CacheBackend. Backends should ignore the options that do not apply to them.
+type CacheOptions struct {
+	// Keychain resolves registry credentials.
+	Keychain authn.Keychain
+	Logger   log.Logger
+	// DeletionEnabled, if true, allows the previous cache to be deleted when the cache is committed.
+	DeletionEnabled bool
+	// Chunked, if true, stores layers as content-defined chunks, for caches that support it.
+	Chunked bool
+	// OCIMediaTypes, if true, saves a cache image with OCI media types instead of Docker media types.
+	OCIMediaTypes bool
+}
+
+var (
+	cacheBackendsMu sync.RWMutex
+	cacheBackends   = map[string]CacheBackend{
+		platform.CacheSchemeImage: newImageCache,
+		platform.CacheSchemeFile:  newVolumeCache,
+	}
+)
+
+// RegisterCacheBackend registers the provided backend for caches whose location has the provided scheme,
+// e.g., `s3` for `s3://some-bucket/some-prefix`, replacing any backend previously registered for the scheme.
+// The built-in backends are registered for the `image` and `file` schemes.
+// It is intended to be called from an init function of a program that embeds the lifecycle;
+// the lifecycle binary itself uses a cache helper for schemes without a registered backend (see NewCache).
+func RegisterCacheBackend(scheme string, backend CacheBackend) {
+	cacheBackendsMu.Lock()
+	defer cacheBackendsMu.Unlock()
+	cacheBackends[scheme] = backend
+}
+
+// CacheBackendSchemes returns the schemes with a registered backend, in sorted order.
+func CacheBackendSchemes() []string {
+	cacheBackendsMu.RLock()
+	defer cacheBackendsMu.RUnlock()
+	var schemes []string
+	for scheme := range cacheBackends {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// NewCache creates the cache at the provided cache image or cache directory with the backend registered for its scheme.
+// Values without a scheme use the provided default scheme, e.g., `platform.CacheSchemeImage` for cache images.
+// If no backend is registered for the scheme, the cache is stored by the cache helper for the scheme
+// (e.g., `lifecycle-cache-s3` for `s3://some-bucket/some-prefix`) found in PATH, if any (see cache.HelperCache).
+func NewCache(value, defaultScheme string, opts CacheOptions) (Cache, error) {
+	scheme, location := platform.SplitCacheLocation(value)
+	if scheme == "" {
+		scheme = defaultScheme
+	}
+	cacheBackendsMu.RLock()
+	backend, ok := cacheBackends[scheme]
+	cacheBackendsMu.RUnlock()
+	if ok {
+		return backend(location, opts)
+	}
+	helper, err := exec.LookPath(cache.HelperPrefix + scheme)
+	if err != nil {
+		return nil, fmt.Errorf("no cache backend is registered for scheme %q of cache %q and cache helper %q was not found in PATH; registered schemes are %q",
+			scheme, value, cache.HelperPrefix+scheme, CacheBackendSchemes())
+	}
+	return cache.NewHelperCache(helper, location), nil
+}
+
+func newImageCache(location string, opts CacheOptions) (Cache, error) {
+	var newImageOpts []remote.ImageOption
+	if opts.OCIMediaTypes {
+		newImageOpts = append(newImageOpts, remote.WithMediaTypes(imgutil.OCITypes))
+	}
+	imageDeleter := cache.NewImageDeleter(cache.NewImageComparer(), opts.Logger, opts.DeletionEnabled)
+	imageCache, err := cache.NewImageCacheFromName(location, opts.Keychain, opts.Logger, imageDeleter, newImageOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating image cache: %w", err)
+	}
+	return imageCache, nil
+}
+
+func newVolumeCache(location string, opts CacheOptions) (Cache, error) {
+	var (
+		volumeCache *cache.VolumeCache
+		err         error
+	)
+	if opts.Chunked {
+		volumeCache, err = cache.NewChunkedVolumeCache(location)
+	} else {
+		volumeCache, err = cache.NewVolumeCache(location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating volume cache: %w", err)
+	}
+	return volumeCache, nil
+}
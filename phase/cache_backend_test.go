@@ -0,0 +1,84 @@
+package phase_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/cache"
+	"github.com/buildpacks/lifecycle/phase"
+	"github.com/buildpacks/lifecycle/platform"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestCacheBackend(t *testing.T) {
+	spec.Run(t, "CacheBackend", testCacheBackend, spec.Report(report.Terminal{}))
+}
+
+func testCacheBackend(t *testing.T, when spec.G, it spec.S) {
+	when(".NewCache", func() {
+		it("creates a cache directory with the built-in file backend", func() {
+			cacheDir := t.TempDir()
+
+			cacheStore, err := phase.NewCache(cacheDir, platform.CacheSchemeFile, phase.CacheOptions{})
+			h.AssertNil(t, err)
+			_, ok := cacheStore.(*cache.VolumeCache)
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, cacheStore.Name(), cacheDir)
+		})
+
+		it("creates a cache with the backend registered for the scheme", func() {
+			fakeCache, err := cache.NewVolumeCache(t.TempDir())
+			h.AssertNil(t, err)
+			var gotLocation string
+			phase.RegisterCacheBackend("some-scheme", func(location string, opts phase.CacheOptions) (phase.Cache, error) {
+				gotLocation = location
+				h.AssertEq(t, opts.DeletionEnabled, true)
+				return fakeCache, nil
+			})
+
+			cacheStore, err := phase.NewCache("some-scheme://some-bucket/some-prefix", platform.CacheSchemeImage, phase.CacheOptions{DeletionEnabled: true})
+			h.AssertNil(t, err)
+			h.AssertEq(t, cacheStore == phase.Cache(fakeCache), true)
+			h.AssertEq(t, gotLocation, "some-bucket/some-prefix")
+			h.AssertContains(t, phase.CacheBackendSchemes(), "file", "image", "some-scheme")
+		})
+
+		it("uses the scheme of the value over the default scheme", func() {
+			cacheDir := t.TempDir()
+
+			cacheStore, err := phase.NewCache("file://"+cacheDir, platform.CacheSchemeImage, phase.CacheOptions{})
+			h.AssertNil(t, err)
+			h.AssertEq(t, cacheStore.Name(), cacheDir)
+		})
+
+		when("no backend is registered for the scheme", func() {
+			when("a cache helper for the scheme is in PATH", func() {
+				it("creates a cache stored by the helper", func() {
+					if runtime.GOOS == "windows" {
+						t.Skip("the cache helper fixture is a shell script")
+					}
+					helpersDir := t.TempDir()
+					h.AssertNil(t, os.WriteFile(filepath.Join(helpersDir, cache.HelperPrefix+"some-helper-scheme"), []byte("#!/bin/sh\necho true\n"), 0755)) // #nosec G306
+					t.Setenv("PATH", helpersDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+					cacheStore, err := phase.NewCache("some-helper-scheme://some-bucket/some-prefix", platform.CacheSchemeImage, phase.CacheOptions{})
+					h.AssertNil(t, err)
+					_, ok := cacheStore.(*cache.HelperCache)
+					h.AssertEq(t, ok, true)
+					h.AssertEq(t, cacheStore.Name(), "some-bucket/some-prefix")
+					h.AssertEq(t, cacheStore.Exists(), true)
+				})
+			})
+
+			it("errors", func() {
+				_, err := phase.NewCache("unknown://some-location", platform.CacheSchemeImage, phase.CacheOptions{})
+				h.AssertError(t, err, `no cache backend is registered for scheme "unknown" of cache "unknown://some-location" and cache helper "lifecycle-cache-unknown" was not found in PATH`)
+			})
+		})
+	})
+}
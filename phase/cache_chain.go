@@ -0,0 +1,129 @@
+package phase
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/lifecycle/platform"
+)
+
+var errCacheChainReadOnly = errors.New("cache chain is read-only")
+
+// cacheChain is a read-only Cache over several caches in precedence order.
+// Metadata for a buildpack is taken from the first cache that has metadata for that buildpack,
+// and layers are retrieved from the first cache that has them.
+type cacheChain []Cache
+
+// newCacheChain returns a Cache over the provided caches in precedence order, ignoring nil caches.
+// It returns nil if no caches are provided, and the cache itself if only one is provided.
+func newCacheChain(caches ...Cache) Cache {
+	var chain cacheChain
+	for _, cache := range caches {
+		if cache != nil {
+			chain = append(chain, cache)
+		}
+	}
+	switch len(chain) {
+	case 0:
+		return nil
+	case 1:
+		return chain[0]
+	default:
+		return chain
+	}
+}
+
+func (c cacheChain) Exists() bool {
+	for _, cache := range c {
+		if cache.Exists() {
+			return true
+		}
+	}
+	return false
+}
+
+func (c cacheChain) Name() string {
+	var names []string
+	for _, cache := range c {
+		names = append(names, cache.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+func (c cacheChain) RetrieveMetadata() (platform.CacheMetadata, error) {
+	var merged platform.CacheMetadata
+	seen := make(map[string]bool)
+	for _, cache := range c {
+		if !cache.Exists() {
+			continue
+		}
+		meta, err := cache.RetrieveMetadata()
+		if err != nil {
+			return platform.CacheMetadata{}, errors.Wrapf(err, "retrieving metadata from cache %q", cache.Name())
+		}
+		if merged.BOM.SHA == "" {
+			merged.BOM = meta.BOM
+		}
+		for _, bpMD := range meta.Buildpacks {
+			if seen[bpMD.ID] {
+				continue
+			}
+			seen[bpMD.ID] = true
+			merged.Buildpacks = append(merged.Buildpacks, bpMD)
+		}
+	}
+	return merged, nil
+}
+
+// RetrieveLayer retrieves the layer from the first cache that has it.
+// If every cache reports that the layer does not exist, the returned error matches os.ErrNotExist.
+func (c cacheChain) RetrieveLayer(sha string) (io.ReadCloser, error) {
+	var tried []string
+	notExist := true
+	for _, cache := range c {
+		rc, err := cache.RetrieveLayer(sha)
+		if err == nil {
+			return rc, nil
+		}
+		notExist = notExist && errors.Is(err, os.ErrNotExist)
+		tried = append(tried, errors.Wrapf(err, "cache %q", cache.Name()).Error())
+	}
+	err := errors.Errorf("layer with SHA '%s' not found in any cache: %s", sha, strings.Join(tried, "; "))
+	if notExist {
+		return nil, &restoreError{kind: os.ErrNotExist, err: err}
+	}
+	return nil, err
+}
+
+// VerifyIntegrity verifies the layer in the first cache that has it, as that is the cache the layer is retrieved from.
+func (c cacheChain) VerifyIntegrity(sha string) error {
+	var tried []string
+	for _, cache := range c {
+		err := cache.VerifyIntegrity(sha)
+		if errors.Is(err, os.ErrNotExist) {
+			tried = append(tried, errors.Wrapf(err, "cache %q", cache.Name()).Error())
+			continue
+		}
+		return errors.Wrapf(err, "cache %q", cache.Name())
+	}
+	return errors.Errorf("layer with SHA '%s' not found in any cache: %s", sha, strings.Join(tried, "; "))
+}
+
+func (c cacheChain) SetMetadata(_ platform.CacheMetadata) error {
+	return errCacheChainReadOnly
+}
+
+func (c cacheChain) AddLayerFile(_ string, _ string) error {
+	return errCacheChainReadOnly
+}
+
+func (c cacheChain) ReuseLayer(_ string) error {
+	return errCacheChainReadOnly
+}
+
+func (c cacheChain) Commit() error {
+	return errCacheChainReadOnly
+}
@@ -140,6 +140,27 @@ func testCache(t *testing.T, when spec.G, it spec.S) {
 				})
 			})
 
+			when("committing asynchronously", func() {
+				it("adds layers to the cache and commits it in the background", func() {
+					wait := exporter.CacheAsync(layersDir, testCache)
+					h.AssertNil(t, wait())
+
+					assertCacheHasLayer(t, testCache, "buildpack.id:cache-true-layer")
+					assertCacheHasLayer(t, testCache, "other.buildpack.id:other-buildpack-layer")
+					metadata, err := testCache.RetrieveMetadata()
+					h.AssertNil(t, err)
+					h.AssertEq(t, metadata.Buildpacks[0].Layers["cache-true-layer"].SHA, testLayerDigest("buildpack.id:cache-true-layer"))
+				})
+
+				when("the commit fails", func() {
+					it("returns the error from the wait function", func() {
+						wait := exporter.CacheAsync(layersDir, &failingCommitCache{Cache: testCache})
+						h.AssertError(t, wait(), "committing cache: some-commit-error")
+						h.AssertError(t, wait(), "committing cache: some-commit-error")
+					})
+				})
+			})
+
 			when("there are previously cached layers", func() {
 				var (
 					metadataTemplate string
@@ -288,6 +309,14 @@ func testCache(t *testing.T, when spec.G, it spec.S) {
 	})
 }
 
+type failingCommitCache struct {
+	phase.Cache
+}
+
+func (c *failingCommitCache) Commit() error {
+	return errors.New("some-commit-error")
+}
+
 func assertCacheHasLayer(t *testing.T, cache phase.Cache, id string) {
 	t.Helper()
 
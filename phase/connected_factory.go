@@ -8,6 +8,7 @@ import (
 	"github.com/buildpacks/lifecycle/api"
 	"github.com/buildpacks/lifecycle/cache"
 	"github.com/buildpacks/lifecycle/image"
+	"github.com/buildpacks/lifecycle/log"
 	"github.com/buildpacks/lifecycle/platform"
 )
 
@@ -41,11 +42,27 @@ func NewConnectedFactory(
 	}
 }
 
-func (f *ConnectedFactory) ensureRegistryAccess(inputs platform.LifecycleInputs) error {
+// ensureRegistryAccess ensures the images to read and write are accessible.
+// Cache images stored by a cache backend other than a registry are not checked.
+// If cache fallback is enabled, a cache image that is not accessible is not an error; it is reported as a warning instead.
+func (f *ConnectedFactory) ensureRegistryAccess(inputs platform.LifecycleInputs, logger log.Logger) error {
 	var readImages, writeImages []string
-	writeImages = append(writeImages, inputs.CacheImageRef)
+	cacheImageRef := inputs.RegistryCacheImageRef()
+	if inputs.CacheFallback && cacheImageRef != "" {
+		if err := f.registryHandler.EnsureWriteAccess(cacheImageRef); err != nil {
+			logger.Warnf("Ignoring cache image %q, validating registry write access failed: %s", cacheImageRef, err)
+		}
+	} else {
+		writeImages = append(writeImages, cacheImageRef)
+	}
 	if f.imageHandler.Kind() == image.RemoteKind {
-		readImages = append(readImages, inputs.PreviousImageRef, inputs.RunImageRef)
+		if !inputs.SkipPrevious {
+			readImages = append(readImages, inputs.PreviousImageRefs()...)
+		}
+		readImages = append(readImages, inputs.RunImageRef)
+		if inputs.ReadsBuildImageMixins() {
+			readImages = append(readImages, inputs.BuildImageRef)
+		}
 		writeImages = append(writeImages, inputs.OutputImageRef)
 		writeImages = append(writeImages, inputs.AdditionalTags...)
 	}
@@ -58,13 +75,29 @@ func (f *ConnectedFactory) ensureRegistryAccess(inputs platform.LifecycleInputs)
 	return nil
 }
 
-func (f *ConnectedFactory) getPreviousImage(imageRef string, launchCacheDir string) (imgutil.Image, error) {
-	if imageRef == "" {
+// getPreviousImage returns the first of the provided image references that is found.
+// If none of the candidates are found, the last candidate is returned so that the caller can report it as not found.
+func (f *ConnectedFactory) getPreviousImage(imageRefs []string, launchCacheDir string, logger log.Logger) (imgutil.Image, error) {
+	if len(imageRefs) == 0 {
 		return nil, nil
 	}
-	previousImage, err := f.imageHandler.InitImage(imageRef)
-	if err != nil {
-		return nil, fmt.Errorf("getting previous image: %w", err)
+	var (
+		previousImage imgutil.Image
+		err           error
+	)
+	for _, imageRef := range imageRefs {
+		previousImage, err = f.imageHandler.InitImage(imageRef)
+		if err != nil {
+			return nil, fmt.Errorf("getting previous image: %w", err)
+		}
+		if len(imageRefs) == 1 {
+			break
+		}
+		if previousImage.Found() {
+			logger.Infof("Using previous image %q", imageRef)
+			break
+		}
+		logger.Debugf("Previous image candidate %q not found", imageRef)
 	}
 	if launchCacheDir == "" || f.imageHandler.Kind() != image.LocalKind {
 		return previousImage, nil
@@ -76,7 +109,26 @@ func (f *ConnectedFactory) getPreviousImage(imageRef string, launchCacheDir stri
 	return cache.NewCachingImage(previousImage, volumeCache), nil
 }
 
-func (f *ConnectedFactory) getRunImage(imageRef string) (imgutil.Image, error) {
+// getBuildImageMixins returns the mixins in the label of the build image, which are the mixins required by the build.
+// If the build image is not found, it errors, as the required mixins cannot be determined.
+func (f *ConnectedFactory) getBuildImageMixins(imageRef string) ([]string, error) {
+	buildImage, err := f.imageHandler.InitImage(imageRef)
+	if err != nil {
+		return nil, fmt.Errorf("getting build image: %w", err)
+	}
+	if !buildImage.Found() {
+		return nil, fmt.Errorf("build image %q not found", imageRef)
+	}
+	var mixins []string
+	if err = image.DecodeLabel(buildImage, platform.MixinsLabel, &mixins); err != nil {
+		return nil, fmt.Errorf("getting build image mixins: %w", err)
+	}
+	return mixins, nil
+}
+
+// getRunImage returns the run image. If the pull policy is `never` and the run image is not in the daemon, it errors,
+// as the run image cannot be pulled.
+func (f *ConnectedFactory) getRunImage(imageRef, pullPolicy string) (imgutil.Image, error) {
 	if imageRef == "" {
 		return nil, nil
 	}
@@ -84,5 +136,8 @@ func (f *ConnectedFactory) getRunImage(imageRef string) (imgutil.Image, error) {
 	if err != nil {
 		return nil, fmt.Errorf("getting run image: %w", err)
 	}
+	if pullPolicy == string(image.PullNever) && f.imageHandler.Kind() == image.LocalKind && !runImage.Found() {
+		return nil, fmt.Errorf("run image %q is not in the daemon and the pull policy is %q", imageRef, pullPolicy)
+	}
 	return runImage, nil
 }
@@ -97,6 +97,50 @@ func testDetector(t *testing.T, when spec.G, it spec.S) {
 			h.AssertNotNil(t, detector.Runs)
 		})
 
+		when("there is a buildpack label selector", func() {
+			it("removes buildpacks without the label from the order", func() {
+				order := buildpack.Order{
+					buildpack.Group{Group: []buildpack.GroupElement{{ID: "A", Version: "v1"}, {ID: "B", Version: "v1"}}},
+					buildpack.Group{Group: []buildpack.GroupElement{{ID: "B", Version: "v1"}}},
+				}
+				configHandler.EXPECT().ReadOrder("some-order-path").Return(order, nil, nil)
+				bpA1 := &buildpack.BpDescriptor{WithAPI: "0.2", Metadata: map[string]interface{}{"experimental": false}}
+				bpB1 := &buildpack.BpDescriptor{WithAPI: "0.2", Metadata: map[string]interface{}{"experimental": true}}
+				dirStore.EXPECT().LookupBp("A", "v1").Return(bpA1, nil)
+				dirStore.EXPECT().LookupBp("B", "v1").Return(bpB1, nil).Times(2)
+				dirStore.EXPECT().Lookup(buildpack.KindBuildpack, "A", "v1").Return(bpA1, nil)
+				apiVerifier.EXPECT().VerifyBuildpackAPI(buildpack.KindBuildpack, "A@v1", "0.2", logger)
+
+				detector, err := detectorFactory.NewDetector(platform.LifecycleInputs{
+					AnalyzedPath:           "some-analyzed-path",
+					BuildpackLabelSelector: "experimental=false",
+					OrderPath:              "some-order-path",
+				}, logger)
+				h.AssertNil(t, err)
+
+				h.AssertEq(t, detector.Order, buildpack.Order{
+					buildpack.Group{Group: []buildpack.GroupElement{{ID: "A", Version: "v1"}}},
+				})
+			})
+
+			when("no buildpacks have the label", func() {
+				it("errors", func() {
+					order := buildpack.Order{
+						buildpack.Group{Group: []buildpack.GroupElement{{ID: "A", Version: "v1"}}},
+					}
+					configHandler.EXPECT().ReadOrder("some-order-path").Return(order, nil, nil)
+					dirStore.EXPECT().LookupBp("A", "v1").Return(&buildpack.BpDescriptor{WithAPI: "0.2"}, nil)
+
+					_, err := detectorFactory.NewDetector(platform.LifecycleInputs{
+						AnalyzedPath:           "some-analyzed-path",
+						BuildpackLabelSelector: "experimental=false",
+						OrderPath:              "some-order-path",
+					}, logger)
+					h.AssertError(t, err, `no buildpacks match label selector "experimental=false"`)
+				})
+			})
+		})
+
 		when("there are extensions", func() {
 			it("prepends the extensions order to the buildpacks order", func() {
 				orderBp := buildpack.Order{
@@ -26,14 +26,37 @@ import (
 	"github.com/buildpacks/lifecycle/platform/files"
 )
 
+// Cache stores layers and metadata between builds. Caches other than the built-in cache image and cache directory
+// may be provided with RegisterCacheBackend or by a cache helper (see NewCache).
+//
+// A cache has a committed state, which is read, and a staged state, which is written: layers and metadata are added to
+// the staged state and replace the committed state only when Commit is called, so that a failed build does not corrupt the cache.
+// Layers not added or reused before Commit are not in the committed state after it.
+// Layers are identified by their diffID, i.e., `sha256:<hex>` of their uncompressed tar.
+// Methods that read the committed state must be safe for concurrent use, as layers are restored in parallel.
+// After Commit, the methods that write the staged state must return an error.
 type Cache interface {
+	// Exists returns true if the cache has a committed state.
 	Exists() bool
+	// Name identifies the cache in logs, e.g., the cache image reference or the cache directory.
 	Name() string
+	// SetMetadata stages the metadata to commit.
 	SetMetadata(metadata platform.CacheMetadata) error
+	// RetrieveMetadata returns the committed metadata. If the cache has no metadata, or its metadata is corrupt,
+	// it returns empty metadata rather than an error, so that the build continues without the cache.
 	RetrieveMetadata() (platform.CacheMetadata, error)
+	// AddLayerFile stages the uncompressed layer tar at the provided path, which has the provided diffID.
+	// The file remains in place until Commit returns.
 	AddLayerFile(tarPath string, sha string) error
+	// ReuseLayer stages the committed layer with the provided diffID, without re-uploading or copying its contents where possible.
+	// It returns an error if the layer is not in the committed state.
 	ReuseLayer(sha string) error
+	// RetrieveLayer returns the uncompressed tar of the committed layer with the provided diffID. The caller closes the reader.
 	RetrieveLayer(sha string) (io.ReadCloser, error)
+	// VerifyIntegrity returns an error if the committed layer with the provided diffID is missing,
+	// or if its contents do not match the diffID.
+	VerifyIntegrity(sha string) error
+	// Commit replaces the committed state with the staged state.
 	Commit() error
 }
 
@@ -42,6 +65,9 @@ type Exporter struct {
 	LayerFactory LayerFactory
 	Logger       log.Logger
 	PlatformAPI  *api.Version
+	// PruneCache, if true, causes layers that are no longer referenced by the cache metadata to be removed from the cache
+	// after it is committed, for caches that support pruning.
+	PruneCache bool
 }
 
 // LayerFactory given a directory on the local filesystem will return a `layers.Layer`
@@ -83,8 +109,28 @@ type ExportOptions struct {
 	RunImageRef string
 	// RunImageForExport is run image metadata for the layer metadata label for Platform API >= 0.12.
 	RunImageForExport files.RunImageForExport
+	// NoRunImage if true indicates that WorkingImage has an empty base (`FROM scratch`),
+	// so that no run image top layer is recorded in the layer metadata label.
+	NoRunImage bool
 	// Project is project metadata for the project metadata label.
 	Project files.ProjectMetadata
+	// SBOMOutputDir is the directory to copy SBOM files to after the image is saved, for Platform API >= 0.8.
+	// If empty, SBOM files are not copied.
+	SBOMOutputDir string
+	// Destinations are additional destinations to save the image to, besides WorkingImage.Name() and AdditionalNames.
+	// The image is assembled once and saved to each destination in turn.
+	Destinations []platform.ExportDestination
+}
+
+// DestinationsError is returned by Export when the image was saved, but could not be saved to some of the additional destinations.
+// The returned report records the outcome for each destination.
+type DestinationsError struct {
+	Failed int
+	Total  int
+}
+
+func (e *DestinationsError) Error() string {
+	return fmt.Sprintf("failed to save image to %d of %d additional destination(s)", e.Failed, e.Total)
 }
 
 func (e *Exporter) Export(opts ExportOptions) (files.Report, error) {
@@ -108,9 +154,11 @@ func (e *Exporter) Export(opts ExportOptions) (files.Report, error) {
 	}
 
 	meta := files.LayersMetadata{}
-	meta.RunImage.TopLayer, err = opts.WorkingImage.TopLayer()
-	if err != nil {
-		return files.Report{}, errors.Wrap(err, "get run image top layer SHA")
+	if !opts.NoRunImage {
+		meta.RunImage.TopLayer, err = opts.WorkingImage.TopLayer()
+		if err != nil {
+			return files.Report{}, errors.Wrap(err, "get run image top layer SHA")
+		}
 	}
 	meta.RunImage.Reference = opts.RunImageRef
 
@@ -187,9 +235,45 @@ func (e *Exporter) Export(opts ExportOptions) (files.Report, error) {
 	if err != nil {
 		return files.Report{}, err
 	}
+
+	if e.PlatformAPI.AtLeast("0.8") && opts.SBOMOutputDir != "" {
+		if err = e.copySBOMsToOutputDir(opts.LayersDir, opts.SBOMOutputDir); err != nil {
+			return files.Report{}, errors.Wrap(err, "copying SBOMs to output directory")
+		}
+	}
+
+	if len(opts.Destinations) > 0 {
+		report.Destinations, err = e.saveToDestinations(opts)
+		if err != nil {
+			return report, err
+		}
+	}
 	return report, nil
 }
 
+// saveToDestinations saves the working image to each of the additional destinations, continuing past failures
+// so that the outcome for every destination is reported.
+func (e *Exporter) saveToDestinations(opts ExportOptions) ([]files.DestinationReport, error) {
+	var (
+		reports []files.DestinationReport
+		failed  int
+	)
+	for _, destination := range opts.Destinations {
+		imageReport, err := saveImageAs(opts.WorkingImage, destination.ImageRef, destination.AdditionalTags, e.Logger)
+		report := files.DestinationReport{Image: imageReport}
+		if err != nil {
+			e.Logger.Errorf("Failed to save image to destination %q: %s", destination.ImageRef, err)
+			report.Error = err.Error()
+			failed++
+		}
+		reports = append(reports, report)
+	}
+	if failed > 0 {
+		return reports, &DestinationsError{Failed: failed, Total: len(opts.Destinations)}
+	}
+	return reports, nil
+}
+
 func SBOMExtensions() []string {
 	return []string{buildpack.ExtensionCycloneDX, buildpack.ExtensionSPDX, buildpack.ExtensionSyft}
 }
@@ -211,6 +295,53 @@ func (e *Exporter) copyBuildpacksioSBOMs(opts ExportOptions) error {
 	}
 }
 
+// copySBOMsToOutputDir copies the SBOM files of each type in the layers directory to outputDir,
+// grouped by buildpack, e.g., `<layers>/sbom/launch/<buildpack-id>/<layer>/sbom.cdx.json`
+// is copied to `<output>/<buildpack-id>/launch/<layer>/sbom.cdx.json`.
+func (e *Exporter) copySBOMsToOutputDir(layersDir, outputDir string) error {
+	for _, bomType := range []string{"build", "cache", "launch"} {
+		typeDir := filepath.Join(layersDir, "sbom", bomType)
+		if _, err := os.Stat(typeDir); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.WalkDir(typeDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() || !hasSBOMExtension(d.Name()) {
+				return nil
+			}
+			relPath, err := filepath.Rel(typeDir, path)
+			if err != nil {
+				return err
+			}
+			bpID, rest, ok := strings.Cut(relPath, string(filepath.Separator))
+			if !ok {
+				return nil // not provided by a buildpack
+			}
+			dstPath := filepath.Join(outputDir, bpID, bomType, rest)
+			if err = os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+				return err
+			}
+			e.Logger.Debugf("Copying SBOM %s to %s", path, dstPath)
+			return fsutil.Copy(path, dstPath)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasSBOMExtension(filename string) bool {
+	for _, extension := range SBOMExtensions() {
+		if strings.HasSuffix(filename, extension) {
+			return true
+		}
+	}
+	return false
+}
+
 func (e *Exporter) copyLauncherSBOMs(srcDir string, dstDir string) error {
 	sboms, err := fsutil.FilesWithExtensions(srcDir, SBOMExtensions())
 	if err != nil {
@@ -2,6 +2,7 @@ package phase_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -27,6 +28,7 @@ import (
 	"github.com/buildpacks/lifecycle/layers"
 	"github.com/buildpacks/lifecycle/phase"
 	"github.com/buildpacks/lifecycle/phase/testmock"
+	"github.com/buildpacks/lifecycle/platform"
 	"github.com/buildpacks/lifecycle/platform/files"
 	h "github.com/buildpacks/lifecycle/testhelpers"
 )
@@ -1435,6 +1437,78 @@ version = "4.5.6"
 				})
 			})
 		})
+
+		when("additional destinations are provided", func() {
+			it.Before(func() {
+				h.RecursiveCopy(t, filepath.Join("testdata", "exporter", "empty-metadata", "layers"), opts.LayersDir)
+				opts.Destinations = []platform.ExportDestination{
+					{ImageRef: "other-registry.io/app-image", AdditionalTags: []string{"other-registry.io/app-image:foo"}},
+					{ImageRef: "another-registry.io/app-image"},
+				}
+			})
+
+			it("saves the image to each destination", func() {
+				report, err := exporter.Export(opts)
+				h.AssertNil(t, err)
+
+				for _, name := range []string{"some-repo/app-image", "other-registry.io/app-image", "other-registry.io/app-image:foo", "another-registry.io/app-image"} {
+					h.AssertContains(t, fakeAppImage.SavedNames(), name)
+				}
+				h.AssertEq(t, len(report.Destinations), 2)
+				h.AssertEq(t, report.Destinations[0].Image.Tags, []string{"other-registry.io/app-image", "other-registry.io/app-image:foo"})
+				h.AssertEq(t, report.Destinations[0].Image.ImageID, "some-image-id")
+				h.AssertEq(t, report.Destinations[1].Image.Tags, []string{"another-registry.io/app-image"})
+			})
+
+			when("saving to a destination fails", func() {
+				it.Before(func() {
+					opts.Destinations[0].AdditionalTags = []string{"other-registry.io/app-image:bad!tag"}
+				})
+
+				it("saves the image to the other destinations and reports the failure", func() {
+					report, err := exporter.Export(opts)
+					var destinationsErr *phase.DestinationsError
+					h.AssertEq(t, errors.As(err, &destinationsErr), true)
+					h.AssertError(t, err, "failed to save image to 1 of 2 additional destination(s)")
+
+					h.AssertContains(t, fakeAppImage.SavedNames(), "another-registry.io/app-image")
+					h.AssertEq(t, report.Image.Tags, []string{"some-repo/app-image", "some-repo/app-image:foo", "some-repo/app-image:bar"})
+					h.AssertEq(t, report.Destinations[0].Image.Tags, []string{"other-registry.io/app-image"})
+					h.AssertStringContains(t, report.Destinations[0].Error, "other-registry.io/app-image:bad!tag")
+					h.AssertEq(t, report.Destinations[1].Error, "")
+				})
+			})
+		})
+
+		when("an SBOM output directory is provided", func() {
+			it.Before(func() {
+				h.RecursiveCopy(t, filepath.Join("testdata", "exporter", "sbom-output", "layers"), opts.LayersDir)
+				opts.SBOMOutputDir = filepath.Join(tmpDir, "sbom-output")
+			})
+
+			it("copies SBOMs to the output directory grouped by buildpack", func() {
+				_, err := exporter.Export(opts)
+				h.AssertNil(t, err)
+
+				h.AssertEq(t, h.MustReadFile(t, filepath.Join(opts.SBOMOutputDir, "buildpack.id", "launch", "launch-layer", "sbom.cdx.json")), []byte(`{"key": "some-launch-bom-content"}`+"\n"))
+				h.AssertEq(t, h.MustReadFile(t, filepath.Join(opts.SBOMOutputDir, "buildpack.id", "cache", "cache-layer", "sbom.spdx.json")), []byte(`{"key": "some-cache-bom-content"}`+"\n"))
+				h.AssertPathExists(t, filepath.Join(opts.SBOMOutputDir, "other.buildpack.id", "build", "sbom.syft.json"))
+				h.AssertPathDoesNotExist(t, filepath.Join(opts.SBOMOutputDir, "buildpack.id", "launch", "launch-layer", "some-file.txt"))
+			})
+
+			when("platform api < 0.8", func() {
+				it.Before(func() {
+					exporter.PlatformAPI = api.MustParse("0.7")
+				})
+
+				it("does not copy SBOMs", func() {
+					_, err := exporter.Export(opts)
+					h.AssertNil(t, err)
+
+					h.AssertPathDoesNotExist(t, opts.SBOMOutputDir)
+				})
+			})
+		})
 	})
 }
 
@@ -1,6 +1,7 @@
 package phase_test
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -85,6 +86,23 @@ group = [{id = "D", version = "v1"}]
 			h.AssertEq(t, group.Group, expectedGroupBp)
 			h.AssertEq(t, group.GroupExtensions, expectedGroupExt)
 		})
+
+		when("the group is malformed", func() {
+			it("errors with the path, line, and column", func() {
+				path := filepath.Join(tmpDir, "group.toml")
+				h.Mkfile(t, "[[group]]\nid = \"A\"\nversion = \"v1\n", path)
+				_, err := files.Handler.ReadGroup(path)
+				h.AssertNotNil(t, err)
+
+				var tomlErr *files.TOMLError
+				h.AssertEq(t, errors.As(err, &tomlErr), true)
+				h.AssertEq(t, tomlErr.Path, path)
+				h.AssertEq(t, tomlErr.Line, 3)
+				h.AssertEq(t, tomlErr.Column, 14)
+				h.AssertEq(t, tomlErr.LastKey, "group.version")
+				h.AssertError(t, err, "failed to read group file: parsing \""+path+"\" at line 3, column 14 (last key \"group.version\"): strings cannot contain newlines")
+			})
+		})
 	})
 
 	when("#ReadOrder", func() {
@@ -95,6 +113,33 @@ group = [{id = "D", version = "v1"}]
 			h.AssertEq(t, foundOrder, expectedOrderBp)
 			h.AssertEq(t, foundOrderExt, expectedOrderExt)
 		})
+
+		when("the order is malformed", func() {
+			it("errors with the path, line, and column", func() {
+				path := filepath.Join(tmpDir, "order.toml")
+				h.Mkfile(t, "[[order]]\ngroup = [{id = \"A\", version = \"v1\"}\n\n[[order]]\n", path)
+				_, _, err := files.Handler.ReadOrder(path)
+				h.AssertNotNil(t, err)
+
+				var tomlErr *files.TOMLError
+				h.AssertEq(t, errors.As(err, &tomlErr), true)
+				h.AssertEq(t, tomlErr.Path, path)
+				h.AssertEq(t, tomlErr.Line, 4)
+				h.AssertEq(t, tomlErr.Column, 1)
+				h.AssertStringContains(t, err.Error(), "failed to read order file: parsing \""+path+"\" at line 4, column 1 (last key \"order.group\"): ")
+			})
+		})
+
+		when("the order does not match the schema", func() {
+			it("errors with the path", func() {
+				path := filepath.Join(tmpDir, "order.toml")
+				h.Mkfile(t, "[[order]]\ngroup = [{id = 1}]\n", path)
+				_, _, err := files.Handler.ReadOrder(path)
+				h.AssertNotNil(t, err)
+				h.AssertStringContains(t, err.Error(), "failed to read order file: parsing \""+path+"\": ")
+				h.AssertStringContains(t, err.Error(), "line 2")
+			})
+		})
 	})
 
 	when("DefaultConfigHandler", func() {
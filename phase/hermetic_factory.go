@@ -44,12 +44,15 @@ func (f *HermeticFactory) getExtensions(groupPath string, logger log.Logger) ([]
 	return group.GroupExtensions, nil
 }
 
-func (f *HermeticFactory) getOrder(path string, logger log.Logger) (order buildpack.Order, hasExtensions bool, err error) {
+func (f *HermeticFactory) getOrder(path, labelSelector string, logger log.Logger) (order buildpack.Order, hasExtensions bool, err error) {
 	orderBp, orderExt, orderErr := f.configHandler.ReadOrder(path)
 	if orderErr != nil {
 		err = errors.Wrap(orderErr, "reading order")
 		return
 	}
+	if orderBp, err = f.filterOrder(orderBp, labelSelector, logger); err != nil {
+		return
+	}
 	if len(orderExt) > 0 {
 		hasExtensions = true
 	}
@@ -60,6 +63,39 @@ func (f *HermeticFactory) getOrder(path string, logger log.Logger) (order buildp
 	return
 }
 
+// filterOrder removes buildpacks that do not carry the label selected by the provided selector from each group of the order,
+// and removes groups that are left empty. Extensions and buildpacks that cannot be found are kept.
+// If the selector is empty, the order is returned unchanged.
+func (f *HermeticFactory) filterOrder(order buildpack.Order, labelSelector string, logger log.Logger) (buildpack.Order, error) {
+	if labelSelector == "" {
+		return order, nil
+	}
+	selector, err := buildpack.ParseLabelSelector(labelSelector)
+	if err != nil {
+		return nil, err
+	}
+	var filtered buildpack.Order
+	for _, group := range order {
+		var groupEls []buildpack.GroupElement
+		for _, groupEl := range group.Group {
+			if !groupEl.Extension {
+				if descriptor, err := f.dirStore.LookupBp(groupEl.ID, groupEl.Version); err == nil && !selector.Matches(descriptor) {
+					logger.Debugf("Excluding buildpack %s, does not match label selector %q", groupEl.String(), selector)
+					continue
+				}
+			}
+			groupEls = append(groupEls, groupEl)
+		}
+		if len(groupEls) > 0 {
+			filtered = append(filtered, buildpack.Group{Group: groupEls, GroupExtensions: group.GroupExtensions})
+		}
+	}
+	if len(order) > 0 && len(filtered) == 0 {
+		return nil, fmt.Errorf("no buildpacks match label selector %q", selector)
+	}
+	return filtered, nil
+}
+
 func (f *HermeticFactory) verifyGroup(group []buildpack.GroupElement, logger log.Logger) error {
 	for _, groupEl := range group {
 		if err := f.apiVerifier.VerifyBuildpackAPI(groupEl.Kind(), groupEl.String(), groupEl.API, logger); err != nil {
@@ -3,7 +3,6 @@ package phase
 import (
 	"encoding/json"
 	"fmt"
-	"sort"
 	"strings"
 
 	"github.com/buildpacks/imgutil"
@@ -12,7 +11,6 @@ import (
 	"github.com/buildpacks/lifecycle/api"
 	"github.com/buildpacks/lifecycle/image"
 	"github.com/buildpacks/lifecycle/internal/encoding"
-	"github.com/buildpacks/lifecycle/internal/str"
 	"github.com/buildpacks/lifecycle/log"
 	"github.com/buildpacks/lifecycle/platform"
 	"github.com/buildpacks/lifecycle/platform/files"
@@ -40,7 +38,7 @@ func (r *Rebaser) Rebase(workingImage imgutil.Image, newBaseImage imgutil.Image,
 	}
 	// perform platform API-specific validations
 	if appPlatformAPI == "" || api.MustParse(appPlatformAPI).LessThan("0.12") {
-		if err = validateStackID(workingImage, newBaseImage); err != nil {
+		if err = r.validateStackID(workingImage, newBaseImage); err != nil {
 			return files.RebaseReport{}, err
 		}
 		if err = validateMixins(workingImage, newBaseImage); err != nil {
@@ -145,7 +143,7 @@ func containsName(origMetadata files.LayersMetadataCompat, newBaseName string) b
 	return origMetadata.Stack.RunImage.Contains(newBaseName)
 }
 
-func validateStackID(appImg, newBaseImage imgutil.Image) error {
+func (r *Rebaser) validateStackID(appImg, newBaseImage imgutil.Image) error {
 	appStackID, err := appImg.Label(platform.StackIDLabel)
 	if err != nil {
 		return fmt.Errorf("get app image stack: %w", err)
@@ -156,6 +154,12 @@ func validateStackID(appImg, newBaseImage imgutil.Image) error {
 		return fmt.Errorf("get new base image stack: %w", err)
 	}
 
+	if appStackID == "" && newBaseStackID == "" && r.Force {
+		// images that only carry target information may be rebased when forced
+		r.Logger.Warn("Skipping stack validation: stack not defined on app image or new base image")
+		return nil
+	}
+
 	if appStackID == "" {
 		return errors.New("stack not defined on app image")
 	}
@@ -182,13 +186,7 @@ func validateMixins(appImg, newBaseImg imgutil.Image) error {
 		return fmt.Errorf("get run image mixins: %w", err)
 	}
 
-	appImageMixins = removeStagePrefixes(appImageMixins)
-	newBaseImageMixins = removeStagePrefixes(newBaseImageMixins)
-
-	_, missing, _ := str.Compare(newBaseImageMixins, appImageMixins)
-
-	if len(missing) > 0 {
-		sort.Strings(missing)
+	if missing := missingMixins(appImageMixins, newBaseImageMixins); len(missing) > 0 {
 		return fmt.Errorf("missing required mixin(s): %s", strings.Join(missing, ", "))
 	}
 
@@ -686,6 +686,27 @@ func testRebaser(t *testing.T, when spec.G, it spec.S) {
 						_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
 						h.AssertError(t, err, "stack not defined on app image")
 					})
+
+					when("neither image has a stack defined", func() {
+						it.Before(func() {
+							h.AssertNil(t, fakeAppImage.SetLabel(platform.StackIDLabel, ""))
+							h.AssertNil(t, fakeNewBaseImage.SetLabel(platform.StackIDLabel, ""))
+						})
+
+						it("errors", func() {
+							_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+							h.AssertError(t, err, "stack not defined on app image")
+						})
+
+						when("force is true", func() {
+							it("skips stack validation with a warning", func() {
+								rebaser.Force = true
+								_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+								h.AssertNil(t, err)
+								assertLogEntry(t, logHandler, "Skipping stack validation")
+							})
+						})
+					})
 				})
 			})
 
@@ -736,6 +757,27 @@ func testRebaser(t *testing.T, when spec.G, it spec.S) {
 						_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
 						h.AssertError(t, err, "stack not defined on app image")
 					})
+
+					when("neither image has a stack defined", func() {
+						it.Before(func() {
+							h.AssertNil(t, fakeAppImage.SetLabel(platform.StackIDLabel, ""))
+							h.AssertNil(t, fakeNewBaseImage.SetLabel(platform.StackIDLabel, ""))
+						})
+
+						it("errors", func() {
+							_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+							h.AssertError(t, err, "stack not defined on app image")
+						})
+
+						when("force is true", func() {
+							it("skips stack validation with a warning", func() {
+								rebaser.Force = true
+								_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+								h.AssertNil(t, err)
+								assertLogEntry(t, logHandler, "Skipping stack validation")
+							})
+						})
+					})
 				})
 			})
 
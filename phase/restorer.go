@@ -1,13 +1,27 @@
 package phase
 
 import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/buildpacks/lifecycle/api"
 	"github.com/buildpacks/lifecycle/buildpack"
+	lifecyclecache "github.com/buildpacks/lifecycle/cache"
+	"github.com/buildpacks/lifecycle/internal/fsutil"
 	"github.com/buildpacks/lifecycle/internal/layer"
 	"github.com/buildpacks/lifecycle/layers"
 	"github.com/buildpacks/lifecycle/log"
@@ -24,25 +38,280 @@ type Restorer struct {
 	LayersMetadata        files.LayersMetadata
 	PlatformAPI           *api.Version
 	SBOMRestorer          layer.SBOMRestorer
+
+	// Layout determines where the layers of each buildpack are found within LayersDir; if nil, the default
+	// `<layers>/<escaped buildpack ID>` layout is used. The LayerMetadataRestorer should be configured with the same layout.
+	// Note that layer data is extracted to the paths recorded when the layer was cached, and data outside the layer directory is rejected,
+	// so the layout should not change between builds.
+	Layout buildpack.LayersDirLayout
+
+	// OverlayUpperDir, if set, is a writable overlay upper directory to which cache layer data is restored,
+	// with the filesystem acting as the read-only lower directory.
+	OverlayUpperDir string
+	// AtomicRestore, if true, causes each cache layer to be extracted into a staging directory beneath the layers directory
+	// and renamed into place, so that an interrupted restore does not leave a partially written layer.
+	AtomicRestore bool
+	// SBOMOnly, if true, causes only SBOM data to be restored; layer metadata and cache layers are left untouched.
+	SBOMOnly bool
+	// SkipSBOM, if true, causes SBOM data not to be restored, regardless of the Platform API;
+	// the SBOM directories in LayersDir are left untouched.
+	SkipSBOM bool
+	// MetadataOnly, if true, causes only layer metadata to be restored; data for cache=true layers and the cached SBOM layer
+	// is not restored, as if every layer matched a skip restore pattern, so that buildpacks re-create the layers.
+	MetadataOnly bool
+	// SkipRestorePatterns are glob patterns matched against layer identifiers of the form `<buildpack-id>:<layer-name>`
+	// using the syntax of path.Match; note that `*` does not match `/` in buildpack IDs.
+	// Data for matching layers is not restored, though their metadata is, so that buildpacks may re-create them.
+	SkipRestorePatterns []string
+	// RestoreBuildpacks, if provided, are the IDs of the buildpacks whose layer metadata and layers are restored;
+	// IDs may be escaped as for the buildpacks' layers directories. The layers directories of other buildpacks in the group
+	// are left untouched, e.g., so that only the buildpack being iterated on is restored when rebuilding locally.
+	RestoreBuildpacks []string
+	// FindBuildpacksWithoutLayers, if true, causes buildpacks with no layers on disk or in the cache after restoring
+	// to be recorded in the summary; this is informational and does not affect what is restored.
+	FindBuildpacksWithoutLayers bool
+	// DedupRestore, if true, causes cache layers with the same sha to be retrieved from the cache only once;
+	// other layers with the sha are hard-linked (or copied, if linking fails) from the first restored layer.
+	// Buildpacks that modify restored files in place may affect each other's layers when this is enabled.
+	DedupRestore bool
+	// LayerRestoreTimeout, if greater than zero, is the maximum time to spend retrieving and extracting a single cache layer.
+	// A layer that takes longer is aborted and removed, so that the buildpack re-creates it.
+	LayerRestoreTimeout time.Duration
+	// MaxLayerSize, if greater than zero, is the maximum number of bytes of uncompressed data to restore for a single cache layer,
+	// so that a corrupt or malicious cache layer cannot fill the disk. Extracting a larger layer is aborted, the layer is removed,
+	// and ErrLayerTooLarge is returned (or recorded in the summary, if BestEffort is true).
+	MaxLayerSize int64
+	// BestEffort, if true, causes a cache layer that cannot be retrieved or extracted to be removed, so that the buildpack re-creates it,
+	// instead of failing the restore; the errors are recorded in the summary. Restoring still fails if the layer cannot be removed.
+	BestEffort bool
+	// SlowLayerThreshold, if greater than zero, is the time after which retrieving and extracting a single cache layer
+	// is logged as a warning, to help identify slow cache backends.
+	SlowLayerThreshold time.Duration
+	// ProgressInterval, if greater than zero, is the number of bytes after which progress restoring a cache layer is logged at debug level,
+	// so that restoring very large layers does not appear to hang.
+	ProgressInterval int64
+	// DryRun, if true, causes the plan for each cache=true layer to be logged instead of carried out;
+	// the layers directory is not modified, and SBOM data is not restored even if SBOMOnly is set.
+	// The LayerMetadataRestorer should also be configured not to write metadata.
+	DryRun bool
+	// PruneCache, if true, causes layers that are not referenced by the cache metadata to be removed from the first provided cache
+	// after restoring, for caches that support pruning. Other caches are only read from.
+	PruneCache bool
+}
+
+// DefaultProgressInterval is the default interval at which progress restoring a cache layer is logged.
+const DefaultProgressInterval = 100 * 1024 * 1024
+
+var (
+	// ErrCacheUnavailable is matched by errors returned by the restorer when the cache metadata or a cache layer cannot be retrieved.
+	ErrCacheUnavailable = errors.New("cache unavailable")
+	// ErrLayerCorrupt is matched by errors returned by the restorer when the data of a cache layer cannot be extracted.
+	ErrLayerCorrupt = errors.New("cache layer corrupt")
+	// ErrLayerTooLarge is matched by errors returned by the restorer when the data of a cache layer exceeds the maximum layer size.
+	ErrLayerTooLarge = errors.New("cache layer exceeds the maximum layer size")
+	// errLayerDigestMismatch is matched by errors returned when the data of a cache layer does not match its sha,
+	// e.g., because the layer was truncated; such layers are treated like cache misses.
+	errLayerDigestMismatch = errors.New("cache layer digest mismatch")
+)
+
+// restoreError associates an error with the kind of failure (ErrCacheUnavailable or ErrLayerCorrupt),
+// so that callers can check for the kind with errors.Is without changing the message of the error.
+type restoreError struct {
+	kind error
+	err  error
+}
+
+func (e *restoreError) Error() string {
+	return e.err.Error()
+}
+
+func (e *restoreError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// sharedLayer tracks the restore of a cache layer whose sha may be shared by other layers.
+type sharedLayer struct {
+	identifier string
+	path       string
+	done       chan struct{}
+	restored   bool // only valid once done is closed
+}
+
+// RestoreSummary counts the outcomes of restoring cache=true layers.
+type RestoreSummary struct {
+	Restored          int
+	Skipped           int
+	RemovedNotInCache int
+	RemovedWrongSHA   int
+	RemovedCorrupt    int
+	RemovedTimedOut   int
+	BytesRestored     int64
+	// RemovedFailed is only populated if BestEffort is true.
+	RemovedFailed int
+	// LayerErrors records why each layer counted in RemovedFailed could not be restored, sorted by layer identifier.
+	LayerErrors []LayerError
+	// LayerTimings records the time spent retrieving and extracting each cache layer, sorted by layer identifier.
+	LayerTimings []LayerTiming
+	// RestoreDuration is the total time spent retrieving and extracting cache layers, across all layers.
+	RestoreDuration time.Duration
+	// BuildpacksWithoutLayers is only populated if FindBuildpacksWithoutLayers is true.
+	BuildpacksWithoutLayers []string
+	// Buildpacks counts the outcomes for each restored buildpack, in group order.
+	Buildpacks []BuildpackRestoreSummary
+}
+
+// BuildpackRestoreSummary counts the outcomes of restoring the cache=true layers of a single buildpack:
+// layers restored from the cache (hits), removed as not in the cache (misses), and removed as having the wrong sha (invalidations).
+type BuildpackRestoreSummary struct {
+	ID                string
+	Restored          int
+	RemovedNotInCache int
+	RemovedWrongSHA   int
+}
+
+// RestorePlan is what restoring does with each cache=true layer found in the layers directory, decided before any layer is modified.
+type RestorePlan struct {
+	// Buildpacks are the buildpacks whose layers are restored.
+	Buildpacks []buildpack.GroupElement
+	Layers     []PlannedLayer
+}
+
+// LayerAction is what restoring does with a single cache=true layer.
+type LayerAction string
+
+const (
+	// LayerActionRemoveNotInCache removes a layer that is not in the cache metadata.
+	LayerActionRemoveNotInCache LayerAction = "remove-not-in-cache"
+	// LayerActionRemoveWrongSHA removes a layer whose sha does not match the cache metadata.
+	LayerActionRemoveWrongSHA LayerAction = "remove-wrong-sha"
+	// LayerActionSkip keeps the layer metadata without restoring layer data.
+	LayerActionSkip LayerAction = "skip"
+	// LayerActionRestore restores layer data from the cache.
+	LayerActionRestore LayerAction = "restore"
+	// LayerActionRestoreSameSHA restores layer data from the first restored layer with the same sha.
+	LayerActionRestoreSameSHA LayerAction = "restore-same-sha"
+)
+
+// PlannedLayer is the action planned for a single cache=true layer.
+type PlannedLayer struct {
+	Layer  buildpack.Layer
+	Action LayerAction
+	// BuildpackID is the ID of the buildpack that created the layer.
+	BuildpackID string
+	// SHA is the sha of the layer in the cache metadata.
+	SHA string
+	// LayerSHA is the sha of the layer in the layers directory; it is only set for LayerActionRemoveWrongSHA.
+	LayerSHA string
+	// Reason explains why the layer is skipped; it is only set for LayerActionSkip.
+	Reason string
+	// SameSHAAs is the identifier of the layer to restore from; it is only set for LayerActionRestoreSameSHA.
+	SameSHAAs string
+}
+
+// LayerError is the error restoring a single cache layer.
+type LayerError struct {
+	Identifier string
+	SHA        string
+	Err        error
+}
+
+// LayerTiming is the time spent retrieving and extracting a single cache layer.
+type LayerTiming struct {
+	Identifier string
+	SHA        string
+	Duration   time.Duration
 }
 
 // Restore restores metadata for launch and cache layers into the layers directory and attempts to restore layer data for cache=true layers, removing the layer when unsuccessful.
 // If a usable cache is not provided, Restore will not restore any cache=true layer metadata.
-func (r *Restorer) Restore(cache Cache) error {
+// When several caches are provided they are tried in order: metadata for a buildpack is taken from the first cache that has it,
+// and layer data is restored from the first cache that has the layer.
+func (r *Restorer) Restore(caches ...Cache) (RestoreSummary, error) {
+	return r.RestoreContext(context.Background(), caches...)
+}
+
+// RestoreContext is like Restore, but restoring layer data is aborted when the provided context is done,
+// e.g., so that platforms can enforce a timeout for the phase. Layers that were being restored are removed,
+// and the error of the context is returned.
+func (r *Restorer) RestoreContext(ctx context.Context, caches ...Cache) (RestoreSummary, error) {
 	defer log.NewMeasurement("Restorer", r.Logger)()
+	cache := newCacheChain(caches...)
+	var summary RestoreSummary
+	for _, pattern := range r.SkipRestorePatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return summary, errors.Wrapf(err, "invalid skip restore pattern %q", pattern)
+		}
+	}
 	cacheMeta, err := retrieveCacheMetadata(cache, r.Logger)
 	if err != nil {
-		return err
+		return summary, err
+	}
+	buildpacks := r.buildpacksToRestore()
+
+	if r.SBOMOnly {
+		if r.DryRun {
+			r.Logger.Info("Dry run: would restore SBOM data only, the layers directory was not modified")
+			return summary, nil
+		}
+		r.Logger.Debug("Restoring SBOM data only")
+		if err := r.restoreSBOM(cache, cacheMeta, buildpacks); err != nil {
+			return summary, errors.Wrap(err, "restoring data")
+		}
+		return summary, nil
 	}
 
 	layerSHAStore := layer.NewSHAStore()
 	r.Logger.Debug("Restoring Layer Metadata")
-	if err := r.LayerMetadataRestorer.Restore(r.Buildpacks, r.LayersMetadata, cacheMeta, layerSHAStore); err != nil {
-		return err
+	if err := r.LayerMetadataRestorer.Restore(buildpacks, r.LayersMetadata, cacheMeta, layerSHAStore); err != nil {
+		return summary, err
 	}
 
-	var g errgroup.Group
-	for _, bp := range r.Buildpacks {
+	plan, err := r.plan(buildpacks, cacheMeta, layerSHAStore)
+	if err != nil {
+		return summary, err
+	}
+	if r.DryRun {
+		r.logPlan(plan)
+		return summary, nil
+	}
+	if err = ctx.Err(); err != nil {
+		return summary, errors.Wrap(err, "restoring data")
+	}
+	summary, err = r.apply(ctx, plan, cache, cacheMeta)
+	if err != nil {
+		return summary, errors.Wrap(err, "restoring data")
+	}
+
+	if r.FindBuildpacksWithoutLayers {
+		if summary.BuildpacksWithoutLayers, err = r.buildpacksWithoutLayers(cacheMeta); err != nil {
+			return summary, err
+		}
+	}
+
+	r.Logger.Infof(
+		"Restored %d layer(s) (%d bytes), skipped %d layer(s), removed %d layer(s) not in cache, removed %d layer(s) with wrong sha, removed %d corrupt layer(s), removed %d layer(s) that timed out",
+		summary.Restored, summary.BytesRestored, summary.Skipped, summary.RemovedNotInCache, summary.RemovedWrongSHA, summary.RemovedCorrupt, summary.RemovedTimedOut,
+	)
+	if summary.RemovedFailed > 0 {
+		r.Logger.Warnf("Removed %d layer(s) that failed to restore", summary.RemovedFailed)
+	}
+	if len(summary.LayerTimings) > 0 {
+		r.Logger.Infof("Spent %s restoring data for %d layer(s) from cache", summary.RestoreDuration, len(summary.LayerTimings))
+	}
+	if r.PruneCache && len(caches) > 0 && caches[0] != nil {
+		pruneCache(caches[0], cacheMeta.LayerSHAs(), r.Logger)
+	}
+	return summary, nil
+}
+
+// plan decides what to do with each cache=true layer found in the layers directory, without modifying it.
+// In a dry run, layers whose metadata would have been restored are planned as if they were found.
+func (r *Restorer) plan(buildpacks []buildpack.GroupElement, cacheMeta platform.CacheMetadata, layerSHAStore layer.SHAStore) (RestorePlan, error) {
+	var (
+		plan      = RestorePlan{Buildpacks: buildpacks}
+		firstSHAs = make(map[string]string) // only populated if DedupRestore is true
+	)
+	for _, bp := range buildpacks {
 		cachedLayers := cacheMeta.MetadataForBuildpack(bp.ID).Layers
 
 		var cachedFn func(buildpack.Layer) bool
@@ -55,75 +324,576 @@ func (r *Restorer) Restore(cache Cache) error {
 		}
 
 		r.Logger.Debugf("Reading Buildpack Layers directory %s", r.LayersDir)
-		buildpackDir, err := buildpack.ReadLayersDir(r.LayersDir, bp, r.Logger)
+		buildpackDir, err := buildpack.ReadLayersDirWithLayout(r.LayersDir, bp, r.Layout, r.Logger)
 		if err != nil {
-			return errors.Wrapf(err, "reading buildpack layer directory")
+			return plan, errors.Wrapf(err, "reading buildpack layer directory")
 		}
 		foundLayers := buildpackDir.FindLayers(cachedFn)
+		if r.DryRun {
+			foundLayers, err = r.addLayersWithRestoredMetadata(foundLayers, &buildpackDir, cachedLayers, layerSHAStore)
+			if err != nil {
+				return plan, err
+			}
+		}
 
 		for _, bpLayer := range foundLayers {
 			cachedLayer, exists := cachedLayers[bpLayer.Name()]
 			if !exists {
 				// This should be unreachable, as "find layers" uses the same cache metadata as the map
-				r.Logger.Infof("Removing %q, not in cache", bpLayer.Identifier())
-				if err := bpLayer.Remove(); err != nil {
-					return errors.Wrapf(err, "removing layer")
-				}
+				plan.Layers = append(plan.Layers, PlannedLayer{Layer: bpLayer, Action: LayerActionRemoveNotInCache, BuildpackID: bp.ID})
 				continue
 			}
 
 			layerSha, err := layerSHAStore.Get(bp.ID, bpLayer)
 			if err != nil {
-				return err
+				return plan, err
 			}
 
+			planned := PlannedLayer{Layer: bpLayer, BuildpackID: bp.ID, SHA: cachedLayer.SHA}
 			if layerSha != cachedLayer.SHA {
-				r.Logger.Infof("Removing %q, wrong sha", bpLayer.Identifier())
-				r.Logger.Debugf("Layer sha: %q, cache sha: %q", layerSha, cachedLayer.SHA)
-				if err := bpLayer.Remove(); err != nil {
-					return errors.Wrapf(err, "removing layer")
-				}
+				planned.Action, planned.LayerSHA = LayerActionRemoveWrongSHA, layerSha
+			} else if r.MetadataOnly {
+				planned.Action, planned.Reason = LayerActionSkip, "restoring metadata only"
+			} else if r.skipRestore(bpLayer.Identifier()) {
+				planned.Action, planned.Reason = LayerActionSkip, "matches skip restore pattern"
+			} else if first, ok := firstSHAs[cachedLayer.SHA]; ok {
+				planned.Action, planned.SameSHAAs = LayerActionRestoreSameSHA, first
 			} else {
-				r.Logger.Infof("Restoring data for %q from cache", bpLayer.Identifier())
-				g.Go(func() error {
-					return r.restoreCacheLayer(cache, cachedLayer.SHA)
-				})
+				planned.Action = LayerActionRestore
+				if r.DedupRestore {
+					firstSHAs[cachedLayer.SHA] = bpLayer.Identifier()
+				}
 			}
+			plan.Layers = append(plan.Layers, planned)
 		}
 	}
+	return plan, nil
+}
+
+// addLayersWithRestoredMetadata returns the found layers along with the cache=true layers whose metadata would have been restored,
+// which are identified by having a sha in the SHA store, sorted by identifier.
+func (r *Restorer) addLayersWithRestoredMetadata(
+	foundLayers []buildpack.Layer,
+	buildpackDir *buildpack.LayersDir,
+	cachedLayers map[string]buildpack.LayerMetadata,
+	layerSHAStore layer.SHAStore,
+) ([]buildpack.Layer, error) {
+	found := make(map[string]bool)
+	for _, bpLayer := range foundLayers {
+		found[bpLayer.Name()] = true
+	}
+	for name, cachedLayer := range cachedLayers {
+		if found[name] || !cachedLayer.Cache {
+			continue
+		}
+		bpLayer := buildpackDir.NewLayer(name, buildpackDir.Buildpack.API, r.Logger)
+		sha, err := layerSHAStore.Get(buildpackDir.Buildpack.ID, *bpLayer)
+		if err != nil {
+			return nil, err
+		}
+		if sha != "" {
+			foundLayers = append(foundLayers, *bpLayer)
+		}
+	}
+	sort.Slice(foundLayers, func(i, j int) bool {
+		return foundLayers[i].Identifier() < foundLayers[j].Identifier()
+	})
+	return foundLayers, nil
+}
 
-	if r.PlatformAPI.AtLeast("0.8") {
-		g.Go(func() error {
-			if cacheMeta.BOM.SHA != "" {
-				r.Logger.Infof("Restoring data for SBOM from cache")
-				if err := r.SBOMRestorer.RestoreFromCache(cache, cacheMeta.BOM.SHA); err != nil {
-					return err
+// logPlan logs what restoring would do with each cache=true layer.
+func (r *Restorer) logPlan(plan RestorePlan) {
+	for _, planned := range plan.Layers {
+		switch planned.Action {
+		case LayerActionRemoveNotInCache:
+			r.Logger.Infof("Would remove %q, not in cache", planned.Layer.Identifier())
+		case LayerActionRemoveWrongSHA:
+			r.Logger.Infof("Would remove %q, wrong sha", planned.Layer.Identifier())
+		case LayerActionSkip:
+			r.Logger.Infof("Would skip restore of data for %q, %s", planned.Layer.Identifier(), planned.Reason)
+		case LayerActionRestoreSameSHA:
+			r.Logger.Infof("Would restore data for %q from %q, same sha", planned.Layer.Identifier(), planned.SameSHAAs)
+		case LayerActionRestore:
+			r.Logger.Infof("Would restore data for %q from cache", planned.Layer.Identifier())
+		}
+	}
+	r.Logger.Infof("Dry run: planned %d layer action(s), the layers directory was not modified", len(plan.Layers))
+}
+
+// apply carries out the provided plan, restoring layer data from the cache and removing layers that cannot be restored,
+// and restores SBOM data from the cache.
+func (r *Restorer) apply(ctx context.Context, plan RestorePlan, cache Cache, cacheMeta platform.CacheMetadata) (RestoreSummary, error) {
+	var (
+		summary         RestoreSummary
+		g               errgroup.Group
+		restored        atomic.Int64
+		removedCorrupt  atomic.Int64
+		removedTimedOut atomic.Int64
+		removedFailed   atomic.Int64
+		bytesRestored   atomic.Int64
+		sharedLayers    = make(map[string]*sharedLayer)
+		summaryMu       sync.Mutex // guards the summary while layers are restored concurrently
+		bpSummaries     = make(map[string]*BuildpackRestoreSummary)
+	)
+	summary.Buildpacks = make([]BuildpackRestoreSummary, len(plan.Buildpacks))
+	for idx, bp := range plan.Buildpacks {
+		summary.Buildpacks[idx].ID = bp.ID
+		bpSummaries[bp.ID] = &summary.Buildpacks[idx]
+	}
+	for _, planned := range plan.Layers {
+		bpLayer := planned.Layer
+		cachedSHA := planned.SHA
+		bpSummary := bpSummaries[planned.BuildpackID]
+		countRestored := func() {
+			restored.Add(1)
+			summaryMu.Lock()
+			bpSummary.Restored++
+			summaryMu.Unlock()
+		}
+		switch planned.Action {
+		case LayerActionRemoveNotInCache:
+			r.Logger.Infof("Removing %q, not in cache", bpLayer.Identifier())
+			if err := bpLayer.Remove(); err != nil {
+				return summary, errors.Wrapf(err, "removing layer")
+			}
+			summary.RemovedNotInCache++
+			bpSummary.RemovedNotInCache++
+		case LayerActionRemoveWrongSHA:
+			r.Logger.Infof("Removing %q, wrong sha", bpLayer.Identifier())
+			r.Logger.Debugf("Layer sha: %q, cache sha: %q", planned.LayerSHA, cachedSHA)
+			if err := bpLayer.Remove(); err != nil {
+				return summary, errors.Wrapf(err, "removing layer")
+			}
+			summary.RemovedWrongSHA++
+			bpSummary.RemovedWrongSHA++
+		case LayerActionSkip:
+			r.Logger.Infof("Skipping restore of data for %q, %s", bpLayer.Identifier(), planned.Reason)
+			summary.Skipped++
+		case LayerActionRestoreSameSHA:
+			first := sharedLayers[cachedSHA]
+			r.Logger.Infof("Restoring data for %q from %q, same sha", bpLayer.Identifier(), first.identifier)
+			g.Go(func() error {
+				<-first.done
+				if !first.restored {
+					r.Logger.Warnf("Removing %q, data for %q was not restored", bpLayer.Identifier(), first.identifier)
+					if err := bpLayer.Remove(); err != nil {
+						return errors.Wrapf(err, "removing layer")
+					}
+					removedCorrupt.Add(1)
+					return nil
+				}
+				if bpLayer.Path() != first.path {
+					if err := fsutil.Link(first.path, bpLayer.Path()); err != nil {
+						return errors.Wrapf(err, "restoring %q from %q", bpLayer.Identifier(), first.identifier)
+					}
 				}
+				countRestored()
+				return nil
+			})
+		case LayerActionRestore:
+			r.Logger.Infof("Restoring data for %q from cache", bpLayer.Identifier())
+			var shared *sharedLayer
+			if r.DedupRestore {
+				shared = &sharedLayer{identifier: bpLayer.Identifier(), path: bpLayer.Path(), done: make(chan struct{})}
+				sharedLayers[cachedSHA] = shared
 			}
-			return r.SBOMRestorer.RestoreToBuildpackLayers(r.Buildpacks)
-		})
+			g.Go(func() error {
+				if shared != nil {
+					defer close(shared.done)
+				}
+				// the layer restore timeout covers retrieving, verifying, and extracting the layer
+				layerCtx, cancel := r.layerContext(ctx)
+				defer cancel()
+				removeTimedOut := func() error {
+					r.Logger.Warnf("Removing %q, restoring data timed out after %s", bpLayer.Identifier(), r.LayerRestoreTimeout)
+					if err := bpLayer.Remove(); err != nil {
+						return errors.Wrapf(err, "removing layer")
+					}
+					removedTimedOut.Add(1)
+					return nil
+				}
+				start := time.Now()
+				n, err := r.restoreCacheLayer(layerCtx, cache, cachedSHA, bpLayer.Path())
+				timing := LayerTiming{Identifier: bpLayer.Identifier(), SHA: cachedSHA, Duration: time.Since(start)}
+				if r.SlowLayerThreshold > 0 && timing.Duration > r.SlowLayerThreshold {
+					r.Logger.Warnf("Restoring data for %q took %s, longer than %s", timing.Identifier, timing.Duration, r.SlowLayerThreshold)
+				}
+				summaryMu.Lock()
+				summary.LayerTimings = append(summary.LayerTimings, timing)
+				summaryMu.Unlock()
+				if ctx.Err() != nil {
+					return r.removeCancelled(ctx, bpLayer)
+				}
+				if layerCtx.Err() != nil {
+					return removeTimedOut()
+				}
+				// treat a missing or corrupt cache layer like a cache miss, so that the buildpack re-creates it
+				removeUnusable := func(reason string) error {
+					r.Logger.Warnf("Removing %q, %s: %s", bpLayer.Identifier(), reason, err)
+					if err := bpLayer.Remove(); err != nil {
+						return errors.Wrapf(err, "removing layer")
+					}
+					removedCorrupt.Add(1)
+					return nil
+				}
+				switch {
+				case errors.Is(err, os.ErrNotExist):
+					return removeUnusable("cache layer was not found")
+				case errors.Is(err, errLayerDigestMismatch):
+					return removeUnusable("cache layer failed verification")
+				}
+				if err != nil {
+					if !r.BestEffort {
+						if errors.Is(err, ErrLayerTooLarge) {
+							// remove the partially extracted data, rather than leave it for the buildpack
+							if removeErr := bpLayer.Remove(); removeErr != nil {
+								return errors.Wrapf(removeErr, "removing layer")
+							}
+						}
+						return err
+					}
+					r.Logger.Warnf("Removing %q, restoring data failed: %s", bpLayer.Identifier(), err)
+					if err := bpLayer.Remove(); err != nil {
+						return errors.Wrapf(err, "removing layer")
+					}
+					summaryMu.Lock()
+					summary.LayerErrors = append(summary.LayerErrors, LayerError{Identifier: bpLayer.Identifier(), SHA: cachedSHA, Err: err})
+					summaryMu.Unlock()
+					removedFailed.Add(1)
+					return nil
+				}
+				if shared != nil {
+					shared.restored = true
+				}
+				countRestored()
+				bytesRestored.Add(n)
+				return nil
+			})
+		}
+	}
+
+	g.Go(func() error {
+		return r.restoreSBOM(cache, cacheMeta, plan.Buildpacks)
+	})
+
+	err := g.Wait()
+	summary.Restored = int(restored.Load())
+	summary.RemovedCorrupt = int(removedCorrupt.Load())
+	summary.RemovedTimedOut = int(removedTimedOut.Load())
+	summary.RemovedFailed = int(removedFailed.Load())
+	summary.BytesRestored = bytesRestored.Load()
+	sort.Slice(summary.LayerTimings, func(i, j int) bool {
+		return summary.LayerTimings[i].Identifier < summary.LayerTimings[j].Identifier
+	})
+	for _, timing := range summary.LayerTimings {
+		summary.RestoreDuration += timing.Duration
 	}
+	sort.Slice(summary.LayerErrors, func(i, j int) bool {
+		return summary.LayerErrors[i].Identifier < summary.LayerErrors[j].Identifier
+	})
+	return summary, err
+}
 
-	if err := g.Wait(); err != nil {
-		return errors.Wrap(err, "restoring data")
+// buildpacksWithoutLayers returns the IDs of buildpacks in the group with no layers in the cache
+// and no layers or SBOM files in their layers directory.
+func (r *Restorer) buildpacksWithoutLayers(cacheMeta platform.CacheMetadata) ([]string, error) {
+	var ids []string
+	for _, bp := range r.Buildpacks {
+		if len(cacheMeta.MetadataForBuildpack(bp.ID).Layers) > 0 {
+			continue
+		}
+		fis, err := os.ReadDir(buildpack.LayoutOrDefault(r.Layout).BuildpackDir(r.LayersDir, bp))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "reading layers directory for buildpack %q", bp.ID)
+		}
+		hasLayers := false
+		for _, fi := range fis {
+			if fi.Name() != "store.toml" {
+				hasLayers = true
+				break
+			}
+		}
+		if !hasLayers {
+			r.Logger.Infof("Buildpack %q has no layers on disk or in the cache", bp.ID)
+			ids = append(ids, bp.ID)
+		}
 	}
+	return ids, nil
+}
 
-	return nil
+func (r *Restorer) skipRestore(identifier string) bool {
+	for _, pattern := range r.SkipRestorePatterns {
+		if matched, _ := path.Match(pattern, identifier); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreSBOM restores SBOM data from the cache and copies SBOM files for the provided buildpacks to their layers directories.
+func (r *Restorer) restoreSBOM(cache Cache, cacheMeta platform.CacheMetadata, buildpacks []buildpack.GroupElement) error {
+	if r.SkipSBOM {
+		r.Logger.Debug("Skipping restore of SBOM data")
+		return nil
+	}
+	if r.PlatformAPI.LessThan("0.8") {
+		return nil
+	}
+	if cacheMeta.BOM.SHA != "" && !r.MetadataOnly {
+		r.Logger.Infof("Restoring data for SBOM from cache")
+		if err := r.SBOMRestorer.RestoreFromCache(cache, cacheMeta.BOM.SHA); err != nil {
+			return err
+		}
+	}
+	return r.SBOMRestorer.RestoreToBuildpackLayers(buildpacks)
+}
+
+// buildpacksToRestore returns the buildpacks in the group whose layers are restored, in group order.
+func (r *Restorer) buildpacksToRestore() []buildpack.GroupElement {
+	if len(r.RestoreBuildpacks) == 0 {
+		return r.Buildpacks
+	}
+	group := buildpack.Group{Group: r.Buildpacks}
+	selected := make(map[string]bool)
+	for _, id := range r.RestoreBuildpacks {
+		bp, ok := group.Lookup(id)
+		if !ok {
+			r.Logger.Warnf("Ignoring buildpack %q to restore, it is not in the group", id)
+			continue
+		}
+		selected[bp.ID] = true
+	}
+	var bps []buildpack.GroupElement
+	for _, bp := range r.Buildpacks {
+		if selected[bp.ID] {
+			bps = append(bps, bp)
+		} else {
+			r.Logger.Debugf("Not restoring layers for buildpack %q", bp.ID)
+		}
+	}
+	return bps
+}
+
+// removeCancelled removes the provided layer, which may have been partially restored when the context was done,
+// and returns the error of the context.
+func (r *Restorer) removeCancelled(ctx context.Context, bpLayer buildpack.Layer) error {
+	r.Logger.Warnf("Removing %q, restoring data was cancelled", bpLayer.Identifier())
+	if err := bpLayer.Remove(); err != nil {
+		return errors.Wrapf(err, "removing layer")
+	}
+	return ctx.Err()
 }
 
-func (r *Restorer) restoreCacheLayer(cache Cache, sha string) error {
+// layerContext returns the context for restoring a single cache layer, which is done when LayerRestoreTimeout (if set) expires.
+func (r *Restorer) layerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.LayerRestoreTimeout > 0 {
+		return context.WithTimeout(ctx, r.LayerRestoreTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// retrieveLayer retrieves the cache layer with the provided sha, returning the error of the context if it is done first;
+// in that case, the layer is closed once it is retrieved.
+func retrieveLayer(ctx context.Context, cache Cache, sha string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	type result struct {
+		rc  io.ReadCloser
+		err error
+	}
+	results := make(chan result, 1)
+	go func() {
+		rc, err := cache.RetrieveLayer(sha)
+		results <- result{rc: rc, err: err}
+	}()
+	select {
+	case res := <-results:
+		return res.rc, res.err
+	case <-ctx.Done():
+		go func() {
+			if res := <-results; res.err == nil {
+				_ = res.rc.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// restoreCacheLayer extracts the cache layer with the provided sha, returning the number of bytes read from the cache.
+// The data is verified against the sha while it is extracted, so that the layer is retrieved from the cache only once;
+// if it does not match, an error matching errLayerDigestMismatch is returned.
+// If the provided context is done (e.g., because the layer restore timeout expired), the layer is closed to abort extraction
+// and the error of the context is returned.
+func (r *Restorer) restoreCacheLayer(ctx context.Context, cache Cache, sha, layerPath string) (int64, error) {
 	// Sanity check to prevent panic.
 	if cache == nil {
-		return errors.New("restoring layer: cache not provided")
+		return 0, errors.New("restoring layer: cache not provided")
 	}
 	r.Logger.Debugf("Retrieving data for %q", sha)
-	rc, err := cache.RetrieveLayer(sha)
+	rc, err := retrieveLayer(ctx, cache, sha)
 	if err != nil {
-		return err
+		if ctx.Err() != nil || errors.Is(err, os.ErrNotExist) {
+			return 0, err
+		}
+		return 0, &restoreError{kind: ErrCacheUnavailable, err: err}
 	}
 	defer rc.Close()
+	stopWatching := context.AfterFunc(ctx, func() {
+		_ = rc.Close()
+	})
+	defer stopWatching()
+	if r.ProgressInterval > 0 {
+		rc = lifecyclecache.NewProgressReader(rc, r.ProgressInterval, func(bytesRead int64) {
+			r.Logger.Debugf("Restored %d bytes of data for %q", bytesRead, sha)
+		})
+	}
 
-	return layers.Extract(rc, "")
+	dr := &digestReader{r: rc, hasher: sha256.New()}
+	verify := func() error {
+		return dr.verify(sha)
+	}
+	var lr io.Reader = dr
+	if r.MaxLayerSize > 0 {
+		lr = &maxSizeReader{r: dr, remaining: r.MaxLayerSize}
+	}
+	cr := &countingReader{r: lr}
+	switch {
+	case r.OverlayUpperDir != "":
+		if err = layers.ExtractLayerDirToOverlay(cr, "", r.OverlayUpperDir, layerPath); err != nil {
+			err = &restoreError{kind: ErrLayerCorrupt, err: err}
+		}
+	case r.AtomicRestore:
+		err = r.extractStaged(cr, layerPath, verify)
+	default:
+		if err = layers.ExtractLayerDir(cr, "", layerPath); err != nil {
+			err = &restoreError{kind: ErrLayerCorrupt, err: err}
+		}
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return cr.n, ctxErr
+	}
+	if errors.Is(err, ErrLayerTooLarge) {
+		return cr.n, &restoreError{kind: ErrLayerTooLarge, err: errors.Errorf("restoring data for %q: layer exceeds the maximum layer size of %d bytes", sha, r.MaxLayerSize)}
+	}
+	// data that does not match the sha explains a failure to extract it, e.g., a truncated layer
+	if verifyErr := verify(); verifyErr != nil {
+		return cr.n, verifyErr
+	}
+	return cr.n, err
+}
+
+// digestReader computes the digest of the data read from a cache layer, so that the layer can be verified while it is extracted.
+// Errors reading the layer (e.g., transport errors) are recorded, so that they are not mistaken for corrupt data.
+type digestReader struct {
+	r       io.Reader
+	hasher  hash.Hash
+	readErr error
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.hasher.Write(p[:n])
+	if err != nil && err != io.EOF {
+		d.readErr = err
+	}
+	return n, err
+}
+
+// verify reads the remaining data (e.g., the padding after the end of the tar archive) and returns an error
+// matching ErrCacheUnavailable if the layer could not be read, or errLayerDigestMismatch if its digest does not match the provided sha.
+func (d *digestReader) verify(sha string) error {
+	if d.readErr == nil {
+		_, _ = io.Copy(io.Discard, d)
+	}
+	if d.readErr != nil {
+		return &restoreError{kind: ErrCacheUnavailable, err: errors.Wrapf(d.readErr, "reading data for %q", sha)}
+	}
+	if actual := fmt.Sprintf("sha256:%x", d.hasher.Sum(nil)); actual != sha {
+		return &restoreError{kind: errLayerDigestMismatch, err: errors.Errorf("layer with SHA '%s' has digest '%s'", sha, actual)}
+	}
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// maxSizeReader reads from an underlying reader, returning ErrLayerTooLarge once more than the remaining number of bytes would be read.
+type maxSizeReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	if m.remaining <= 0 {
+		// a layer of exactly the maximum size is allowed, so only fail if there is more data
+		var b [1]byte
+		n, err := m.r.Read(b[:])
+		if n == 0 {
+			return 0, err
+		}
+		return 0, ErrLayerTooLarge
+	}
+	if int64(len(p)) > m.remaining {
+		p = p[:m.remaining]
+	}
+	n, err := m.r.Read(p)
+	m.remaining -= int64(n)
+	return n, err
+}
+
+// extractStaged extracts the provided layer into a staging directory beneath the layers directory,
+// and on success renames the layer directory into place, replacing any existing directory.
+// The provided verify function is called before renaming, so that data that fails verification is never put into place.
+func (r *Restorer) extractStaged(rc io.Reader, layerPath string, verify func() error) error {
+	layerPath, err := filepath.Abs(layerPath)
+	if err != nil {
+		return err
+	}
+	stagingDir, err := os.MkdirTemp(r.LayersDir, ".restore-")
+	if err != nil {
+		return errors.Wrap(err, "creating staging directory")
+	}
+	defer os.RemoveAll(stagingDir)
+
+	if err = layers.ExtractLayerDir(rc, stagingDir, layerPath); err != nil {
+		return &restoreError{kind: ErrLayerCorrupt, err: err}
+	}
+	if err = verify(); err != nil {
+		return err
+	}
+	stagedPath := filepath.Join(stagingDir, strings.TrimPrefix(layerPath, filepath.VolumeName(layerPath)))
+	if _, err = os.Stat(stagedPath); err != nil {
+		return errors.Wrapf(err, "finding staged data for %q", layerPath)
+	}
+
+	if _, err = os.Lstat(layerPath); os.IsNotExist(err) {
+		if err = os.MkdirAll(filepath.Dir(layerPath), os.ModePerm); err != nil {
+			return err
+		}
+		return fsutil.RenameWithWindowsFallback(stagedPath, layerPath)
+	}
+	// move the existing directory aside so that it can be put back if the rename fails;
+	// it is removed along with the staging directory otherwise
+	backupPath := filepath.Join(stagingDir, "previous")
+	if err = fsutil.RenameWithWindowsFallback(layerPath, backupPath); err != nil {
+		return errors.Wrapf(err, "moving existing directory %q", layerPath)
+	}
+	if err = fsutil.RenameWithWindowsFallback(stagedPath, layerPath); err != nil {
+		if rollbackErr := fsutil.RenameWithWindowsFallback(backupPath, layerPath); rollbackErr != nil {
+			return errors.Wrapf(rollbackErr, "rolling back %q", layerPath)
+		}
+		return errors.Wrapf(err, "renaming staged data to %q", layerPath)
+	}
+	return nil
 }
 
 func retrieveCacheMetadata(fromCache Cache, logger log.Logger) (platform.CacheMetadata, error) {
@@ -132,11 +902,11 @@ func retrieveCacheMetadata(fromCache Cache, logger log.Logger) (platform.CacheMe
 	if fromCache != nil {
 		var err error
 		if !fromCache.Exists() {
-			logger.Info("Layer cache not found")
+			logger.Debug("Layer cache not found")
 		}
 		cacheMeta, err = fromCache.RetrieveMetadata()
 		if err != nil {
-			return cacheMeta, errors.Wrap(err, "retrieving cache metadata")
+			return cacheMeta, &restoreError{kind: ErrCacheUnavailable, err: errors.Wrap(err, "retrieving cache metadata")}
 		}
 	} else {
 		logger.Debug("Usable cache not provided, using empty cache metadata")
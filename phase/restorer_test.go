@@ -1,11 +1,18 @@
 package phase_test
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/memory"
@@ -18,6 +25,7 @@ import (
 	"github.com/buildpacks/lifecycle/buildpack"
 	"github.com/buildpacks/lifecycle/cache"
 	"github.com/buildpacks/lifecycle/internal/layer"
+	"github.com/buildpacks/lifecycle/launch"
 	"github.com/buildpacks/lifecycle/layers"
 	"github.com/buildpacks/lifecycle/phase"
 	"github.com/buildpacks/lifecycle/phase/testmock"
@@ -98,7 +106,8 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 					it.Before(func() {
 						var meta, sha string
 						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-true", meta, sha))
-						h.AssertNil(t, restorer.Restore(nil))
+						_, err := restorer.Restore(nil)
+						h.AssertNil(t, err)
 					})
 
 					it("does not restore layer data", func() {
@@ -110,7 +119,8 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 					it.Before(func() {
 						var meta, sha string
 						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-false", meta, sha))
-						h.AssertNil(t, restorer.Restore(testCache))
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
 					})
 
 					it("keeps metadata file", func() {
@@ -128,7 +138,8 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 					it.Before(func() {
 						var meta, sha string
 						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-true", meta, sha))
-						h.AssertNil(t, restorer.Restore(testCache))
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
 					})
 
 					it("does not restore layer data", func() {
@@ -140,7 +151,8 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 					it.Before(func() {
 						var meta, sha string
 						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-false", meta, sha))
-						h.AssertNil(t, restorer.Restore(testCache))
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
 					})
 
 					it("keeps metadata file", func() {
@@ -151,6 +163,24 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-false"))
 					})
 				})
+
+				when("finding buildpacks without layers", func() {
+					var summary phase.RestoreSummary
+
+					it.Before(func() {
+						restorer.FindBuildpacksWithoutLayers = true
+						var meta, sha string
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-false", meta, sha))
+						var err error
+						summary, err = restorer.Restore(testCache)
+						h.AssertNil(t, err)
+					})
+
+					it("reports buildpacks with no layers on disk or in the cache", func() {
+						h.AssertEq(t, summary.BuildpacksWithoutLayers, []string{"escaped/buildpack/id"})
+						assertLogEntry(t, logHandler, `Buildpack "escaped/buildpack/id" has no layers on disk or in the cache`)
+					})
+				})
 			})
 
 			when("there is a cache", func() {
@@ -270,7 +300,8 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 						meta += "[metadata]\n  cache-only-key = \"cache-only-val\"\n"
 						var sha string
 						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", meta, sha))
-						h.AssertNil(t, restorer.Restore(testCache))
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
 					})
 
 					it("keeps layer metadata", func() {
@@ -285,13 +316,417 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 					})
 				})
 
+				when("restoring atomically", func() {
+					it.Before(func() {
+						restorer.AtomicRestore = true
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "", ""))
+						h.Mkdir(t, filepath.Join(layersDir, "buildpack.id", "cache-only"))
+						h.Mkfile(t, "some-stale-data", filepath.Join(layersDir, "buildpack.id", "cache-only", "some-stale-file"))
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
+					})
+
+					it("replaces the existing layer directory with the restored data", func() {
+						got := h.MustReadFile(t, filepath.Join(layersDir, "buildpack.id", "cache-only", "file-from-cache-only-layer"))
+						want := "echo text from cache-only layer\n"
+						h.AssertEq(t, string(got), want)
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only", "some-stale-file"))
+					})
+
+					it("removes the staging directory", func() {
+						matches, err := filepath.Glob(filepath.Join(layersDir, ".restore-*"))
+						h.AssertNil(t, err)
+						h.AssertEq(t, len(matches), 0)
+					})
+				})
+
+				when("there is a corrupt cache=true layer", func() {
+					var summary phase.RestoreSummary
+
+					it.Before(func() {
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "", ""))
+						matches, err := filepath.Glob(filepath.Join(cacheDir, "committed", "*"+strings.TrimPrefix(cacheOnlyLayerSHA, "sha256:")+".tar"))
+						h.AssertNil(t, err)
+						h.AssertEq(t, len(matches), 1)
+						h.AssertNil(t, os.Truncate(matches[0], 512))
+
+						summary, err = restorer.Restore(testCache)
+						h.AssertNil(t, err)
+					})
+
+					it("removes the layer", func() {
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only.toml"))
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only"))
+						assertLogEntry(t, logHandler, "Removing \"buildpack.id:cache-only\", cache layer failed verification")
+					})
+
+					it("counts the removed layer in the summary", func() {
+						h.AssertEq(t, summary.RemovedCorrupt, 1)
+						h.AssertEq(t, summary.Restored, 1) // escaped/buildpack/id:escaped-bp-layer
+					})
+				})
+
+				when("restoring a layer times out", func() {
+					var summary phase.RestoreSummary
+
+					it.Before(func() {
+						restorer.LayerRestoreTimeout = 100 * time.Millisecond
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "", ""))
+
+						var err error
+						summary, err = restorer.Restore(&stalledCache{Cache: testCache})
+						h.AssertNil(t, err)
+					})
+
+					it("removes the layer", func() {
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only.toml"))
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only"))
+						assertLogEntry(t, logHandler, "Removing \"buildpack.id:cache-only\", restoring data timed out after 100ms")
+					})
+
+					it("counts the removed layer in the summary", func() {
+						h.AssertEq(t, summary.RemovedTimedOut, 2) // every layer stalls, including escaped/buildpack/id:escaped-bp-layer
+						h.AssertEq(t, summary.Restored, 0)
+					})
+
+					when("retrieving the layer blocks", func() {
+						it("removes the layer", func() {
+							h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "", ""))
+							blocking := &blockingCache{Cache: testCache, unblock: make(chan struct{})}
+							defer close(blocking.unblock)
+
+							summary, err := restorer.Restore(blocking)
+							h.AssertNil(t, err)
+
+							h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only.toml"))
+							h.AssertEq(t, summary.RemovedTimedOut, 2)
+						})
+					})
+				})
+
+				when("a slow layer threshold is set", func() {
+					var summary phase.RestoreSummary
+
+					it.Before(func() {
+						restorer.SlowLayerThreshold = time.Nanosecond
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "", ""))
+
+						var err error
+						summary, err = restorer.Restore(testCache)
+						h.AssertNil(t, err)
+					})
+
+					it("warns about slow layers", func() {
+						assertLogEntry(t, logHandler, "Restoring data for \"buildpack.id:cache-only\" took")
+					})
+
+					it("returns the time spent restoring each layer", func() {
+						h.AssertEq(t, len(summary.LayerTimings), 2)
+						h.AssertEq(t, summary.LayerTimings[0].Identifier, "buildpack.id:cache-only")
+						h.AssertEq(t, summary.LayerTimings[0].SHA, cacheOnlyLayerSHA)
+						h.AssertEq(t, summary.LayerTimings[1].Identifier, "escaped/buildpack/id:escaped-bp-layer")
+						h.AssertEq(t, summary.RestoreDuration, summary.LayerTimings[0].Duration+summary.LayerTimings[1].Duration)
+						assertLogEntry(t, logHandler, "restoring data for 2 layer(s) from cache")
+					})
+				})
+
+				when("pruning the cache", func() {
+					it("removes layers not referenced by the cache metadata", func() {
+						restorer.PruneCache = true
+						stalePath := filepath.Join(cacheDir, "committed", "sha256:some-stale-layer.tar")
+						h.AssertNil(t, os.WriteFile(stalePath, []byte("some-data"), 0600))
+
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
+
+						h.AssertPathDoesNotExist(t, stalePath)
+						matches, err := filepath.Glob(filepath.Join(cacheDir, "committed", "*"+strings.TrimPrefix(cacheOnlyLayerSHA, "sha256:")+".tar"))
+						h.AssertNil(t, err)
+						h.AssertEq(t, len(matches), 1)
+					})
+				})
+
+				when("a layout is provided", func() {
+					it("restores layers into the directories determined by the layout", func() {
+						layout := versionedLayout{version: "v1"}
+						restorer.Layout = layout
+						restorer.LayerMetadataRestorer = &layer.DefaultMetadataRestorer{LayersDir: layersDir, Logger: restorer.Logger, Layout: layout}
+
+						// the layer data is cached from the directories determined by the layout, as it is extracted to the same paths
+						layoutCacheDir, err := os.MkdirTemp("", "")
+						h.AssertNil(t, err)
+						defer os.RemoveAll(layoutCacheDir)
+						layoutCache, err := cache.NewVolumeCache(layoutCacheDir)
+						h.AssertNil(t, err)
+						h.Mkdir(t, filepath.Join(layersDir, "v1"))
+						h.RecursiveCopy(t, filepath.Join("testdata", "restorer"), filepath.Join(layersDir, "v1"))
+						lf := layers.Factory{ArtifactsDir: tarTempDir}
+						cacheOnlyLayer, err := lf.DirLayer("buildpack.id:cache-only", filepath.Join(layersDir, "v1", "buildpack.id", "cache-only"), "")
+						h.AssertNil(t, err)
+						h.AssertNil(t, layoutCache.AddLayerFile(cacheOnlyLayer.TarPath, cacheOnlyLayer.Digest))
+						escapedLayer, err := lf.DirLayer("escaped/buildpack/id:escaped-bp-layer", filepath.Join(layersDir, "v1", "escaped_buildpack_id", "escaped-bp-layer"), "")
+						h.AssertNil(t, err)
+						h.AssertNil(t, layoutCache.AddLayerFile(escapedLayer.TarPath, escapedLayer.Digest))
+						h.AssertNil(t, layoutCache.Commit())
+						h.AssertNil(t, os.RemoveAll(filepath.Join(layersDir, "v1")))
+						h.AssertNil(t, os.WriteFile(
+							filepath.Join(layoutCacheDir, "committed", "io.buildpacks.lifecycle.cache.metadata"),
+							[]byte(fmt.Sprintf(`{
+    "buildpacks": [
+        {"key": "buildpack.id", "layers": {"cache-only": {"cache": true, "data": {"cache-only-key": "cache-only-val"}, "sha": "%s"}}},
+        {"key": "escaped/buildpack/id", "layers": {"escaped-bp-layer": {"cache": true, "sha": "%s"}}}
+    ]
+}`, cacheOnlyLayer.Digest, escapedLayer.Digest)),
+							0600,
+						))
+
+						summary, err := restorer.Restore(layoutCache)
+						h.AssertNil(t, err)
+
+						h.AssertPathExists(t, filepath.Join(layersDir, "v1", "buildpack.id", "cache-only.toml"))
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only.toml"))
+						assertLogEntry(t, logHandler, `Restoring data for "buildpack.id:cache-only" from cache`)
+						h.AssertEq(t, summary.Restored, 2) // including escaped/buildpack/id:escaped-bp-layer
+					})
+				})
+
+				when("dry run", func() {
+					var summary phase.RestoreSummary
+
+					it.Before(func() {
+						restorer.DryRun = true
+						restorer.LayerMetadataRestorer = &layer.DefaultMetadataRestorer{LayersDir: layersDir, Logger: restorer.Logger, DryRun: true}
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-launch", "", ""))
+						h.AssertNil(t, json.Unmarshal([]byte(`{
+   "buildpacks": [
+       {
+           "key": "buildpack.id",
+           "layers": {
+               "cache-launch": {
+                   "cache": true,
+                   "launch": true,
+                   "sha": "some-made-up-sha"
+               }
+           }
+       }
+   ]
+}
+`), &restorer.LayersMetadata))
+
+						var err error
+						summary, err = restorer.Restore(testCache)
+						h.AssertNil(t, err)
+					})
+
+					it("logs the plan", func() {
+						assertLogEntry(t, logHandler, `Would remove "buildpack.id:cache-launch", wrong sha`)
+						assertLogEntry(t, logHandler, `Would restore data for "buildpack.id:cache-only" from cache`)
+						assertLogEntry(t, logHandler, `Would restore data for "escaped/buildpack/id:escaped-bp-layer" from cache`)
+					})
+
+					it("does not modify the layers directory", func() {
+						h.AssertPathExists(t, filepath.Join(layersDir, "buildpack.id", "cache-launch.toml"))
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only.toml"))
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only"))
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "escaped_buildpack_id"))
+						h.AssertEq(t, summary, phase.RestoreSummary{})
+					})
+				})
+
+				when("a cache layer cannot be retrieved", func() {
+					it("returns an error matching ErrCacheUnavailable", func() {
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "", ""))
+
+						_, err := restorer.Restore(&unavailableCache{Cache: testCache})
+						h.AssertNotNil(t, err)
+						h.AssertEq(t, errors.Is(err, phase.ErrCacheUnavailable), true)
+						h.AssertEq(t, errors.Is(err, phase.ErrLayerCorrupt), false)
+					})
+				})
+
+				when("a cache layer cannot be read to the end", func() {
+					it("returns an error matching ErrCacheUnavailable", func() {
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "", ""))
+
+						_, err := restorer.Restore(&failingReadCache{Cache: testCache})
+						h.AssertNotNil(t, err)
+						h.AssertEq(t, errors.Is(err, phase.ErrCacheUnavailable), true)
+						h.AssertEq(t, errors.Is(err, phase.ErrLayerCorrupt), false)
+					})
+				})
+
+				when("a cache layer is restored", func() {
+					it("retrieves the layer data once", func() {
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "", ""))
+						counting := &countingCache{Cache: testCache, retrieved: map[string]int{}}
+
+						_, err := restorer.Restore(counting)
+						h.AssertNil(t, err)
+
+						h.AssertEq(t, counting.retrieved[cacheOnlyLayerSHA], 1)
+					})
+				})
+
+				when("a cache layer cannot be extracted", func() {
+					it("returns an error matching ErrLayerCorrupt", func() {
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "", ""))
+
+						_, err := restorer.Restore(&corruptCache{Cache: testCache})
+						h.AssertNotNil(t, err)
+						h.AssertEq(t, errors.Is(err, phase.ErrLayerCorrupt), true)
+						h.AssertEq(t, errors.Is(err, phase.ErrCacheUnavailable), false)
+					})
+
+					when("restoring with best effort", func() {
+						it("removes the layer and restores other layers", func() {
+							restorer.BestEffort = true
+							h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "", ""))
+
+							summary, err := restorer.Restore(&partiallyCorruptCache{Cache: testCache, corruptSHA: cacheOnlyLayerSHA})
+							h.AssertNil(t, err)
+
+							h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only"))
+							h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only.toml"))
+							h.AssertPathExists(t, filepath.Join(layersDir, "escaped_buildpack_id", "escaped-bp-layer", "file-from-escaped-bp"))
+							h.AssertEq(t, summary.Restored, 1)
+							h.AssertEq(t, summary.RemovedFailed, 1)
+							h.AssertEq(t, len(summary.LayerErrors), 1)
+							h.AssertEq(t, summary.LayerErrors[0].Identifier, "buildpack.id:cache-only")
+							h.AssertEq(t, summary.LayerErrors[0].SHA, notATarSHA)
+							h.AssertEq(t, errors.Is(summary.LayerErrors[0].Err, phase.ErrLayerCorrupt), true)
+							h.AssertLogEntry(t, logHandler, `Removing "buildpack.id:cache-only", restoring data failed`)
+						})
+					})
+				})
+
+				when("a maximum layer size is set", func() {
+					it.Before(func() {
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "", ""))
+					})
+
+					it("restores layers within the maximum layer size", func() {
+						restorer.MaxLayerSize = 1024 * 1024
+
+						summary, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
+						h.AssertEq(t, summary.Restored, 2)
+					})
+
+					when("a cache layer exceeds the maximum layer size", func() {
+						it("removes the layer and returns an error matching ErrLayerTooLarge", func() {
+							restorer.MaxLayerSize = 512
+
+							_, err := restorer.Restore(testCache)
+							h.AssertNotNil(t, err)
+							h.AssertEq(t, errors.Is(err, phase.ErrLayerTooLarge), true)
+							h.AssertStringContains(t, err.Error(), "layer exceeds the maximum layer size of 512 bytes")
+							h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only"))
+							h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only.toml"))
+						})
+					})
+				})
+
+				when("the context is done", func() {
+					it("errors without restoring data", func() {
+						ctx, cancel := context.WithCancel(context.Background())
+						cancel()
+
+						_, err := restorer.RestoreContext(ctx, testCache)
+						h.AssertError(t, err, "context canceled")
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only"))
+					})
+
+					when("data is being extracted", func() {
+						it("closes the layer data and removes the layer", func() {
+							h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "", ""))
+							stalling := &stallingReadCache{Cache: testCache, stalled: make(chan struct{})}
+							ctx, cancel := context.WithCancel(context.Background())
+							defer cancel()
+							go func() {
+								<-stalling.stalled
+								cancel()
+							}()
+
+							_, err := restorer.RestoreContext(ctx, stalling)
+							h.AssertError(t, err, "context canceled")
+							h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only"))
+							h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only.toml"))
+							h.AssertEq(t, stalling.openReaders(), 0)
+						})
+					})
+				})
+
+				when("a progress interval is set", func() {
+					it.Before(func() {
+						restorer.ProgressInterval = 1
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "", ""))
+
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
+					})
+
+					it("logs progress restoring layer data", func() {
+						assertLogEntry(t, logHandler, fmt.Sprintf("bytes of data for %q", cacheOnlyLayerSHA))
+					})
+				})
+
+				when("there are multiple caches", func() {
+					var (
+						emptyCacheDir string
+						emptyCache    phase.Cache
+					)
+
+					it.Before(func() {
+						var err error
+						emptyCacheDir, err = os.MkdirTemp("", "")
+						h.AssertNil(t, err)
+						emptyCache, err = cache.NewVolumeCache(emptyCacheDir)
+						h.AssertNil(t, err)
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "", ""))
+					})
+
+					it.After(func() {
+						h.AssertNil(t, os.RemoveAll(emptyCacheDir))
+					})
+
+					it("restores data from the first cache that has the layer", func() {
+						summary, err := restorer.Restore(emptyCache, testCache)
+						h.AssertNil(t, err)
+
+						got := h.MustReadFile(t, filepath.Join(layersDir, "buildpack.id", "cache-only", "file-from-cache-only-layer"))
+						want := "echo text from cache-only layer\n"
+						h.AssertEq(t, string(got), want)
+						h.AssertEq(t, summary.Restored, 2) // including escaped/buildpack/id:escaped-bp-layer
+					})
+
+					when("no cache has the layer", func() {
+						it.Before(func() {
+							matches, err := filepath.Glob(filepath.Join(cacheDir, "committed", "*"+strings.TrimPrefix(cacheOnlyLayerSHA, "sha256:")+".tar"))
+							h.AssertNil(t, err)
+							h.AssertEq(t, len(matches), 1)
+							h.AssertNil(t, os.Remove(matches[0]))
+						})
+
+						it("names each cache that was tried", func() {
+							_, err := restorer.Restore(emptyCache, testCache)
+							h.AssertNil(t, err)
+
+							h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only"))
+							assertLogEntry(t, logHandler, "not found in any cache")
+							assertLogEntry(t, logHandler, fmt.Sprintf("cache %q", emptyCacheDir))
+							assertLogEntry(t, logHandler, fmt.Sprintf("cache %q", cacheDir))
+						})
+					})
+				})
+
 				when("there is a cache=false layer", func() {
 					var meta string
 					it.Before(func() {
 						meta = "[metadata]\n  cache-false-key = \"cache-false-val\""
 						var sha string
 						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-false", meta, sha))
-						h.AssertNil(t, restorer.Restore(testCache))
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
 					})
 
 					it("keeps layer metadata", func() {
@@ -305,7 +740,10 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 				})
 
 				when("there is a cache=true layer with wrong sha", func() {
-					var otherSHA string
+					var (
+						otherSHA string
+						summary  phase.RestoreSummary
+					)
 					it.Before(func() {
 						otherSHA = "some-made-up-sha"
 						var meta, layerSha string
@@ -332,7 +770,9 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 
 						h.AssertNil(t, json.Unmarshal(appMetaContents, &restorer.LayersMetadata))
 
-						h.AssertNil(t, restorer.Restore(testCache))
+						var err error
+						summary, err = restorer.Restore(testCache)
+						h.AssertNil(t, err)
 					})
 
 					it("removes metadata file", func() {
@@ -346,13 +786,148 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 						expected = fmt.Sprintf("Layer sha: %q", otherSHA)
 						assertLogEntry(t, logHandler, expected)
 					})
+
+					it("counts the removed layer in the summary", func() {
+						h.AssertEq(t, summary.RemovedWrongSHA, 1)
+						h.AssertEq(t, summary.Restored, 2) // buildpack.id:cache-only and escaped/buildpack/id:escaped-bp-layer
+						assertLogEntry(t, logHandler, "removed 1 layer(s) with wrong sha")
+					})
+
+					it("counts the outcomes for each buildpack in the summary", func() {
+						h.AssertEq(t, summary.Buildpacks, []phase.BuildpackRestoreSummary{
+							{ID: "buildpack.id", Restored: 1, RemovedWrongSHA: 1},
+							{ID: "escaped/buildpack/id", Restored: 1},
+						})
+					})
+				})
+
+				when("a layer matches a skip restore pattern", func() {
+					var (
+						meta    string
+						summary phase.RestoreSummary
+					)
+
+					it.Before(func() {
+						restorer.SkipRestorePatterns = []string{"buildpack.id:cache-*"}
+						meta = "[metadata]\n  cache-only-key = \"cache-only-val\"\n"
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", meta, ""))
+						h.AssertNil(t, writeLayer(layersDir, "escaped_buildpack_id", "escaped-bp-layer", "[metadata]\n  escaped-bp-key = \"escaped-bp-val\"\n", ""))
+						var err error
+						summary, err = restorer.Restore(testCache)
+						h.AssertNil(t, err)
+					})
+
+					it("keeps layer metadata", func() {
+						got := h.MustReadFile(t, filepath.Join(layersDir, "buildpack.id", "cache-only.toml"))
+						h.AssertEq(t, string(got), meta)
+					})
+
+					it("does not restore data for the matching layer", func() {
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only"))
+						assertLogEntry(t, logHandler, "Skipping restore of data for \"buildpack.id:cache-only\"")
+						h.AssertEq(t, summary.Skipped, 1)
+					})
+
+					it("restores data for other layers", func() {
+						h.AssertPathExists(t, filepath.Join(layersDir, "escaped_buildpack_id", "escaped-bp-layer", "file-from-escaped-bp"))
+					})
+				})
+
+				when("a skip restore pattern is invalid", func() {
+					it("errors", func() {
+						restorer.SkipRestorePatterns = []string{"buildpack.id:["}
+						_, err := restorer.Restore(testCache)
+						h.AssertError(t, err, "invalid skip restore pattern \"buildpack.id:[\"")
+					})
+				})
+
+				when("restoring only layer metadata", func() {
+					var (
+						meta    string
+						summary phase.RestoreSummary
+					)
+
+					it.Before(func() {
+						restorer.MetadataOnly = true
+						meta = "[metadata]\n  cache-only-key = \"cache-only-val\"\n"
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", meta, ""))
+						h.AssertNil(t, writeLayer(layersDir, "escaped_buildpack_id", "escaped-bp-layer", "[metadata]\n  escaped-bp-key = \"escaped-bp-val\"\n", ""))
+						var err error
+						summary, err = restorer.Restore(testCache)
+						h.AssertNil(t, err)
+					})
+
+					it("keeps layer metadata", func() {
+						got := h.MustReadFile(t, filepath.Join(layersDir, "buildpack.id", "cache-only.toml"))
+						h.AssertEq(t, string(got), meta)
+					})
+
+					it("does not restore layer data", func() {
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only"))
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "escaped_buildpack_id", "escaped-bp-layer"))
+						assertLogEntry(t, logHandler, "Skipping restore of data for \"buildpack.id:cache-only\", restoring metadata only")
+						h.AssertEq(t, summary.Restored, 0)
+						h.AssertEq(t, summary.Skipped, 2)
+					})
+				})
+
+				when("restore buildpacks are provided", func() {
+					var skippedMeta string
+
+					it.Before(func() {
+						skippedMeta = "[metadata]\n  escaped-bp-key = \"some-local-val\"\n"
+						h.AssertNil(t, writeLayer(layersDir, "escaped_buildpack_id", "escaped-bp-layer", skippedMeta, "some-local-sha"))
+						h.AssertNil(t, os.MkdirAll(filepath.Join(layersDir, "escaped_buildpack_id", "escaped-bp-layer"), 0755))
+						h.Mkfile(t, "some-local-data", filepath.Join(layersDir, "escaped_buildpack_id", "escaped-bp-layer", "some-file"))
+
+						restorer.RestoreBuildpacks = []string{"buildpack.id", "missing/buildpack"}
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
+					})
+
+					it("restores layers only for the provided buildpacks", func() {
+						h.AssertPathExists(t, filepath.Join(layersDir, "buildpack.id", "cache-only.toml"))
+					})
+
+					it("leaves the layers of other buildpacks untouched", func() {
+						h.AssertEq(t, string(h.MustReadFile(t, filepath.Join(layersDir, "escaped_buildpack_id", "escaped-bp-layer.toml"))), skippedMeta)
+						h.AssertEq(t, string(h.MustReadFile(t, filepath.Join(layersDir, "escaped_buildpack_id", "escaped-bp-layer.sha"))), "some-local-sha")
+						h.AssertEq(t, string(h.MustReadFile(t, filepath.Join(layersDir, "escaped_buildpack_id", "escaped-bp-layer", "some-file"))), "some-local-data")
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "escaped_buildpack_id", "escaped-bp-layer", "file-from-escaped-bp"))
+					})
+
+					it("warns about buildpacks that are not in the group", func() {
+						assertLogEntry(t, logHandler, "Ignoring buildpack \"missing/buildpack\" to restore, it is not in the group")
+					})
+				})
+
+				when("restoring only SBOM data", func() {
+					var meta string
+
+					it.Before(func() {
+						restorer.SBOMOnly = true
+						meta = "[metadata]\n  cache-only-key = \"cache-only-val\"\n"
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", meta, ""))
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
+					})
+
+					it("does not modify layer metadata", func() {
+						got := h.MustReadFile(t, filepath.Join(layersDir, "buildpack.id", "cache-only.toml"))
+						h.AssertEq(t, string(got), meta)
+					})
+
+					it("does not restore layer data", func() {
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only"))
+					})
 				})
 
 				when("there is a cache=true layer not in cache", func() {
 					it.Before(func() {
 						var meta, sha string
 						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-layer-not-in-cache", meta, sha))
-						h.AssertNil(t, restorer.Restore(testCache))
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
 					})
 
 					it("does not restore layer data", func() {
@@ -366,7 +941,8 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 						meta += "[metadata]\n  escaped-bp-key = \"escaped-bp-val\"\n"
 						var sha string
 						h.AssertNil(t, writeLayer(layersDir, "escaped_buildpack_id", "escaped-bp-layer", meta, sha))
-						h.AssertNil(t, restorer.Restore(testCache))
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
 					})
 
 					it("keeps layer metadata", func() {
@@ -381,11 +957,66 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 					})
 				})
 
+				when("restoring with deduplication", func() {
+					var (
+						summary   phase.RestoreSummary
+						sharedSHA string
+						counting  *countingCache
+					)
+
+					it.Before(func() {
+						restorer.DedupRestore = true
+
+						// both layers reference the same layer data in the cache, made from a real layer directory;
+						// the data is extracted into the first layer and linked into the second
+						sharedDir := filepath.Join(layersDir, "buildpack.id", "cache-only")
+						h.AssertNil(t, os.MkdirAll(sharedDir, 0755))
+						h.Mkfile(t, "some-shared-content", filepath.Join(sharedDir, "some-shared-file"))
+						sharedLayer, err := (&layers.Factory{ArtifactsDir: tarTempDir}).DirLayer("buildpack.id:cache-only", sharedDir, "")
+						h.AssertNil(t, err)
+						h.AssertNil(t, os.RemoveAll(sharedDir))
+						sharedSHA = sharedLayer.Digest
+
+						sharedCache, err := cache.NewVolumeCache(cacheDir)
+						h.AssertNil(t, err)
+						h.AssertNil(t, sharedCache.AddLayerFile(sharedLayer.TarPath, sharedSHA))
+						h.AssertNil(t, sharedCache.SetMetadata(platform.CacheMetadata{Buildpacks: []buildpack.LayersMetadata{
+							{ID: "buildpack.id", Layers: map[string]buildpack.LayerMetadata{
+								"cache-only": {SHA: sharedSHA, LayerMetadataFile: buildpack.LayerMetadataFile{Cache: true}},
+							}},
+							{ID: "escaped/buildpack/id", Layers: map[string]buildpack.LayerMetadata{
+								"escaped-bp-layer": {SHA: sharedSHA, LayerMetadataFile: buildpack.LayerMetadataFile{Cache: true}},
+							}},
+						}}))
+						h.AssertNil(t, sharedCache.Commit())
+						h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "", ""))
+						h.AssertNil(t, writeLayer(layersDir, "escaped_buildpack_id", "escaped-bp-layer", "", ""))
+
+						counting = &countingCache{Cache: sharedCache, retrieved: map[string]int{}}
+						summary, err = restorer.Restore(counting)
+						h.AssertNil(t, err)
+					})
+
+					it("retrieves layers with the same sha once", func() {
+						assertLogEntry(t, logHandler, `Restoring data for "escaped/buildpack/id:escaped-bp-layer" from "buildpack.id:cache-only", same sha`)
+						h.AssertEq(t, counting.retrieved[sharedSHA], 1)
+						for _, layerDir := range []string{
+							filepath.Join(layersDir, "buildpack.id", "cache-only"),
+							filepath.Join(layersDir, "escaped_buildpack_id", "escaped-bp-layer"),
+						} {
+							got := h.MustReadFile(t, filepath.Join(layerDir, "some-shared-file"))
+							h.AssertEq(t, string(got), "some-shared-content")
+						}
+						h.AssertEq(t, summary.Restored, 2)
+					})
+				})
+
 				when("there is a cache=true layer in cache but not in group", func() {
 					it.Before(func() {
 						var meta, sha string
 						h.AssertNil(t, writeLayer(layersDir, "nogroup.buildpack.id", "some-layer", meta, sha))
-						h.AssertNil(t, restorer.Restore(testCache))
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
 					})
 
 					it("does not restore layer data", func() {
@@ -395,7 +1026,8 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 					when("the buildpack is detected", func() {
 						it.Before(func() {
 							restorer.Buildpacks = []buildpack.GroupElement{{ID: "nogroup.buildpack.id", API: buildpackAPI}}
-							h.AssertNil(t, restorer.Restore(testCache))
+							_, err := restorer.Restore(testCache)
+							h.AssertNil(t, err)
 						})
 
 						it("keeps metadata file", func() {
@@ -411,7 +1043,10 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 				})
 
 				when("there are multiple cache=true layers", func() {
-					var cacheOnlyMeta, cacheLaunchMeta, escapedMeta string
+					var (
+						cacheOnlyMeta, cacheLaunchMeta, escapedMeta string
+						summary                                     phase.RestoreSummary
+					)
 
 					it.Before(func() {
 						var typesMeta, cacheOnlySha, cacheLaunchSha, escapedSha string
@@ -446,7 +1081,9 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 
 						h.AssertNil(t, json.Unmarshal(appMetaContents, &restorer.LayersMetadata))
 
-						h.AssertNil(t, restorer.Restore(testCache))
+						var err error
+						summary, err = restorer.Restore(testCache)
+						h.AssertNil(t, err)
 					})
 
 					it("keeps layer metadata for all layers", func() {
@@ -469,6 +1106,15 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 						want = "echo text from escaped bp layer\n"
 						h.AssertEq(t, string(got), want)
 					})
+
+					it("summarizes the restored layers", func() {
+						h.AssertEq(t, summary.Restored, 3)
+						h.AssertEq(t, summary.RemovedWrongSHA, 0)
+						h.AssertEq(t, summary.RemovedNotInCache, 0)
+						if summary.BytesRestored <= 0 {
+							t.Fatalf("expected bytes restored to be positive, got %d", summary.BytesRestored)
+						}
+					})
 				})
 			})
 
@@ -494,9 +1140,53 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 
 				it("restores the SBOM layer from the cache", func() {
 					sbomRestorer.EXPECT().RestoreFromCache(testCache, "some-digest")
-					err := restorer.Restore(testCache)
+					_, err := restorer.Restore(testCache)
 					h.AssertNil(t, err)
 				})
+
+				when("restoring only SBOM data", func() {
+					it("restores the SBOM layer from the cache", func() {
+						restorer.SBOMOnly = true
+						sbomRestorer.EXPECT().RestoreFromCache(testCache, "some-digest")
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
+					})
+
+					when("dry run", func() {
+						it("does not restore the SBOM layer from the cache", func() {
+							restorer.SBOMOnly = true
+							restorer.DryRun = true
+							_, err := restorer.Restore(testCache)
+							h.AssertNil(t, err)
+							assertLogEntry(t, logHandler, "Dry run: would restore SBOM data only")
+						})
+					})
+				})
+
+				when("restoring only layer metadata", func() {
+					it("does not restore the SBOM layer from the cache", func() {
+						restorer.MetadataOnly = true
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
+					})
+				})
+
+				when("skipping SBOM data", func() {
+					it("leaves the SBOM directories untouched", func() {
+						restorer.SkipSBOM = true
+						restorer.SBOMRestorer = &layer.DefaultSBOMRestorer{LayersDir: layersDir, Logger: restorer.Logger}
+						h.AssertNil(t, os.MkdirAll(filepath.Join(layersDir, "sbom", "launch", "buildpack.id", "some-layer"), 0755))
+						h.Mkfile(t, "some-sbom", filepath.Join(layersDir, "sbom", "launch", "buildpack.id", "some-layer", "sbom.cdx.json"))
+
+						_, err := restorer.Restore(testCache)
+						h.AssertNil(t, err)
+
+						h.AssertPathExists(t, filepath.Join(layersDir, "sbom", "launch", "buildpack.id", "some-layer", "sbom.cdx.json"))
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "sbom", "cache"))
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "some-layer.sbom.cdx.json"))
+						h.AssertLogEntry(t, logHandler, "Skipping restore of SBOM data")
+					})
+				})
 			})
 
 			when("there is no app image metadata", func() {
@@ -505,7 +1195,7 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 				})
 
 				it("analyzes with no layer metadata", func() {
-					err := restorer.Restore(testCache)
+					_, err := restorer.Restore(testCache)
 					h.AssertNil(t, err)
 				})
 			})
@@ -513,6 +1203,181 @@ func testRestorer(buildpackAPI, platformAPI string) func(t *testing.T, when spec
 	}
 }
 
+// stalledCache is a cache from which layer data never arrives.
+type stalledCache struct {
+	phase.Cache
+}
+
+func (c *stalledCache) RetrieveLayer(_ string) (io.ReadCloser, error) {
+	pr, _ := io.Pipe()
+	return pr, nil
+}
+
+// stallingReadCache is a cache whose layer data stalls after the first bytes until it is closed.
+// stalled is closed once any layer data has stalled.
+type stallingReadCache struct {
+	phase.Cache
+	stalled chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	open    int
+}
+
+func (c *stallingReadCache) RetrieveLayer(sha string) (io.ReadCloser, error) {
+	rc, err := c.Cache.RetrieveLayer(sha)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.open++
+	c.mu.Unlock()
+	pr, _ := io.Pipe()
+	stall := readerFunc(func([]byte) (int, error) {
+		c.once.Do(func() { close(c.stalled) })
+		return pr.Read(nil)
+	})
+	var closeOnce sync.Once
+	return &stallingReader{Reader: io.MultiReader(io.LimitReader(rc, 512), stall), close: func() error {
+		// the restorer may close the layer data both when the context is done and after extraction
+		closeOnce.Do(func() {
+			c.mu.Lock()
+			c.open--
+			c.mu.Unlock()
+		})
+		_ = pr.Close()
+		return rc.Close()
+	}}, nil
+}
+
+// openReaders returns the number of layer data readers that have not been closed.
+func (c *stallingReadCache) openReaders() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.open
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+type stallingReader struct {
+	io.Reader
+	close func() error
+}
+
+func (r *stallingReader) Close() error {
+	return r.close()
+}
+
+// blockingCache is a cache whose calls to retrieve a layer block until unblock is closed.
+type blockingCache struct {
+	phase.Cache
+	unblock chan struct{}
+}
+
+func (c *blockingCache) RetrieveLayer(sha string) (io.ReadCloser, error) {
+	<-c.unblock
+	return c.Cache.RetrieveLayer(sha)
+}
+
+// countingCache is a cache that counts the calls to retrieve each layer.
+type countingCache struct {
+	phase.Cache
+	mu        sync.Mutex
+	retrieved map[string]int
+}
+
+func (c *countingCache) RetrieveLayer(sha string) (io.ReadCloser, error) {
+	c.mu.Lock()
+	c.retrieved[sha]++
+	c.mu.Unlock()
+	return c.Cache.RetrieveLayer(sha)
+}
+
+// failingReadCache is a cache whose layer data cannot be read to the end.
+type failingReadCache struct {
+	phase.Cache
+}
+
+func (c *failingReadCache) RetrieveLayer(sha string) (io.ReadCloser, error) {
+	rc, err := c.Cache.RetrieveLayer(sha)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(io.MultiReader(io.LimitReader(rc, 512), iotest.ErrReader(errors.New("some-read-error")))), nil
+}
+
+// unavailableCache is a cache from which layer data cannot be retrieved.
+type unavailableCache struct {
+	phase.Cache
+}
+
+func (c *unavailableCache) RetrieveLayer(_ string) (io.ReadCloser, error) {
+	return nil, errors.New("some-error")
+}
+
+const notATar = "not a tar"
+
+// notATarSHA is the digest of layer data that is not a valid tar.
+var notATarSHA = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(notATar)))
+
+// corruptCache is a cache whose layer data is not a valid tar, though it matches the digest recorded in the cache metadata.
+type corruptCache struct {
+	phase.Cache
+}
+
+func (c *corruptCache) RetrieveMetadata() (platform.CacheMetadata, error) {
+	return withLayerSHAs(c.Cache, func(string) bool { return true })
+}
+
+func (c *corruptCache) RetrieveLayer(_ string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(notATar)), nil
+}
+
+// partiallyCorruptCache is a cache whose layer data is not a valid tar for a single layer.
+type partiallyCorruptCache struct {
+	phase.Cache
+	corruptSHA string
+}
+
+func (c *partiallyCorruptCache) RetrieveMetadata() (platform.CacheMetadata, error) {
+	return withLayerSHAs(c.Cache, func(sha string) bool { return sha == c.corruptSHA })
+}
+
+func (c *partiallyCorruptCache) RetrieveLayer(sha string) (io.ReadCloser, error) {
+	if sha == notATarSHA {
+		return io.NopCloser(strings.NewReader(notATar)), nil
+	}
+	return c.Cache.RetrieveLayer(sha)
+}
+
+// withLayerSHAs returns the metadata of the cache, with the SHA of each matching layer replaced by notATarSHA.
+func withLayerSHAs(cache phase.Cache, matches func(sha string) bool) (platform.CacheMetadata, error) {
+	meta, err := cache.RetrieveMetadata()
+	if err != nil {
+		return platform.CacheMetadata{}, err
+	}
+	for _, bpMD := range meta.Buildpacks {
+		for name, layerMD := range bpMD.Layers {
+			if matches(layerMD.SHA) {
+				layerMD.SHA = notATarSHA
+				bpMD.Layers[name] = layerMD
+			}
+		}
+	}
+	return meta, nil
+}
+
+type versionedLayout struct {
+	version string
+}
+
+func (l versionedLayout) BuildpackDir(layersDir string, bp buildpack.GroupElement) string {
+	return filepath.Join(layersDir, l.version, launch.EscapeID(bp.ID))
+}
+
 func writeLayer(layersDir, buildpack, name, metadata, sha string) error {
 	buildpackDir := filepath.Join(layersDir, buildpack)
 	if err := os.MkdirAll(buildpackDir, 0755); err != nil {
@@ -1,7 +1,10 @@
 package phase
 
 import (
+	"sort"
 	"strings"
+
+	"github.com/buildpacks/lifecycle/internal/str"
 )
 
 func TruncateSha(sha string) string {
@@ -24,3 +27,22 @@ func removeStagePrefixes(mixins []string) []string {
 	}
 	return result
 }
+
+// missingMixins returns the (sorted) mixins in `required` that are not present in `provided`, ignoring stage prefixes.
+func missingMixins(required, provided []string) []string {
+	_, missing, _ := str.Compare(removeStagePrefixes(provided), removeStagePrefixes(required))
+	sort.Strings(missing)
+	return missing
+}
+
+// runStageMixins returns the provided mixins that are needed at runtime, i.e., all mixins without a `build:` stage prefix.
+func runStageMixins(mixins []string) []string {
+	var result []string
+	for _, m := range mixins {
+		if strings.HasPrefix(m, "build:") {
+			continue
+		}
+		result = append(result, m)
+	}
+	return result
+}
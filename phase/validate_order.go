@@ -0,0 +1,46 @@
+package phase
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/buildpacks/lifecycle/log"
+)
+
+// ValidateOrder reads the order file at the provided path and ensures that each buildpack and extension in it
+// exists in the buildpacks or extensions directory and declares a supported Buildpack API.
+// Unlike detection, it does not stop at the first problem: all problems are returned as a single combined error,
+// so that a broken order can be fixed before the detector runs.
+// If a label selector is provided, only buildpacks that carry the selected label are validated.
+func (f *HermeticFactory) ValidateOrder(path, labelSelector string, logger log.Logger) error {
+	orderBp, orderExt, err := f.configHandler.ReadOrder(path)
+	if err != nil {
+		return fmt.Errorf("reading order: %w", err)
+	}
+	if orderBp, err = f.filterOrder(orderBp, labelSelector, logger); err != nil {
+		return fmt.Errorf("invalid order %q: %w", path, err)
+	}
+	var errs []error
+	seen := make(map[string]bool)
+	for _, group := range append(orderBp, orderExt...) {
+		for _, groupEl := range group.Group {
+			key := groupEl.Kind() + ":" + groupEl.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			module, err := f.dirStore.Lookup(groupEl.Kind(), groupEl.ID, groupEl.Version)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if err = f.apiVerifier.VerifyBuildpackAPI(groupEl.Kind(), groupEl.String(), module.API(), logger); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid order %q: %w", path, errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package phase_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/api"
+	"github.com/buildpacks/lifecycle/buildpack"
+	"github.com/buildpacks/lifecycle/log"
+	"github.com/buildpacks/lifecycle/phase"
+	"github.com/buildpacks/lifecycle/phase/testmock"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestValidateOrder(t *testing.T) {
+	spec.Run(t, "ValidateOrder", testValidateOrder, spec.Report(report.Terminal{}))
+}
+
+func testValidateOrder(t *testing.T, when spec.G, it spec.S) {
+	var (
+		mockController *gomock.Controller
+
+		apiVerifier   *testmock.MockBuildpackAPIVerifier
+		configHandler *testmock.MockConfigHandler
+		dirStore      *testmock.MockDirStore
+		logger        log.Logger
+
+		factory *phase.HermeticFactory
+	)
+
+	it.Before(func() {
+		mockController = gomock.NewController(t)
+
+		apiVerifier = testmock.NewMockBuildpackAPIVerifier(mockController)
+		configHandler = testmock.NewMockConfigHandler(mockController)
+		dirStore = testmock.NewMockDirStore(mockController)
+		logger = log.NewDefaultLogger(io.Discard)
+
+		factory = phase.NewHermeticFactory(api.Platform.Latest(), apiVerifier, configHandler, dirStore)
+	})
+
+	it.After(func() {
+		mockController.Finish()
+	})
+
+	when("#ValidateOrder", func() {
+		it("verifies each buildpack and extension once", func() {
+			orderBp := buildpack.Order{
+				buildpack.Group{Group: []buildpack.GroupElement{{ID: "A", Version: "v1"}, {ID: "B", Version: "v1"}}},
+				buildpack.Group{Group: []buildpack.GroupElement{{ID: "A", Version: "v1"}}},
+			}
+			orderExt := buildpack.Order{
+				buildpack.Group{Group: []buildpack.GroupElement{{ID: "C", Version: "v1", Extension: true}}},
+			}
+			configHandler.EXPECT().ReadOrder("some-order-path").Return(orderBp, orderExt, nil)
+			dirStore.EXPECT().Lookup(buildpack.KindBuildpack, "A", "v1").Return(&buildpack.BpDescriptor{WithAPI: "0.9"}, nil)
+			apiVerifier.EXPECT().VerifyBuildpackAPI(buildpack.KindBuildpack, "A@v1", "0.9", logger)
+			dirStore.EXPECT().Lookup(buildpack.KindBuildpack, "B", "v1").Return(&buildpack.BpDescriptor{WithAPI: "0.10"}, nil)
+			apiVerifier.EXPECT().VerifyBuildpackAPI(buildpack.KindBuildpack, "B@v1", "0.10", logger)
+			dirStore.EXPECT().Lookup(buildpack.KindExtension, "C", "v1").Return(&buildpack.ExtDescriptor{WithAPI: "0.10"}, nil)
+			apiVerifier.EXPECT().VerifyBuildpackAPI(buildpack.KindExtension, "C@v1", "0.10", logger)
+
+			h.AssertNil(t, factory.ValidateOrder("some-order-path", "", logger))
+		})
+
+		when("there are problems with multiple buildpacks", func() {
+			it("returns all of them", func() {
+				orderBp := buildpack.Order{
+					buildpack.Group{Group: []buildpack.GroupElement{{ID: "A", Version: "v1"}, {ID: "B", Version: "v1"}, {ID: "C", Version: "v1"}}},
+				}
+				configHandler.EXPECT().ReadOrder("some-order-path").Return(orderBp, nil, nil)
+				dirStore.EXPECT().Lookup(buildpack.KindBuildpack, "A", "v1").Return(nil, errors.New("some-lookup-error"))
+				dirStore.EXPECT().Lookup(buildpack.KindBuildpack, "B", "v1").Return(&buildpack.BpDescriptor{WithAPI: "0.1"}, nil)
+				apiVerifier.EXPECT().VerifyBuildpackAPI(buildpack.KindBuildpack, "B@v1", "0.1", logger).Return(errors.New("some-api-error"))
+				dirStore.EXPECT().Lookup(buildpack.KindBuildpack, "C", "v1").Return(&buildpack.BpDescriptor{WithAPI: "0.10"}, nil)
+				apiVerifier.EXPECT().VerifyBuildpackAPI(buildpack.KindBuildpack, "C@v1", "0.10", logger)
+
+				err := factory.ValidateOrder("some-order-path", "", logger)
+				h.AssertError(t, err, `invalid order "some-order-path"`)
+				h.AssertError(t, err, "some-lookup-error")
+				h.AssertError(t, err, "some-api-error")
+			})
+		})
+
+		when("the order cannot be read", func() {
+			it("errors", func() {
+				configHandler.EXPECT().ReadOrder("some-order-path").Return(nil, nil, errors.New("some-read-error"))
+
+				err := factory.ValidateOrder("some-order-path", "", logger)
+				h.AssertError(t, err, "reading order: some-read-error")
+			})
+		})
+	})
+}
@@ -0,0 +1,71 @@
+package platform
+
+import (
+	"github.com/buildpacks/lifecycle/log"
+	"github.com/buildpacks/lifecycle/platform/files"
+)
+
+// NewAnalyzeReport returns a report of the decisions recorded in the provided analyzed metadata.
+// To determine whether the selected run image is a mirror, the run image metadata is read from run.toml (or stack.toml);
+// if it cannot be read, the run image is not reported as a mirror.
+func NewAnalyzeReport(inputs *LifecycleInputs, analyzedMD files.Analyzed, logger log.Logger) files.AnalyzeReport {
+	report := files.AnalyzeReport{
+		RunImage: files.AnalyzeRunImageReport{
+			Image: inputs.RunImageRef,
+		},
+		PreviousImage: files.AnalyzePreviousImageReport{
+			Reference: analyzedMD.PreviousImageRef(),
+			Found:     analyzedMD.PreviousImageRef() != "",
+		},
+		Cache: files.AnalyzeCacheReport{
+			Image: inputs.CacheImageRef,
+			Dir:   inputs.CacheDir,
+		},
+	}
+	if !inputs.SkipPrevious {
+		report.PreviousImage.Image = selectedPreviousImage(inputs, analyzedMD)
+	}
+	if analyzedMD.RunImage != nil {
+		report.RunImage.Reference = analyzedMD.RunImage.Reference
+		if analyzedMD.RunImage.TargetMetadata != nil {
+			report.StackID = analyzedMD.RunImage.TargetMetadata.ID
+		}
+	}
+	if runImageMD, ok := readRunImageMD(inputs, logger); ok {
+		for _, mirror := range runImageMD.Mirrors {
+			if mirror == inputs.RunImageRef && mirror != runImageMD.Image {
+				report.RunImage.Mirror = true
+			}
+		}
+	}
+	return report
+}
+
+// selectedPreviousImage returns the candidate reference the analyzer selected for the previous image:
+// the candidate that was found, or the last candidate if none was found.
+func selectedPreviousImage(inputs *LifecycleInputs, analyzedMD files.Analyzed) string {
+	if analyzedMD.PreviousImage != nil && analyzedMD.PreviousImage.Image != "" {
+		return analyzedMD.PreviousImage.Image
+	}
+	refs := inputs.PreviousImageRefs()
+	if len(refs) == 0 {
+		return ""
+	}
+	return refs[len(refs)-1]
+}
+
+// readRunImageMD reads the metadata for the run image from run.toml for Platform API >= 0.12, or stack.toml otherwise.
+func readRunImageMD(inputs *LifecycleInputs, logger log.Logger) (files.RunImageForExport, bool) {
+	if inputs.PlatformAPI.LessThan("0.12") {
+		stackMD, err := files.Handler.ReadStack(inputs.StackPath, logger)
+		if err != nil {
+			return files.RunImageForExport{}, false
+		}
+		return stackMD.RunImage, true
+	}
+	runMD, err := files.Handler.ReadRun(inputs.RunPath, logger)
+	if err != nil || len(runMD.Images) == 0 {
+		return files.RunImageForExport{}, false
+	}
+	return runMD.Images[0], true
+}
@@ -0,0 +1,119 @@
+package platform_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/api"
+	"github.com/buildpacks/lifecycle/platform"
+	"github.com/buildpacks/lifecycle/platform/files"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestAnalyzeReport(t *testing.T) {
+	spec.Run(t, "AnalyzeReport", testAnalyzeReport, spec.Report(report.Terminal{}))
+}
+
+func testAnalyzeReport(t *testing.T, when spec.G, it spec.S) {
+	when("#NewAnalyzeReport", func() {
+		var (
+			tmpDir string
+			inputs *platform.LifecycleInputs
+			logger = &log.Logger{Handler: memory.New()}
+		)
+
+		it.Before(func() {
+			var err error
+			tmpDir, err = os.MkdirTemp("", "analyze-report")
+			h.AssertNil(t, err)
+			runPath := filepath.Join(tmpDir, "run.toml")
+			h.AssertNil(t, os.WriteFile(runPath, []byte(`[[images]]
+  image = "some-registry.io/run"
+  mirrors = ["other-registry.io/run"]
+`), 0600))
+			inputs = &platform.LifecycleInputs{
+				PlatformAPI:      api.MustParse("0.13"),
+				CacheImageRef:    "some-cache-image",
+				PreviousImageRef: "some-registry.io/app",
+				RunImageRef:      "other-registry.io/run",
+				RunPath:          runPath,
+			}
+		})
+
+		it.After(func() {
+			h.AssertNil(t, os.RemoveAll(tmpDir))
+		})
+
+		it("records the decisions in the analyzed metadata", func() {
+			analyzedMD := files.Analyzed{
+				PreviousImage: &files.ImageIdentifier{Reference: "some-registry.io/app@sha256:abc"},
+				RunImage: &files.RunImage{
+					Reference:      "other-registry.io/run@sha256:def",
+					TargetMetadata: &files.TargetMetadata{ID: "some-stack-id", OS: "linux"},
+				},
+			}
+
+			h.AssertEq(t, platform.NewAnalyzeReport(inputs, analyzedMD, logger), files.AnalyzeReport{
+				RunImage: files.AnalyzeRunImageReport{
+					Image:     "other-registry.io/run",
+					Reference: "other-registry.io/run@sha256:def",
+					Mirror:    true,
+				},
+				PreviousImage: files.AnalyzePreviousImageReport{
+					Image:     "some-registry.io/app",
+					Reference: "some-registry.io/app@sha256:abc",
+					Found:     true,
+				},
+				StackID: "some-stack-id",
+				Cache:   files.AnalyzeCacheReport{Image: "some-cache-image"},
+			})
+		})
+
+		when("the previous image was selected from multiple candidates", func() {
+			it.Before(func() {
+				inputs.PreviousImageRef = "some-registry.io/app:some-branch,some-registry.io/app"
+			})
+
+			it("records the candidate that was found", func() {
+				analyzedMD := files.Analyzed{PreviousImage: &files.ImageIdentifier{
+					Reference: "some-registry.io/app@sha256:abc",
+					Image:     "some-registry.io/app:some-branch",
+				}}
+
+				analyzeReport := platform.NewAnalyzeReport(inputs, analyzedMD, logger)
+
+				h.AssertEq(t, analyzeReport.PreviousImage.Image, "some-registry.io/app:some-branch")
+				h.AssertEq(t, analyzeReport.PreviousImage.Found, true)
+			})
+
+			when("no candidate was found", func() {
+				it("records the last candidate", func() {
+					analyzedMD := files.Analyzed{PreviousImage: &files.ImageIdentifier{}}
+
+					analyzeReport := platform.NewAnalyzeReport(inputs, analyzedMD, logger)
+
+					h.AssertEq(t, analyzeReport.PreviousImage.Image, "some-registry.io/app")
+					h.AssertEq(t, analyzeReport.PreviousImage.Found, false)
+				})
+			})
+		})
+
+		when("the previous image was not found and the run image is not a mirror", func() {
+			it("records it", func() {
+				inputs.RunImageRef = "some-registry.io/run"
+				analyzedMD := files.Analyzed{PreviousImage: &files.ImageIdentifier{}}
+
+				analyzeReport := platform.NewAnalyzeReport(inputs, analyzedMD, logger)
+
+				h.AssertEq(t, analyzeReport.PreviousImage.Found, false)
+				h.AssertEq(t, analyzeReport.RunImage.Mirror, false)
+			})
+		})
+	})
+}
@@ -0,0 +1,144 @@
+package platform
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/buildpacks/lifecycle/buildpack"
+	"github.com/buildpacks/lifecycle/internal/encoding"
+	"github.com/buildpacks/lifecycle/platform/files"
+)
+
+// Difference is a difference in a single field between two analyzed.toml files.
+// Field is the path of the field in analyzed.toml, e.g., `run-image.reference`;
+// Old and New are the values of the field in the first and second files, or empty if the field is not present.
+type Difference struct {
+	Field string
+	Old   string
+	New   string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %q -> %q", d.Field, d.Old, d.New)
+}
+
+// DiffAnalyzed returns the field-level differences between the provided analyzed metadata, in a stable order.
+// It is intended for debugging, e.g., to understand why layers from a previous build were not reused.
+// The time of analysis is ignored, as it differs between any two builds.
+func DiffAnalyzed(before, after files.Analyzed) []Difference {
+	var diffs []Difference
+	add := func(field, oldVal, newVal string) {
+		if oldVal != newVal {
+			diffs = append(diffs, Difference{Field: field, Old: oldVal, New: newVal})
+		}
+	}
+
+	oldPrevious, newPrevious := imageIdentifierOrEmpty(before.PreviousImage), imageIdentifierOrEmpty(after.PreviousImage)
+	add("image.reference", oldPrevious.Reference, newPrevious.Reference)
+	add("image.image", oldPrevious.Image, newPrevious.Image)
+
+	oldBuild, newBuild := imageIdentifierOrEmpty(before.BuildImage), imageIdentifierOrEmpty(after.BuildImage)
+	add("build-image.reference", oldBuild.Reference, newBuild.Reference)
+	add("build-image.image", oldBuild.Image, newBuild.Image)
+
+	oldRun, newRun := runImageOrEmpty(before.RunImage), runImageOrEmpty(after.RunImage)
+	add("run-image.reference", oldRun.Reference, newRun.Reference)
+	add("run-image.image", oldRun.Image, newRun.Image)
+	add("run-image.digest", oldRun.Digest, newRun.Digest)
+	add("run-image.pinned", strconv.FormatBool(oldRun.Pinned), strconv.FormatBool(newRun.Pinned))
+	add("run-image.extend", strconv.FormatBool(oldRun.Extend), strconv.FormatBool(newRun.Extend))
+	add("run-image.target", targetOrEmpty(oldRun.TargetMetadata), targetOrEmpty(newRun.TargetMetadata))
+
+	oldMD, newMD := before.LayersMetadata, after.LayersMetadata
+	add("metadata.app", layerSHAs(oldMD.App), layerSHAs(newMD.App))
+	add("metadata.sbom.sha", layerSHAOrEmpty(oldMD.BOM), layerSHAOrEmpty(newMD.BOM))
+	add("metadata.config.sha", oldMD.Config.SHA, newMD.Config.SHA)
+	add("metadata.launcher.sha", oldMD.Launcher.SHA, newMD.Launcher.SHA)
+	add("metadata.process-types.sha", oldMD.ProcessTypes.SHA, newMD.ProcessTypes.SHA)
+	add("metadata.run-image.top-layer", oldMD.RunImage.TopLayer, newMD.RunImage.TopLayer)
+	add("metadata.run-image.reference", oldMD.RunImage.Reference, newMD.RunImage.Reference)
+	add("metadata.run-image.image", oldMD.RunImage.Image, newMD.RunImage.Image)
+	add("metadata.run-image.mirrors", strings.Join(oldMD.RunImage.Mirrors, ","), strings.Join(newMD.RunImage.Mirrors, ","))
+	oldStack, newStack := stackOrEmpty(oldMD.Stack), stackOrEmpty(newMD.Stack)
+	add("metadata.stack.run-image.image", oldStack.RunImage.Image, newStack.RunImage.Image)
+	add("metadata.stack.run-image.mirrors", strings.Join(oldStack.RunImage.Mirrors, ","), strings.Join(newStack.RunImage.Mirrors, ","))
+
+	for _, bpID := range buildpackIDs(oldMD, newMD) {
+		oldBp, newBp := oldMD.LayersMetadataFor(bpID), newMD.LayersMetadataFor(bpID)
+		field := fmt.Sprintf("metadata.buildpacks.%s", bpID)
+		add(field+".version", oldBp.Version, newBp.Version)
+		var layerNames []string
+		for layerName := range oldBp.Layers {
+			layerNames = append(layerNames, layerName)
+		}
+		for layerName := range newBp.Layers {
+			if _, ok := oldBp.Layers[layerName]; !ok {
+				layerNames = append(layerNames, layerName)
+			}
+		}
+		sort.Strings(layerNames)
+		for _, layerName := range layerNames {
+			add(fmt.Sprintf("%s.layers.%s.sha", field, layerName), oldBp.Layers[layerName].SHA, newBp.Layers[layerName].SHA)
+		}
+	}
+	return diffs
+}
+
+func imageIdentifierOrEmpty(id *files.ImageIdentifier) files.ImageIdentifier {
+	if id == nil {
+		return files.ImageIdentifier{}
+	}
+	return *id
+}
+
+func runImageOrEmpty(runImage *files.RunImage) files.RunImage {
+	if runImage == nil {
+		return files.RunImage{}
+	}
+	return *runImage
+}
+
+func stackOrEmpty(stack *files.Stack) files.Stack {
+	if stack == nil {
+		return files.Stack{}
+	}
+	return *stack
+}
+
+func targetOrEmpty(target *files.TargetMetadata) string {
+	if target == nil {
+		return ""
+	}
+	return encoding.ToJSONMaybe(*target)
+}
+
+func layerSHAOrEmpty(layer *files.LayerMetadata) string {
+	if layer == nil {
+		return ""
+	}
+	return layer.SHA
+}
+
+func layerSHAs(layers []files.LayerMetadata) string {
+	var shas []string
+	for _, layer := range layers {
+		shas = append(shas, layer.SHA)
+	}
+	return strings.Join(shas, ",")
+}
+
+// buildpackIDs returns the IDs of buildpacks with layers metadata in either of the provided metadata,
+// in the order in which they first appear.
+func buildpackIDs(before, after files.LayersMetadata) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, bpMD := range append(append([]buildpack.LayersMetadata{}, before.Buildpacks...), after.Buildpacks...) {
+		if !seen[bpMD.ID] {
+			seen[bpMD.ID] = true
+			ids = append(ids, bpMD.ID)
+		}
+	}
+	return ids
+}
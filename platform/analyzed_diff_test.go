@@ -0,0 +1,92 @@
+package platform_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/buildpack"
+	"github.com/buildpacks/lifecycle/platform"
+	"github.com/buildpacks/lifecycle/platform/files"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestAnalyzedDiff(t *testing.T) {
+	spec.Run(t, "AnalyzedDiff", testAnalyzedDiff, spec.Report(report.Terminal{}))
+}
+
+func testAnalyzedDiff(t *testing.T, when spec.G, it spec.S) {
+	when(".DiffAnalyzed", func() {
+		var before files.Analyzed
+
+		it.Before(func() {
+			analyzedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			before = files.Analyzed{
+				PreviousImage: &files.ImageIdentifier{Reference: "some-previous-image@sha256:abc"},
+				RunImage:      &files.RunImage{Reference: "some-run-image@sha256:def", Image: "some-run-image"},
+				LayersMetadata: files.LayersMetadata{
+					Buildpacks: []buildpack.LayersMetadata{{
+						ID:      "some-buildpack",
+						Version: "1.0.0",
+						Layers: map[string]buildpack.LayerMetadata{
+							"some-layer":  {SHA: "some-sha"},
+							"other-layer": {SHA: "other-sha"},
+						},
+					}},
+					Stack: &files.Stack{RunImage: files.RunImageForExport{Image: "some-run-image"}},
+				},
+				AnalyzedAt: &analyzedAt,
+			}
+		})
+
+		when("the metadata is the same", func() {
+			it("returns no differences", func() {
+				after := before
+				after.AnalyzedAt = nil
+				h.AssertEq(t, len(platform.DiffAnalyzed(before, after)), 0)
+			})
+		})
+
+		when("the metadata is different", func() {
+			it("returns the differences in a stable order", func() {
+				after := files.Analyzed{
+					RunImage: &files.RunImage{Reference: "some-run-image@sha256:123", Image: "some-run-image"},
+					LayersMetadata: files.LayersMetadata{
+						Buildpacks: []buildpack.LayersMetadata{
+							{
+								ID:      "some-buildpack",
+								Version: "1.0.1",
+								Layers: map[string]buildpack.LayerMetadata{
+									"some-layer": {SHA: "new-sha"},
+									"new-layer":  {SHA: "new-layer-sha"},
+								},
+							},
+							{
+								ID:     "new-buildpack",
+								Layers: map[string]buildpack.LayerMetadata{"some-layer": {SHA: "some-sha"}},
+							},
+						},
+						Stack: &files.Stack{RunImage: files.RunImageForExport{Image: "other-run-image", Mirrors: []string{"some-mirror"}}},
+					},
+				}
+
+				diffs := platform.DiffAnalyzed(before, after)
+
+				h.AssertEq(t, diffs, []platform.Difference{
+					{Field: "image.reference", Old: "some-previous-image@sha256:abc", New: ""},
+					{Field: "run-image.reference", Old: "some-run-image@sha256:def", New: "some-run-image@sha256:123"},
+					{Field: "metadata.stack.run-image.image", Old: "some-run-image", New: "other-run-image"},
+					{Field: "metadata.stack.run-image.mirrors", Old: "", New: "some-mirror"},
+					{Field: "metadata.buildpacks.some-buildpack.version", Old: "1.0.0", New: "1.0.1"},
+					{Field: "metadata.buildpacks.some-buildpack.layers.new-layer.sha", Old: "", New: "new-layer-sha"},
+					{Field: "metadata.buildpacks.some-buildpack.layers.other-layer.sha", Old: "other-sha", New: ""},
+					{Field: "metadata.buildpacks.some-buildpack.layers.some-layer.sha", Old: "some-sha", New: "new-sha"},
+					{Field: "metadata.buildpacks.new-buildpack.layers.some-layer.sha", Old: "", New: "some-sha"},
+				})
+				h.AssertEq(t, diffs[1].String(), `run-image.reference: "some-run-image@sha256:def" -> "some-run-image@sha256:123"`)
+			})
+		})
+	})
+}
@@ -18,3 +18,25 @@ func (cm *CacheMetadata) MetadataForBuildpack(id string) buildpack.LayersMetadat
 	}
 	return buildpack.LayersMetadata{}
 }
+
+// LayerSHAs returns the SHAs of the layers referenced by the cache metadata, including the SBOM layer.
+func (cm *CacheMetadata) LayerSHAs() []string {
+	var shas []string
+	if cm.BOM.SHA != "" {
+		shas = append(shas, cm.BOM.SHA)
+	}
+	for _, bpMD := range cm.Buildpacks {
+		for _, layerMD := range bpMD.Layers {
+			if layerMD.SHA != "" {
+				shas = append(shas, layerMD.SHA)
+			}
+		}
+	}
+	return shas
+}
+
+// CacheSource is a cache to restore from, provided as either a cache image reference or a cache directory.
+type CacheSource struct {
+	ImageRef string
+	Dir      string
+}
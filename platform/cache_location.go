@@ -0,0 +1,31 @@
+package platform
+
+import "strings"
+
+// The schemes of the built-in cache backends.
+// Cache images and cache directories provided without a scheme use CacheSchemeImage and CacheSchemeFile, respectively.
+const (
+	CacheSchemeImage = "image"
+	CacheSchemeFile  = "file"
+)
+
+// SplitCacheLocation returns the scheme and the location of the provided cache image or cache directory,
+// e.g., `s3` and `some-bucket/some-prefix` for `s3://some-bucket/some-prefix`.
+// If the value does not have a scheme, the scheme returned is empty and the location is the value.
+func SplitCacheLocation(value string) (scheme, location string) {
+	scheme, location, ok := strings.Cut(value, "://")
+	if !ok || scheme == "" || strings.ContainsAny(scheme, "/\\") {
+		return "", value
+	}
+	return strings.ToLower(scheme), location
+}
+
+// RegistryCacheImageRef returns the reference of the cache image if it is stored in a registry,
+// or an empty string if a cache image was not provided or it is stored by a cache backend with another scheme, e.g., `s3`.
+func (i *LifecycleInputs) RegistryCacheImageRef() string {
+	scheme, location := SplitCacheLocation(i.CacheImageRef)
+	if scheme != "" && scheme != CacheSchemeImage {
+		return ""
+	}
+	return location
+}
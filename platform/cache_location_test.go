@@ -0,0 +1,58 @@
+package platform_test
+
+import (
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/api"
+	"github.com/buildpacks/lifecycle/platform"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestCacheLocation(t *testing.T) {
+	spec.Run(t, "CacheLocation", testCacheLocation, spec.Report(report.Terminal{}))
+}
+
+func testCacheLocation(t *testing.T, when spec.G, it spec.S) {
+	when(".SplitCacheLocation", func() {
+		it("splits the scheme from the location", func() {
+			scheme, location := platform.SplitCacheLocation("S3://some-bucket/some-prefix")
+			h.AssertEq(t, scheme, "s3")
+			h.AssertEq(t, location, "some-bucket/some-prefix")
+		})
+
+		it("returns an empty scheme for cache images and directories without a scheme", func() {
+			for _, value := range []string{"some-registry.io/some-cache:latest", "/some/cache", "some/path://with-separator"} {
+				scheme, location := platform.SplitCacheLocation(value)
+				h.AssertEq(t, scheme, "")
+				h.AssertEq(t, location, value)
+			}
+		})
+	})
+
+	when("#RegistryCacheImageRef", func() {
+		var inputs *platform.LifecycleInputs
+
+		it.Before(func() {
+			inputs = platform.NewLifecycleInputs(api.Platform.Latest())
+		})
+
+		it("returns the cache image", func() {
+			inputs.CacheImageRef = "some-registry.io/some-cache"
+			h.AssertEq(t, inputs.RegistryCacheImageRef(), "some-registry.io/some-cache")
+
+			inputs.CacheImageRef = "image://some-registry.io/some-cache"
+			h.AssertEq(t, inputs.RegistryCacheImageRef(), "some-registry.io/some-cache")
+		})
+
+		when("the cache image is stored by another cache backend", func() {
+			it("returns an empty string so that it is not validated as an image", func() {
+				inputs.CacheImageRef = "s3://some-bucket/some-prefix"
+				h.AssertEq(t, inputs.RegistryCacheImageRef(), "")
+				h.AssertNil(t, platform.ValidateImageRefs(inputs, nil))
+			})
+		})
+	})
+}
@@ -0,0 +1,93 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/buildpacks/lifecycle/log"
+)
+
+// CacheNamespacesDir is the directory beneath a cache directory that contains the caches for each namespace.
+const CacheNamespacesDir = "namespaces"
+
+// maxTagLength is the maximum length of an image tag, per the OCI distribution spec.
+const maxTagLength = 128
+
+var invalidNamespaceChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// SanitizeCacheNamespace returns the provided namespace with each character that is invalid in an image tag replaced by `-`,
+// e.g., so that a branch name such as `feature/foo` may be used as the namespace `feature-foo`.
+func SanitizeCacheNamespace(namespace string) string {
+	return invalidNamespaceChars.ReplaceAllString(namespace, "-")
+}
+
+// Namespaced returns the cache source for the provided namespace.
+// A namespace partitions a cache entirely: each namespace has its own cache metadata and layers,
+// and the per-buildpack layer keys within a namespace are unchanged.
+// For a cache image, the tag is suffixed with the namespace, e.g., `some-registry.io/cache:latest` becomes `some-registry.io/cache:latest-some-namespace`;
+// for a cache directory, the cache for the namespace is stored in a `namespaces/<namespace>` subdirectory.
+func (s CacheSource) Namespaced(namespace string) (CacheSource, error) {
+	namespace = SanitizeCacheNamespace(namespace)
+	if namespace == "" {
+		return s, nil
+	}
+	var namespaced CacheSource
+	if s.ImageRef != "" {
+		tag, err := name.NewTag(s.ImageRef, name.WeakValidation)
+		if err != nil {
+			return CacheSource{}, fmt.Errorf("cache image %q must be a tag to use a cache namespace: %w", s.ImageRef, err)
+		}
+		suffixed := tag.TagStr() + "-" + namespace
+		if len(suffixed) > maxTagLength {
+			return CacheSource{}, fmt.Errorf("cache namespace %q is too long for cache image %q", namespace, s.ImageRef)
+		}
+		namespaced.ImageRef = tag.Context().Tag(suffixed).Name()
+	}
+	if s.Dir != "" {
+		namespaced.Dir = filepath.Join(s.Dir, CacheNamespacesDir, namespace)
+	}
+	return namespaced, nil
+}
+
+// ResolveCacheNamespace scopes the cache to the provided cache namespace, if any.
+// Caches for each of the provided fallback namespaces are added as additional cache sources, in order,
+// so that the restorer may restore layers cached by, e.g., the default branch when the namespace has no cached data.
+// Only the cache for the namespace is written to.
+func ResolveCacheNamespace(i *LifecycleInputs, logger log.Logger) error {
+	if i.CacheNamespace == "" {
+		if len(i.CacheNamespaceFallbacks) > 0 {
+			logger.Warn("Ignoring cache namespace fallbacks, no cache namespace specified.")
+		}
+		return nil
+	}
+	sources := i.CacheSources
+	if len(sources) <= 1 {
+		switch {
+		case i.CacheImageRef != "":
+			sources = []CacheSource{{ImageRef: i.CacheImageRef}}
+		case i.CacheDir != "":
+			sources = []CacheSource{{Dir: i.CacheDir}}
+		default:
+			return errors.New("a cache namespace requires a cache image or cache directory")
+		}
+	}
+	var namespaced []CacheSource
+	for _, namespace := range append([]string{i.CacheNamespace}, i.CacheNamespaceFallbacks...) {
+		for _, source := range sources {
+			namespacedSource, err := source.Namespaced(namespace)
+			if err != nil {
+				return err
+			}
+			namespaced = append(namespaced, namespacedSource)
+		}
+	}
+	i.CacheImageRef, i.CacheDir = namespaced[0].ImageRef, namespaced[0].Dir
+	if len(namespaced) > 1 {
+		i.CacheSources = namespaced
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package platform_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/api"
+	"github.com/buildpacks/lifecycle/platform"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestCacheNamespace(t *testing.T) {
+	spec.Run(t, "CacheNamespace", testCacheNamespace, spec.Report(report.Terminal{}))
+}
+
+func testCacheNamespace(t *testing.T, when spec.G, it spec.S) {
+	when("CacheSource#Namespaced", func() {
+		it("suffixes the tag of a cache image", func() {
+			source, err := platform.CacheSource{ImageRef: "some-registry.io/cache:latest"}.Namespaced("feature/foo")
+			h.AssertNil(t, err)
+			h.AssertEq(t, source, platform.CacheSource{ImageRef: "some-registry.io/cache:latest-feature-foo"})
+		})
+
+		it("uses a subdirectory of a cache directory", func() {
+			source, err := platform.CacheSource{Dir: "/some/cache"}.Namespaced("some-namespace")
+			h.AssertNil(t, err)
+			h.AssertEq(t, source, platform.CacheSource{Dir: filepath.Join("/some/cache", "namespaces", "some-namespace")})
+		})
+
+		when("the namespace is empty", func() {
+			it("returns the source unchanged", func() {
+				source, err := platform.CacheSource{ImageRef: "some-registry.io/cache"}.Namespaced("")
+				h.AssertNil(t, err)
+				h.AssertEq(t, source, platform.CacheSource{ImageRef: "some-registry.io/cache"})
+			})
+		})
+
+		when("the cache image is a digest reference", func() {
+			it("errors", func() {
+				_, err := platform.CacheSource{ImageRef: "some-registry.io/cache@sha256:" + sha256Hex}.Namespaced("some-namespace")
+				h.AssertError(t, err, "must be a tag to use a cache namespace")
+			})
+		})
+	})
+
+	when(".ResolveCacheNamespace", func() {
+		var (
+			inputs *platform.LifecycleInputs
+			logger = &log.Logger{Handler: memory.New()}
+		)
+
+		it.Before(func() {
+			inputs = platform.NewLifecycleInputs(api.Platform.Latest())
+			inputs.CacheImageRef = "some-registry.io/cache"
+			inputs.CacheSources = nil
+		})
+
+		it("scopes the cache to the namespace", func() {
+			inputs.CacheNamespace = "some-namespace"
+			h.AssertNil(t, platform.ResolveCacheNamespace(inputs, logger))
+			h.AssertEq(t, inputs.CacheImageRef, "some-registry.io/cache:latest-some-namespace")
+			h.AssertEq(t, len(inputs.CacheSources), 0)
+		})
+
+		it("adds the fallback namespaces as cache sources, in order", func() {
+			inputs.CacheNamespace = "some-namespace"
+			inputs.CacheNamespaceFallbacks = []string{"main", "other"}
+			h.AssertNil(t, platform.ResolveCacheNamespace(inputs, logger))
+			h.AssertEq(t, inputs.CacheImageRef, "some-registry.io/cache:latest-some-namespace")
+			h.AssertEq(t, inputs.CacheSources, []platform.CacheSource{
+				{ImageRef: "some-registry.io/cache:latest-some-namespace"},
+				{ImageRef: "some-registry.io/cache:latest-main"},
+				{ImageRef: "some-registry.io/cache:latest-other"},
+			})
+		})
+
+		when("there is no namespace", func() {
+			it("leaves the cache unchanged", func() {
+				h.AssertNil(t, platform.ResolveCacheNamespace(inputs, logger))
+				h.AssertEq(t, inputs.CacheImageRef, "some-registry.io/cache")
+			})
+		})
+
+		when("there is no cache", func() {
+			it("errors", func() {
+				inputs.CacheImageRef = ""
+				inputs.CacheDir = ""
+				inputs.CacheNamespace = "some-namespace"
+				h.AssertError(t, platform.ResolveCacheNamespace(inputs, logger), "a cache namespace requires a cache image or cache directory")
+			})
+		})
+	})
+}
+
+const sha256Hex = "0000000000000000000000000000000000000000000000000000000000000000"
@@ -0,0 +1,91 @@
+package platform
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/buildpacks/lifecycle/platform/files"
+)
+
+// RedactedValue replaces the values of environment variables that may contain secrets in a config dump.
+const RedactedValue = "<redacted>"
+
+var proxyEnvVars = map[string]bool{
+	"HTTP_PROXY":  true,
+	"HTTPS_PROXY": true,
+	"NO_PROXY":    true,
+}
+
+// NewConfigDump returns the effective configuration of the provided phase, given its resolved inputs
+// and the environment as returned by os.Environ.
+// Only environment variables that affect the lifecycle are included, and values that may contain secrets are redacted.
+func NewConfigDump(phase string, inputs *LifecycleInputs, environ []string) files.ConfigDump {
+	dump := files.ConfigDump{
+		Phase: phase,
+		Inputs: files.ConfigDumpInputs{
+			OutputImage:        inputs.OutputImageRef,
+			AdditionalTags:     inputs.AdditionalTags,
+			PreviousImage:      inputs.PreviousImageRef,
+			RunImage:           inputs.RunImageRef,
+			CacheImage:         inputs.CacheImageRef,
+			CacheDir:           inputs.CacheDir,
+			LaunchCacheDir:     inputs.LaunchCacheDir,
+			LayersDir:          inputs.LayersDir,
+			AnalyzedPath:       inputs.AnalyzedPath,
+			LayoutDir:          inputs.LayoutDir,
+			UID:                inputs.UID,
+			GID:                inputs.GID,
+			UseDaemon:          inputs.UseDaemon,
+			UseLayout:          inputs.UseLayout,
+			SkipLayers:         inputs.SkipLayers,
+			SkipPrevious:       inputs.SkipPrevious,
+			InsecureRegistries: inputs.InsecureRegistries,
+		},
+		Env: map[string]string{},
+	}
+	if inputs.PlatformAPI != nil {
+		dump.PlatformAPI = inputs.PlatformAPI.String()
+	}
+	for _, kv := range environ {
+		key, val, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		switch {
+		case proxyEnvVars[strings.ToUpper(key)]:
+			dump.Env[key] = redactURLCredentials(val)
+		case strings.HasPrefix(key, "CNB_") || key == "DOCKER_CONFIG":
+			if isSecretEnvVar(key) {
+				val = RedactedValue
+			}
+			dump.Env[key] = val
+		}
+	}
+	return dump
+}
+
+// isSecretEnvVar returns true if the value of the provided environment variable may contain a secret.
+// Variables that provide paths to files or directories are not secret, even if the files they point to are.
+func isSecretEnvVar(key string) bool {
+	key = strings.ToUpper(key)
+	for _, suffix := range []string{"_FILE", "_PATH", "_DIR"} {
+		if strings.HasSuffix(key, suffix) {
+			return false
+		}
+	}
+	for _, word := range []string{"AUTH", "TOKEN", "PASSWORD", "SECRET", "KEY"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURLCredentials redacts the password in the provided URL, if any.
+func redactURLCredentials(val string) string {
+	u, err := url.Parse(val)
+	if err != nil || u.User == nil {
+		return val
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,41 @@
+package platform
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/buildpacks/lifecycle/log"
+)
+
+// CreationTimeNow is the creation time value that sets the created time of the exported image to the time of export.
+const CreationTimeNow = "now"
+
+// ParseCreationTime parses the provided creation time, which may be an integer number of seconds since the Unix epoch,
+// an RFC3339 timestamp, or CreationTimeNow. An empty value returns the zero time, meaning the default creation time should be used.
+func ParseCreationTime(value string, now time.Time) (time.Time, error) {
+	switch value {
+	case "":
+		return time.Time{}, nil
+	case CreationTimeNow:
+		return now.UTC(), nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid creation time %q: must be seconds since the Unix epoch, an RFC3339 timestamp, or %q", value, CreationTimeNow)
+	}
+	return t.UTC(), nil
+}
+
+// ResolveCreationTime parses the provided creation time, if any, into the created time of the exported image.
+func ResolveCreationTime(i *LifecycleInputs, _ log.Logger) error {
+	createdAt, err := ParseCreationTime(i.CreationTime, time.Now())
+	if err != nil {
+		return err
+	}
+	i.CreatedAt = createdAt
+	return nil
+}
@@ -0,0 +1,78 @@
+package platform_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/api"
+	"github.com/buildpacks/lifecycle/platform"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestCreationTime(t *testing.T) {
+	spec.Run(t, "CreationTime", testCreationTime, spec.Report(report.Terminal{}))
+}
+
+func testCreationTime(t *testing.T, when spec.G, it spec.S) {
+	when(".ParseCreationTime", func() {
+		now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+		it("parses seconds since the Unix epoch", func() {
+			created, err := platform.ParseCreationTime("1700000000", now)
+			h.AssertNil(t, err)
+			h.AssertEq(t, created, time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC))
+		})
+
+		it("parses RFC3339 timestamps", func() {
+			created, err := platform.ParseCreationTime("2023-11-15T00:13:20+02:00", now)
+			h.AssertNil(t, err)
+			h.AssertEq(t, created, time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC))
+		})
+
+		it("returns the current time for now", func() {
+			created, err := platform.ParseCreationTime("now", now)
+			h.AssertNil(t, err)
+			h.AssertEq(t, created, now)
+		})
+
+		it("returns the zero time for an empty value", func() {
+			created, err := platform.ParseCreationTime("", now)
+			h.AssertNil(t, err)
+			h.AssertEq(t, created.IsZero(), true)
+		})
+
+		when("the value is invalid", func() {
+			it("errors", func() {
+				_, err := platform.ParseCreationTime("2023-11-15", now)
+				h.AssertError(t, err, `invalid creation time "2023-11-15"`)
+			})
+		})
+	})
+
+	when(".ResolveCreationTime", func() {
+		var inputs *platform.LifecycleInputs
+
+		it.Before(func() {
+			inputs = platform.NewLifecycleInputs(api.Platform.Latest())
+		})
+
+		it("resolves the created time", func() {
+			inputs.CreationTime = "1700000000"
+			h.AssertNil(t, platform.ResolveCreationTime(inputs, &log.Logger{Handler: memory.New()}))
+			h.AssertEq(t, inputs.CreatedAt, time.Unix(1700000000, 0).UTC())
+		})
+
+		when("the creation time is invalid", func() {
+			it("errors", func() {
+				inputs.CreationTime = "yesterday"
+				err := platform.ResolveCreationTime(inputs, &log.Logger{Handler: memory.New()})
+				h.AssertError(t, err, `invalid creation time "yesterday"`)
+			})
+		})
+	})
+}
@@ -33,6 +33,9 @@ const (
 
 	EnvNoColor = "CNB_NO_COLOR"
 
+	// EnvBuildID is an ID that prefixes every line logged by the lifecycle, e.g., to separate the logs of concurrent builds.
+	EnvBuildID = "CNB_BUILD_ID"
+
 	// EnvDeprecationMode is the desired behavior when deprecated APIs (either Platform or Buildpack) are requested.
 	EnvDeprecationMode = "CNB_DEPRECATION_MODE" // defaults to ModeQuiet
 
@@ -56,9 +59,36 @@ const (
 // via a credential helper, or via the `CNB_REGISTRY_AUTH` environment variable. See [auth.DefaultKeychain] for further information.
 const EnvUseDaemon = "CNB_USE_DAEMON"
 
+// EnvPullPolicy configures whether the analyzer pulls the previous image and the run image from the registry into the daemon
+// before reading them, when exporting to a daemon: `always`, `if-not-present`, or `never`.
+// With `never`, the analyzer fails if the run image is not in the daemon; a missing previous image is not an error, as for the first build.
+// If not provided, images already in the daemon are used and missing images are not pulled.
+const EnvPullPolicy = "CNB_PULL_POLICY"
+
+// EnvNoNetwork is a flag used to instruct the analyzer to fail immediately, instead of contacting a registry,
+// if it would read an image from a registry or pull an image into the daemon, e.g., for offline builds
+// in which all images are expected to be in the daemon or a layout directory.
+// It requires exporting to a daemon or a layout directory (see EnvUseDaemon and EnvUseLayout).
+const EnvNoNetwork = "CNB_NO_NETWORK"
+
+// EnvRegistryMirrors is a comma-separated list of registry mirrors of the form `<from>=<to>`, e.g., `docker.io=mirror.internal`.
+// The references of the read-only base images (the run image and the build image)
+// that are in `<from>`, a registry optionally followed by a repository prefix, are rewritten to be in `<to>` instead.
+// The first mirror that applies to a reference is used. The previous image, the cache image, and destination images are not rewritten.
+const EnvRegistryMirrors = "CNB_REGISTRY_MIRRORS"
+
+// EnvRegistryAuthFile is the path to a file that maps registry hostnames to `<username>:<password>` credentials.
+// See [auth.NewFileKeychain] for the format of the file.
+const EnvRegistryAuthFile = "CNB_REGISTRY_AUTH_FILE"
+
 // EnvInsecureRegistries configures the lifecycle to export the application to a remote "insecure" registry.
 const EnvInsecureRegistries = "CNB_INSECURE_REGISTRIES"
 
+// EnvReadOnlyPaths is a comma-separated list of paths beneath the lifecycle's volumes (such as the layers directory) that are
+// intentionally read-only, for example a bind-mounted dependency. When the lifecycle runs as root and fails to chown one of these paths
+// (or their children) to the build user, the failure is logged as a warning instead of failing the build.
+const EnvReadOnlyPaths = "CNB_READ_ONLY_PATHS"
+
 // ## Provided to handle inputs and outputs in OCI layout format
 
 // The lifecycle can be configured to read the input images like `run-image` or `previous-image` in OCI layout format instead of from a
@@ -163,6 +193,40 @@ const (
 	// It contains information about the output application image.
 	EnvReportPath     = "CNB_REPORT_PATH"
 	DefaultReportFile = "report.toml"
+
+	// EnvEgressReportPath is the location of the egress report file, an optional output of the `analyze` and `restore` phases.
+	// It records each registry host contacted during the phase, so that platforms can audit the network egress of a build.
+	EnvEgressReportPath = "CNB_EGRESS_REPORT_PATH"
+
+	// EnvRegistryCACert is the location of a PEM-encoded bundle of CA certificates to trust, in addition to the system certificates,
+	// when making requests to registries, e.g., for registries with certificates issued by an internal CA.
+	EnvRegistryCACert = "CNB_REGISTRY_CA_CERT"
+
+	// EnvNoProxy is a comma-separated list of hosts, domains, and IP ranges (in the format of NO_PROXY, e.g., `mirror.internal`)
+	// for which requests to registries bypass the proxy, in addition to those in NO_PROXY.
+	// Otherwise, requests to registries use the proxy configured by the HTTP_PROXY and HTTPS_PROXY environment variables.
+	EnvNoProxy = "CNB_NO_PROXY"
+
+	// EnvLogHTTP is a flag used to instruct the lifecycle to log the method, URL, status, and duration of each request to registries
+	// at debug level, if true, to help debug registry authentication or redirect issues. Credentials are redacted.
+	EnvLogHTTP = "CNB_LOG_HTTP"
+
+	// EnvConfigDumpPath is the location of the config dump file, an optional output of the `analyze` phase.
+	// It records the resolved inputs of the phase and the relevant environment (with secrets redacted),
+	// so that the invocation can be reproduced.
+	EnvConfigDumpPath = "CNB_CONFIG_DUMP_PATH"
+
+	// EnvAnalyzeReportPath is the location of the analyze report file, an optional output of the `analyze` phase.
+	// It records the selected run image, whether the previous image was found, the stack ID of the run image, and the cache source.
+	EnvAnalyzeReportPath = "CNB_ANALYZE_REPORT_PATH"
+
+	// EnvRestoreReportPath is the location of the restore report file, an optional output of the `restore` phase.
+	// It records the outcome of restoring cache layers, and the buildpacks in the group with no layers on disk or in the cache.
+	EnvRestoreReportPath = "CNB_RESTORE_REPORT_PATH"
+
+	// EnvSBOMOutputDir is the location of an optional output of the `export` phase for Platform API >= 0.8.
+	// After export, the SBOM files in the layers directory are copied to it, grouped by buildpack and then by SBOM type.
+	EnvSBOMOutputDir = "CNB_SBOM_OUTPUT_DIR"
 )
 
 // The following are configuration options with respect to caching.
@@ -174,45 +238,243 @@ const (
 	// EnvCacheImage is a reference to the cache image in an OCI registry. Only one of cache directory or cache image may be used.
 	// The cache is used to store buildpack-generated layers that are needed at build-time for future builds.
 	// Cache images in a daemon are disallowed (for performance reasons).
+	// Either may instead be the location of a cache stored by another cache backend, prefixed with its scheme, e.g., `s3://some-bucket/some-prefix`,
+	// which is stored by the cache helper executable for the scheme in PATH, e.g., `lifecycle-cache-s3`.
 	EnvCacheImage = "CNB_CACHE_IMAGE"
 
+	// EnvCacheArchivePath is the location of a cache image that was exported to a tarball in `docker save` format,
+	// e.g., for transfer to an air-gapped environment. The restorer restores from it after any other configured caches.
+	// The archive is read-only; it is never updated by the lifecycle.
+	EnvCacheArchivePath = "CNB_CACHE_ARCHIVE"
+
+	// EnvCacheNamespace partitions the cache, so that builds in different namespaces (e.g., feature branches) sharing a cache image
+	// or cache directory do not overwrite each other's cached layers. See CacheSource.Namespaced.
+	EnvCacheNamespace = "CNB_CACHE_NAMESPACE"
+
+	// EnvCacheNamespaceFallbacks is a comma-separated list of cache namespaces to restore from, in order,
+	// after the cache namespace, e.g., to share the cache of the default branch with feature branches.
+	// Caches for fallback namespaces are never written to.
+	EnvCacheNamespaceFallbacks = "CNB_CACHE_NAMESPACE_FALLBACKS"
+
 	// EnvLaunchCacheDir is the location of the launch cache directory.
 	// The launch cache is used when exporting to a daemon to store buildpack-generated layers, in order to speed up data retrieval for future builds.
 	EnvLaunchCacheDir = "CNB_LAUNCH_CACHE_DIR"
 
+	// EnvOverlayUpper is the location of a writable overlay upper directory. If provided, the restorer writes cached layer data
+	// beneath this directory instead of the layers directory, skipping files that are already present and identical in the layers directory.
+	// The platform is responsible for mounting the overlay so that later phases see the merged layers directory.
+	EnvOverlayUpper = "CNB_OVERLAY_UPPER"
+
+	// EnvAtomicRestore is a flag used to instruct the restorer to extract each cache layer into a staging directory
+	// and rename it into place on success, if true. This avoids partially written layers when the process is interrupted.
+	EnvAtomicRestore = "CNB_ATOMIC_RESTORE"
+
+	// EnvBestEffortRestore is a flag used to instruct the restorer to remove a cache layer that cannot be retrieved or extracted,
+	// so that the buildpack re-creates it, instead of failing, if true.
+	EnvBestEffortRestore = "CNB_BEST_EFFORT_RESTORE"
+
+	// EnvDedupRestore is a flag used to instruct the restorer to retrieve cache layers with the same sha from the cache only once, if true.
+	// Other layers with the sha are hard-linked from the first restored layer, so buildpacks that modify restored files in place may affect each other.
+	EnvDedupRestore = "CNB_DEDUP_RESTORE"
+
+	// EnvRestoreDryRun is a flag used to instruct the restorer to log what it would do with each cache layer, if true.
+	// The layers directory, analyzed metadata, and restore report are not written.
+	EnvRestoreDryRun = "CNB_RESTORE_DRY_RUN"
+
+	// EnvSBOMOnly is a flag used to instruct the restorer to restore only SBOM data from the cache, if true.
+	// Layer metadata and cache layers are not restored.
+	EnvSBOMOnly = "CNB_SBOM_ONLY"
+
+	// EnvSkipSBOM is a flag used to instruct the restorer not to restore SBOM data, if true, e.g., for platforms that do not consume SBOMs.
+	// The SBOM directories in the layers directory are left untouched.
+	EnvSkipSBOM = "CNB_SKIP_SBOM"
+
+	// EnvMetadataOnly is a flag used to instruct the restorer to restore only layer metadata from the cache, if true.
+	// Data for cache=true layers is not restored, so buildpacks see the metadata from the previous build but must re-create the layers.
+	EnvMetadataOnly = "CNB_RESTORE_METADATA_ONLY"
+
+	// EnvSkipRestorePatterns is a comma-separated list of glob patterns, matched against layer identifiers of the form `<buildpack-id>:<layer-name>`
+	// using the syntax of Go's `path.Match` (`*` does not match `/`). The restorer does not restore data for matching cache layers,
+	// though their metadata is still restored so that buildpacks may re-create them.
+	EnvSkipRestorePatterns = "CNB_SKIP_RESTORE_PATTERNS"
+
+	// EnvRestoreBuildpacks is a comma-separated list of the IDs of the buildpacks whose layer metadata and layers the restorer restores.
+	// The layers directories of other buildpacks in the group are left untouched, e.g., to restore only the buildpack being iterated on
+	// when rebuilding locally. If not provided, the layers of every buildpack in the group are restored.
+	EnvRestoreBuildpacks = "CNB_RESTORE_BUILDPACKS"
+
+	// EnvPreserveModTimes is a comma-separated list of glob patterns, matched against layer identifiers of the form `<buildpack-id>:<layer-name>`
+	// using the syntax of Go's `path.Match`. Files in matching layers keep their modification times when exported,
+	// rather than being normalized to a fixed time. Matching layers are not reproducible, as their digests change whenever the times do.
+	EnvPreserveModTimes = "CNB_PRESERVE_MTIMES"
+
 	// EnvSkipLayers when true will instruct the lifecycle to ignore layers from a previously built image.
 	EnvSkipLayers = "CNB_SKIP_LAYERS"
 
+	// EnvSkipPrevious when true will instruct the analyzer to behave as if the previous image does not exist,
+	// even if it is present; no layer metadata is read from it.
+	EnvSkipPrevious = "CNB_SKIP_PREVIOUS"
+
 	// EnvSkipRestore is used when running the creator, and is equivalent to passing EnvSkipLayers to both the analyzer and
 	// the restorer in the 5-phase invocation.
 	EnvSkipRestore = "CNB_SKIP_RESTORE"
 
+	// EnvSkipAnalyzedChecksum disables writing and verifying the checksum of analyzed.toml,
+	// for platforms that ensure the integrity of the file by other means.
+	EnvSkipAnalyzedChecksum = "CNB_SKIP_ANALYZED_CHECKSUM"
+
 	// EnvKanikoCacheTTL is the amount of time to persist layers cached by kaniko during the `extend` phase.
 	EnvKanikoCacheTTL = "CNB_KANIKO_CACHE_TTL"
 
 	// EnvParallelExport is a flag used to instruct the lifecycle to export of application image and cache image in parallel, if true.
 	EnvParallelExport = "CNB_PARALLEL_EXPORT"
+
+	// EnvAsyncCacheCommit is a flag used to instruct the lifecycle to commit the cache in the background, if true.
+	// Cache layers are still added before the application image is exported, but the lifecycle only waits for the commit to finish before exiting.
+	EnvAsyncCacheCommit = "CNB_ASYNC_CACHE_COMMIT"
+
+	// EnvStrictCacheCommit is a flag used to instruct the lifecycle to fail the export if the cache cannot be committed, if true.
+	// By default, cache errors are logged as warnings.
+	EnvStrictCacheCommit = "CNB_STRICT_CACHE_COMMIT"
+
+	// EnvPruneCache is a flag used to instruct the lifecycle to remove layers that are no longer referenced by the cache metadata
+	// from the cache directory after restoring or exporting, if true. Cache images are not pruned.
+	EnvPruneCache = "CNB_PRUNE_CACHE"
+
+	// EnvCacheChunking is a flag used to instruct the lifecycle to store layers added to a cache directory as content-defined chunks, if true.
+	// Chunks that are unchanged between builds are stored once, reducing the size of the cache when layers change only slightly.
+	// Chunked layers are reassembled transparently when restored. Cache images are not chunked.
+	EnvCacheChunking = "CNB_CACHE_CHUNKING"
+
+	// EnvCacheCompression is the compression of layers added to the cache: `none`, `gzip`, or `zstd`.
+	// Layers added to a cache directory are not compressed by default; layers added to a cache image are gzip-compressed
+	// unless `zstd` is selected. Layers are restored regardless of their compression.
+	EnvCacheCompression = "CNB_CACHE_COMPRESSION"
+
+	// EnvCacheImageOCI is a flag used to instruct the lifecycle to save the cache image with OCI media types, if true.
+	// By default, the cache image uses Docker media types. Cache images with either media types can be restored.
+	EnvCacheImageOCI = "CNB_CACHE_IMAGE_OCI"
+
+	// EnvCacheFallback is a flag used to instruct the analyzer and restorer to warn rather than fail when the cache image cannot be accessed, if true,
+	// e.g., because of a registry authentication failure. The restorer then falls back to the cache directory, if provided, or to an empty cache.
+	// By default, a cache image that cannot be accessed is an error, as it would otherwise silently invalidate the cache.
+	EnvCacheFallback = "CNB_CACHE_FALLBACK"
+
+	// EnvBuildpackLabelSelector is a `key=value` selector used to instruct the analyzer and detector to consider only buildpacks
+	// whose buildpack.toml `[metadata]` table has the provided key and value. It is applied to the groups read from the order file:
+	// buildpacks without the label are removed from each group, and groups that are left empty are removed.
+	// The selector can only narrow the order; it never adds buildpacks that are not in the order file.
+	EnvBuildpackLabelSelector = "CNB_BUILDPACK_LABEL_SELECTOR"
+
+	// EnvWarnUnsupportedAPI is a flag used to instruct the lifecycle to warn instead of failing
+	// when buildpacks request Buildpack APIs that are incompatible with the lifecycle, if true.
+	// It is intended for experimental setups only.
+	EnvWarnUnsupportedAPI = "CNB_WARN_UNSUPPORTED_API"
 )
 
 // DefaultKanikoCacheTTL is the default kaniko cache TTL (2 weeks).
 var DefaultKanikoCacheTTL = 14 * (24 * time.Hour)
 
+// EnvClockSkewThreshold is the maximum difference between the time recorded by the analyzer in analyzed.toml
+// and the restorer's clock before the restorer warns about clock skew.
+// The analyzer also tolerates this difference when checking whether an existing analyzed.toml is newer than the current run.
+const EnvClockSkewThreshold = "CNB_CLOCK_SKEW_THRESHOLD"
+
+// DefaultClockSkewThreshold is the default clock skew threshold (5 minutes).
+var DefaultClockSkewThreshold = 5 * time.Minute
+
+// EnvLayerRestoreTimeout is the maximum time the restorer spends retrieving and extracting a single cache layer.
+// A layer that takes longer is removed so that the buildpack re-creates it. By default, there is no limit.
+const EnvLayerRestoreTimeout = "CNB_LAYER_RESTORE_TIMEOUT"
+
+// EnvMaxLayerSize is the maximum number of bytes of uncompressed data the restorer restores for a single cache layer,
+// so that a corrupt or malicious cache layer cannot fill the disk. Restoring fails if a layer is larger. By default, there is no limit.
+const EnvMaxLayerSize = "CNB_MAX_LAYER_SIZE"
+
+// EnvSlowLayerThreshold is the time after which the restorer warns that retrieving and extracting a single cache layer was slow.
+// By default, no warnings are logged.
+const EnvSlowLayerThreshold = "CNB_SLOW_LAYER_THRESHOLD"
+
 // The following are images used by the lifecycle during the build.
 const (
 	// EnvPreviousImage is a reference to a previously built image; if not provided, it defaults to the output image reference.
 	// It allows the lifecycle to re-use image layers that are unchanged from the previous build, avoiding the re-uploading
 	// of data to the registry or daemon.
+	// For the analyzer and the creator, a comma-separated list of references may be provided, in which case the first reference that is found is used.
 	EnvPreviousImage = "CNB_PREVIOUS_IMAGE"
 
+	// EnvPreviousImageDigest is the digest the previous image is expected to resolve to, e.g., as pinned by a prior step in a pipeline.
+	// If provided, the analyzer fails when the previous image resolves to a different digest, unless EnvAllowPreviousDrift is true.
+	EnvPreviousImageDigest = "CNB_PREVIOUS_IMAGE_DIGEST"
+
+	// EnvAllowPreviousDrift is a flag used to instruct the analyzer to warn rather than fail
+	// when the previous image does not resolve to the expected digest, if true.
+	EnvAllowPreviousDrift = "CNB_ALLOW_PREVIOUS_DRIFT"
+
+	// EnvRunImageLineage is used to instruct the analyzer to validate that the previous image was built on the provided run image,
+	// i.e., that its recorded run image is in the same repository or has the same digest. The value is either `warn` or `fail`,
+	// which determines whether the analyzer warns or fails when the run image diverges; if not provided, the run image is not validated.
+	EnvRunImageLineage = "CNB_RUN_IMAGE_LINEAGE"
+
+	// EnvPinRunImage is a flag used to instruct the analyzer to record in analyzed.toml that the run image should be pulled by digest
+	// rather than by the provided tag, if true, so that later phases use the run image that was analyzed even if the tag is moved.
+	EnvPinRunImage = "CNB_PIN_RUN_IMAGE"
+
+	// EnvNoRunImage is a flag used to instruct the analyzer not to resolve a run image, if true,
+	// so that the app image is exported on an empty base (`FROM scratch`), e.g., for apps that are static binaries.
+	// It is recorded in analyzed.toml for the exporter, and is only supported for Platform API 0.12 and later.
+	// The target (see EnvTargetOS and EnvTargetArch) is required, as it determines the platform of the app image;
+	// Windows images are not supported.
+	EnvNoRunImage = "CNB_NO_RUN_IMAGE"
+
+	// EnvAllowMutableRunImage is a flag used to instruct the analyzer not to warn
+	// when the run image is provided with the `latest` tag or no tag, if true.
+	EnvAllowMutableRunImage = "CNB_ALLOW_MUTABLE_RUN_IMAGE"
+
+	// EnvForceRebuild is a flag used to instruct the analyzer to omit the metadata of the launch layers of the previous image, if true,
+	// so that buildpacks rebuild every launch layer (e.g., after a vulnerability is patched in a base image) while cache=true,
+	// launch=false layers are still restored from the cache.
+	EnvForceRebuild = "CNB_FORCE_REBUILD"
+
+	// EnvForceAnalyze is a flag used to instruct the analyzer to overwrite analyzed.toml even if it was written after the analyzer started,
+	// e.g., by another run of the analyzer for the same layers directory, if true.
+	EnvForceAnalyze = "CNB_FORCE_ANALYZE"
+
+	// EnvExportDestinations is a semicolon-separated list of additional destinations for the exported image.
+	// Each destination is a comma-separated list of image references, the first of which is the destination image
+	// and the rest of which are additional tags for it, e.g., `registry-a.io/app:latest,registry-a.io/app:v1;registry-b.io/app:latest`.
+	// Destinations may be on different registries; the image is assembled once and saved to each destination in turn.
+	EnvExportDestinations = "CNB_EXPORT_DESTINATIONS"
+
 	// EnvRunImage is a reference to the runtime base image. It is used to construct the output application image.
 	EnvRunImage = "CNB_RUN_IMAGE"
 
 	// EnvBuildImage is a reference to the build-time base image. It is needed when image extensions are used to extend the build-time base image.
 	EnvBuildImage = "CNB_BUILD_IMAGE"
+
+	// EnvRequiredMixins is a comma-separated list of mixins required by the build, typically taken from the `io.buildpacks.stack.mixins` label
+	// on the build-time base image. If provided, the analyzer verifies that the run image provides each mixin that is not specific to the build stage.
+	// If not provided, the mixins may instead be read from the label on the build image (see EnvUseBuildImageMixins).
+	EnvRequiredMixins = "CNB_REQUIRED_MIXINS"
+
+	// EnvUseBuildImageMixins is used to make the analyzer read the mixins required by the build from the `io.buildpacks.stack.mixins` label
+	// on the build image (see EnvBuildImage) when EnvRequiredMixins is not provided.
+	EnvUseBuildImageMixins = "CNB_USE_BUILD_IMAGE_MIXINS"
+
+	// EnvTargetOS is the operating system of the target platform, which builders may set in the environment of the build image.
+	// If provided, the analyzer verifies that the run image is for the operating system.
+	EnvTargetOS = "CNB_TARGET_OS"
+	// EnvTargetArch is the architecture of the target platform, which builders may set in the environment of the build image.
+	// If provided, the analyzer verifies that the run image is for the architecture.
+	EnvTargetArch = "CNB_TARGET_ARCH"
 )
 
 // The following are configuration options for the output application image.
 const (
+	// EnvCreationTime is the created time of the output image config, for Platform API >= 0.9. It may be an integer number of seconds
+	// since the Unix epoch, an RFC3339 timestamp, or `now`. It takes precedence over SOURCE_DATE_EPOCH.
+	EnvCreationTime = "CNB_CREATION_TIME"
+
 	// EnvProcessType is the default process for the application image, the entrypoint in the output image config.
 	EnvProcessType = "CNB_PROCESS_TYPE"
 
@@ -7,19 +7,23 @@ const (
 )
 
 const (
-	FailedDetect             LifecycleExitError = iota // generic detect error
-	FailedDetectWithErrors                             // no buildpacks detected
-	DetectError                                        // no buildpacks detected and at least one errored
-	AnalyzeError                                       // generic analyze error
-	RestoreError                                       // generic restore error
-	FailedBuildWithErrors                              // buildpack error during /bin/build
-	BuildError                                         // generic build error
-	ExportError                                        // generic export error
-	RebaseError                                        // generic rebase error
-	LaunchError                                        // generic launch error
-	FailedGenerateWithErrors                           // extension error during /bin/generate
-	GenerateError                                      // generic generate error
-	ExtendError                                        // generic extend error
+	FailedDetect                 LifecycleExitError = iota // generic detect error
+	FailedDetectWithErrors                                 // no buildpacks detected
+	DetectError                                            // no buildpacks detected and at least one errored
+	AnalyzeError                                           // generic analyze error
+	RestoreError                                           // generic restore error
+	FailedBuildWithErrors                                  // buildpack error during /bin/build
+	BuildError                                             // generic build error
+	ExportError                                            // generic export error
+	RebaseError                                            // generic rebase error
+	LaunchError                                            // generic launch error
+	FailedGenerateWithErrors                               // extension error during /bin/generate
+	GenerateError                                          // generic generate error
+	ExtendError                                            // generic extend error
+	RestoreCacheUnavailableError                           // cache could not be read during restore
+	RestoreLayerCorruptError                               // cache layer could not be extracted during restore
+	RestoreChownError                                      // volumes could not be chowned during restore
+	RestoreLayerTooLargeError                              // cache layer exceeded the maximum layer size during restore
 )
 
 type Exiter interface {
@@ -43,7 +47,11 @@ var defaultExitCodes = map[LifecycleExitError]int{
 	AnalyzeError: 32, // AnalyzeError indicates generic analyze error
 
 	// restore phase errors: 40-49
-	RestoreError: 42, // RestoreError indicates generic restore error
+	RestoreError:                 42, // RestoreError indicates generic restore error
+	RestoreCacheUnavailableError: 43, // RestoreCacheUnavailableError indicates the cache metadata or a cache layer could not be retrieved
+	RestoreLayerCorruptError:     44, // RestoreLayerCorruptError indicates the data of a cache layer could not be extracted
+	RestoreChownError:            45, // RestoreChownError indicates the volumes could not be chowned to the build user
+	RestoreLayerTooLargeError:    46, // RestoreLayerTooLargeError indicates the data of a cache layer exceeded the maximum layer size
 
 	// build phase errors: 50-59
 	FailedBuildWithErrors: 51, // FailedBuildWithErrors indicates buildpack error during /bin/build
@@ -0,0 +1,76 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/buildpacks/lifecycle/log"
+)
+
+// ExportDestination is an additional destination for the exported image, besides the output image and its additional tags.
+// Each destination may be on a different registry than the output image; additional tags must be on the same registry as the destination.
+type ExportDestination struct {
+	ImageRef       string
+	AdditionalTags []string
+}
+
+// ParseExportDestination parses a comma-separated list of image references,
+// the first of which is the destination image and the rest of which are additional tags for it.
+func ParseExportDestination(value string) (ExportDestination, error) {
+	var refs []string
+	for _, ref := range strings.Split(value, ",") {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	if len(refs) == 0 {
+		return ExportDestination{}, fmt.Errorf("invalid export destination %q: an image reference is required", value)
+	}
+	return ExportDestination{ImageRef: refs[0], AdditionalTags: refs[1:]}, nil
+}
+
+// Images returns the destination image and its additional tags.
+func (d ExportDestination) Images() []string {
+	return append([]string{d.ImageRef}, d.AdditionalTags...)
+}
+
+// exportDestinationsEnv parses export destinations separated by semicolons from the provided environment variable;
+// invalid destinations are ignored.
+func exportDestinationsEnv(k string) []ExportDestination {
+	var destinations []ExportDestination
+	for _, value := range strings.Split(os.Getenv(k), ";") {
+		if destination, err := ParseExportDestination(value); err == nil {
+			destinations = append(destinations, destination)
+		}
+	}
+	return destinations
+}
+
+// ValidateExportDestinations ensures the references of each export destination are valid, and that each destination's
+// additional tags are on the same registry as the destination.
+func ValidateExportDestinations(i *LifecycleInputs, _ log.Logger) error {
+	if len(i.ExportDestinations) == 0 {
+		return nil
+	}
+	if i.UseLayout {
+		return errors.New("exporting to additional destinations is unsupported when exporting to layout format")
+	}
+	for _, destination := range i.ExportDestinations {
+		for _, imageRef := range destination.Images() {
+			if _, err := name.ParseReference(imageRef, name.WeakValidation); err != nil {
+				return err
+			}
+		}
+		if i.UseDaemon {
+			continue
+		}
+		if err := ValidateSameRegistry(destination.Images()...); err != nil {
+			return fmt.Errorf("invalid export destination %q: %w", destination.ImageRef, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package platform_test
+
+import (
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/api"
+	"github.com/buildpacks/lifecycle/platform"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestExportDestination(t *testing.T) {
+	spec.Run(t, "ExportDestination", testExportDestination, spec.Report(report.Terminal{}))
+}
+
+func testExportDestination(t *testing.T, when spec.G, it spec.S) {
+	when(".ParseExportDestination", func() {
+		it("returns the destination image and its additional tags", func() {
+			destination, err := platform.ParseExportDestination("some-registry.io/app, some-registry.io/app:v1,")
+			h.AssertNil(t, err)
+			h.AssertEq(t, destination, platform.ExportDestination{
+				ImageRef:       "some-registry.io/app",
+				AdditionalTags: []string{"some-registry.io/app:v1"},
+			})
+		})
+
+		when("no image reference is provided", func() {
+			it("errors", func() {
+				_, err := platform.ParseExportDestination(" , ")
+				h.AssertError(t, err, "an image reference is required")
+			})
+		})
+	})
+
+	when(".ValidateExportDestinations", func() {
+		var inputs *platform.LifecycleInputs
+
+		it.Before(func() {
+			inputs = platform.NewLifecycleInputs(api.Platform.Latest())
+		})
+
+		logger := &log.Logger{Handler: memory.New()}
+
+		it("allows destinations on different registries", func() {
+			inputs.ExportDestinations = []platform.ExportDestination{
+				{ImageRef: "some-registry.io/app", AdditionalTags: []string{"some-registry.io/app:v1"}},
+				{ImageRef: "other-registry.io/app"},
+			}
+			h.AssertNil(t, platform.ValidateExportDestinations(inputs, logger))
+		})
+
+		when("a destination's additional tags are on a different registry", func() {
+			it("errors", func() {
+				inputs.ExportDestinations = []platform.ExportDestination{
+					{ImageRef: "some-registry.io/app", AdditionalTags: []string{"other-registry.io/app:v1"}},
+				}
+				err := platform.ValidateExportDestinations(inputs, logger)
+				h.AssertError(t, err, `invalid export destination "some-registry.io/app": writing to multiple registries is unsupported`)
+			})
+		})
+
+		when("a reference is invalid", func() {
+			it("errors", func() {
+				inputs.ExportDestinations = []platform.ExportDestination{{ImageRef: "some-registry.io/app:bad!tag"}}
+				h.AssertNotNil(t, platform.ValidateExportDestinations(inputs, logger))
+			})
+		})
+
+		when("exporting to layout format", func() {
+			it("errors", func() {
+				inputs.UseLayout = true
+				inputs.ExportDestinations = []platform.ExportDestination{{ImageRef: "some-registry.io/app"}}
+				h.AssertError(t, platform.ValidateExportDestinations(inputs, logger), "unsupported when exporting to layout format")
+			})
+		})
+	})
+}
@@ -1,6 +1,17 @@
 package files
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/go-containerregistry/pkg/name"
+
 	"github.com/buildpacks/lifecycle/buildpack"
 	"github.com/buildpacks/lifecycle/internal/encoding"
 )
@@ -26,6 +37,34 @@ type Analyzed struct {
 	// It is used to validate that buildpacks satisfy os/arch constraints,
 	// and to provide information about the export target to buildpacks.
 	RunImage *RunImage `toml:"run-image,omitempty"`
+	// AnalyzedAt is the time at which the analyzer wrote the file. It is optional, as older analyzers did not record it.
+	// It is used by the restorer to detect clock skew between the nodes running each phase.
+	AnalyzedAt *time.Time `toml:"analyzed-at,omitempty"`
+	// ForceRebuild is true if the analyzer was asked to force a rebuild, in which case LayersMetadata omits
+	// the metadata of the launch layers and the SBOM layer of the previous image, so that no launch layer metadata is restored
+	// and buildpacks rebuild every launch layer. The metadata of cache=true layers is still restored from the cache.
+	ForceRebuild bool `toml:"force-rebuild,omitempty"`
+}
+
+// AnalyzedWrittenAt returns the time at which the analyzed.toml file at the provided path was last written,
+// which is the later of the time recorded by the analyzer and the modification time of the file, as later phases may update it.
+// As the recorded time may come from the clock of another node, it is only used if it is after the modification time
+// by more than the provided clock skew tolerance.
+// It returns false if the file does not exist.
+func AnalyzedWrittenAt(path string, tolerance time.Duration) (time.Time, bool, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, err
+	}
+	writtenAt := fi.ModTime()
+	var analyzed Analyzed
+	if _, err = toml.DecodeFile(path, &analyzed); err == nil && analyzed.AnalyzedAt != nil && analyzed.AnalyzedAt.After(writtenAt.Add(tolerance)) {
+		writtenAt = *analyzed.AnalyzedAt
+	}
+	return writtenAt, true, nil
 }
 
 func (a Analyzed) PreviousImageRef() string {
@@ -35,6 +74,116 @@ func (a Analyzed) PreviousImageRef() string {
 	return a.PreviousImage.Reference
 }
 
+// ClockSkew returns the absolute difference between the provided time and the time recorded by the analyzer,
+// or false if the analyzer did not record a time.
+func (a Analyzed) ClockSkew(now time.Time) (time.Duration, bool) {
+	if a.AnalyzedAt == nil {
+		return 0, false
+	}
+	skew := now.Sub(*a.AnalyzedAt)
+	if skew < 0 {
+		skew = -skew
+	}
+	return skew, true
+}
+
+// ValidationError is returned by Validate and enumerates every problem found, rather than just the first.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid analyzed metadata: %s", strings.Join(e.Problems, "; "))
+}
+
+var (
+	digestRegex  = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	imageIDRegex = regexp.MustCompile(`^(sha256:)?[a-f0-9]{64}$`)
+)
+
+// Validate checks that the image references in analyzed.toml can be parsed,
+// that the previous image fields are self-consistent, and that any digests are well-formed `sha256:` strings.
+// It is intended for platforms that read analyzed.toml written by another tool before providing it to later phases.
+// If any problems are found, it returns a *ValidationError.
+func (a Analyzed) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	checkRef := func(field, ref string) {
+		if ref != "" && !isValidImageRef(ref) {
+			addf("%s %q is not a valid image reference", field, ref)
+		}
+	}
+	checkDigest := func(field, digest string) {
+		if digest != "" && !digestRegex.MatchString(digest) {
+			addf("%s %q is not a valid sha256 digest", field, digest)
+		}
+	}
+
+	if a.PreviousImage != nil {
+		if a.PreviousImage.Reference == "" {
+			addf("previous image reference must be provided when the previous image is present")
+		}
+		checkRef("previous image reference", a.PreviousImage.Reference)
+		checkRef("previous image name", a.PreviousImage.Image)
+	}
+	if a.BuildImage != nil {
+		checkRef("build image reference", a.BuildImage.Reference)
+	}
+	if a.RunImage != nil {
+		if a.RunImage.Reference == "" && a.RunImage.Image == "" {
+			addf("run image reference must be provided when the run image is present")
+		}
+		checkRef("run image reference", a.RunImage.Reference)
+		checkRef("run image name", a.RunImage.Image)
+		checkDigest("run image digest", a.RunImage.Digest)
+	}
+
+	md := a.LayersMetadata
+	checkDigest("run image top layer", md.RunImage.TopLayer)
+	for i, layer := range md.App {
+		checkDigest(fmt.Sprintf("app layer %d sha", i), layer.SHA)
+	}
+	if md.BOM != nil {
+		checkDigest("sbom layer sha", md.BOM.SHA)
+	}
+	checkDigest("config layer sha", md.Config.SHA)
+	checkDigest("launcher layer sha", md.Launcher.SHA)
+	checkDigest("process-types layer sha", md.ProcessTypes.SHA)
+	for _, bpMD := range md.Buildpacks {
+		// sort layer names so that problems are reported in a stable order
+		var layerNames []string
+		for layerName := range bpMD.Layers {
+			layerNames = append(layerNames, layerName)
+		}
+		sort.Strings(layerNames)
+		for _, layerName := range layerNames {
+			checkDigest(fmt.Sprintf("layer %q of buildpack %q sha", layerName, bpMD.ID), bpMD.Layers[layerName].SHA)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// isValidImageRef returns true if the provided reference is a registry reference,
+// a daemon image ID, or an OCI layout path, as these are the identifiers recorded by the lifecycle.
+func isValidImageRef(ref string) bool {
+	if imageIDRegex.MatchString(ref) || filepath.IsAbs(ref) {
+		return true
+	}
+	_, err := name.ParseReference(ref)
+	return err == nil
+}
+
+// HasNoRunImage returns true if the platform requested that the app image be exported on an empty base, see RunImage.Scratch.
+func (a Analyzed) HasNoRunImage() bool {
+	return a.RunImage != nil && a.RunImage.Scratch
+}
+
 func (a Analyzed) RunImageImage() string {
 	if a.RunImage == nil {
 		return ""
@@ -49,6 +198,15 @@ func (a Analyzed) RunImageRef() string {
 	return a.RunImage.Reference
 }
 
+// RunImageTopLayer returns the diff ID of the top layer of the run image recorded by the analyzer,
+// e.g., so that the rebaser can detect whether the run image changed; it is empty if it was not recorded.
+func (a Analyzed) RunImageTopLayer() string {
+	if a.RunImage == nil || a.RunImage.Metadata == nil {
+		return ""
+	}
+	return a.RunImage.Metadata.TopLayer
+}
+
 func (a Analyzed) RunImageTarget() TargetMetadata {
 	if a.RunImage == nil {
 		return TargetMetadata{}
@@ -61,6 +219,8 @@ func (a Analyzed) RunImageTarget() TargetMetadata {
 
 type ImageIdentifier struct {
 	Reference string `toml:"reference"` // FIXME: fix key name to be accurate in the daemon case
+	// Image specifies the name of the image that was resolved, e.g., when the previous image was selected from multiple candidates.
+	Image string `toml:"image,omitempty"`
 }
 
 // NOTE: This struct MUST be kept in sync with `LayersMetadataCompat`
@@ -129,9 +289,30 @@ type RunImage struct {
 	// When exporting to a daemon, the restorer uses this field to pull the run image if needed for the extender;
 	// it can't use `Reference` because this may be a daemon image ID if analyzed.toml was last written by the analyzer.
 	Image string `toml:"image,omitempty"`
+	// Digest is the immutable digest (e.g., `sha256:...`) of the run image, recorded so that later phases can verify
+	// that the run image did not change since it was resolved.
+	// It is omitted when the run image does not have a registry digest, e.g., when exporting to a daemon.
+	Digest string `toml:"digest,omitempty"`
+	// Pinned if true indicates that later phases should pull the run image by `Reference`, which includes its digest,
+	// rather than by `Image`, so that they use the run image that was analyzed even if the tag is moved.
+	Pinned bool `toml:"pinned,omitempty"`
 	// Extend if true indicates that the run image should be extended by the extender.
-	Extend         bool            `toml:"extend,omitempty"`
+	Extend bool `toml:"extend,omitempty"`
+	// Scratch if true indicates that there is no run image, as requested by the platform;
+	// the exporter exports the app image on an empty base (`FROM scratch`). The other fields, except TargetMetadata, are empty.
+	Scratch        bool            `toml:"scratch,omitempty"`
 	TargetMetadata *TargetMetadata `json:"target,omitempty" toml:"target,omitempty"`
+	// Metadata is metadata read from the run image by the analyzer, recorded so that later phases need not pull the run image again to read it.
+	// It is omitted when the run image was not found, or when analyzed.toml was written by an older lifecycle.
+	Metadata *RunImageMetadata `json:"metadata,omitempty" toml:"metadata,omitempty"`
+}
+
+// RunImageMetadata is metadata read from the run image.
+type RunImageMetadata struct {
+	// TopLayer is the diff ID of the top layer of the run image.
+	TopLayer string `json:"top-layer,omitempty" toml:"top-layer,omitempty"`
+	// Labels are the `io.buildpacks.*` labels of the run image, e.g., the stack ID and mixins.
+	Labels map[string]string `json:"labels,omitempty" toml:"labels,omitempty"`
 }
 
 type TargetMetadata struct {
@@ -1,12 +1,24 @@
 package files_test
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/BurntSushi/toml"
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
 	"github.com/sclevine/spec"
 
+	"github.com/buildpacks/lifecycle/buildpack"
 	"github.com/buildpacks/lifecycle/cmd"
+	"github.com/buildpacks/lifecycle/internal/encoding"
 	"github.com/buildpacks/lifecycle/platform/files"
 	h "github.com/buildpacks/lifecycle/testhelpers"
 )
@@ -93,6 +105,339 @@ func testAnalyzed(t *testing.T, when spec.G, it spec.S) {
 				h.AssertEq(t, amd.LayersMetadata, amd2.LayersMetadata)
 				h.AssertEq(t, amd.BuildImage, amd2.BuildImage)
 			})
+
+			it("serializes and deserializes the time of analysis", func() {
+				analyzedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+				amd := files.Analyzed{AnalyzedAt: &analyzedAt}
+				f := h.TempFile(t, "", "")
+				h.AssertNil(t, files.Handler.WriteAnalyzed(f, &amd, cmd.DefaultLogger))
+				amd2, err := files.Handler.ReadAnalyzed(f, nil)
+				h.AssertNil(t, err)
+				h.AssertNotNil(t, amd2.AnalyzedAt)
+				h.AssertEq(t, amd2.AnalyzedAt.Equal(analyzedAt), true)
+			})
+		})
+	})
+
+	when("round-tripping", func() {
+		it("re-encodes analyzed.toml to identical bytes", func() {
+			amd := files.Analyzed{
+				PreviousImage: &files.ImageIdentifier{Reference: "some-previous-image"},
+				LayersMetadata: files.LayersMetadata{
+					Buildpacks: []buildpack.LayersMetadata{{
+						ID:      "some-buildpack",
+						Version: "some-version",
+						Layers: map[string]buildpack.LayerMetadata{
+							"z-layer": {SHA: "some-sha", LayerMetadataFile: buildpack.LayerMetadataFile{Data: map[string]interface{}{"b": "1", "a": "2"}}},
+							"a-layer": {SHA: "other-sha", LayerMetadataFile: buildpack.LayerMetadataFile{Launch: true}},
+							"m-layer": {SHA: "another-sha", LayerMetadataFile: buildpack.LayerMetadataFile{Cache: true}},
+						},
+					}},
+					Stack: &files.Stack{RunImage: files.RunImageForExport{Image: "some-run-image", Mirrors: []string{"some-mirror", "other-mirror"}}},
+				},
+				RunImage: &files.RunImage{Reference: "some-run-image-ref"},
+			}
+			f := h.TempFile(t, "", "")
+			h.AssertNil(t, files.Handler.WriteAnalyzed(f, &amd, cmd.DefaultLogger))
+			contents := h.MustReadFile(t, f)
+
+			amd2, err := files.Handler.ReadAnalyzed(f, nil)
+			h.AssertNil(t, err)
+			f2 := h.TempFile(t, "", "")
+			h.AssertNil(t, files.Handler.WriteAnalyzed(f2, &amd2, cmd.DefaultLogger))
+			h.AssertEq(t, string(h.MustReadFile(t, f2)), string(contents))
+		})
+
+		it("re-encodes stack.toml to identical bytes", func() {
+			stack := files.Stack{RunImage: files.RunImageForExport{Image: "some-run-image", Mirrors: []string{"some-mirror", "other-mirror"}}}
+			f := h.TempFile(t, "", "")
+			h.AssertNil(t, encoding.WriteTOML(f, stack))
+			contents := h.MustReadFile(t, f)
+
+			stack2, err := files.Handler.ReadStack(f, cmd.DefaultLogger)
+			h.AssertNil(t, err)
+			f2 := h.TempFile(t, "", "")
+			h.AssertNil(t, encoding.WriteTOML(f2, stack2))
+			h.AssertEq(t, string(h.MustReadFile(t, f2)), string(contents))
+		})
+
+		when("stack.toml is missing", func() {
+			it("returns empty stack metadata", func() {
+				stack, err := files.Handler.ReadStack(filepath.Join(t.TempDir(), "stack.toml"), cmd.DefaultLogger)
+				h.AssertNil(t, err)
+				h.AssertEq(t, stack, files.Stack{})
+			})
+		})
+
+		when("stack.toml is missing the run image", func() {
+			it("errors", func() {
+				f := filepath.Join(t.TempDir(), "stack.toml")
+				h.Mkfile(t, "[run-image]\n  mirrors = [\"some-mirror\"]\n", f)
+
+				_, err := files.Handler.ReadStack(f, cmd.DefaultLogger)
+				h.AssertError(t, err, fmt.Sprintf("invalid stack file %q: run-image.image must be provided", f))
+			})
+		})
+
+		when("stack.toml has an invalid mirror", func() {
+			it("errors", func() {
+				f := filepath.Join(t.TempDir(), "stack.toml")
+				h.Mkfile(t, "[run-image]\n  image = \"some-run-image\"\n  mirrors = [\"some-mirror\", \"Not A Mirror\"]\n", f)
+
+				_, err := files.Handler.ReadStack(f, cmd.DefaultLogger)
+				h.AssertError(t, err, `run-image.mirrors[1] "Not A Mirror" is not a valid image reference`)
+			})
+		})
+
+		when("stack.toml does not define the run image table", func() {
+			it("errors", func() {
+				f := filepath.Join(t.TempDir(), "stack.toml")
+				h.Mkfile(t, "[build-image]\n  image = \"some-build-image\"\n", f)
+
+				_, err := files.Handler.ReadStack(f, cmd.DefaultLogger)
+				h.AssertError(t, err, "missing [run-image] table")
+			})
+		})
+
+		when("stack.toml defines the build image table", func() {
+			it("does not warn about the build image", func() {
+				f := filepath.Join(t.TempDir(), "stack.toml")
+				h.Mkfile(t, "[run-image]\n  image = \"some-run-image\"\n[build-image]\n  image = \"some-build-image\"\n  mirrors = [\"some-mirror\"]\n", f)
+				logHandler := memory.New()
+
+				stack, err := files.Handler.ReadStack(f, &log.Logger{Handler: logHandler})
+				h.AssertNil(t, err)
+				h.AssertEq(t, stack.RunImage.Image, "some-run-image")
+				h.AssertEq(t, len(logHandler.Entries), 0)
+			})
+
+			it("warns about other unknown keys", func() {
+				f := filepath.Join(t.TempDir(), "stack.toml")
+				h.Mkfile(t, "[run-image]\n  image = \"some-run-image\"\n  some-key = \"some-value\"\n[build-image]\n  image = \"some-build-image\"\n", f)
+				logHandler := memory.New()
+
+				_, err := files.Handler.ReadStack(f, &log.Logger{Handler: logHandler})
+				h.AssertNil(t, err)
+				h.AssertEq(t, len(logHandler.Entries), 1)
+				h.AssertStringContains(t, logHandler.Entries[0].Message, `Ignoring unknown key "run-image.some-key"`)
+			})
+		})
+
+		when("stack.toml is empty", func() {
+			it("returns empty stack metadata", func() {
+				f := filepath.Join(t.TempDir(), "stack.toml")
+				h.Mkfile(t, "", f)
+
+				stack, err := files.Handler.ReadStack(f, cmd.DefaultLogger)
+				h.AssertNil(t, err)
+				h.AssertEq(t, stack, files.Stack{})
+			})
+		})
+	})
+
+	when(".AnalyzedWrittenAt", func() {
+		var path string
+
+		it.Before(func() {
+			path = filepath.Join(t.TempDir(), "analyzed.toml")
+		})
+
+		when("analyzed.toml does not exist", func() {
+			it("returns false", func() {
+				_, ok, err := files.AnalyzedWrittenAt(path, 0)
+				h.AssertNil(t, err)
+				h.AssertEq(t, ok, false)
+			})
+		})
+
+		it("returns the modification time of the file if it was updated after the recorded time", func() {
+			analyzedAt := time.Now().Add(-time.Hour).UTC()
+			h.AssertNil(t, encoding.WriteTOML(path, files.Analyzed{AnalyzedAt: &analyzedAt}))
+			modTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+			h.AssertNil(t, os.Chtimes(path, modTime, modTime))
+
+			writtenAt, ok, err := files.AnalyzedWrittenAt(path, 0)
+			h.AssertNil(t, err)
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, writtenAt.Equal(modTime), true)
+		})
+
+		it("returns the recorded time if it is after the modification time of the file", func() {
+			analyzedAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+			h.AssertNil(t, encoding.WriteTOML(path, files.Analyzed{AnalyzedAt: &analyzedAt}))
+
+			writtenAt, ok, err := files.AnalyzedWrittenAt(path, 0)
+			h.AssertNil(t, err)
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, writtenAt.Equal(analyzedAt), true)
+		})
+
+		it("returns the modification time of the file if the recorded time is after it by no more than the tolerance", func() {
+			analyzedAt := time.Now().Add(time.Minute).UTC()
+			h.AssertNil(t, encoding.WriteTOML(path, files.Analyzed{AnalyzedAt: &analyzedAt}))
+			modTime := time.Now().Truncate(time.Second)
+			h.AssertNil(t, os.Chtimes(path, modTime, modTime))
+
+			writtenAt, ok, err := files.AnalyzedWrittenAt(path, 5*time.Minute)
+			h.AssertNil(t, err)
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, writtenAt.Equal(modTime), true)
+		})
+	})
+
+	when("checksum", func() {
+		var (
+			tmpDir string
+			path   string
+			amd    = files.Analyzed{RunImage: &files.RunImage{Reference: "some-run-image-ref"}}
+		)
+
+		it.Before(func() {
+			var err error
+			tmpDir, err = os.MkdirTemp("", "analyzed-checksum")
+			h.AssertNil(t, err)
+			path = filepath.Join(tmpDir, "analyzed.toml")
+		})
+
+		it.After(func() {
+			h.AssertNil(t, os.RemoveAll(tmpDir))
+		})
+
+		it("writes a checksum file alongside analyzed.toml", func() {
+			h.AssertNil(t, files.Handler.WriteAnalyzed(path, &amd, cmd.DefaultLogger))
+			sum := sha256.Sum256(h.MustReadFile(t, path))
+			h.AssertEq(t, string(h.MustReadFile(t, files.AnalyzedChecksumPath(path))), hex.EncodeToString(sum[:])+"  analyzed.toml\n")
+
+			amd2, err := files.Handler.ReadAnalyzed(path, cmd.DefaultLogger)
+			h.AssertNil(t, err)
+			h.AssertEq(t, amd2.RunImage.Reference, "some-run-image-ref")
+		})
+
+		when("analyzed.toml does not match the checksum", func() {
+			it("returns an error", func() {
+				h.AssertNil(t, files.Handler.WriteAnalyzed(path, &amd, cmd.DefaultLogger))
+				contents := h.MustReadFile(t, path)
+				h.AssertNil(t, os.WriteFile(path, contents[:len(contents)/2], 0600))
+
+				_, err := files.Handler.ReadAnalyzed(path, cmd.DefaultLogger)
+				h.AssertError(t, err, "analyzed metadata is corrupt")
+				h.AssertEq(t, errors.Is(err, files.ErrCorruptAnalyzed), true)
+			})
+
+			when("skipping the analyzed checksum", func() {
+				it("does not verify the checksum", func() {
+					handler := &files.TOMLHandler{SkipAnalyzedChecksum: true}
+					h.AssertNil(t, os.WriteFile(files.AnalyzedChecksumPath(path), []byte("some-checksum  analyzed.toml\n"), 0600))
+					h.AssertNil(t, handler.WriteAnalyzed(path, &amd, cmd.DefaultLogger))
+					h.AssertEq(t, string(h.MustReadFile(t, files.AnalyzedChecksumPath(path))), "some-checksum  analyzed.toml\n")
+
+					_, err := handler.ReadAnalyzed(path, cmd.DefaultLogger)
+					h.AssertNil(t, err)
+				})
+			})
+		})
+
+		when("there is no checksum file", func() {
+			it("does not verify the checksum", func() {
+				h.AssertNil(t, encoding.WriteTOML(path, amd))
+
+				_, err := files.Handler.ReadAnalyzed(path, cmd.DefaultLogger)
+				h.AssertNil(t, err)
+			})
+		})
+
+		when("the path is -", func() {
+			it("writes analyzed.toml to stdout without a checksum", func() {
+				r, w, err := os.Pipe()
+				h.AssertNil(t, err)
+				origStdout := os.Stdout
+				os.Stdout = w
+				err = files.Handler.WriteAnalyzed(files.StdoutPath, &amd, cmd.DefaultLogger)
+				os.Stdout = origStdout
+				h.AssertNil(t, err)
+				h.AssertNil(t, w.Close())
+				out, err := io.ReadAll(r)
+				h.AssertNil(t, err)
+
+				var amd2 files.Analyzed
+				_, err = toml.Decode(string(out), &amd2)
+				h.AssertNil(t, err)
+				h.AssertEq(t, amd2.RunImage.Reference, "some-run-image-ref")
+				h.AssertPathDoesNotExist(t, files.AnalyzedChecksumPath(files.StdoutPath))
+			})
+		})
+	})
+
+	when("#ClockSkew", func() {
+		var analyzedAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		it("returns the absolute difference from the time of analysis", func() {
+			amd := files.Analyzed{AnalyzedAt: &analyzedAt}
+
+			skew, ok := amd.ClockSkew(analyzedAt.Add(10 * time.Minute))
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, skew, 10*time.Minute)
+
+			skew, ok = amd.ClockSkew(analyzedAt.Add(-10 * time.Minute))
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, skew, 10*time.Minute)
+		})
+
+		when("the time of analysis was not recorded", func() {
+			it("returns false", func() {
+				_, ok := files.Analyzed{}.ClockSkew(analyzedAt)
+				h.AssertEq(t, ok, false)
+			})
+		})
+	})
+	when("#Validate", func() {
+		const (
+			validDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+			validID     = "2222222222222222222222222222222222222222222222222222222222222222"
+		)
+
+		it("accepts registry references, daemon image IDs, and well-formed digests", func() {
+			amd := files.Analyzed{
+				PreviousImage: &files.ImageIdentifier{Reference: validID, Image: "some-registry.io/some-app"},
+				RunImage: &files.RunImage{
+					Reference: "some-registry.io/some-run-image@" + validDigest,
+					Image:     "some-registry.io/some-run-image",
+					Digest:    validDigest,
+				},
+				LayersMetadata: files.LayersMetadata{
+					App:      []files.LayerMetadata{{SHA: validDigest}},
+					Launcher: files.LayerMetadata{SHA: validDigest},
+					RunImage: files.RunImageForRebase{TopLayer: validDigest},
+				},
+			}
+			h.AssertNil(t, amd.Validate())
+			h.AssertNil(t, files.Analyzed{}.Validate())
+		})
+
+		it("returns every problem found", func() {
+			amd := files.Analyzed{
+				PreviousImage: &files.ImageIdentifier{Image: "some-registry.io/some-app"},
+				RunImage: &files.RunImage{
+					Reference: "Not A Reference",
+					Digest:    "sha256:not-hex",
+				},
+				LayersMetadata: files.LayersMetadata{
+					Buildpacks: []buildpack.LayersMetadata{{
+						ID:     "some-buildpack",
+						Layers: map[string]buildpack.LayerMetadata{"some-layer": {SHA: "md5:abc"}},
+					}},
+				},
+			}
+			err := amd.Validate()
+			h.AssertNotNil(t, err)
+			var validationErr *files.ValidationError
+			h.AssertEq(t, errors.As(err, &validationErr), true)
+			h.AssertEq(t, validationErr.Problems, []string{
+				"previous image reference must be provided when the previous image is present",
+				`run image reference "Not A Reference" is not a valid image reference`,
+				`run image digest "sha256:not-hex" is not a valid sha256 digest`,
+				`layer "some-layer" of buildpack "some-buildpack" sha "md5:abc" is not a valid sha256 digest`,
+			})
 		})
 	})
 }
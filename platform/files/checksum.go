@@ -0,0 +1,53 @@
+package files
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildpacks/lifecycle/internal/encoding"
+)
+
+// ErrCorruptAnalyzed is returned when the contents of analyzed.toml do not match its checksum,
+// e.g., because the file was only partially written.
+var ErrCorruptAnalyzed = errors.New("analyzed metadata is corrupt")
+
+// AnalyzedChecksumPath returns the path of the checksum file for the analyzed.toml file at the provided path.
+// The checksum file has the format of the output of `sha256sum`.
+func AnalyzedChecksumPath(path string) string {
+	return path + ".sha256"
+}
+
+func writeChecksum(contents []byte, checksumPath string) error {
+	sum := sha256.Sum256(contents)
+	line := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), strings.TrimSuffix(filepath.Base(checksumPath), ".sha256"))
+	if err := os.MkdirAll(filepath.Dir(checksumPath), 0777); err != nil {
+		return err
+	}
+	return encoding.WriteFileAtomic(checksumPath, []byte(line))
+}
+
+// verifyChecksum returns ErrCorruptAnalyzed if the provided contents do not match the checksum file at the provided path.
+// Contents without a checksum file (e.g., written by an older lifecycle or by the platform) are not verified.
+func verifyChecksum(contents []byte, checksumPath string) error {
+	checksumFile, err := os.ReadFile(checksumPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read analyzed checksum file: %w", err)
+	}
+	fields := strings.Fields(string(checksumFile))
+	if len(fields) == 0 {
+		return fmt.Errorf("%w: checksum file %q is empty", ErrCorruptAnalyzed, checksumPath)
+	}
+	sum := sha256.Sum256(contents)
+	if !strings.EqualFold(fields[0], hex.EncodeToString(sum[:])) {
+		return fmt.Errorf("%w: contents do not match checksum file %q", ErrCorruptAnalyzed, checksumPath)
+	}
+	return nil
+}
@@ -2,8 +2,10 @@
 package files
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 
@@ -16,8 +18,15 @@ import (
 // Handler is the default handler used to read and write lifecycle configuration files.
 var Handler = &TOMLHandler{}
 
+// StdoutPath is the path at which analyzed metadata is written to stdout instead of to a file.
+const StdoutPath = "-"
+
 // TOMLHandler reads and writes lifecycle configuration files in TOML format.
-type TOMLHandler struct{}
+type TOMLHandler struct {
+	// SkipAnalyzedChecksum, if true, disables writing and verifying the checksum of analyzed.toml,
+	// for platforms that ensure the integrity of the file by other means.
+	SkipAnalyzedChecksum bool
+}
 
 // NewHandler returns a new file handler.
 func NewHandler() *TOMLHandler {
@@ -26,31 +35,69 @@ func NewHandler() *TOMLHandler {
 
 // ReadAnalyzed reads the provided analyzed.toml file.
 // It logs a warning and returns empty analyzed metadata if the file does not exist.
+// If a checksum file exists alongside the analyzed.toml file, the contents are verified against it
+// and ErrCorruptAnalyzed is returned if they do not match, unless SkipAnalyzedChecksum is true.
 func (h *TOMLHandler) ReadAnalyzed(path string, logger log.Logger) (Analyzed, error) {
-	var analyzed Analyzed
-	if _, err := toml.DecodeFile(path, &analyzed); err != nil {
+	contents, err := os.ReadFile(path)
+	if err != nil {
 		if os.IsNotExist(err) {
 			logger.Warnf("No analyzed metadata found at path %q", path)
 			return Analyzed{}, nil
 		}
 		return Analyzed{}, fmt.Errorf("failed to read analyzed file: %w", err)
 	}
+	if !h.SkipAnalyzedChecksum {
+		if err = verifyChecksum(contents, AnalyzedChecksumPath(path)); err != nil {
+			return Analyzed{}, err
+		}
+	}
+	var analyzed Analyzed
+	if _, err = toml.Decode(string(contents), &analyzed); err != nil {
+		return Analyzed{}, fmt.Errorf("failed to read analyzed file: %w", err)
+	}
 	return analyzed, nil
 }
 
 // WriteAnalyzed writes the provided analyzed metadata at the provided path.
+// Unless SkipAnalyzedChecksum is true, a checksum file is written first at AnalyzedChecksumPath(path),
+// so that a partially written analyzed.toml file can be detected by ReadAnalyzed.
+// If the path is StdoutPath, the metadata is written to stdout and no checksum is written.
 func (h *TOMLHandler) WriteAnalyzed(path string, analyzedMD *Analyzed, logger log.Logger) error {
 	logger.Debugf("Run image info in analyzed metadata is: ")
 	logger.Debugf(encoding.ToJSONMaybe(analyzedMD.RunImage))
-	if err := encoding.WriteTOML(path, analyzedMD); err != nil {
+	if path == StdoutPath {
+		contents, err := encoding.MarshalTOML(analyzedMD)
+		if err != nil {
+			return fmt.Errorf("failed to write analyzed metadata: %w", err)
+		}
+		if _, err = os.Stdout.Write(contents); err != nil {
+			return fmt.Errorf("failed to write analyzed metadata: %w", err)
+		}
+		return nil
+	}
+	if h.SkipAnalyzedChecksum {
+		if err := encoding.WriteTOML(path, analyzedMD); err != nil {
+			return fmt.Errorf("failed to write analyzed file: %w", err)
+		}
+		return nil
+	}
+	contents, err := encoding.MarshalTOML(analyzedMD)
+	if err != nil {
+		return fmt.Errorf("failed to write analyzed file: %w", err)
+	}
+	if err = writeChecksum(contents, AnalyzedChecksumPath(path)); err != nil {
+		return fmt.Errorf("failed to write analyzed checksum file: %w", err)
+	}
+	if err = encoding.WriteFileAtomic(path, contents); err != nil {
 		return fmt.Errorf("failed to write analyzed file: %w", err)
 	}
 	return nil
 }
 
 // ReadGroup reads the provided group.toml file.
+// If the file is malformed, the returned error includes the path and the line and column of the error.
 func (h *TOMLHandler) ReadGroup(path string) (group buildpack.Group, err error) {
-	if _, err = toml.DecodeFile(path, &group); err != nil {
+	if err = decodeTOMLFile(path, &group); err != nil {
 		return buildpack.Group{}, fmt.Errorf("failed to read group file: %w", err)
 	}
 	for e := range group.GroupExtensions {
@@ -96,6 +143,7 @@ func (h *TOMLHandler) WriteBuildMetadata(path string, buildMD *BuildMetadata) er
 }
 
 // ReadOrder reads the provided order.toml file.
+// If the file is malformed, the returned error includes the path and the line and column of the error.
 func (h *TOMLHandler) ReadOrder(path string) (buildpack.Order, buildpack.Order, error) {
 	orderBp, orderExt, err := readOrder(path)
 	if err != nil {
@@ -109,8 +157,7 @@ func readOrder(path string) (buildpack.Order, buildpack.Order, error) {
 		Order           buildpack.Order `toml:"order"`
 		OrderExtensions buildpack.Order `toml:"order-extensions"`
 	}
-	_, err := toml.DecodeFile(path, &order)
-	if err != nil {
+	if err := decodeTOMLFile(path, &order); err != nil {
 		return nil, nil, fmt.Errorf("failed to read order file: %w", err)
 	}
 	for g, group := range order.OrderExtensions {
@@ -123,6 +170,73 @@ func readOrder(path string) (buildpack.Order, buildpack.Order, error) {
 	return order.Order, order.OrderExtensions, nil
 }
 
+// decodeTOMLFile decodes the provided TOML file into v.
+// If the file cannot be parsed or decoded, the returned error includes the path and, when known, the line and column of the error.
+func decodeTOMLFile(path string, v interface{}) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if _, err = toml.Decode(string(contents), v); err != nil {
+		var parseErr toml.ParseError
+		if !errors.As(err, &parseErr) || parseErr.Position.Line == 0 {
+			return fmt.Errorf("parsing %q: %w", path, err)
+		}
+		return &TOMLError{
+			Path:    path,
+			Line:    parseErr.Position.Line,
+			Column:  column(string(contents), parseErr.Position.Start),
+			LastKey: parseErr.LastKey,
+			Message: parseErrorMessage(parseErr),
+			err:     err,
+		}
+	}
+	return nil
+}
+
+// column returns the column, starting at 1, of the provided byte offset in the provided contents.
+func column(contents string, offset int) int {
+	if offset > len(contents) {
+		offset = len(contents)
+	}
+	return offset - strings.LastIndex(contents[:offset], "\n")
+}
+
+// parseErrorMessage returns the message of the provided error without the position, which toml.ParseError includes
+// only if the message is not set.
+func parseErrorMessage(parseErr toml.ParseError) string {
+	if parseErr.Message != "" {
+		return parseErr.Message
+	}
+	prefix := fmt.Sprintf("toml: line %d: ", parseErr.Position.Line)
+	if parseErr.LastKey != "" {
+		prefix = fmt.Sprintf("toml: line %d (last key %q): ", parseErr.Position.Line, parseErr.LastKey)
+	}
+	return strings.TrimPrefix(parseErr.Error(), prefix)
+}
+
+// TOMLError is returned when a lifecycle configuration file is not valid TOML, or does not match the expected schema.
+type TOMLError struct {
+	Path    string
+	Line    int    // Line starts at 1.
+	Column  int    // Column starts at 1.
+	LastKey string // LastKey is the last key parsed before the error, if any.
+	Message string
+	err     error
+}
+
+func (e *TOMLError) Error() string {
+	if e.LastKey == "" {
+		return fmt.Sprintf("parsing %q at line %d, column %d: %s", e.Path, e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("parsing %q at line %d, column %d (last key %q): %s", e.Path, e.Line, e.Column, e.LastKey, e.Message)
+}
+
+// Unwrap returns the underlying toml.ParseError.
+func (e *TOMLError) Unwrap() error {
+	return e.err
+}
+
 // ReadPlan reads the provided plan.toml file.
 func (h *TOMLHandler) ReadPlan(path string) (Plan, error) {
 	var plan Plan
@@ -162,6 +276,14 @@ func (h *TOMLHandler) WriteReport(path string, report *Report) error {
 	return nil
 }
 
+// WriteConfigDump writes the provided config dump, i.e., the effective configuration of a phase, at the provided path.
+func (h *TOMLHandler) WriteConfigDump(path string, dump *ConfigDump) error {
+	if err := encoding.WriteTOML(path, dump); err != nil {
+		return fmt.Errorf("failed to write config dump file: %w", err)
+	}
+	return nil
+}
+
 // WriteRebaseReport writes the provided report information at the provided path.
 func (h *TOMLHandler) WriteRebaseReport(path string, report *RebaseReport) error {
 	if err := encoding.WriteTOML(path, report); err != nil {
@@ -170,6 +292,30 @@ func (h *TOMLHandler) WriteRebaseReport(path string, report *RebaseReport) error
 	return nil
 }
 
+// WriteEgressReport writes the provided egress report at the provided path.
+func (h *TOMLHandler) WriteEgressReport(path string, report *EgressReport) error {
+	if err := encoding.WriteTOML(path, report); err != nil {
+		return fmt.Errorf("failed to write egress report file: %w", err)
+	}
+	return nil
+}
+
+// WriteAnalyzeReport writes the provided analyze report at the provided path.
+func (h *TOMLHandler) WriteAnalyzeReport(path string, report *AnalyzeReport) error {
+	if err := encoding.WriteTOML(path, report); err != nil {
+		return fmt.Errorf("failed to write analyze report file: %w", err)
+	}
+	return nil
+}
+
+// WriteRestoreReport writes the provided restore report at the provided path.
+func (h *TOMLHandler) WriteRestoreReport(path string, report *RestoreReport) error {
+	if err := encoding.WriteTOML(path, report); err != nil {
+		return fmt.Errorf("failed to write restore report file: %w", err)
+	}
+	return nil
+}
+
 // ReadRun reads the provided run.toml file.
 func (h *TOMLHandler) ReadRun(path string, logger log.Logger) (Run, error) {
 	var runMD Run
@@ -184,14 +330,25 @@ func (h *TOMLHandler) ReadRun(path string, logger log.Logger) (Run, error) {
 }
 
 // ReadStack reads the provided stack.toml file.
+// A missing file is not an error, but a file that is present must define a valid run image.
 func (h *TOMLHandler) ReadStack(path string, logger log.Logger) (Stack, error) {
 	var stackMD Stack
-	if _, err := toml.DecodeFile(path, &stackMD); err != nil {
+	md, err := toml.DecodeFile(path, &stackMD)
+	if err != nil {
 		if os.IsNotExist(err) {
 			logger.Infof("No stack metadata found at path %q", path)
 			return Stack{}, nil
 		}
 		return Stack{}, fmt.Errorf("failed to read stack file: %w", err)
 	}
+	for _, key := range md.Undecoded() {
+		if key[0] == stackBuildImageKey {
+			continue
+		}
+		logger.Warnf("Ignoring unknown key %q in stack file %q", key.String(), path)
+	}
+	if err = stackMD.validate(md); err != nil {
+		return Stack{}, fmt.Errorf("invalid stack file %q: %w", path, err)
+	}
 	return stackMD, nil
 }
@@ -6,8 +6,16 @@ import "github.com/buildpacks/lifecycle/buildpack"
 // It is not included in the output image, but can be saved off by the platform before the build container exits.
 // The location of the file can be specified by providing `-report <path>` to the lifecycle.
 type Report struct {
-	Build BuildReport `toml:"build,omitempty"`
+	Build        BuildReport         `toml:"build,omitempty"`
+	Image        ImageReport         `toml:"image"`
+	Destinations []DestinationReport `toml:"destinations,omitempty"`
+}
+
+// DestinationReport records the outcome of saving the image to an additional export destination.
+// Image.Tags contains only the references that were saved successfully; Error is set if any of the references were not.
+type DestinationReport struct {
 	Image ImageReport `toml:"image"`
+	Error string      `toml:"error,omitempty"`
 }
 
 type BuildReport struct {
@@ -25,3 +33,100 @@ type ImageReport struct {
 type RebaseReport struct {
 	Image ImageReport `toml:"image"`
 }
+
+// EgressReport is written by the analyzer and restorer, if requested, to record the registries contacted during the phase.
+type EgressReport struct {
+	Registries []RegistryEgress `toml:"registries"`
+}
+
+// RestoreReport is written by the restorer, if requested, to record the outcome of restoring cache layers.
+// BuildpacksWithoutLayers lists buildpacks in the group with no layers on disk or in the cache after restoring,
+// which may indicate a detection or ordering problem.
+type RestoreReport struct {
+	Restored                int                      `toml:"restored"`
+	Skipped                 int                      `toml:"skipped"`
+	RemovedNotInCache       int                      `toml:"removed-not-in-cache"`
+	RemovedWrongSHA         int                      `toml:"removed-wrong-sha"`
+	RemovedCorrupt          int                      `toml:"removed-corrupt"`
+	RemovedTimedOut         int                      `toml:"removed-timed-out"`
+	RemovedFailed           int                      `toml:"removed-failed"`
+	BytesRestored           int64                    `toml:"bytes-restored"`
+	BuildpacksWithoutLayers []string                 `toml:"buildpacks-without-layers,omitempty"`
+	Buildpacks              []RestoreBuildpackReport `toml:"buildpacks,omitempty"`
+}
+
+// RestoreBuildpackReport records, for a single buildpack, the number of cache=true layers restored from the cache (hits),
+// removed as not in the cache (misses), and removed as having the wrong sha (invalidations), so that platforms can tune caching.
+type RestoreBuildpackReport struct {
+	ID                string `toml:"id"`
+	Restored          int    `toml:"restored"`
+	RemovedNotInCache int    `toml:"removed-not-in-cache"`
+	RemovedWrongSHA   int    `toml:"removed-wrong-sha"`
+}
+
+// AnalyzeReport is written by the analyzer, if requested, to record the decisions made during the phase,
+// so that platforms can audit them without parsing logs.
+type AnalyzeReport struct {
+	RunImage      AnalyzeRunImageReport      `toml:"run-image"`
+	PreviousImage AnalyzePreviousImageReport `toml:"previous-image"`
+	StackID       string                     `toml:"stack-id,omitempty"`
+	Cache         AnalyzeCacheReport         `toml:"cache"`
+}
+
+// AnalyzeRunImageReport records the run image that was selected.
+// Mirror is true if the selected image is a registry mirror of the run image in run.toml (or stack.toml).
+type AnalyzeRunImageReport struct {
+	Image     string `toml:"image"`
+	Reference string `toml:"reference,omitempty"`
+	Mirror    bool   `toml:"mirror"`
+}
+
+// AnalyzePreviousImageReport records whether the previous image was found.
+type AnalyzePreviousImageReport struct {
+	Image     string `toml:"image,omitempty"`
+	Reference string `toml:"reference,omitempty"`
+	Found     bool   `toml:"found"`
+}
+
+// AnalyzeCacheReport records the cache source used by the phase, if any.
+type AnalyzeCacheReport struct {
+	Image string `toml:"image,omitempty"`
+	Dir   string `toml:"dir,omitempty"`
+}
+
+// ConfigDump is written by the analyzer, if requested, to record the effective configuration of the phase,
+// so that the invocation can be reproduced from a support bundle.
+// The values of environment variables that may contain secrets are redacted.
+type ConfigDump struct {
+	Phase       string            `toml:"phase"`
+	PlatformAPI string            `toml:"platform-api"`
+	Inputs      ConfigDumpInputs  `toml:"inputs"`
+	Env         map[string]string `toml:"env,omitempty"`
+}
+
+// ConfigDumpInputs holds the resolved values of the inputs used by the phase.
+type ConfigDumpInputs struct {
+	OutputImage        string   `toml:"output-image"`
+	AdditionalTags     []string `toml:"additional-tags,omitempty"`
+	PreviousImage      string   `toml:"previous-image,omitempty"`
+	RunImage           string   `toml:"run-image,omitempty"`
+	CacheImage         string   `toml:"cache-image,omitempty"`
+	CacheDir           string   `toml:"cache-dir,omitempty"`
+	LaunchCacheDir     string   `toml:"launch-cache-dir,omitempty"`
+	LayersDir          string   `toml:"layers-dir"`
+	AnalyzedPath       string   `toml:"analyzed-path"`
+	LayoutDir          string   `toml:"layout-dir,omitempty"`
+	UID                int      `toml:"uid"`
+	GID                int      `toml:"gid"`
+	UseDaemon          bool     `toml:"use-daemon"`
+	UseLayout          bool     `toml:"use-layout"`
+	SkipLayers         bool     `toml:"skip-layers"`
+	SkipPrevious       bool     `toml:"skip-previous"`
+	InsecureRegistries []string `toml:"insecure-registries,omitempty"`
+}
+
+// RegistryEgress records the number of operations performed against a registry host.
+type RegistryEgress struct {
+	Host       string `toml:"host"`
+	Operations int    `toml:"operations"`
+}
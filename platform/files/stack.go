@@ -1,6 +1,13 @@
 package files
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/go-containerregistry/pkg/name"
+
 	iname "github.com/buildpacks/lifecycle/internal/name"
 )
 
@@ -13,6 +20,36 @@ type Stack struct {
 	RunImage RunImageForExport `json:"runImage" toml:"run-image"`
 }
 
+// stackBuildImageKey is the stack.toml table describing the build image, which platforms may provide
+// (e.g., by copying the stack.toml of the builder) but which is not used by the lifecycle.
+const stackBuildImageKey = "build-image"
+
+// validate checks the structure of a decoded stack.toml, returning an error that names every invalid field.
+// An empty file is valid, as platforms may provide one when the run image is provided by other means.
+func (s Stack) validate(md toml.MetaData) error {
+	if len(md.Keys()) == 0 {
+		return nil
+	}
+	if !md.IsDefined("run-image") {
+		return errors.New("missing [run-image] table")
+	}
+	var problems []string
+	if s.RunImage.Image == "" {
+		problems = append(problems, "run-image.image must be provided")
+	} else if _, err := name.ParseReference(s.RunImage.Image, name.WeakValidation); err != nil {
+		problems = append(problems, fmt.Sprintf("run-image.image %q is not a valid image reference", s.RunImage.Image))
+	}
+	for i, mirror := range s.RunImage.Mirrors {
+		if _, err := name.ParseReference(mirror, name.WeakValidation); err != nil {
+			problems = append(problems, fmt.Sprintf("run-image.mirrors[%d] %q is not a valid image reference", i, mirror))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
 type RunImageForExport struct {
 	Image   string   `toml:"image,omitempty" json:"image,omitempty"`
 	Mirrors []string `toml:"mirrors,omitempty" json:"mirrors,omitempty"`
@@ -19,48 +19,108 @@ import (
 // LifecycleInputs holds the values of command-line flags and args i.e., platform inputs to the lifecycle.
 // Fields are the cumulative total of inputs across all lifecycle phases and all supported Platform APIs.
 type LifecycleInputs struct {
-	PlatformAPI           *api.Version
-	AnalyzedPath          string
-	AppDir                string
-	BuildConfigDir        string
-	BuildImageRef         string
-	BuildpacksDir         string
-	CacheDir              string
-	CacheImageRef         string
-	DefaultProcessType    string
-	DeprecatedRunImageRef string
-	ExtendKind            string
-	ExtendedDir           string
-	ExtensionsDir         string
-	GeneratedDir          string
-	GroupPath             string
-	KanikoDir             string
-	LaunchCacheDir        string
-	LauncherPath          string
-	LauncherSBOMDir       string
-	LayersDir             string
-	LayoutDir             string
-	LogLevel              string
-	OrderPath             string
-	OutputImageRef        string
-	PlanPath              string
-	PlatformDir           string
-	PreviousImageRef      string
-	ProjectMetadataPath   string
-	ReportPath            string
-	RunImageRef           string
-	RunPath               string
-	StackPath             string
-	UID                   int
-	GID                   int
-	ForceRebase           bool
-	SkipLayers            bool
-	ParallelExport        bool
-	UseDaemon             bool
-	UseLayout             bool
-	AdditionalTags        str.Slice // str.Slice satisfies the `Value` interface required by the `flag` package
-	KanikoCacheTTL        time.Duration
-	InsecureRegistries    str.Slice
+	PlatformAPI             *api.Version
+	AnalyzedPath            string
+	AnalyzeReportPath       string
+	AppDir                  string
+	BuildConfigDir          string
+	BuildImageRef           string
+	BuildpacksDir           string
+	BuildpackLabelSelector  string
+	CacheDir                string
+	CacheArchivePath        string
+	CacheImageRef           string
+	CacheCompression        string
+	CacheNamespace          string
+	ConfigDumpPath          string
+	CreationTime            string
+	DefaultProcessType      string
+	DeprecatedRunImageRef   string
+	EgressReportPath        string
+	RegistryAuthFile        string
+	RegistryCACert          string
+	NoProxy                 string
+	RestoreReportPath       string
+	ExtendKind              string
+	ExtendedDir             string
+	ExtensionsDir           string
+	GeneratedDir            string
+	GroupPath               string
+	KanikoDir               string
+	LaunchCacheDir          string
+	LauncherPath            string
+	LauncherSBOMDir         string
+	LayersDir               string
+	LayoutDir               string
+	LogLevel                string
+	MaxLayerSize            string
+	OrderPath               string
+	OutputImageRef          string
+	OverlayUpperDir         string
+	PlanPath                string
+	PlatformDir             string
+	PreviousImageRef        string
+	PreviousImageDigest     string
+	ProjectMetadataPath     string
+	PullPolicy              string
+	NoNetwork               bool
+	ReportPath              string
+	RunImageRef             string
+	UnmirroredRunImageRef   string // resolved from RunImageRef before registry mirrors are applied
+	RunImageLineage         string
+	RunPath                 string
+	SBOMOutputDir           string
+	StackPath               string
+	TargetArch              string
+	TargetOS                string
+	UID                     int
+	GID                     int
+	AllowPreviousDrift      bool
+	AllowMutableRunImage    bool
+	PinRunImage             bool
+	NoRunImage              bool
+	AtomicRestore           bool
+	BestEffortRestore       bool
+	DedupRestore            bool
+	RestoreDryRun           bool
+	ForceAnalyze            bool
+	ForceRebase             bool
+	ForceRebuild            bool
+	MetadataOnly            bool
+	SBOMOnly                bool
+	SkipSBOM                bool
+	SkipLayers              bool
+	SkipPrevious            bool
+	SkipAnalyzedChecksum    bool
+	ParallelExport          bool
+	AsyncCacheCommit        bool
+	CacheChunking           bool
+	CacheImageOCI           bool
+	CacheFallback           bool
+	LogHTTP                 bool
+	StrictCacheCommit       bool
+	PruneCache              bool
+	UseBuildImageMixins     bool
+	UseDaemon               bool
+	UseLayout               bool
+	WarnUnsupportedAPI      bool
+	AdditionalTags          str.Slice // str.Slice satisfies the `Value` interface required by the `flag` package
+	KanikoCacheTTL          time.Duration
+	ClockSkewThreshold      time.Duration
+	LayerRestoreTimeout     time.Duration
+	MaxLayerSizeBytes       int64     // resolved from MaxLayerSize
+	CreatedAt               time.Time // resolved from CreationTime
+	SlowLayerThreshold      time.Duration
+	InsecureRegistries      str.Slice
+	ReadOnlyPaths           str.Slice
+	RegistryMirrors         str.Slice
+	RequiredMixins          str.Slice
+	SkipRestorePatterns     str.Slice
+	RestoreBuildpacks       str.Slice
+	PreserveModTimes        str.Slice
+	CacheNamespaceFallbacks str.Slice
+	CacheSources            []CacheSource // provided by repeating the restorer's cache flags, in precedence order
+	ExportDestinations      []ExportDestination
 }
 
 const PlaceholderLayers = "<layers>"
@@ -91,8 +151,13 @@ func NewLifecycleInputs(platformAPI *api.Version) *LifecycleInputs {
 		PlatformAPI:        platformAPI,
 		ExtendKind:         envOrDefault(EnvExtendKind, DefaultExtendKind),
 		UseDaemon:          boolEnv(EnvUseDaemon),
+		PullPolicy:         os.Getenv(EnvPullPolicy),
+		NoNetwork:          boolEnv(EnvNoNetwork),
 		InsecureRegistries: sliceEnv(EnvInsecureRegistries),
 		UseLayout:          boolEnv(EnvUseLayout),
+		WarnUnsupportedAPI: boolEnv(EnvWarnUnsupportedAPI),
+		ReadOnlyPaths:      sliceEnv(EnvReadOnlyPaths),
+		RegistryMirrors:    sliceEnv(EnvRegistryMirrors),
 
 		// Provided by the base image
 
@@ -109,11 +174,12 @@ func NewLifecycleInputs(platformAPI *api.Version) *LifecycleInputs {
 
 		// Provided at build time
 
-		AppDir:      envOrDefault(EnvAppDir, DefaultAppDir),
-		LayersDir:   envOrDefault(EnvLayersDir, DefaultLayersDir),
-		LayoutDir:   os.Getenv(EnvLayoutDir),
-		OrderPath:   envOrDefault(EnvOrderPath, filepath.Join(PlaceholderLayers, DefaultOrderFile)),
-		PlatformDir: envOrDefault(EnvPlatformDir, DefaultPlatformDir),
+		AppDir:                 envOrDefault(EnvAppDir, DefaultAppDir),
+		LayersDir:              envOrDefault(EnvLayersDir, DefaultLayersDir),
+		LayoutDir:              os.Getenv(EnvLayoutDir),
+		OrderPath:              envOrDefault(EnvOrderPath, filepath.Join(PlaceholderLayers, DefaultOrderFile)),
+		BuildpackLabelSelector: os.Getenv(EnvBuildpackLabelSelector),
+		PlatformDir:            envOrDefault(EnvPlatformDir, DefaultPlatformDir),
 
 		// The following instruct the lifecycle where to write files and data during the build
 
@@ -124,15 +190,55 @@ func NewLifecycleInputs(platformAPI *api.Version) *LifecycleInputs {
 		PlanPath:     envOrDefault(EnvPlanPath, filepath.Join(PlaceholderLayers, DefaultPlanFile)),
 		ReportPath:   envOrDefault(EnvReportPath, filepath.Join(PlaceholderLayers, DefaultReportFile)),
 
+		ConfigDumpPath:    os.Getenv(EnvConfigDumpPath),
+		AnalyzeReportPath: os.Getenv(EnvAnalyzeReportPath),
+		EgressReportPath:  os.Getenv(EnvEgressReportPath),
+		RegistryAuthFile:  os.Getenv(EnvRegistryAuthFile),
+		RegistryCACert:    os.Getenv(EnvRegistryCACert),
+		NoProxy:           os.Getenv(EnvNoProxy),
+		RestoreReportPath: os.Getenv(EnvRestoreReportPath),
+		SBOMOutputDir:     os.Getenv(EnvSBOMOutputDir),
+
 		// Configuration options with respect to caching
 
-		CacheDir:       os.Getenv(EnvCacheDir),
-		CacheImageRef:  os.Getenv(EnvCacheImage),
-		KanikoCacheTTL: timeEnvOrDefault(EnvKanikoCacheTTL, DefaultKanikoCacheTTL),
-		KanikoDir:      "/kaniko",
-		LaunchCacheDir: os.Getenv(EnvLaunchCacheDir),
-		SkipLayers:     skipLayers,
-		ParallelExport: boolEnv(EnvParallelExport),
+		CacheDir:             os.Getenv(EnvCacheDir),
+		CacheImageRef:        os.Getenv(EnvCacheImage),
+		CacheArchivePath:     os.Getenv(EnvCacheArchivePath),
+		CacheCompression:     os.Getenv(EnvCacheCompression),
+		CacheNamespace:       os.Getenv(EnvCacheNamespace),
+		KanikoCacheTTL:       timeEnvOrDefault(EnvKanikoCacheTTL, DefaultKanikoCacheTTL),
+		KanikoDir:            "/kaniko",
+		LaunchCacheDir:       os.Getenv(EnvLaunchCacheDir),
+		SkipLayers:           skipLayers,
+		SkipPrevious:         boolEnv(EnvSkipPrevious),
+		SkipAnalyzedChecksum: boolEnv(EnvSkipAnalyzedChecksum),
+		ParallelExport:       boolEnv(EnvParallelExport),
+
+		OverlayUpperDir:     os.Getenv(EnvOverlayUpper),
+		AtomicRestore:       boolEnv(EnvAtomicRestore),
+		BestEffortRestore:   boolEnv(EnvBestEffortRestore),
+		DedupRestore:        boolEnv(EnvDedupRestore),
+		RestoreDryRun:       boolEnv(EnvRestoreDryRun),
+		MetadataOnly:        boolEnv(EnvMetadataOnly),
+		SBOMOnly:            boolEnv(EnvSBOMOnly),
+		SkipSBOM:            boolEnv(EnvSkipSBOM),
+		ClockSkewThreshold:  timeEnvOrDefault(EnvClockSkewThreshold, DefaultClockSkewThreshold),
+		LayerRestoreTimeout: timeEnvOrDefault(EnvLayerRestoreTimeout, 0),
+		MaxLayerSize:        os.Getenv(EnvMaxLayerSize),
+		SlowLayerThreshold:  timeEnvOrDefault(EnvSlowLayerThreshold, 0),
+
+		SkipRestorePatterns:     sliceEnv(EnvSkipRestorePatterns),
+		RestoreBuildpacks:       sliceEnv(EnvRestoreBuildpacks),
+		PreserveModTimes:        sliceEnv(EnvPreserveModTimes),
+		CacheNamespaceFallbacks: sliceEnv(EnvCacheNamespaceFallbacks),
+
+		AsyncCacheCommit:  boolEnv(EnvAsyncCacheCommit),
+		StrictCacheCommit: boolEnv(EnvStrictCacheCommit),
+		PruneCache:        boolEnv(EnvPruneCache),
+		CacheChunking:     boolEnv(EnvCacheChunking),
+		CacheImageOCI:     boolEnv(EnvCacheImageOCI),
+		CacheFallback:     boolEnv(EnvCacheFallback),
+		LogHTTP:           boolEnv(EnvLogHTTP),
 
 		// Images used by the lifecycle during the build
 
@@ -141,10 +247,24 @@ func NewLifecycleInputs(platformAPI *api.Version) *LifecycleInputs {
 		DeprecatedRunImageRef: "", // no default
 		OutputImageRef:        "", // no default
 		PreviousImageRef:      os.Getenv(EnvPreviousImage),
+		PreviousImageDigest:   os.Getenv(EnvPreviousImageDigest),
+		AllowPreviousDrift:    boolEnv(EnvAllowPreviousDrift),
+		ForceRebuild:          boolEnv(EnvForceRebuild),
+		PinRunImage:           boolEnv(EnvPinRunImage),
+		NoRunImage:            boolEnv(EnvNoRunImage),
+		AllowMutableRunImage:  boolEnv(EnvAllowMutableRunImage),
+		ForceAnalyze:          boolEnv(EnvForceAnalyze),
 		RunImageRef:           os.Getenv(EnvRunImage),
+		RunImageLineage:       os.Getenv(EnvRunImageLineage),
+		RequiredMixins:        sliceEnv(EnvRequiredMixins),
+		UseBuildImageMixins:   boolEnv(EnvUseBuildImageMixins),
+		TargetArch:            os.Getenv(EnvTargetArch),
+		TargetOS:              os.Getenv(EnvTargetOS),
+		ExportDestinations:    exportDestinationsEnv(EnvExportDestinations),
 
 		// Configuration options for the output application image
 
+		CreationTime:        os.Getenv(EnvCreationTime),
 		DefaultProcessType:  os.Getenv(EnvProcessType),
 		LauncherPath:        DefaultLauncherPath,
 		LauncherSBOMDir:     DefaultBuildpacksioSBOMDir,
@@ -186,17 +306,39 @@ func (i *LifecycleInputs) DestinationImages() []string {
 func (i *LifecycleInputs) Images() []string {
 	var ret []string
 	ret = appendOnce(ret, i.DestinationImages()...)
-	ret = appendOnce(ret, i.PreviousImageRef, i.BuildImageRef, i.RunImageRef, i.DeprecatedRunImageRef, i.CacheImageRef)
+	ret = appendOnce(ret, i.PreviousImageRefs()...)
+	ret = appendOnce(ret, i.BuildImageRef, i.RunImageRef, i.DeprecatedRunImageRef, i.RegistryCacheImageRef())
+	return ret
+}
+
+// PreviousImageRefs returns the candidate references for the previous image, in the order they should be tried.
+// The previous image may be provided as a comma-separated list of references (e.g., a branch-specific tag followed by a fallback tag).
+func (i *LifecycleInputs) PreviousImageRefs() []string {
+	var ret []string
+	for _, ref := range strings.Split(i.PreviousImageRef, ",") {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			ret = append(ret, ref)
+		}
+	}
 	return ret
 }
 
+// ReadsBuildImageMixins returns true if the mixins required by the build are to be read from the build image,
+// which is only the case if the platform asks for it and does not provide the mixins itself.
+func (i *LifecycleInputs) ReadsBuildImageMixins() bool {
+	return i.UseBuildImageMixins && len(i.RequiredMixins) == 0 && i.BuildImageRef != ""
+}
+
 func (i *LifecycleInputs) RegistryImages() []string {
 	var ret []string
-	ret = appendOnce(ret, i.CacheImageRef)
+	ret = appendOnce(ret, i.RegistryCacheImageRef())
 	if i.UseDaemon {
 		return ret
 	}
 	ret = appendOnce(ret, i.Images()...)
+	for _, destination := range i.ExportDestinations {
+		ret = appendOnce(ret, destination.Images()...)
+	}
 	return ret
 }
 
@@ -342,6 +484,8 @@ func (i *LifecycleInputs) directoryPaths() []*string {
 		&i.KanikoDir,
 		&i.LaunchCacheDir,
 		&i.LayersDir,
+		&i.OverlayUpperDir,
 		&i.PlatformDir,
+		&i.SBOMOutputDir,
 	}
 }
@@ -279,6 +279,27 @@ func testLifecycleInputs(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#PreviousImageRefs", func() {
+		it("returns the candidate references in order", func() {
+			inputs := &platform.LifecycleInputs{PreviousImageRef: "some/repo:some-branch, some/repo:main"}
+			h.AssertEq(t, inputs.PreviousImageRefs(), []string{"some/repo:some-branch", "some/repo:main"})
+		})
+
+		when("a single reference is provided", func() {
+			it("returns the reference", func() {
+				inputs := &platform.LifecycleInputs{PreviousImageRef: "some/repo:main"}
+				h.AssertEq(t, inputs.PreviousImageRefs(), []string{"some/repo:main"})
+			})
+		})
+
+		when("no reference is provided", func() {
+			it("returns nothing", func() {
+				inputs := &platform.LifecycleInputs{}
+				h.AssertEq(t, len(inputs.PreviousImageRefs()), 0)
+			})
+		})
+	})
+
 	when("#ValidateSameRegistry", func() {
 		when("multiple registries are provided", func() {
 			it("errors as unsupported", func() {
@@ -0,0 +1,100 @@
+package platform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/buildpacks/lifecycle/log"
+)
+
+// RegistryMirror rewrites image references in From, a registry optionally followed by a repository prefix (e.g., `docker.io`
+// or `gcr.io/some-org`), to be in To instead (e.g., `mirror.internal` or `mirror.internal/gcr`).
+type RegistryMirror struct {
+	From string
+	To   string
+}
+
+// ParseRegistryMirror parses a registry mirror of the form `<from>=<to>`, e.g., `docker.io=mirror.internal`.
+func ParseRegistryMirror(value string) (RegistryMirror, error) {
+	from, to, ok := strings.Cut(value, "=")
+	from, to = strings.Trim(strings.TrimSpace(from), "/"), strings.Trim(strings.TrimSpace(to), "/")
+	if !ok || from == "" || to == "" {
+		return RegistryMirror{}, fmt.Errorf("invalid registry mirror %q: must be of the form <from>=<to>", value)
+	}
+	host, repo, _ := strings.Cut(from, "/")
+	registry, err := name.NewRegistry(host, name.WeakValidation)
+	if err != nil {
+		return RegistryMirror{}, fmt.Errorf("invalid registry mirror %q: %w", value, err)
+	}
+	// normalize the registry, e.g., `docker.io` to `index.docker.io`, so that it matches parsed references
+	from = registry.RegistryStr()
+	if repo != "" {
+		from += "/" + repo
+	}
+	return RegistryMirror{From: from, To: to}, nil
+}
+
+// Rewrite returns the provided image reference rewritten to be in the mirror, and whether the mirror applies to it.
+// References that cannot be parsed are returned unchanged.
+func (m RegistryMirror) Rewrite(imageRef string) (string, bool) {
+	ref, err := name.ParseReference(imageRef, name.WeakValidation)
+	if err != nil {
+		return imageRef, false
+	}
+	repo := ref.Context().RegistryStr() + "/" + ref.Context().RepositoryStr()
+	if repo != m.From && !strings.HasPrefix(repo, m.From+"/") {
+		return imageRef, false
+	}
+	rewritten := m.To + strings.TrimPrefix(repo, m.From)
+	switch r := ref.(type) {
+	case name.Digest:
+		return rewritten + "@" + r.DigestStr(), true
+	case name.Tag:
+		return rewritten + ":" + r.TagStr(), true
+	}
+	return rewritten, true
+}
+
+// RewriteReference returns the provided image reference rewritten by the first of the provided mirrors that applies to it, if any.
+func RewriteReference(imageRef string, mirrors []RegistryMirror) string {
+	for _, mirror := range mirrors {
+		if rewritten, ok := mirror.Rewrite(imageRef); ok {
+			return rewritten
+		}
+	}
+	return imageRef
+}
+
+// ApplyRegistryMirrors rewrites the references of the read-only base images (the run image and the build image)
+// according to the provided registry mirrors, if any.
+// The previous image and the cache image are not rewritten, as they are also written to (e.g., the cache image is exported
+// and the previous image is usually the output image), and a mirror is not expected to contain the latest version of them.
+func ApplyRegistryMirrors(i *LifecycleInputs, logger log.Logger) error {
+	if len(i.RegistryMirrors) == 0 {
+		return nil
+	}
+	var mirrors []RegistryMirror
+	for _, value := range i.RegistryMirrors {
+		mirror, err := ParseRegistryMirror(value)
+		if err != nil {
+			return err
+		}
+		mirrors = append(mirrors, mirror)
+	}
+	rewrite := func(imageRef *string) {
+		if *imageRef == "" {
+			return
+		}
+		if rewritten := RewriteReference(*imageRef, mirrors); rewritten != *imageRef {
+			logger.Debugf("Using registry mirror %q for %q", rewritten, *imageRef)
+			*imageRef = rewritten
+		}
+	}
+	i.UnmirroredRunImageRef = i.RunImageRef
+	rewrite(&i.RunImageRef)
+	rewrite(&i.DeprecatedRunImageRef)
+	rewrite(&i.BuildImageRef)
+	return nil
+}
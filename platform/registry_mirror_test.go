@@ -0,0 +1,115 @@
+package platform_test
+
+import (
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/api"
+	"github.com/buildpacks/lifecycle/platform"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestRegistryMirror(t *testing.T) {
+	spec.Run(t, "RegistryMirror", testRegistryMirror, spec.Report(report.Terminal{}))
+}
+
+func testRegistryMirror(t *testing.T, when spec.G, it spec.S) {
+	when(".ParseRegistryMirror", func() {
+		it("normalizes the registry", func() {
+			mirror, err := platform.ParseRegistryMirror("docker.io=mirror.internal/")
+			h.AssertNil(t, err)
+			h.AssertEq(t, mirror, platform.RegistryMirror{From: "index.docker.io", To: "mirror.internal"})
+		})
+
+		when("the mirror is not of the form <from>=<to>", func() {
+			it("errors", func() {
+				_, err := platform.ParseRegistryMirror("docker.io")
+				h.AssertError(t, err, `invalid registry mirror "docker.io": must be of the form <from>=<to>`)
+			})
+		})
+	})
+
+	when("#Rewrite", func() {
+		it("rewrites references in the registry", func() {
+			mirror, err := platform.ParseRegistryMirror("docker.io=mirror.internal")
+			h.AssertNil(t, err)
+
+			for imageRef, expected := range map[string]string{
+				"ubuntu":                                 "mirror.internal/library/ubuntu:latest",
+				"docker.io/some-org/some-image:some-tag": "mirror.internal/some-org/some-image:some-tag",
+				"some-org/some-image@sha256:0000000000000000000000000000000000000000000000000000000000000000": "mirror.internal/some-org/some-image@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			} {
+				rewritten, ok := mirror.Rewrite(imageRef)
+				h.AssertEq(t, ok, true)
+				h.AssertEq(t, rewritten, expected)
+			}
+		})
+
+		it("rewrites references with the repository prefix", func() {
+			mirror, err := platform.ParseRegistryMirror("gcr.io/some-org=mirror.internal/gcr")
+			h.AssertNil(t, err)
+
+			rewritten, ok := mirror.Rewrite("gcr.io/some-org/some-image:some-tag")
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, rewritten, "mirror.internal/gcr/some-image:some-tag")
+
+			_, ok = mirror.Rewrite("gcr.io/some-org-other/some-image:some-tag")
+			h.AssertEq(t, ok, false)
+		})
+
+		it("does not rewrite references in other registries", func() {
+			mirror, err := platform.ParseRegistryMirror("docker.io=mirror.internal")
+			h.AssertNil(t, err)
+
+			rewritten, ok := mirror.Rewrite("gcr.io/some-org/some-image")
+			h.AssertEq(t, ok, false)
+			h.AssertEq(t, rewritten, "gcr.io/some-org/some-image")
+		})
+	})
+
+	when(".ApplyRegistryMirrors", func() {
+		var (
+			inputs *platform.LifecycleInputs
+			logger = &log.Logger{Handler: memory.New()}
+		)
+
+		it.Before(func() {
+			inputs = platform.NewLifecycleInputs(api.Platform.Latest())
+			inputs.RegistryMirrors = []string{"gcr.io/some-org=mirror.internal/gcr", "docker.io=mirror.internal"}
+		})
+
+		it("rewrites the references of the base images", func() {
+			inputs.RunImageRef = "gcr.io/some-org/some-run-image"
+			inputs.BuildImageRef = "some-org/some-build-image"
+
+			h.AssertNil(t, platform.ApplyRegistryMirrors(inputs, logger))
+
+			h.AssertEq(t, inputs.RunImageRef, "mirror.internal/gcr/some-run-image:latest")
+			h.AssertEq(t, inputs.UnmirroredRunImageRef, "gcr.io/some-org/some-run-image")
+			h.AssertEq(t, inputs.BuildImageRef, "mirror.internal/some-org/some-build-image:latest")
+		})
+
+		it("does not rewrite the references of images that are written", func() {
+			inputs.OutputImageRef = "some-org/some-app"
+			inputs.PreviousImageRef = "some-org/some-app:branch,some-org/some-app"
+			inputs.CacheImageRef = "some-org/some-cache-image"
+
+			h.AssertNil(t, platform.ApplyRegistryMirrors(inputs, logger))
+
+			h.AssertEq(t, inputs.OutputImageRef, "some-org/some-app")
+			h.AssertEq(t, inputs.PreviousImageRef, "some-org/some-app:branch,some-org/some-app")
+			h.AssertEq(t, inputs.CacheImageRef, "some-org/some-cache-image")
+		})
+
+		when("a mirror is invalid", func() {
+			it("errors", func() {
+				inputs.RegistryMirrors = []string{"=mirror.internal"}
+				h.AssertError(t, platform.ApplyRegistryMirrors(inputs, logger), `invalid registry mirror "=mirror.internal"`)
+			})
+		})
+	})
+}
@@ -1,7 +1,9 @@
 package platform_test
 
 import (
+	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/apex/log"
@@ -58,6 +60,19 @@ func testResolveAnalyzeInputs(platformAPI string) func(t *testing.T, when spec.G
 						h.AssertEq(t, inputs.RunImageRef, "some-run-image")
 					})
 
+					when("the run image in run.toml is pinned to a digest", func() {
+						it("preserves the run image reference even when there are mirrors in the output registry", func() {
+							runImage := "some-registry.io/some-run-image@sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+							inputs.RunPath = filepath.Join(t.TempDir(), "run.toml")
+							h.Mkfile(t, "[[images]]\n image = \""+runImage+"\"\n mirrors = [\"some-output-registry.io/some-run-image:some-tag\"]\n", inputs.RunPath)
+							inputs.OutputImageRef = "some-output-registry.io/some-output-image"
+
+							err := platform.ResolveInputs(platform.Analyze, inputs, logger)
+							h.AssertNil(t, err)
+							h.AssertEq(t, inputs.RunImageRef, runImage)
+						})
+					})
+
 					when("run.toml", func() {
 						when("not provided", func() {
 							it("defaults to /cnb/run.toml", func() {
@@ -79,6 +94,47 @@ func testResolveAnalyzeInputs(platformAPI string) func(t *testing.T, when spec.G
 					})
 				})
 			})
+
+			when("no run image", func() {
+				it.Before(func() {
+					h.SkipIf(t, runtime.GOOS == "windows", "Windows images cannot be exported without a run image")
+					inputs.RunImageRef = ""
+					inputs.NoRunImage = true
+					inputs.TargetOS = "linux"
+					inputs.TargetArch = "amd64"
+				})
+
+				it("does not read run.toml", func() {
+					inputs.RunPath = "not-exist-run.toml"
+					err := platform.ResolveInputs(platform.Analyze, inputs, logger)
+					h.AssertNil(t, err)
+					h.AssertEq(t, inputs.RunImageRef, "")
+				})
+
+				when("a run image is provided", func() {
+					it("errors", func() {
+						inputs.RunImageRef = "some-run-image"
+						err := platform.ResolveInputs(platform.Analyze, inputs, logger)
+						h.AssertError(t, err, platform.ErrSupplyOnlyOneOfRunImageOrNoRunImage)
+					})
+				})
+
+				when("a target is not provided", func() {
+					it("errors", func() {
+						inputs.TargetArch = ""
+						err := platform.ResolveInputs(platform.Analyze, inputs, logger)
+						h.AssertError(t, err, platform.ErrNoRunImageTargetRequired)
+					})
+				})
+
+				when("the target is Windows", func() {
+					it("errors", func() {
+						inputs.TargetOS = "windows"
+						err := platform.ResolveInputs(platform.Analyze, inputs, logger)
+						h.AssertError(t, err, platform.ErrNoRunImageUnsupportedOnWindows)
+					})
+				})
+			})
 		})
 
 		when("Platform API 0.7 to 0.11", func() {
@@ -118,6 +174,14 @@ func testResolveAnalyzeInputs(platformAPI string) func(t *testing.T, when spec.G
 					})
 				})
 			})
+
+			when("no run image", func() {
+				it("errors", func() {
+					inputs.NoRunImage = true
+					err := platform.ResolveInputs(platform.Analyze, inputs, logger)
+					h.AssertError(t, err, platform.ErrNoRunImageUnsupported)
+				})
+			})
 		})
 
 		when("provided destination tags are on different registries", func() {
@@ -138,5 +202,144 @@ func testResolveAnalyzeInputs(platformAPI string) func(t *testing.T, when spec.G
 				h.AssertStringContains(t, err.Error(), expected)
 			})
 		})
+
+		when("pull policy", func() {
+			it.Before(func() {
+				inputs.RunImageRef = "some-run-image" // satisfy validation
+			})
+
+			when("invalid", func() {
+				it("errors", func() {
+					inputs.PullPolicy = "sometimes"
+					err := platform.ResolveInputs(platform.Analyze, inputs, logger)
+					h.AssertError(t, err, `invalid pull policy "sometimes"`)
+				})
+			})
+
+			when("not exporting to a daemon", func() {
+				it("warns", func() {
+					inputs.PullPolicy = "always"
+					inputs.UseDaemon = false
+					inputs.OutputImageRef = "some-registry.io/some-namespace/some-image"
+					inputs.RunImageRef = "some-registry.io/some-namespace/some-run-image"
+					h.AssertNil(t, platform.ResolveInputs(platform.Analyze, inputs, logger))
+					expected := platform.MsgIgnoringPullPolicy
+					h.AssertLogEntry(t, logHandler, expected)
+				})
+			})
+
+			when("network access is disabled", func() {
+				it.Before(func() {
+					inputs.NoNetwork = true
+					inputs.UseDaemon = true
+				})
+
+				it("errors if images would be pulled", func() {
+					inputs.PullPolicy = "if-not-present"
+					err := platform.ResolveInputs(platform.Analyze, inputs, logger)
+					h.AssertError(t, err, `pull policy "if-not-present" pulls images from the registry, but network access is disabled`)
+				})
+
+				it("allows images not to be pulled", func() {
+					inputs.PullPolicy = "never"
+					h.AssertNil(t, platform.ResolveInputs(platform.Analyze, inputs, logger))
+				})
+			})
+		})
+
+		when("network access is disabled", func() {
+			it.Before(func() {
+				inputs.RunImageRef = "some-run-image" // satisfy validation
+				inputs.NoNetwork = true
+			})
+
+			when("images are read from a registry", func() {
+				it("errors", func() {
+					inputs.UseDaemon = false
+					inputs.OutputImageRef = "some-registry.io/some-namespace/some-image"
+					inputs.RunImageRef = "some-registry.io/some-namespace/some-run-image"
+					err := platform.ResolveInputs(platform.Analyze, inputs, logger)
+					h.AssertError(t, err, platform.ErrNoNetworkRequiresDaemonOrLayout)
+				})
+			})
+
+			when("images are read from the daemon", func() {
+				it("succeeds", func() {
+					inputs.UseDaemon = true
+					h.AssertNil(t, platform.ResolveInputs(platform.Analyze, inputs, logger))
+				})
+			})
+		})
+
+		when("the mixins of the build image are requested", func() {
+			it.Before(func() {
+				inputs.RunImageRef = "some-run-image" // satisfy validation
+				inputs.UseBuildImageMixins = true
+			})
+
+			when("no build image is provided", func() {
+				it("errors", func() {
+					err := platform.ResolveInputs(platform.Analyze, inputs, logger)
+					h.AssertError(t, err, platform.ErrUseBuildImageMixinsRequiresBuildImage)
+				})
+			})
+
+			when("a build image is provided", func() {
+				it("succeeds", func() {
+					inputs.BuildImageRef = "some-build-image"
+					h.AssertNil(t, platform.ResolveInputs(platform.Analyze, inputs, logger))
+				})
+			})
+		})
+
+		when("run image lineage", func() {
+			it.Before(func() {
+				inputs.RunImageRef = "some-run-image" // satisfy validation
+			})
+
+			it("accepts warn and fail", func() {
+				for _, lineage := range []string{platform.RunImageLineageWarn, platform.RunImageLineageFail} {
+					inputs.RunImageLineage = lineage
+					h.AssertNil(t, platform.ResolveInputs(platform.Analyze, inputs, logger))
+				}
+			})
+
+			when("invalid", func() {
+				it("errors", func() {
+					inputs.RunImageLineage = "sometimes"
+					err := platform.ResolveInputs(platform.Analyze, inputs, logger)
+					h.AssertError(t, err, `invalid run image lineage check "sometimes": must be "warn" or "fail"`)
+				})
+			})
+		})
+
+		when("provided destination tags contain templates", func() {
+			it.Before(func() {
+				inputs.RunImageRef = "some-run-image" // satisfy validation
+				inputs.OutputImageRef = "some-registry.io/some-namespace/some-image"
+			})
+
+			it("expands them", func() {
+				h.AssertNil(t, os.Setenv("SOME_TAG_VAR", "some-value"))
+				defer os.Unsetenv("SOME_TAG_VAR")
+				inputs.AdditionalTags = str.Slice{
+					"some-registry.io/some-namespace/some-image:{{env \"SOME_TAG_VAR\"}}",
+					"some-registry.io/some-namespace/some-image:plain",
+				}
+				h.AssertNil(t, platform.ResolveInputs(platform.Analyze, inputs, logger))
+				h.AssertEq(t, inputs.AdditionalTags, str.Slice{
+					"some-registry.io/some-namespace/some-image:some-value",
+					"some-registry.io/some-namespace/some-image:plain",
+				})
+			})
+
+			when("a template is invalid", func() {
+				it("errors", func() {
+					inputs.AdditionalTags = str.Slice{"some-registry.io/some-namespace/some-image:{{.Unknown}}"}
+					err := platform.ResolveInputs(platform.Analyze, inputs, logger)
+					h.AssertError(t, err, `invalid tag template "some-registry.io/some-namespace/some-image:{{.Unknown}}"`)
+				})
+			})
+		})
 	}
 }
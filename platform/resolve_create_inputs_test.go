@@ -57,6 +57,14 @@ func testResolveCreateInputs(platformAPI string) func(t *testing.T, when spec.G,
 				})
 			})
 
+			when("no run image", func() {
+				it("errors", func() {
+					inputs.NoRunImage = true
+					err := platform.ResolveInputs(platform.Create, inputs, logger)
+					h.AssertError(t, err, platform.ErrNoRunImageUnsupportedByCreator)
+				})
+			})
+
 			when("run image", func() {
 				when("not provided", func() {
 					it.Before(func() {
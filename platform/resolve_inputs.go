@@ -2,10 +2,16 @@ package platform
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"path"
+	"runtime"
+	"strconv"
 
 	"github.com/google/go-containerregistry/pkg/name"
 
+	"github.com/buildpacks/lifecycle/buildpack"
+	"github.com/buildpacks/lifecycle/image"
 	"github.com/buildpacks/lifecycle/log"
 	"github.com/buildpacks/lifecycle/platform/files"
 )
@@ -17,61 +23,116 @@ var (
 	ErrRunImageRequiredWhenNoRunMD = "-run-image is required when there is no run metadata available"
 	// ErrSupplyOnlyOneRunImage user facing error message
 	ErrSupplyOnlyOneRunImage = "supply only one of -run-image or (deprecated) -image"
+	// ErrNoRunImageUnsupported user facing error message
+	ErrNoRunImageUnsupported = "-no-run-image is unsupported for Platform API < 0.12"
+	// ErrNoRunImageUnsupportedByCreator user facing error message
+	ErrNoRunImageUnsupportedByCreator = "exporting without a run image is unsupported by the creator"
+	// ErrSupplyOnlyOneOfRunImageOrNoRunImage user facing error message
+	ErrSupplyOnlyOneOfRunImageOrNoRunImage = "supply only one of -run-image or -no-run-image"
+	// ErrNoNetworkRequiresDaemonOrLayout user facing error message
+	ErrNoNetworkRequiresDaemonOrLayout = "-no-network requires -daemon or -layout, as images are otherwise read from a registry"
+	// ErrNoRunImageTargetRequired user facing error message
+	ErrNoRunImageTargetRequired = "-target-os and -target-arch are required with -no-run-image"
+	// ErrNoRunImageUnsupportedOnWindows user facing error message
+	ErrNoRunImageUnsupportedOnWindows = "-no-run-image is unsupported for Windows images"
+	// ErrUseBuildImageMixinsRequiresBuildImage user facing error message
+	ErrUseBuildImageMixinsRequiresBuildImage = "-use-build-image-mixins requires -build-image"
 	// ErrRunImageUnsupported user facing error message
 	ErrRunImageUnsupported = "-run-image is unsupported"
 	// ErrImageUnsupported user facing error message
 	ErrImageUnsupported = "-image is unsupported"
 	// MsgIgnoringLaunchCache user facing error message
 	MsgIgnoringLaunchCache = "Ignoring -launch-cache, only intended for use with -daemon"
+	// MsgIgnoringPullPolicy user facing error message
+	MsgIgnoringPullPolicy = "Ignoring -pull-policy, only intended for use with -daemon"
+)
+
+const (
+	// RunImageLineageWarn instructs the analyzer to warn when the previous image was built on a different run image.
+	RunImageLineageWarn = "warn"
+	// RunImageLineageFail instructs the analyzer to fail when the previous image was built on a different run image.
+	RunImageLineageFail = "fail"
 )
 
 func ResolveInputs(phase LifecyclePhase, i *LifecycleInputs, logger log.Logger) error {
+	// analyzed.toml is read and written through the default handler, including by the operations below
+	files.Handler.SkipAnalyzedChecksum = i.SkipAnalyzedChecksum
+
 	// order of operations is important
 	ops := []LifecycleInputsOperation{UpdatePlaceholderPaths, ResolveAbsoluteDirPaths}
 	switch phase {
 	case Analyze:
 		ops = append(ops,
+			ValidateNoRunImage,
+			ValidateNoNetwork,
 			FillAnalyzeImages,
+			ApplyRegistryMirrors,
 			ValidateOutputImageProvided,
+			ResolveCacheNamespace,
 			CheckLaunchCache,
+			ExpandTagTemplates,
 			ValidateImageRefs,
 			ValidateTargetsAreSameRegistry,
 			CheckParallelExport,
+			ValidateBuildpackLabelSelector,
+			ValidatePullPolicy,
+			ValidateRunImageLineage,
+			ValidateUseBuildImageMixins,
 		)
 	case Build:
 		// nop
 	case Create:
 		ops = append(ops,
+			ValidateCreateNoRunImage,
 			FillCreateImages,
+			ApplyRegistryMirrors,
 			ValidateOutputImageProvided,
 			CheckCache,
+			ResolveCacheNamespace,
 			CheckLaunchCache,
+			ExpandTagTemplates,
 			ValidateImageRefs,
 			ValidateTargetsAreSameRegistry,
+			ValidateExportDestinations,
 			CheckParallelExport,
+			ValidatePreserveModTimes,
+			ResolveCreationTime,
+			ValidateBuildpackLabelSelector,
+			ValidatePullPolicy,
+			ValidateRunImageLineage,
+			ValidateUseBuildImageMixins,
 		)
 	case Detect:
-		// nop
+		ops = append(ops, ValidateBuildpackLabelSelector)
 	case Export:
 		ops = append(ops,
 			FillExportRunImage,
+			ApplyRegistryMirrors,
 			ValidateOutputImageProvided,
 			CheckCache,
+			ResolveCacheNamespace,
 			CheckLaunchCache,
+			ExpandTagTemplates,
 			ValidateImageRefs,
 			ValidateTargetsAreSameRegistry,
+			ValidateExportDestinations,
+			ValidatePreserveModTimes,
+			ResolveCreationTime,
 		)
 	case Extend:
 		// nop
 	case Rebase:
 		ops = append(ops,
 			ValidateRebaseRunImage,
+			ValidateRebasePreviousImage,
+			ApplyRegistryMirrors,
 			ValidateOutputImageProvided,
+			ExpandTagTemplates,
 			ValidateImageRefs,
 			ValidateTargetsAreSameRegistry,
 		)
 	case Restore:
-		ops = append(ops, CheckCache)
+		ops = append(ops, ApplyRegistryMirrors, CheckCache, ResolveCacheNamespace, ValidateRestoreMode, ValidateMaxLayerSize)
 	}
 
 	var err error
@@ -101,10 +162,91 @@ func CheckLaunchCache(i *LifecycleInputs, logger log.Logger) error {
 	return nil
 }
 
+// ValidatePullPolicy ensures the pull policy, if provided, is valid; it is ignored unless exporting to a daemon.
+// If network access is disabled, the pull policy must not pull images.
+func ValidatePullPolicy(i *LifecycleInputs, logger log.Logger) error {
+	if i.PullPolicy == "" {
+		return nil
+	}
+	policy, err := image.ParsePullPolicy(i.PullPolicy)
+	if err != nil {
+		return err
+	}
+	if !i.UseDaemon {
+		logger.Warn(MsgIgnoringPullPolicy)
+		return nil
+	}
+	if i.NoNetwork && policy != image.PullNever {
+		return fmt.Errorf("pull policy %q pulls images from the registry, but network access is disabled", i.PullPolicy)
+	}
+	return nil
+}
+
+// ValidateNoNetwork ensures that, if network access is disabled, images are read from the daemon or a layout directory,
+// as reading images from a registry (e.g., to select the run image from run.toml) requires network access.
+// It must run before the run image is selected, so that no registry is contacted.
+func ValidateNoNetwork(i *LifecycleInputs, _ log.Logger) error {
+	if i.NoNetwork && !i.UseDaemon && !i.UseLayout {
+		return errors.New(ErrNoNetworkRequiresDaemonOrLayout)
+	}
+	return nil
+}
+
+// ValidateUseBuildImageMixins ensures that, if the mixins required by the build are to be read from the build image, a build image is provided.
+func ValidateUseBuildImageMixins(i *LifecycleInputs, _ log.Logger) error {
+	if i.UseBuildImageMixins && len(i.RequiredMixins) == 0 && i.BuildImageRef == "" {
+		return errors.New(ErrUseBuildImageMixinsRequiresBuildImage)
+	}
+	return nil
+}
+
+// ValidateRunImageLineage ensures the run image lineage check, if provided, is either `warn` or `fail`.
+func ValidateRunImageLineage(i *LifecycleInputs, _ log.Logger) error {
+	switch i.RunImageLineage {
+	case "", RunImageLineageWarn, RunImageLineageFail:
+		return nil
+	default:
+		return fmt.Errorf("invalid run image lineage check %q: must be %q or %q", i.RunImageLineage, RunImageLineageWarn, RunImageLineageFail)
+	}
+}
+
+// ValidateNoRunImage ensures that, if no run image is requested, the Platform API supports it, no run image is provided,
+// and a target is provided, as the platform of the app image cannot be read from a run image.
+// Windows images cannot be exported on an empty base, as they require the layers of a Windows base image.
+func ValidateNoRunImage(i *LifecycleInputs, _ log.Logger) error {
+	if !i.NoRunImage {
+		return nil
+	}
+	if i.PlatformAPI.LessThan("0.12") {
+		return errors.New(ErrNoRunImageUnsupported)
+	}
+	if i.RunImageRef != "" {
+		return errors.New(ErrSupplyOnlyOneOfRunImageOrNoRunImage)
+	}
+	if runtime.GOOS == "windows" || i.TargetOS == "windows" {
+		return errors.New(ErrNoRunImageUnsupportedOnWindows)
+	}
+	if i.TargetOS == "" || i.TargetArch == "" {
+		return errors.New(ErrNoRunImageTargetRequired)
+	}
+	return nil
+}
+
+// ValidateCreateNoRunImage ensures that no run image is not requested of the creator, which always exports on a run image.
+func ValidateCreateNoRunImage(i *LifecycleInputs, _ log.Logger) error {
+	if i.NoRunImage {
+		return errors.New(ErrNoRunImageUnsupportedByCreator)
+	}
+	return nil
+}
+
 func FillAnalyzeImages(i *LifecycleInputs, logger log.Logger) error {
 	if i.PreviousImageRef == "" {
 		i.PreviousImageRef = i.OutputImageRef
 	}
+	if i.NoRunImage {
+		return nil
+	}
 	if i.PlatformAPI.LessThan("0.12") {
 		return fillRunImageFromStackTOMLIfNeeded(i, logger)
 	}
@@ -139,6 +281,11 @@ func FillExportRunImage(i *LifecycleInputs, logger log.Logger) error {
 		if err != nil {
 			return err
 		}
+		if analyzedMD.HasNoRunImage() {
+			// the app image is exported on an empty base
+			i.RunImageRef = ""
+			return nil
+		}
 		if analyzedMD.RunImage.Reference == "" {
 			return errors.New("run image not found in analyzed metadata")
 		}
@@ -228,6 +375,15 @@ func ValidateRebaseRunImage(i *LifecycleInputs, _ log.Logger) error {
 	}
 }
 
+// ValidateRebasePreviousImage ensures a single previous image is provided to the rebaser,
+// as a list of candidate references is only supported when analyzing.
+func ValidateRebasePreviousImage(i *LifecycleInputs, _ log.Logger) error {
+	if len(i.PreviousImageRefs()) > 1 {
+		return fmt.Errorf("invalid previous image %q: rebase accepts a single reference", i.PreviousImageRef)
+	}
+	return nil
+}
+
 // CheckParallelExport will warn when parallel export is enabled without a cache.
 func CheckParallelExport(i *LifecycleInputs, logger log.Logger) error {
 	if i.ParallelExport && (i.CacheImageRef == "" && i.CacheDir == "") {
@@ -236,6 +392,41 @@ func CheckParallelExport(i *LifecycleInputs, logger log.Logger) error {
 	return nil
 }
 
+// ValidatePreserveModTimes ensures all provided preserve mtimes patterns are valid glob patterns.
+func ValidatePreserveModTimes(i *LifecycleInputs, _ log.Logger) error {
+	for _, pattern := range i.PreserveModTimes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid preserve mtimes pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// ValidateRestoreMode ensures at most one of the restore modes that restore partial data is selected.
+func ValidateRestoreMode(i *LifecycleInputs, _ log.Logger) error {
+	if i.MetadataOnly && i.SBOMOnly {
+		return errors.New("metadata only and SBOM only restore are mutually exclusive")
+	}
+	if i.SBOMOnly && i.SkipSBOM {
+		return errors.New("SBOM only restore and skipping SBOM restore are mutually exclusive")
+	}
+	return nil
+}
+
+// ValidateMaxLayerSize parses the provided maximum layer size, if any, into a number of bytes; zero means there is no limit.
+// It errors if the maximum layer size is not a non-negative integer, rather than restoring layers of any size.
+func ValidateMaxLayerSize(i *LifecycleInputs, _ log.Logger) error {
+	if i.MaxLayerSize == "" {
+		return nil
+	}
+	size, err := strconv.ParseInt(i.MaxLayerSize, 10, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf("invalid maximum layer size %q: must be a non-negative number of bytes", i.MaxLayerSize)
+	}
+	i.MaxLayerSizeBytes = size
+	return nil
+}
+
 // ValidateTargetsAreSameRegistry ensures all output images are on the same registry.
 func ValidateTargetsAreSameRegistry(i *LifecycleInputs, _ log.Logger) error {
 	if i.UseDaemon {
@@ -263,3 +454,12 @@ func ValidateSameRegistry(tags ...string) error {
 	}
 	return nil
 }
+
+// ValidateBuildpackLabelSelector ensures the buildpack label selector, if provided, is of the form key=value.
+func ValidateBuildpackLabelSelector(i *LifecycleInputs, _ log.Logger) error {
+	if i.BuildpackLabelSelector == "" {
+		return nil
+	}
+	_, err := buildpack.ParseLabelSelector(i.BuildpackLabelSelector)
+	return err
+}
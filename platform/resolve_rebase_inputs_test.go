@@ -0,0 +1,51 @@
+package platform_test
+
+import (
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/api"
+	llog "github.com/buildpacks/lifecycle/log"
+	"github.com/buildpacks/lifecycle/platform"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestRebaseInputs(t *testing.T) {
+	for _, api := range api.Platform.Supported {
+		spec.Run(t, "unit-rebase-inputs/"+api.String(), testResolveRebaseInputs(api.String()), spec.Parallel(), spec.Report(report.Terminal{}))
+	}
+}
+
+func testResolveRebaseInputs(platformAPI string) func(t *testing.T, when spec.G, it spec.S) {
+	return func(t *testing.T, when spec.G, it spec.S) {
+		var (
+			inputs *platform.LifecycleInputs
+			logger llog.Logger
+		)
+
+		it.Before(func() {
+			inputs = platform.NewLifecycleInputs(api.MustParse(platformAPI))
+			inputs.OutputImageRef = "some-registry.io/some-app"
+			logger = &log.Logger{Handler: memory.New()}
+		})
+
+		when("previous image", func() {
+			it("accepts a single reference", func() {
+				inputs.PreviousImageRef = "some-registry.io/some-app:some-tag"
+				h.AssertNil(t, platform.ResolveInputs(platform.Rebase, inputs, logger))
+			})
+
+			when("multiple references are provided", func() {
+				it("errors", func() {
+					inputs.PreviousImageRef = "some-registry.io/some-app:some-branch,some-registry.io/some-app"
+					err := platform.ResolveInputs(platform.Rebase, inputs, logger)
+					h.AssertError(t, err, `invalid previous image "some-registry.io/some-app:some-branch,some-registry.io/some-app": rebase accepts a single reference`)
+				})
+			})
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package platform_test
+
+import (
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/api"
+	llog "github.com/buildpacks/lifecycle/log"
+	"github.com/buildpacks/lifecycle/platform"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestRestoreInputs(t *testing.T) {
+	for _, api := range api.Platform.Supported {
+		spec.Run(t, "unit-restore-inputs/"+api.String(), testResolveRestoreInputs(api.String()), spec.Parallel(), spec.Report(report.Terminal{}))
+	}
+}
+
+func testResolveRestoreInputs(platformAPI string) func(t *testing.T, when spec.G, it spec.S) {
+	return func(t *testing.T, when spec.G, it spec.S) {
+		var (
+			inputs *platform.LifecycleInputs
+			logger llog.Logger
+		)
+
+		it.Before(func() {
+			inputs = platform.NewLifecycleInputs(api.MustParse(platformAPI))
+			inputs.CacheDir = "some-cache-dir"
+			logger = &log.Logger{Handler: memory.New()}
+		})
+
+		when("restore mode", func() {
+			when("only one mode is selected", func() {
+				it("does not error", func() {
+					inputs.MetadataOnly = true
+					h.AssertNil(t, platform.ResolveInputs(platform.Restore, inputs, logger))
+				})
+			})
+
+			when("metadata only and SBOM only are both selected", func() {
+				it("errors", func() {
+					inputs.MetadataOnly = true
+					inputs.SBOMOnly = true
+					err := platform.ResolveInputs(platform.Restore, inputs, logger)
+					h.AssertError(t, err, "metadata only and SBOM only restore are mutually exclusive")
+				})
+			})
+
+			when("SBOM only and skipping SBOM are both selected", func() {
+				it("errors", func() {
+					inputs.SBOMOnly = true
+					inputs.SkipSBOM = true
+					err := platform.ResolveInputs(platform.Restore, inputs, logger)
+					h.AssertError(t, err, "SBOM only restore and skipping SBOM restore are mutually exclusive")
+				})
+			})
+		})
+
+		when("maximum layer size", func() {
+			it("resolves the number of bytes", func() {
+				inputs.MaxLayerSize = "1048576"
+				h.AssertNil(t, platform.ResolveInputs(platform.Restore, inputs, logger))
+				h.AssertEq(t, inputs.MaxLayerSizeBytes, int64(1048576))
+			})
+
+			when("negative", func() {
+				it("errors", func() {
+					inputs.MaxLayerSize = "-1"
+					err := platform.ResolveInputs(platform.Restore, inputs, logger)
+					h.AssertError(t, err, `invalid maximum layer size "-1": must be a non-negative number of bytes`)
+				})
+			})
+
+			when("not a number of bytes", func() {
+				it("errors", func() {
+					inputs.MaxLayerSize = "10G"
+					err := platform.ResolveInputs(platform.Restore, inputs, logger)
+					h.AssertError(t, err, `invalid maximum layer size "10G": must be a non-negative number of bytes`)
+				})
+			})
+		})
+	}
+}
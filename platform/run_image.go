@@ -3,7 +3,9 @@ package platform
 import (
 	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/buildpacks/imgutil"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 
@@ -22,11 +24,41 @@ const (
 	OSDistroVersionLabel = "io.buildpacks.distro.version"
 )
 
+// GetRunImageMetadata reads the top layer and the `io.buildpacks.*` labels of the provided run image.
+// The top layer is the diff ID of the layer both for registry and daemon images, so that it can be compared across them;
+// it is omitted if it cannot be read, e.g., because the run image has no layers.
+func GetRunImageMetadata(fromImage imgutil.Image) (*files.RunImageMetadata, error) {
+	topLayer, err := fromImage.TopLayer()
+	if err != nil {
+		topLayer = ""
+	}
+	labels, err := fromImage.Labels()
+	if err != nil {
+		return nil, fmt.Errorf("getting labels: %w", err)
+	}
+	md := &files.RunImageMetadata{TopLayer: topLayer}
+	for k, v := range labels {
+		if !strings.HasPrefix(k, "io.buildpacks.") {
+			continue
+		}
+		if md.Labels == nil {
+			md.Labels = map[string]string{}
+		}
+		md.Labels[k] = v
+	}
+	return md, nil
+}
+
+// BestRunImageMirrorFor returns the run image, or the mirror of it, that is accessible and preferably in the target registry.
+// A run image that is pinned to a digest is returned verbatim, as its mirrors may be tags that resolve to other images.
 func BestRunImageMirrorFor(targetRegistry string, runImageMD files.RunImageForExport, checkReadAccess CheckReadAccess) (string, error) {
 	var runImageMirrors []string
 	if runImageMD.Image == "" {
 		return "", errors.New("missing run image metadata (-run-image)")
 	}
+	if _, err := name.NewDigest(runImageMD.Image, name.WeakValidation); err == nil {
+		return runImageMD.Image, nil
+	}
 	runImageMirrors = append(runImageMirrors, runImageMD.Image)
 	runImageMirrors = append(runImageMirrors, runImageMD.Mirrors...)
 
@@ -97,6 +129,9 @@ func GetRunImageForExport(inputs LifecycleInputs) (files.RunImageForExport, erro
 	if err != nil {
 		return files.RunImageForExport{}, err
 	}
+	if analyzedMD.HasNoRunImage() {
+		return files.RunImageForExport{}, nil
+	}
 	for _, runImage := range runMD.Images {
 		if runImage.Contains(analyzedMD.RunImageImage()) {
 			return runImage, nil
@@ -1,9 +1,11 @@
 package platform_test
 
 import (
+	"errors"
 	"path/filepath"
 	"testing"
 
+	"github.com/buildpacks/imgutil/fakes"
 	"github.com/google/go-containerregistry/pkg/authn"
 
 	"github.com/buildpacks/lifecycle/platform"
@@ -21,6 +23,38 @@ func TestRunImage(t *testing.T) {
 }
 
 func testRunImage(t *testing.T, when spec.G, it spec.S) {
+	when(".GetRunImageMetadata", func() {
+		it("returns the top layer and buildpacks labels of the run image", func() {
+			runImage := fakes.NewImage("some-run-image", "some-top-layer", nil)
+			h.AssertNil(t, runImage.SetLabel(platform.StackIDLabel, "some-stack-id"))
+			h.AssertNil(t, runImage.SetLabel(platform.MixinsLabel, `["some-mixin"]`))
+			h.AssertNil(t, runImage.SetLabel("some.other.label", "some-value"))
+
+			md, err := platform.GetRunImageMetadata(runImage)
+			h.AssertNil(t, err)
+			h.AssertEq(t, md, &files.RunImageMetadata{
+				TopLayer: "some-top-layer",
+				Labels: map[string]string{
+					platform.StackIDLabel: "some-stack-id",
+					platform.MixinsLabel:  `["some-mixin"]`,
+				},
+			})
+		})
+
+		when("the top layer cannot be read", func() {
+			it("omits the top layer", func() {
+				runImage := &noLayersImage{Image: fakes.NewImage("some-run-image", "", nil)}
+				h.AssertNil(t, runImage.SetLabel(platform.StackIDLabel, "some-stack-id"))
+
+				md, err := platform.GetRunImageMetadata(runImage)
+				h.AssertNil(t, err)
+				h.AssertEq(t, md, &files.RunImageMetadata{
+					Labels: map[string]string{platform.StackIDLabel: "some-stack-id"},
+				})
+			})
+		})
+	})
+
 	when(".GetRunImageForExport", func() {
 		var inputs = platform.LifecycleInputs{
 			AnalyzedPath: filepath.Join("testdata", "layers", "analyzed.toml"),
@@ -192,6 +226,19 @@ func testRunImage(t *testing.T, when spec.G, it spec.S) {
 			}}
 		})
 
+		when("the run image is pinned to a digest", func() {
+			it("returns the run image verbatim", func() {
+				stackMD.RunImage.Image = "first.com/org/repo@sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+				noReadAccess := func(_ string, _ authn.Keychain) (bool, error) {
+					return false, nil
+				}
+
+				name, err := platform.BestRunImageMirrorFor("gcr.io", stackMD.RunImage, noReadAccess)
+				h.AssertNil(t, err)
+				h.AssertEq(t, name, "first.com/org/repo@sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+			})
+		})
+
 		when("repoName is dockerhub", func() {
 			it("returns the dockerhub image", func() {
 				name, err := platform.BestRunImageMirrorFor("index.docker.io", stackMD.RunImage, nopCheckReadAccess)
@@ -250,3 +297,12 @@ func testRunImage(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 }
+
+// noLayersImage is an image without layers, whose top layer cannot be read.
+type noLayersImage struct {
+	*fakes.Image
+}
+
+func (i *noLayersImage) TopLayer() (string, error) {
+	return "", errors.New("image has no layers")
+}
@@ -0,0 +1,63 @@
+package platform
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/buildpacks/lifecycle/log"
+)
+
+// TagTemplateData holds the variables available to templates in additional tags, e.g., `some-app:{{.Date}}`.
+// Environment variables may be looked up with the `env` function, e.g., `some-app:{{env "GIT_SHA"}}`.
+type TagTemplateData struct {
+	// Date is the current date in UTC, formatted as `YYYYMMDD`.
+	Date string
+	// Unix is the current time in seconds since the Unix epoch.
+	Unix int64
+}
+
+// NewTagTemplateData returns the template variables for the provided time.
+func NewTagTemplateData(now time.Time) TagTemplateData {
+	return TagTemplateData{
+		Date: now.UTC().Format("20060102"),
+		Unix: now.Unix(),
+	}
+}
+
+// ExpandTagTemplates expands templates in the provided additional tags.
+// Because each phase expands templates independently, time-based variables may resolve differently in different phases;
+// this does not affect the analyzer, which only checks that the registries of the additional tags are accessible.
+func ExpandTagTemplates(i *LifecycleInputs, _ log.Logger) error {
+	data := NewTagTemplateData(time.Now())
+	for idx, tag := range i.AdditionalTags {
+		expanded, err := ExpandTagTemplate(tag, data)
+		if err != nil {
+			return err
+		}
+		i.AdditionalTags[idx] = expanded
+	}
+	return nil
+}
+
+// ExpandTagTemplate expands the provided tag as a Go template with the provided data.
+// Tags without template actions are returned unchanged.
+func ExpandTagTemplate(tag string, data TagTemplateData) (string, error) {
+	if !strings.Contains(tag, "{{") {
+		return tag, nil
+	}
+	tmpl, err := template.New("tag").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"env": os.Getenv}).
+		Parse(tag)
+	if err != nil {
+		return "", fmt.Errorf("invalid tag template %q: %w", tag, err)
+	}
+	var expanded strings.Builder
+	if err = tmpl.Execute(&expanded, data); err != nil {
+		return "", fmt.Errorf("invalid tag template %q: %w", tag, err)
+	}
+	return expanded.String(), nil
+}
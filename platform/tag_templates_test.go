@@ -0,0 +1,41 @@
+package platform_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/platform"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestTagTemplates(t *testing.T) {
+	spec.Run(t, "TagTemplates", testTagTemplates, spec.Report(report.Terminal{}))
+}
+
+func testTagTemplates(t *testing.T, when spec.G, it spec.S) {
+	when(".ExpandTagTemplate", func() {
+		data := platform.NewTagTemplateData(time.Date(2024, 1, 2, 23, 4, 5, 0, time.FixedZone("UTC-2", -2*60*60)))
+
+		it("expands the date and unix time", func() {
+			expanded, err := platform.ExpandTagTemplate("some-image:{{.Date}}-{{.Unix}}", data)
+			h.AssertNil(t, err)
+			h.AssertEq(t, expanded, "some-image:20240103-1704243845")
+		})
+
+		it("returns tags without templates unchanged", func() {
+			expanded, err := platform.ExpandTagTemplate("some-image:some-tag", data)
+			h.AssertNil(t, err)
+			h.AssertEq(t, expanded, "some-image:some-tag")
+		})
+
+		when("the template cannot be parsed", func() {
+			it("errors", func() {
+				_, err := platform.ExpandTagTemplate("some-image:{{.Date", data)
+				h.AssertError(t, err, `invalid tag template "some-image:{{.Date"`)
+			})
+		})
+	})
+}
@@ -0,0 +1,96 @@
+package priv
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// IDMapping maps a range of IDs in a user namespace to IDs in the parent namespace, as in a line of /proc/<pid>/uid_map.
+type IDMapping struct {
+	Inside  int64
+	Outside int64
+	Count   int64
+}
+
+// IDMap is the mapping of the user or group IDs of a user namespace, e.g., when running rootless.
+// An empty IDMap maps every ID to itself.
+type IDMap []IDMapping
+
+const maxIDCount = 4294967295
+
+// ParseIDMap parses an ID map in the format of /proc/<pid>/uid_map and /proc/<pid>/gid_map,
+// i.e., lines of `<inside> <outside> <count>`.
+func ParseIDMap(data string) (IDMap, error) {
+	var m IDMap
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid ID mapping %q: must be of the form <inside> <outside> <count>", line)
+		}
+		var values [3]int64
+		for idx, field := range fields {
+			value, err := strconv.ParseInt(field, 10, 64)
+			if err != nil || value < 0 {
+				return nil, fmt.Errorf("invalid ID mapping %q: %q is not a valid ID", line, field)
+			}
+			values[idx] = value
+		}
+		m = append(m, IDMapping{Inside: values[0], Outside: values[1], Count: values[2]})
+	}
+	return m, nil
+}
+
+// ReadIDMap reads the ID map at the provided path; a missing file, e.g., on a kernel without user namespaces, is an empty IDMap.
+func ReadIDMap(path string) (IDMap, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading ID map: %w", err)
+	}
+	return ParseIDMap(string(data))
+}
+
+// IsIdentity returns true if every ID is mapped to itself, i.e., the process is not running in a user namespace that remaps IDs.
+func (m IDMap) IsIdentity() bool {
+	if len(m) == 0 {
+		return true
+	}
+	return len(m) == 1 && m[0].Inside == 0 && m[0].Outside == 0 && m[0].Count == maxIDCount
+}
+
+// Resolve returns the ID in the namespace to use for ownership operations on behalf of the provided ID,
+// i.e., the ID itself if it is mapped in the namespace. It returns false if the ID is not mapped, as it cannot be used in the namespace.
+// IDs of the parent namespace (e.g., host IDs) are not translated, as a host ID may be mapped to a privileged ID in the namespace
+// (e.g., the invoking host user is root in a rootless namespace), which the lifecycle must not run as on behalf of the provided ID.
+func (m IDMap) Resolve(id int) (int, bool) {
+	if m.IsIdentity() {
+		return id, true
+	}
+	for _, mapping := range m {
+		if int64(id) >= mapping.Inside && int64(id) < mapping.Inside+mapping.Count {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// ResolveIDs resolves the provided user and group IDs with the provided ID maps, as for IDMap.Resolve,
+// returning an error if either cannot be used in the namespace.
+func ResolveIDs(uid, gid int, uidMap, gidMap IDMap) (int, int, error) {
+	resolvedUID, ok := uidMap.Resolve(uid)
+	if !ok {
+		return 0, 0, fmt.Errorf("user ID %d is not mapped in the user namespace", uid)
+	}
+	resolvedGID, ok := gidMap.Resolve(gid)
+	if !ok {
+		return 0, 0, fmt.Errorf("group ID %d is not mapped in the user namespace", gid)
+	}
+	return resolvedUID, resolvedGID, nil
+}
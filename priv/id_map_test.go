@@ -0,0 +1,141 @@
+package priv_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/priv"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestIDMap(t *testing.T) {
+	spec.Run(t, "IDMap", testIDMap, spec.Report(report.Terminal{}))
+}
+
+func testIDMap(t *testing.T, when spec.G, it spec.S) {
+	// a rootless namespace, in which the invoking host user 1000 is root and host IDs 100000-165535 are IDs 1-65536
+	const rootless = "         0       1000          1\n         1     100000      65536\n"
+
+	when(".ParseIDMap", func() {
+		it("parses each mapping", func() {
+			m, err := priv.ParseIDMap(rootless)
+			h.AssertNil(t, err)
+			h.AssertEq(t, m, priv.IDMap{
+				{Inside: 0, Outside: 1000, Count: 1},
+				{Inside: 1, Outside: 100000, Count: 65536},
+			})
+		})
+
+		when("a mapping is invalid", func() {
+			it("errors", func() {
+				_, err := priv.ParseIDMap("0 1000")
+				h.AssertError(t, err, `invalid ID mapping "0 1000": must be of the form <inside> <outside> <count>`)
+
+				_, err = priv.ParseIDMap("0 some-id 1")
+				h.AssertError(t, err, `invalid ID mapping "0 some-id 1": "some-id" is not a valid ID`)
+			})
+		})
+	})
+
+	when(".ReadIDMap", func() {
+		it("reads the map", func() {
+			path := filepath.Join(t.TempDir(), "uid_map")
+			h.AssertNil(t, os.WriteFile(path, []byte(rootless), 0600))
+
+			m, err := priv.ReadIDMap(path)
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(m), 2)
+		})
+
+		when("the file does not exist", func() {
+			it("returns an identity map", func() {
+				m, err := priv.ReadIDMap(filepath.Join(t.TempDir(), "uid_map"))
+				h.AssertNil(t, err)
+				h.AssertEq(t, m.IsIdentity(), true)
+			})
+		})
+	})
+
+	when("#IsIdentity", func() {
+		it("is true for the initial user namespace", func() {
+			m, err := priv.ParseIDMap("0 0 4294967295")
+			h.AssertNil(t, err)
+			h.AssertEq(t, m.IsIdentity(), true)
+		})
+
+		it("is false for a namespace that remaps IDs", func() {
+			m, err := priv.ParseIDMap(rootless)
+			h.AssertNil(t, err)
+			h.AssertEq(t, m.IsIdentity(), false)
+		})
+	})
+
+	when("#Resolve", func() {
+		var m priv.IDMap
+
+		it.Before(func() {
+			var err error
+			m, err = priv.ParseIDMap(rootless)
+			h.AssertNil(t, err)
+		})
+
+		it("returns IDs that are mapped in the namespace", func() {
+			for _, id := range []int{0, 1, 1000, 65536} {
+				resolved, ok := m.Resolve(id)
+				h.AssertEq(t, ok, true)
+				h.AssertEq(t, resolved, id)
+			}
+		})
+
+		it("does not translate IDs of the parent namespace", func() {
+			_, ok := m.Resolve(100999)
+			h.AssertEq(t, ok, false)
+		})
+
+		it("does not resolve IDs that are not mapped", func() {
+			_, ok := m.Resolve(70000)
+			h.AssertEq(t, ok, false)
+		})
+
+		it("returns every ID for an identity map", func() {
+			resolved, ok := priv.IDMap(nil).Resolve(70000)
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, resolved, 70000)
+		})
+	})
+
+	when(".ResolveIDs", func() {
+		it("resolves the user and group IDs", func() {
+			m, err := priv.ParseIDMap(rootless)
+			h.AssertNil(t, err)
+
+			uid, gid, err := priv.ResolveIDs(1234, 1000, m, m)
+			h.AssertNil(t, err)
+			h.AssertEq(t, uid, 1234)
+			h.AssertEq(t, gid, 1000)
+		})
+
+		it("errors for IDs of the parent namespace", func() {
+			m, err := priv.ParseIDMap("0 1000 1\n")
+			h.AssertNil(t, err)
+
+			_, _, err = priv.ResolveIDs(1000, 1000, m, m)
+			h.AssertError(t, err, "user ID 1000 is not mapped in the user namespace")
+		})
+
+		it("errors for IDs that are not mapped", func() {
+			m, err := priv.ParseIDMap(rootless)
+			h.AssertNil(t, err)
+
+			_, _, err = priv.ResolveIDs(70000, 1000, m, m)
+			h.AssertError(t, err, "user ID 70000 is not mapped in the user namespace")
+
+			_, _, err = priv.ResolveIDs(1000, 70000, m, m)
+			h.AssertError(t, err, "group ID 70000 is not mapped in the user namespace")
+		})
+	})
+}
@@ -8,30 +8,105 @@ import (
 	"os/user"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
+
+	"github.com/buildpacks/lifecycle/log"
 )
 
-// EnsureOwner recursively chowns a dir if it isn't writable
+// EnsureOwner recursively chowns a dir if it isn't writable.
+// Entries that are already owned by the uid and gid are not chowned.
+// Symlinks among the provided paths (e.g., a layers directory that links to a mounted volume) are resolved,
+// and the directory they point to is chowned. Symlinks beneath the provided paths are not followed; the links themselves are chowned.
+// Special files such as sockets, devices, and named pipes are skipped.
 func EnsureOwner(uid, gid int, paths ...string) error {
+	return EnsureOwnerTolerating(uid, gid, nil, nil, paths...)
+}
+
+// EnsureOwnerTolerating is like EnsureOwner, but chown failures on any of the provided read-only paths (or their children)
+// are logged as warnings instead of being returned, and the children of such a path are not visited.
+// This allows builds with intentionally read-only sub-mounts (such as a bind-mounted dependency) beneath the provided paths.
+func EnsureOwnerTolerating(uid, gid int, readOnlyPaths []string, logger log.Logger, paths ...string) error {
+	uid, gid, skip, err := namespacedOwner(uid, gid)
+	if err != nil || skip {
+		return err
+	}
+	o := &owner{uid: uid, gid: gid, logger: logger}
+	for _, ro := range readOnlyPaths {
+		o.readOnlyPaths = append(o.readOnlyPaths, ro)
+		if resolved, err := filepath.EvalSymlinks(ro); err == nil && resolved != filepath.Clean(ro) {
+			o.readOnlyPaths = append(o.readOnlyPaths, resolved)
+		}
+	}
 	for _, p := range paths {
-		fi, err := os.Stat(p)
+		resolved, err := filepath.EvalSymlinks(p)
 		if os.IsNotExist(err) {
 			continue
 		}
 		if err != nil {
 			return err
 		}
+		fi, err := os.Lstat(resolved)
+		if err != nil {
+			return err
+		}
+		if isSpecial(fi.Mode()) {
+			continue
+		}
 		if stat, ok := fi.Sys().(*syscall.Stat_t); ok && canWrite(uid, gid, stat) {
 			// if a dir has correct ownership, assume it's children do, for performance
 			continue
 		}
-		if err := recursiveEnsureOwner(p, uid, gid); err != nil {
+		if !fi.IsDir() {
+			if !o.isOwner(resolved) {
+				if err := os.Lchown(resolved, uid, gid); err != nil {
+					if err = o.tolerate(resolved, err); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+		if err := o.recursiveEnsureOwner(resolved); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// isSpecial returns true for sockets, devices, and named pipes, which should not be chowned.
+func isSpecial(mode os.FileMode) bool {
+	return mode&(os.ModeSocket|os.ModeDevice|os.ModeCharDevice|os.ModeNamedPipe) != 0
+}
+
+type owner struct {
+	uid, gid      int
+	readOnlyPaths []string
+	logger        log.Logger
+}
+
+// tolerate returns nil if the provided chown error occurred on a read-only path, after logging it.
+func (o *owner) tolerate(path string, err error) error {
+	if !o.isReadOnly(path) {
+		return err
+	}
+	if o.logger != nil {
+		o.logger.Warnf("Failed to chown read-only path %q, continuing: %s", path, err)
+	}
+	return nil
+}
+
+func (o *owner) isReadOnly(path string) bool {
+	path = filepath.Clean(path)
+	for _, ro := range o.readOnlyPaths {
+		ro = filepath.Clean(ro)
+		if path == ro || strings.HasPrefix(path, ro+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
 const (
 	worldWrite uint32 = 0002
 	groupWrite uint32 = 0020
@@ -51,13 +126,61 @@ func canWrite(uid, gid int, stat *syscall.Stat_t) bool {
 	return false
 }
 
+// The ID maps of the user namespace the lifecycle runs in, e.g., when running rootless.
+const (
+	uidMapPath = "/proc/self/uid_map"
+	gidMapPath = "/proc/self/gid_map"
+)
+
+// MappedIDs resolves the provided user and group IDs with the ID maps of the user namespace the lifecycle runs in (see IDMap.Resolve),
+// so that ownership operations target IDs that are mapped in the namespace.
+func MappedIDs(uid, gid int) (int, int, error) {
+	uidMap, gidMap, err := readIDMaps()
+	if err != nil {
+		return 0, 0, err
+	}
+	return ResolveIDs(uid, gid, uidMap, gidMap)
+}
+
+func readIDMaps() (IDMap, IDMap, error) {
+	uidMap, err := ReadIDMap(uidMapPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	gidMap, err := ReadIDMap(gidMapPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return uidMap, gidMap, nil
+}
+
+// namespacedOwner returns the IDs to chown to, resolved with the ID maps of the user namespace,
+// and whether chowning should be skipped because the lifecycle runs unprivileged in a user namespace as the provided user,
+// in which case it cannot chown files to other IDs and files it creates are already owned by the user.
+func namespacedOwner(uid, gid int) (int, int, bool, error) {
+	uidMap, gidMap, err := readIDMaps()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	uid, gid, err = ResolveIDs(uid, gid, uidMap, gidMap)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	skip := !uidMap.IsIdentity() && os.Getuid() != 0 && uid == os.Getuid() && gid == os.Getgid()
+	return uid, gid, skip, nil
+}
+
 func IsPrivileged() bool {
 	return os.Getuid() == 0
 }
 
-func recursiveEnsureOwner(path string, uid, gid int) error {
-	if err := os.Chown(path, uid, gid); err != nil {
-		return err
+// recursiveEnsureOwner chowns the provided directory and its children; the directory must not be a symlink.
+func (o *owner) recursiveEnsureOwner(path string) error {
+	if !o.isOwner(path) {
+		if err := os.Lchown(path, o.uid, o.gid); err != nil {
+			// don't descend into a read-only directory, as its children can't be chowned either
+			return o.tolerate(path, err)
+		}
 	}
 	fis, err := os.ReadDir(path)
 	if err != nil {
@@ -65,21 +188,42 @@ func recursiveEnsureOwner(path string, uid, gid int) error {
 	}
 	for _, fi := range fis {
 		filePath := filepath.Join(path, fi.Name())
+		if isSpecial(fi.Type()) {
+			continue
+		}
 		if fi.IsDir() {
-			if err := recursiveEnsureOwner(filePath, uid, gid); err != nil {
+			if err := o.recursiveEnsureOwner(filePath); err != nil {
 				return err
 			}
-		} else {
-			if err := os.Lchown(filePath, uid, gid); err != nil {
-				return err
+		} else if !o.isOwner(filePath) {
+			if err := os.Lchown(filePath, o.uid, o.gid); err != nil {
+				if err = o.tolerate(filePath, err); err != nil {
+					return err
+				}
 			}
 		}
 	}
 	return nil
 }
 
+// isOwner returns true if the provided path (or symlink) is already owned by the uid and gid,
+// so that it need not be chowned; in rootless setups chown may fail even when it would be a no-op.
+func (o *owner) isOwner(path string) bool {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && stat.Uid == uint32(o.uid) && stat.Gid == uint32(o.gid)
+}
+
 // RunAs sets the user ID and group ID of the calling process.
+// The IDs are resolved with the ID maps of the user namespace the lifecycle runs in, as for MappedIDs.
 func RunAs(uid, gid int) error {
+	uid, gid, err := MappedIDs(uid, gid)
+	if err != nil {
+		return err
+	}
 	if uid == os.Getuid() && gid == os.Getgid() {
 		return nil
 	}
@@ -0,0 +1,202 @@
+//go:build linux
+// +build linux
+
+package priv_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+	"golang.org/x/sys/unix"
+
+	llog "github.com/buildpacks/lifecycle/log"
+	"github.com/buildpacks/lifecycle/priv"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestUserLinux(t *testing.T) {
+	spec.Run(t, "UserLinux", testUserLinux, spec.Report(report.Terminal{}))
+}
+
+func testUserLinux(t *testing.T, when spec.G, it spec.S) {
+	const (
+		uid = 1234
+		gid = 4321
+	)
+	var (
+		tmpDir string
+		file   string
+	)
+
+	it.Before(func() {
+		h.SkipIf(t, os.Getuid() != 0, "chown requires root")
+		var err error
+		tmpDir, err = os.MkdirTemp("", "ensure-owner")
+		h.AssertNil(t, err)
+		h.AssertNil(t, os.Chmod(tmpDir, 0755))
+		file = filepath.Join(tmpDir, "some-file")
+		h.AssertNil(t, os.WriteFile(file, []byte("some-content"), 0600))
+	})
+
+	it.After(func() {
+		h.AssertNil(t, os.RemoveAll(tmpDir))
+	})
+
+	stat := func(path string) *syscall.Stat_t {
+		fi, err := os.Lstat(path)
+		h.AssertNil(t, err)
+		return fi.Sys().(*syscall.Stat_t)
+	}
+
+	when("#EnsureOwner", func() {
+		when("entries need an ownership change", func() {
+			it("chowns them", func() {
+				h.AssertNil(t, priv.EnsureOwner(uid, gid, tmpDir))
+
+				for _, path := range []string{tmpDir, file} {
+					h.AssertEq(t, stat(path).Uid, uint32(uid))
+					h.AssertEq(t, stat(path).Gid, uint32(gid))
+				}
+			})
+		})
+
+		when("a path is a symlink", func() {
+			var (
+				volume string
+				link   string
+			)
+
+			it.Before(func() {
+				volume = filepath.Join(tmpDir, "volume")
+				h.AssertNil(t, os.Mkdir(volume, 0755))
+				h.AssertNil(t, os.WriteFile(filepath.Join(volume, "some-file"), []byte("some-content"), 0600))
+				h.AssertNil(t, os.Lchown(volume, 5678, 5678))
+				link = filepath.Join(tmpDir, "layers")
+				h.AssertNil(t, os.Symlink(volume, link))
+			})
+
+			it("chowns the directory it points to", func() {
+				h.AssertNil(t, priv.EnsureOwner(uid, gid, link))
+
+				for _, path := range []string{volume, filepath.Join(volume, "some-file")} {
+					h.AssertEq(t, stat(path).Uid, uint32(uid))
+					h.AssertEq(t, stat(path).Gid, uint32(gid))
+				}
+			})
+
+			when("the symlink is beneath the path", func() {
+				it("does not follow it", func() {
+					h.AssertNil(t, os.Lchown(tmpDir, 5678, 5678))
+					outside, err := os.MkdirTemp("", "ensure-owner-outside")
+					h.AssertNil(t, err)
+					defer os.RemoveAll(outside)
+					h.AssertNil(t, os.Symlink(outside, filepath.Join(volume, "outside")))
+
+					h.AssertNil(t, priv.EnsureOwner(uid, gid, tmpDir))
+
+					h.AssertEq(t, stat(filepath.Join(volume, "outside")).Uid, uint32(uid))
+					h.AssertEq(t, stat(outside).Uid, uint32(0))
+				})
+			})
+		})
+
+		when("there are special files", func() {
+			it("does not chown them", func() {
+				fifo := filepath.Join(tmpDir, "some-fifo")
+				h.AssertNil(t, syscall.Mkfifo(fifo, 0600))
+
+				h.AssertNil(t, priv.EnsureOwner(uid, gid, tmpDir))
+
+				h.AssertEq(t, stat(file).Uid, uint32(uid))
+				h.AssertEq(t, stat(fifo).Uid, uint32(0))
+			})
+		})
+
+		when("entries already have the correct ownership", func() {
+			it("does not chown them", func() {
+				h.AssertNil(t, os.Lchown(file, uid, gid))
+				ctime := stat(file).Ctim
+				time.Sleep(10 * time.Millisecond)
+
+				h.AssertNil(t, priv.EnsureOwner(uid, gid, tmpDir))
+
+				h.AssertEq(t, stat(tmpDir).Uid, uint32(uid))
+				h.AssertEq(t, stat(file).Ctim, ctime) // chown updates ctime even if the owner is unchanged
+			})
+		})
+	})
+
+	when("#EnsureOwnerTolerating", func() {
+		var (
+			immutable  string
+			logHandler *memory.Handler
+			logger     llog.Logger
+		)
+
+		it.Before(func() {
+			// even root cannot chown an immutable file
+			immutable = filepath.Join(tmpDir, "some-immutable-file")
+			h.AssertNil(t, os.WriteFile(immutable, []byte("some-content"), 0600))
+			setImmutable(t, immutable, true)
+			logHandler = memory.New()
+			logger = &llog.DefaultLogger{Logger: &log.Logger{Handler: logHandler}}
+		})
+
+		it.After(func() {
+			setImmutable(t, immutable, false)
+		})
+
+		when("a read-only path cannot be chowned", func() {
+			it("warns and chowns the other entries", func() {
+				h.AssertNil(t, priv.EnsureOwnerTolerating(uid, gid, []string{immutable}, logger, tmpDir))
+
+				h.AssertEq(t, stat(file).Uid, uint32(uid))
+				h.AssertEq(t, stat(immutable).Uid, uint32(0))
+				h.AssertEq(t, len(logHandler.Entries), 1)
+				h.AssertStringContains(t, logHandler.Entries[0].Message, fmt.Sprintf("Failed to chown read-only path %q, continuing", immutable))
+			})
+		})
+
+		when("a path that is not read-only cannot be chowned", func() {
+			it("returns an error", func() {
+				err := priv.EnsureOwnerTolerating(uid, gid, []string{filepath.Join(tmpDir, "some-other-path")}, logger, tmpDir)
+				h.AssertNotNil(t, err)
+				h.AssertEq(t, errors.Is(err, syscall.EPERM), true)
+				h.AssertEq(t, len(logHandler.Entries), 0)
+			})
+		})
+	})
+}
+
+// fsImmutableFl is the FS_IMMUTABLE_FL inode flag from linux/fs.h.
+const fsImmutableFl = 0x00000010
+
+// setImmutable sets or clears the immutable attribute of the provided file,
+// skipping the test if the file system does not support it.
+func setImmutable(t *testing.T, path string, immutable bool) {
+	t.Helper()
+	f, err := os.Open(path)
+	h.AssertNil(t, err)
+	defer f.Close()
+	flags, err := unix.IoctlGetUint32(int(f.Fd()), unix.FS_IOC_GETFLAGS)
+	if err != nil {
+		t.Skipf("file attributes are not supported: %s", err)
+	}
+	if immutable {
+		flags |= fsImmutableFl
+	} else {
+		flags &^= fsImmutableFl
+	}
+	if err = unix.IoctlSetPointerInt(int(f.Fd()), unix.FS_IOC_SETFLAGS, int(flags)); err != nil {
+		t.Skipf("the immutable attribute is not supported: %s", err)
+	}
+}
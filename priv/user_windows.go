@@ -1,9 +1,15 @@
 package priv
 
+import "github.com/buildpacks/lifecycle/log"
+
 func EnsureOwner(uid, gid int, paths ...string) error {
 	return nil
 }
 
+func EnsureOwnerTolerating(uid, gid int, readOnlyPaths []string, logger log.Logger, paths ...string) error {
+	return nil
+}
+
 func IsPrivileged() bool {
 	return false
 }